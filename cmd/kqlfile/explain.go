@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"kqlfile/pkg/binder"
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/exec"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/optimizer"
+	"kqlfile/pkg/plan"
+)
+
+// explanation is what --explain prints for a query and each of its lets.
+type explanation struct {
+	Lets      []explainedLet `json:"lets,omitempty"`
+	Parsed    []explainStep  `json:"parsed"`
+	Optimized []explainStep  `json:"optimized"`
+	Physical  *explainNode   `json:"physical"`
+}
+
+type explainedLet struct {
+	Name      string        `json:"name"`
+	Parsed    []explainStep `json:"parsed"`
+	Optimized []explainStep `json:"optimized"`
+	Physical  *explainNode  `json:"physical"`
+}
+
+// explainStep is one plan operator and the schema of its output, which is
+// nil when it depends on the data.
+type explainStep struct {
+	Op     string          `json:"op"`
+	Text   string          `json:"text"`
+	Schema []explainColumn `json:"schema"`
+}
+
+type explainColumn struct {
+	Name string     `json:"name"`
+	Type model.Type `json:"type"`
+}
+
+type explainNode struct {
+	Op           string          `json:"op"`
+	Detail       string          `json:"detail,omitempty"`
+	Materializes string          `json:"materializes,omitempty"`
+	Batch        bool            `json:"batch,omitempty"`
+	Schema       []explainColumn `json:"schema"`
+	Inputs       []*explainNode  `json:"inputs,omitempty"`
+	Branches     []*explainNode  `json:"branches,omitempty"`
+}
+
+// explainSteps binds ops over input and pairs each operator with its
+// output schema. It also returns the schemas for exec.Tables.Describe.
+func explainSteps(input *model.Schema, ops []plan.Operator, tables binder.Tables) ([]explainStep, []*model.Schema, error) {
+	schemas, err := binder.Bind("", input, ops, tables)
+	if err != nil {
+		return nil, nil, err
+	}
+	steps := make([]explainStep, len(ops))
+	for i, op := range ops {
+		steps[i] = explainStep{Op: op.Type(), Text: plan.Format(op), Schema: explainSchema(schemas[i])}
+	}
+	return steps, schemas, nil
+}
+
+func explainSchema(s *model.Schema) []explainColumn {
+	if s == nil {
+		return nil
+	}
+	cols := make([]explainColumn, len(s.Columns))
+	for i, c := range s.Columns {
+		cols[i] = explainColumn{Name: c.Name, Type: c.Type}
+	}
+	return cols
+}
+
+func explainTree(n *exec.Node) *explainNode {
+	if n == nil {
+		return nil
+	}
+	out := &explainNode{Op: n.Op, Detail: n.Detail, Materializes: n.Materializes, Batch: n.Batch, Schema: explainSchema(n.Schema)}
+	for _, in := range n.Inputs {
+		out.Inputs = append(out.Inputs, explainTree(in))
+	}
+	for _, b := range n.Branches {
+		out.Branches = append(out.Branches, explainTree(b))
+	}
+	return out
+}
+
+// readerNode describes an input reader reading the columns and applying the
+// filter of sc.
+func readerNode(fileType, path string, schema model.Schema, sc scan) *exec.Node {
+	n := &exec.Node{Op: "CSVReader", Detail: path}
+	if fileType == "json" {
+		n.Op = "JSONReader"
+	}
+	if sc.columns != nil {
+		schema, _ = csvio.Select(schema, sc.columns)
+		n.Detail += " columns=(" + strings.Join(sc.columns, ", ") + ")"
+	}
+	if sc.filter != nil {
+		n.Detail += " filter=(" + plan.FormatExpr(sc.filter.Predicate) + ")"
+	}
+	n.Schema = &schema
+	return n
+}
+
+// writeExplanation prints e as JSON, or as indented text for any other
+// format.
+func writeExplanation(w io.Writer, format string, e *explanation) error {
+	if strings.EqualFold(format, "json") {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(e)
+	}
+	for _, l := range e.Lets {
+		fmt.Fprintf(w, "let %s:\n", l.Name)
+		writePlan(w, "  ", l.Parsed, l.Optimized, l.Physical)
+	}
+	writePlan(w, "", e.Parsed, e.Optimized, e.Physical)
+	return nil
+}
+
+func writePlan(w io.Writer, indent string, parsed, optimized []explainStep, physical *explainNode) {
+	fmt.Fprintf(w, "%sparsed plan:\n", indent)
+	writeSteps(w, indent+"  ", parsed)
+	fmt.Fprintf(w, "%soptimized plan:\n", indent)
+	writeSteps(w, indent+"  ", optimized)
+	fmt.Fprintf(w, "%sphysical plan:\n", indent)
+	writeNode(w, indent+"  ", physical)
+}
+
+func writeSteps(w io.Writer, indent string, steps []explainStep) {
+	for _, s := range steps {
+		fmt.Fprintf(w, "%s%s  %s\n", indent, s.Text, formatColumns(s.Schema))
+	}
+}
+
+func writeNode(w io.Writer, indent string, n *explainNode) {
+	if n == nil {
+		return
+	}
+	line := indent + n.Op
+	if n.Batch {
+		line += " (batch)"
+	}
+	if n.Detail != "" {
+		line += ": " + n.Detail
+	}
+	line += "  " + formatColumns(n.Schema)
+	if n.Materializes != "" {
+		line += "  materializes " + n.Materializes
+	}
+	fmt.Fprintln(w, line)
+	for _, in := range n.Inputs {
+		writeNode(w, indent+"  ", in)
+	}
+	for i, b := range n.Branches {
+		fmt.Fprintf(w, "%s  branch %d:\n", indent, i+1)
+		writeNode(w, indent+"    ", b)
+	}
+}
+
+func formatColumns(cols []explainColumn) string {
+	if cols == nil {
+		return "[?]"
+	}
+	parts := make([]string, len(cols))
+	for i, c := range cols {
+		parts[i] = c.Name + ":" + string(c.Type)
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// explainLets describes each let binding; a let reading an input reads only
+// the columns its subquery uses.
+func explainLets(lets, parsed []plan.LetStmt, checked *checkedQuery, bound exec.Tables, inputs map[string]string, fileType string, disabled map[string]bool) ([]explainedLet, error) {
+	out := make([]explainedLet, len(lets))
+	for i, l := range lets {
+		in := checked.letInputs[l.Name]
+		before, _, err := explainSteps(in, parsed[i].Ops, checked.tables)
+		if err != nil {
+			return nil, err
+		}
+		after, schemas, err := explainSteps(in, l.Ops, checked.tables)
+		if err != nil {
+			return nil, err
+		}
+		var source *exec.Node
+		if _, ok := bound[l.Source]; ok {
+			source = tableNode(l.Source, lets, in)
+		} else if l.Source != "" {
+			var sc scan
+			if !disabled["all"] {
+				sc.columns = optimizer.RequiredColumns(l.Ops)
+			}
+			source = readerNode(fileType, inputs[l.Source], *in, sc)
+		}
+		ops := resolveJoinInputs(l.Ops, inputs)
+		out[i] = explainedLet{Name: l.Name, Parsed: before, Optimized: after, Physical: explainTree(bound.Describe(source, ops, schemas, checked.joinInput(lets, bound)))}
+	}
+	return out, nil
+}
+
+// joinInput describes the right side of a join, a let binding or a CSV file,
+// with the schema the binder inferred for it.
+func (c *checkedQuery) joinInput(lets []plan.LetStmt, bound exec.Tables) func(name string) *exec.Node {
+	return func(name string) *exec.Node {
+		schema, ok := c.tables(name)
+		var known *model.Schema
+		if ok {
+			known = &schema
+		}
+		if _, ok := bound[name]; ok {
+			return tableNode(name, lets, known)
+		}
+		if !ok {
+			return &exec.Node{Op: "CSVReader", Detail: name}
+		}
+		return readerNode("csv", name, schema, scan{})
+	}
+}
+
+// tableNode describes reading the let binding name.
+func tableNode(name string, lets []plan.LetStmt, schema *model.Schema) *exec.Node {
+	n := &exec.Node{Op: "Table", Detail: name, Schema: schema}
+	for _, l := range lets {
+		if l.Name == name && l.Materialize {
+			n.Materializes = "all rows of the binding, shared by its readers"
+		}
+	}
+	return n
+}
@@ -6,12 +6,17 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
+	"kqlfile/pkg/binder"
 	"kqlfile/pkg/csvio"
 	"kqlfile/pkg/exec"
 	"kqlfile/pkg/jsonio"
 	"kqlfile/pkg/model"
+	"kqlfile/pkg/optimizer"
 	"kqlfile/pkg/output"
 	"kqlfile/pkg/parser"
 	"kqlfile/pkg/plan"
@@ -34,42 +39,89 @@ func run(args []string, stdout, stderr io.Writer) error {
 	var format string
 	var schemaStr string
 	var fileType string
+	var seed int64
+	var outputDir string
+	var disableRules string
+	var explain bool
+	var showStats bool
+	var statsFile string
 
-	fs.Var(&inputs, "input", "CSV input file path or name=path (repeatable)")
+	fs.Var(&inputs, "input", "CSV input file path or name=path (repeatable; not needed for range, datatable or print)")
 	fs.StringVar(&query, "query", "", "KQL query string")
 	fs.StringVar(&format, "format", "csv", "Output format: csv|json|table")
 	fs.StringVar(&schemaStr, "schema", "", "Schema override: col:type,col:type")
 	fs.StringVar(&fileType, "type", "csv", "Input file type (csv)")
+	fs.Int64Var(&seed, "seed", 0, "Random seed for sample and sample-distinct (0 picks a new seed per run)")
+	fs.StringVar(&outputDir, "output-dir", "", "Write each result set to its own file in this directory")
+	fs.StringVar(&disableRules, "disable-rules", "", "Comma-separated optimizer rules to skip, or all (for debugging)")
+	fs.BoolVar(&explain, "explain", false, "Print the query plans instead of running the query (json with --format json)")
+	fs.BoolVar(&showStats, "stats", false, "Print rows, bytes, time and memory per operator and per input to stderr")
+	fs.StringVar(&statsFile, "stats-file", "", "Write the --stats figures as JSON to this file")
 
+	// "kqlfile explain ..." is the same as "kqlfile --explain ...".
+	if len(args) > 0 && args[0] == "explain" {
+		args, explain = args[1:], true
+	}
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
 
-	if len(inputs) == 0 || query == "" {
+	if query == "" {
 		fmt.Fprintln(stderr, "input and query are required")
 		fs.Usage()
 		return errors.New("missing required flags")
 	}
+	lets, body, err := parser.ParseLets(query)
+	if err != nil {
+		fmt.Fprintln(stderr, "parse error:", err)
+		return err
+	}
+	letSource := ""
+	for _, l := range lets {
+		if l.Name == parseTableName(body) {
+			letSource = l.Name
+		}
+	}
+	var ops []plan.Operator
+	if strings.TrimSpace(body) != letSource {
+		if ops, err = parser.Parse(body); err != nil {
+			fmt.Fprintln(stderr, "parse error:", err)
+			return err
+		}
+	}
+	generated := len(ops) > 0 && exec.IsGenerator(ops[0])
+	if len(inputs) == 0 && !generated && letSource == "" {
+		fmt.Fprintln(stderr, "input and query are required")
+		fs.Usage()
+		return errors.New("missing required flags")
+	}
+	disabled, err := parseDisabledRules(disableRules)
+	if err != nil {
+		fmt.Fprintln(stderr, "flag error:", err)
+		return err
+	}
 	fileType = strings.ToLower(fileType)
 	if fileType != "csv" && fileType != "json" {
 		return fmt.Errorf("unsupported input type: %s", fileType)
 	}
 
-	inputMap, err := inputs.ToMap()
-	if err != nil {
-		fmt.Fprintln(stderr, "input error:", err)
+	inputMap := map[string]string{}
+	if len(inputs) > 0 {
+		if inputMap, err = inputs.ToMap(); err != nil {
+			fmt.Fprintln(stderr, "input error:", err)
+			return err
+		}
+	}
+	if err := checkLets(lets, inputMap); err != nil {
+		fmt.Fprintln(stderr, "parse error:", err)
 		return err
 	}
-	tableName := parseTableName(query)
-	if tableName == "" {
-		if len(inputMap) > 1 {
-			return errors.New("query must specify a table name when multiple inputs are provided")
-		}
-		tableName = "T"
+	var tables []string
+	if !generated && letSource == "" {
+		tables, err = sourceTables(body, ops, inputMap)
 	}
-	inputPath, ok := inputMap[tableName]
-	if !ok {
-		return fmt.Errorf("unknown table name: %s", tableName)
+	if err != nil {
+		return err
 	}
 
 	var schema *model.Schema
@@ -82,47 +134,179 @@ func run(args []string, stdout, stderr io.Writer) error {
 		schema = &parsed
 	}
 
-	reader, err := openReader(fileType, inputPath, schema)
+	// The inputs are opened once to learn their schemas, and again once the
+	// optimized query tells which of their columns it reads.
+	sources := make([]exec.NamedReader, len(tables))
+	for i, name := range tables {
+		reader, err := openReader(fileType, inputMap[name], schema)
+		if err != nil {
+			fmt.Fprintln(stderr, "reader error:", err)
+			return err
+		}
+		sources[i] = exec.NamedReader{Name: name, Schema: reader.Schema()}
+		reader.Close()
+	}
+	var inputSchema *model.Schema
+	switch {
+	case len(sources) == 1:
+		inputSchema = &sources[0].Schema
+	case len(sources) > 1:
+		sch := (&exec.UnionSource{Inputs: sources}).Schema()
+		inputSchema = &sch
+	}
+	checked, err := bindQuery(query, body, lets, ops, inputSchema, letSource, inputMap, func(path string) (rowReader, error) {
+		return openReader(fileType, path, schema)
+	})
 	if err != nil {
-		fmt.Fprintln(stderr, "reader error:", err)
+		fmt.Fprintln(stderr, "semantic error:", err)
 		return err
 	}
-	defer reader.Close()
+	parsedOps, parsedLets := ops, append([]plan.LetStmt(nil), lets...)
+	for i, l := range lets {
+		lets[i].Ops = optimizer.Optimize(l.Ops, optimizer.Options{Input: checked.letInputs[l.Name], Tables: checked.tables, Disabled: disabled})
+	}
+	ops = optimizer.Optimize(ops, optimizer.Options{Input: checked.input, Tables: checked.tables, Disabled: disabled})
 
-	ops, err := parser.Parse(query)
+	optimizedOps := ops
+	var sc scan
+	if !disabled["all"] {
+		sc, ops = planScan(ops, len(sources) == 1)
+	}
+	var stats *exec.Stats
+	if showStats || statsFile != "" {
+		stats = &exec.Stats{}
+	}
+	// now() and ago() read one instant in the query and every let binding.
+	start := time.Now().UTC()
+	bound, closeLets := bindLets(lets, inputMap, seed, start, stats, func(path string, columns []string) (rowReader, error) {
+		if disabled["all"] {
+			columns = nil
+		}
+		return openReader(fileType, path, schema, columnOptions(columns)...)
+	})
+	defer closeLets()
+	if explain {
+		e := &explanation{}
+		if e.Lets, err = explainLets(lets, parsedLets, checked, bound, inputMap, fileType, disabled); err != nil {
+			fmt.Fprintln(stderr, "plan error:", err)
+			return err
+		}
+		if e.Parsed, _, err = explainSteps(checked.input, parsedOps, checked.tables); err != nil {
+			fmt.Fprintln(stderr, "plan error:", err)
+			return err
+		}
+		var schemas []*model.Schema
+		if e.Optimized, schemas, err = explainSteps(checked.input, optimizedOps, checked.tables); err != nil {
+			fmt.Fprintln(stderr, "plan error:", err)
+			return err
+		}
+		var source *exec.Node
+		switch {
+		case letSource != "":
+			source = tableNode(letSource, lets, checked.input)
+		case len(sources) == 1:
+			source = readerNode(fileType, inputMap[sources[0].Name], sources[0].Schema, sc)
+		case len(sources) > 1:
+			source = &exec.Node{Op: "UnionSource", Schema: checked.input}
+			for _, s := range sources {
+				source.Inputs = append(source.Inputs, readerNode(fileType, inputMap[s.Name], s.Schema, sc))
+			}
+		}
+		// The reader runs the where it filters on, so the physical plan
+		// starts after it.
+		if sc.filter != nil {
+			schemas = schemas[1:]
+		}
+		e.Physical = explainTree(bound.Describe(source, resolveJoinInputs(ops, inputMap), schemas, checked.joinInput(lets, bound)))
+		if err := writeExplanation(stdout, format, e); err != nil {
+			fmt.Fprintln(stderr, "output error:", err)
+			return err
+		}
+		return nil
+	}
+	readOpts, err := sc.options()
 	if err != nil {
-		fmt.Fprintln(stderr, "parse error:", err)
+		fmt.Fprintln(stderr, "plan error:", err)
 		return err
 	}
+	for i := range sources {
+		sch := sources[i].Schema
+		reader, err := openReader(fileType, inputMap[sources[i].Name], &sch, readOpts...)
+		if err != nil {
+			fmt.Fprintln(stderr, "reader error:", err)
+			return err
+		}
+		defer reader.Close()
+		sources[i].Reader, sources[i].Schema = reader, reader.Schema()
+	}
+	var source exec.RowReader
+	switch {
+	case letSource != "":
+		if source, err = bound[letSource](); err != nil {
+			fmt.Fprintln(stderr, "reader error:", err)
+			return err
+		}
+	case len(sources) == 1:
+		source = sources[0].Reader
+	case len(sources) > 1:
+		source = &exec.UnionSource{Inputs: sources}
+	}
 	ops = resolveJoinInputs(ops, inputMap)
+	ops = exec.PinClock(applySeed(ops, seed), start)
 
-	pipe, err := exec.BuildPipeline(reader, ops)
+	results, err := bound.ResultsWithStats(source, ops, stats)
 	if err != nil {
 		fmt.Fprintln(stderr, "plan error:", err)
 		return err
 	}
 
-	rows := make(chan *csvio.Row)
-	go func() {
-		defer close(rows)
-		for {
-			row, err := pipe.Next()
-			if err == io.EOF {
-				return
+	sets := make([]output.ResultSet, len(results))
+	var running sync.WaitGroup
+	for i, r := range results {
+		rows := make(chan *csvio.Row)
+		running.Add(1)
+		go func(pipe exec.Operator) {
+			defer running.Done()
+			defer close(rows)
+			for {
+				row, err := pipe.Next()
+				if err == io.EOF {
+					return
+				}
+				if err != nil {
+					fmt.Fprintln(stderr, "exec error:", err)
+					return
+				}
+				rows <- row
 			}
-			if err != nil {
-				fmt.Fprintln(stderr, "exec error:", err)
-				return
-			}
-			rows <- row
-		}
-	}()
+		}(r.Op)
+		sets[i] = output.ResultSet{Name: r.Name, Rows: rows}
+	}
 
 	fmtType := output.Format(strings.ToLower(format))
-	if err := output.WriteTo(stdout, fmtType, rows); err != nil {
+	if outputDir != "" {
+		err = output.WriteResultsToDir(outputDir, fmtType, sets)
+	} else {
+		err = output.WriteResults(stdout, fmtType, sets)
+	}
+	if err != nil {
 		fmt.Fprintln(stderr, "output error:", err)
 		return err
 	}
+	if stats == nil {
+		return nil
+	}
+	running.Wait()
+	collected := collectStats(stats)
+	if showStats {
+		writeStats(stderr, collected)
+	}
+	if statsFile != "" {
+		if err := writeStatsFile(statsFile, collected); err != nil {
+			fmt.Fprintln(stderr, "stats error:", err)
+			return err
+		}
+	}
 	return nil
 }
 
@@ -154,17 +338,57 @@ type rowReader interface {
 	Close() error
 }
 
-func openReader(fileType, path string, schema *model.Schema) (rowReader, error) {
+func openReader(fileType, path string, schema *model.Schema, opts ...csvio.Option) (rowReader, error) {
 	switch fileType {
 	case "csv":
-		return csvio.NewReader(path, schema)
+		return csvio.NewReader(path, schema, opts...)
 	case "json":
-		return jsonio.NewReader(path, schema)
+		return jsonio.NewReader(path, schema, opts...)
 	default:
 		return nil, fmt.Errorf("unsupported input type: %s", fileType)
 	}
 }
 
+// columnOptions returns the reader options reading only columns, or every
+// column when columns is nil.
+func columnOptions(columns []string) []csvio.Option {
+	if columns == nil {
+		return nil
+	}
+	return []csvio.Option{csvio.WithColumns(columns...)}
+}
+
+// scan is the work of a query done by its input readers: they read only
+// columns, or every column when it is nil, and drop the rows filter rejects.
+type scan struct {
+	columns []string
+	filter  *optimizer.ScanFilter
+}
+
+// planScan moves work of ops into the input readers: they read only the
+// columns ops use and, for a single input, evaluate a leading where on each
+// record. It returns the scan and the operators left to run.
+func planScan(ops []plan.Operator, single bool) (scan, []plan.Operator) {
+	sc := scan{columns: optimizer.RequiredColumns(ops)}
+	if single {
+		sc.filter, ops = optimizer.SplitScanFilter(ops)
+	}
+	return sc, ops
+}
+
+// options returns the reader options for the scan.
+func (sc scan) options() ([]csvio.Option, error) {
+	opts := columnOptions(sc.columns)
+	if sc.filter == nil {
+		return opts, nil
+	}
+	keep, err := exec.NewRowFilter(sc.filter.Predicate)
+	if err != nil {
+		return nil, err
+	}
+	return append(opts, csvio.WithFilter(csvio.Filter{Columns: sc.filter.Columns, Keep: keep})), nil
+}
+
 type inputList []string
 
 func (i *inputList) String() string {
@@ -206,6 +430,35 @@ func (i inputList) ToMap() (map[string]string, error) {
 	return out, nil
 }
 
+// sourceTables resolves the inputs a query reads from: the leading table name,
+// the tables of a leading search (all inputs when none are listed), or the
+// single default input.
+func sourceTables(query string, ops []plan.Operator, inputs map[string]string) ([]string, error) {
+	var tables []string
+	if name := parseTableName(query); name != "" {
+		tables = []string{name}
+	} else if s, ok := ops[0].(plan.SearchOp); ok {
+		tables = s.Tables
+		if len(tables) == 0 {
+			for name := range inputs {
+				tables = append(tables, name)
+			}
+			sort.Strings(tables)
+		}
+	} else {
+		if len(inputs) > 1 {
+			return nil, errors.New("query must specify a table name when multiple inputs are provided")
+		}
+		tables = []string{"T"}
+	}
+	for _, name := range tables {
+		if _, ok := inputs[name]; !ok {
+			return nil, fmt.Errorf("unknown table name: %s", name)
+		}
+	}
+	return tables, nil
+}
+
 func parseTableName(query string) string {
 	parts := strings.Split(query, "|")
 	first := strings.TrimSpace(parts[0])
@@ -220,26 +473,218 @@ func parseTableName(query string) string {
 }
 
 func isOperatorToken(tok string) bool {
-	switch strings.ToLower(tok) {
-	case "where", "project", "extend", "summarize", "take", "order", "join":
-		return true
-	default:
-		return false
+	return parser.IsOperator(tok)
+}
+
+// checkLets rejects let names that shadow an input and subqueries reading
+// from a table that is neither an input nor bound earlier.
+func checkLets(lets []plan.LetStmt, inputs map[string]string) error {
+	bound := map[string]bool{}
+	for _, l := range lets {
+		if _, ok := inputs[l.Name]; ok {
+			return fmt.Errorf("let %s shadows an input", l.Name)
+		}
+		if _, ok := inputs[l.Source]; l.Source != "" && !ok && !bound[l.Source] {
+			return fmt.Errorf("let %s: unknown table name: %s", l.Name, l.Source)
+		}
+		bound[l.Name] = true
+	}
+	return nil
+}
+
+// bindLets turns let statements into tables. Every reference runs the
+// subquery again, re-reading its input, unless the binding is materialized.
+// Inputs are opened reading only the columns of the subquery. The returned
+// function closes the inputs opened on the way.
+func bindLets(lets []plan.LetStmt, inputs map[string]string, seed int64, start time.Time, stats *exec.Stats, open func(path string, columns []string) (rowReader, error)) (exec.Tables, func()) {
+	tables := exec.Tables{}
+	var mu sync.Mutex
+	var readers []rowReader
+	openTable := func(name string, columns []string) (exec.RowReader, error) {
+		if t, ok := tables[name]; ok {
+			return t()
+		}
+		r, err := open(inputs[name], columns)
+		if err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		readers = append(readers, r)
+		mu.Unlock()
+		return r, nil
+	}
+	for _, l := range lets {
+		l := l
+		ops := exec.PinClock(applySeed(resolveJoinInputs(l.Ops, inputs), seed), start)
+		columns := optimizer.RequiredColumns(ops)
+		build := func() (exec.Operator, error) {
+			var src exec.RowReader
+			if l.Source != "" {
+				var err error
+				if src, err = openTable(l.Source, columns); err != nil {
+					return nil, err
+				}
+			}
+			return tables.PipelineWithStats(src, ops, stats)
+		}
+		if l.Materialize {
+			m := exec.NewMaterialized(build)
+			tables[l.Name] = func() (exec.RowReader, error) { return m.Reader(), nil }
+			continue
+		}
+		tables[l.Name] = func() (exec.RowReader, error) { return build() }
+	}
+	return tables, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, r := range readers {
+			r.Close()
+		}
 	}
 }
 
+// checkedQuery holds the schemas found while binding a query, which the
+// optimizer needs again.
+type checkedQuery struct {
+	tables    binder.Tables
+	input     *model.Schema
+	letInputs map[string]*model.Schema
+}
+
+// bindQuery checks the let subqueries and then the query against the
+// schemas of their inputs. Error positions are offsets in the full query.
+func bindQuery(query, body string, lets []plan.LetStmt, ops []plan.Operator, input *model.Schema, letSource string, inputs map[string]string, open func(path string) (rowReader, error)) (*checkedQuery, error) {
+	bound := map[string]*model.Schema{}
+	joined := map[string]model.Schema{}
+	checked := &checkedQuery{letInputs: map[string]*model.Schema{}}
+	tables := func(name string) (model.Schema, bool) {
+		if s, ok := bound[name]; ok {
+			if s == nil {
+				return model.Schema{}, false
+			}
+			return *s, true
+		}
+		path := name
+		if p, ok := inputs[name]; ok {
+			path = p
+		}
+		if s, ok := joined[path]; ok {
+			return s, true
+		}
+		// Join inputs that are not let bindings are always read as CSV.
+		r, err := csvio.NewReader(path, nil)
+		if err != nil {
+			return model.Schema{}, false
+		}
+		defer r.Close()
+		joined[path] = r.Schema()
+		return joined[path], true
+	}
+	for _, l := range lets {
+		var in *model.Schema
+		if s, ok := bound[l.Source]; ok {
+			in = s
+		} else if l.Source != "" {
+			r, err := open(inputs[l.Source])
+			if err != nil {
+				return nil, err
+			}
+			sch := r.Schema()
+			r.Close()
+			in = &sch
+		}
+		schemas, err := binder.Bind(query, in, l.Ops, tables)
+		if err != nil {
+			return nil, err
+		}
+		checked.letInputs[l.Name] = in
+		bound[l.Name] = in
+		if len(schemas) > 0 {
+			bound[l.Name] = schemas[len(schemas)-1]
+		}
+	}
+	if letSource != "" {
+		input = bound[letSource]
+	}
+	if _, err := binder.Bind(body, input, ops, tables); err != nil {
+		var be *binder.Error
+		if errors.As(err, &be) {
+			be.Pos += strings.LastIndex(query, body)
+		}
+		return nil, err
+	}
+	checked.tables, checked.input = tables, input
+	return checked, nil
+}
+
+// parseDisabledRules reads the --disable-rules list of optimizer rule names.
+func parseDisabledRules(raw string) (map[string]bool, error) {
+	disabled := map[string]bool{}
+	known := map[string]bool{"all": true}
+	for _, r := range optimizer.Rules {
+		known[r.Name] = true
+	}
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !known[name] {
+			return nil, fmt.Errorf("unknown optimizer rule %q", name)
+		}
+		disabled[name] = true
+	}
+	return disabled, nil
+}
+
 func resolveJoinInputs(ops []plan.Operator, inputs map[string]string) []plan.Operator {
 	out := make([]plan.Operator, 0, len(ops))
 	for _, op := range ops {
-		j, ok := op.(plan.JoinOp)
-		if !ok {
-			out = append(out, op)
-			continue
+		switch o := op.(type) {
+		case plan.JoinOp:
+			if path, ok := inputs[o.Right]; ok {
+				o.Right = path
+			}
+			op = o
+		case plan.ForkOp:
+			op = plan.ForkOp{Branches: mapBranches(o.Branches, func(ops []plan.Operator) []plan.Operator {
+				return resolveJoinInputs(ops, inputs)
+			})}
 		}
-		if path, ok := inputs[j.Right]; ok {
-			j.Right = path
+		out = append(out, op)
+	}
+	return out
+}
+
+// applySeed sets the random seed of every sampling operator, including those
+// inside mv-apply subqueries and fork branches.
+func applySeed(ops []plan.Operator, seed int64) []plan.Operator {
+	out := make([]plan.Operator, 0, len(ops))
+	for _, op := range ops {
+		switch o := op.(type) {
+		case plan.SampleOp:
+			o.Seed = seed
+			op = o
+		case plan.SampleDistinctOp:
+			o.Seed = seed
+			op = o
+		case plan.MvApplyOp:
+			o.Subquery = applySeed(o.Subquery, seed)
+			op = o
+		case plan.ForkOp:
+			op = plan.ForkOp{Branches: mapBranches(o.Branches, func(ops []plan.Operator) []plan.Operator {
+				return applySeed(ops, seed)
+			})}
 		}
-		out = append(out, j)
+		out = append(out, op)
+	}
+	return out
+}
+
+func mapBranches(branches []plan.ForkBranch, fn func([]plan.Operator) []plan.Operator) []plan.ForkBranch {
+	out := make([]plan.ForkBranch, len(branches))
+	for i, b := range branches {
+		out[i] = plan.ForkBranch{Name: b.Name, Ops: fn(b.Ops)}
 	}
 	return out
 }
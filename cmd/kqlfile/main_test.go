@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"io"
 	"os"
@@ -385,3 +386,321 @@ func TestRunInputMapError(t *testing.T) {
 		t.Fatalf("expected input map error")
 	}
 }
+
+func TestRunSearchMultipleInputs(t *testing.T) {
+	pathA := filepath.Join(t.TempDir(), "a.csv")
+	pathB := filepath.Join(t.TempDir(), "b.csv")
+	if err := os.WriteFile(pathA, []byte("ip,msg\n10.0.0.5,connection timeout\n10.0.0.6,ok\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(pathB, []byte("host,note\nweb,Timeout reached\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var out bytes.Buffer
+	var errBuf bytes.Buffer
+	if err := run([]string{"--input", "A=" + pathA, "--input", "B=" + pathB, "--query", "search \"timeout\""}, &out, &errBuf); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	want := "$table,ip,msg,host,note\nA,10.0.0.5,connection timeout,,\nB,,,web,Timeout reached\n"
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+	out.Reset()
+	if err := run([]string{"--input", "A=" + pathA, "--input", "B=" + pathB, "--query", "search in (C) \"x\""}, &out, &errBuf); err == nil {
+		t.Fatalf("expected unknown table error")
+	}
+}
+
+func TestRunGeneratorWithoutInput(t *testing.T) {
+	var out bytes.Buffer
+	var errBuf bytes.Buffer
+	if err := run([]string{"--query", "range x from 1 to 3 step 1 | extend y = x * 10"}, &out, &errBuf); err != nil {
+		t.Fatalf("run: %v (%s)", err, errBuf.String())
+	}
+	if out.String() != "x,y\n1,10\n2,20\n3,30\n" {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+	out.Reset()
+	if err := run([]string{"--query", "T | take 1"}, &out, &errBuf); err == nil {
+		t.Fatalf("expected missing input error")
+	}
+}
+
+func TestRunSampleSeed(t *testing.T) {
+	var first, second bytes.Buffer
+	var errBuf bytes.Buffer
+	args := []string{"--query", "range x from 1 to 1000 step 1 | sample 5", "--seed", "9"}
+	if err := run(args, &first, &errBuf); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if err := run(args, &second, &errBuf); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if first.String() != second.String() || strings.Count(first.String(), "\n") != 6 {
+		t.Fatalf("expected identical seeded samples, got %q and %q", first.String(), second.String())
+	}
+	ops := applySeed([]plan.Operator{plan.MvApplyOp{Subquery: []plan.Operator{plan.SampleDistinctOp{Count: 1}}}}, 3)
+	if ops[0].(plan.MvApplyOp).Subquery[0].(plan.SampleDistinctOp).Seed != 3 {
+		t.Fatalf("expected seed in subquery")
+	}
+}
+
+func TestRunFork(t *testing.T) {
+	var out bytes.Buffer
+	var errBuf bytes.Buffer
+	query := "range x from 1 to 10 step 1 | fork (summarize count()) big=(top 2 by x)"
+	if err := run([]string{"--query", query}, &out, &errBuf); err != nil {
+		t.Fatalf("run: %v (%s)", err, errBuf.String())
+	}
+	if out.String() != "# fork1\ncount\n10\n\n# big\nx\n10\n9\n" {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+
+	dir := t.TempDir()
+	out.Reset()
+	if err := run([]string{"--query", query, "--output-dir", dir}, &out, &errBuf); err != nil {
+		t.Fatalf("run: %v (%s)", err, errBuf.String())
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "big.csv"))
+	if err != nil || string(data) != "x\n10\n9\n" || out.Len() != 0 {
+		t.Fatalf("unexpected output file: %q (%v)", data, err)
+	}
+	dir = t.TempDir()
+	if err := run([]string{"--query", "range x from 1 to 2 step 1", "--output-dir", dir}, &out, &errBuf); err != nil {
+		t.Fatalf("run: %v (%s)", err, errBuf.String())
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "result.csv"))
+	if err != nil || string(data) != "x\n1\n2\n" {
+		t.Fatalf("unexpected output file for a plain query: %q (%v)", data, err)
+	}
+	ops := applySeed([]plan.Operator{plan.ForkOp{Branches: []plan.ForkBranch{{Ops: []plan.Operator{plan.SampleOp{Count: 1}}}}}}, 5)
+	if ops[0].(plan.ForkOp).Branches[0].Ops[0].(plan.SampleOp).Seed != 5 {
+		t.Fatalf("expected seed in fork branch")
+	}
+}
+
+func TestRunLetMaterialize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte("id,boss\n1,0\n2,1\n3,1\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var out bytes.Buffer
+	var errBuf bytes.Buffer
+	query := "let E = materialize(T | project id, boss); E | join kind=inner (E) on boss == id | project id, right.boss"
+	if err := run([]string{"--input", path, "--query", query}, &out, &errBuf); err != nil {
+		t.Fatalf("run: %v (%s)", err, errBuf.String())
+	}
+	if out.String() != "id,right.boss\n2,0\n3,0\n" {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+
+	out.Reset()
+	if err := run([]string{"--query", "let d = datatable(a:int)[1, 2]; d"}, &out, &errBuf); err != nil {
+		t.Fatalf("run: %v (%s)", err, errBuf.String())
+	}
+	if out.String() != "a\n1\n2\n" {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+
+	for _, q := range []string{"let T = datatable(a:int)[1]; T", "let x = y | take 1; x"} {
+		if err := run([]string{"--input", path, "--query", q}, &out, &errBuf); err == nil {
+			t.Fatalf("expected error for %s", q)
+		}
+	}
+}
+
+func TestRunSemanticError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte("name,age\nalice,30\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var out bytes.Buffer
+	var errBuf bytes.Buffer
+	if err := run([]string{"--input", path, "--query", "T | where agee > 30"}, &out, &errBuf); err == nil {
+		t.Fatalf("expected semantic error")
+	}
+	if got := errBuf.String(); !strings.Contains(got, `unknown column "agee" at position 10 (did you mean "age"?)`) {
+		t.Fatalf("unexpected error: %s", got)
+	}
+	errBuf.Reset()
+	query := "let A = T | project name; A | where nam == \"alice\""
+	if err := run([]string{"--input", path, "--query", query}, &out, &errBuf); err == nil {
+		t.Fatalf("expected semantic error in let query")
+	}
+	if got := errBuf.String(); !strings.Contains(got, "at position 36") {
+		t.Fatalf("position should count from the start of the query: %s", got)
+	}
+}
+
+func TestRunDisableRules(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "people.csv")
+	if err := os.WriteFile(path, []byte("name,age,city\nalice,30,Oslo\nbob,25,Rome\ncarol,41,Oslo\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	cities := filepath.Join(dir, "cities.csv")
+	if err := os.WriteFile(cities, []byte("city,country\nOslo,NO\nRome,IT\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	query := "T | join kind=inner (" + cities + ") on city == city | where country == \"NO\" and age > 35 | order by age desc | take 1 | project name"
+	var want string
+	for _, rules := range []string{"", "all", "top-n,push-predicates"} {
+		var out, errBuf bytes.Buffer
+		if err := run([]string{"--input", path, "--query", query, "--disable-rules", rules}, &out, &errBuf); err != nil {
+			t.Fatalf("run with %q disabled: %v (%s)", rules, err, errBuf.String())
+		}
+		if want == "" {
+			want = out.String()
+		}
+		if out.String() != want || want != "name\ncarol\n" {
+			t.Fatalf("unexpected output with %q disabled: %q", rules, out.String())
+		}
+	}
+	var out, errBuf bytes.Buffer
+	if err := run([]string{"--input", path, "--query", "T | take 1", "--disable-rules", "fold"}, &out, &errBuf); err == nil {
+		t.Fatalf("expected an error for an unknown rule")
+	}
+	if !strings.Contains(errBuf.String(), `unknown optimizer rule "fold"`) {
+		t.Fatalf("unexpected error: %s", errBuf.String())
+	}
+}
+
+func TestRunSkipsUnusedColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte("name,age,score\nalice,30,1.5\nbob,41,oops\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	args := []string{"--input", path, "--schema", "name:string,age:int,score:float"}
+	var out, errBuf bytes.Buffer
+	if err := run(append(args, "--query", "T | where age > 35 | project name"), &out, &errBuf); err != nil {
+		t.Fatalf("run: %v (%s)", err, errBuf.String())
+	}
+	if out.String() != "name\nbob\n" || errBuf.Len() != 0 {
+		t.Fatalf("the unused score column should not be parsed: %q %q", out.String(), errBuf.String())
+	}
+	out.Reset()
+	if err := run(append(args, "--query", "T | project name, score"), &out, &errBuf); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !strings.Contains(errBuf.String(), "parse column score") {
+		t.Fatalf("expected a parse error for the score column, got %q", errBuf.String())
+	}
+}
+
+func TestRunFiltersWhileReading(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if err := os.WriteFile(path, []byte("name,age,score\nalice,30,oops\nbob,41,2.5\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	args := []string{"--input", path, "--schema", "name:string,age:int,score:float"}
+	var out, errBuf bytes.Buffer
+	if err := run(append(args, "--query", "T | where age > 35"), &out, &errBuf); err != nil {
+		t.Fatalf("run: %v (%s)", err, errBuf.String())
+	}
+	if out.String() != "name,age,score\nbob,41,2.5\n" || errBuf.Len() != 0 {
+		t.Fatalf("the filtered row should not be parsed: %q %q", out.String(), errBuf.String())
+	}
+	out.Reset()
+	if err := run(append(args, "--query", "T | where age > 35", "--disable-rules", "all"), &out, &errBuf); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !strings.Contains(errBuf.String(), "parse column score") {
+		t.Fatalf("with the optimizer off every column is parsed, got %q", errBuf.String())
+	}
+}
+
+func TestRunExplain(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "people.csv")
+	if err := os.WriteFile(path, []byte("name,age,city,score\nalice,30,Oslo,1\nbob,25,Rome,2\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	cities := filepath.Join(dir, "cities.csv")
+	if err := os.WriteFile(cities, []byte("city,country\nOslo,NO\nRome,IT\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	query := "T | where age > 20 | join kind=inner (" + cities + ") on city == city | order by age desc | take 1 | project name, country"
+	var out, errBuf bytes.Buffer
+	if err := run([]string{"explain", "--input", path, "--query", query, "--format", "json"}, &out, &errBuf); err != nil {
+		t.Fatalf("run: %v (%s)", err, errBuf.String())
+	}
+	var e explanation
+	if err := json.Unmarshal(out.Bytes(), &e); err != nil {
+		t.Fatalf("decode %s: %v", out.String(), err)
+	}
+	var ops []string
+	for _, s := range e.Optimized {
+		ops = append(ops, s.Op)
+	}
+	if got := strings.Join(ops, ","); got != "where,join,top,project" {
+		t.Fatalf("unexpected optimized plan %s", got)
+	}
+	if len(e.Parsed) != 5 || e.Parsed[4].Text != "project name, country" || formatColumns(e.Parsed[4].Schema) != "[name:string, country:string]" {
+		t.Fatalf("unexpected parsed plan %+v", e.Parsed)
+	}
+	top := e.Physical.Inputs[0]
+	join := top.Inputs[0]
+	if top.Op != "TopOp" || top.Materializes == "" || join.Op != "JoinOp" || join.Materializes == "" {
+		t.Fatalf("unexpected physical plan %s", out.String())
+	}
+	scan := join.Inputs[0]
+	if scan.Op != "CSVReader" || strings.Contains(scan.Detail, "score") || !strings.Contains(scan.Detail, "filter=(age > 20)") {
+		t.Fatalf("unexpected scan %+v", scan)
+	}
+	build := join.Inputs[1]
+	if build.Op != "CSVReader" || build.Detail != cities || formatColumns(build.Schema) != "[city:string, country:string]" {
+		t.Fatalf("unexpected build side %+v", build)
+	}
+
+	out.Reset()
+	if err := run([]string{"--explain", "--input", path, "--query", query}, &out, &errBuf); err != nil {
+		t.Fatalf("run: %v (%s)", err, errBuf.String())
+	}
+	for _, want := range []string{"parsed plan:", "optimized plan:", "physical plan:", "TopOp: top 1 by age desc"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected %q in\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRunStats(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "people.csv")
+	if err := os.WriteFile(path, []byte("name,age,city\nalice,30,Oslo\nbob,25,Rome\ncarol,41,Oslo\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	statsPath := filepath.Join(dir, "stats.json")
+	var out, errBuf bytes.Buffer
+	args := []string{"--input", path, "--query", "T | where age > 26 | order by age desc | project name", "--stats", "--stats-file", statsPath}
+	if err := run(args, &out, &errBuf); err != nil {
+		t.Fatalf("run: %v (%s)", err, errBuf.String())
+	}
+	if out.String() != "name\ncarol\nalice\n" {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+	for _, want := range []string{"operator", "OrderByOp: order by age desc", "peak heap:", "rows parsed", path} {
+		if !strings.Contains(errBuf.String(), want) {
+			t.Fatalf("expected %q in the stats table:\n%s", want, errBuf.String())
+		}
+	}
+	data, err := os.ReadFile(statsPath)
+	if err != nil {
+		t.Fatalf("read stats: %v", err)
+	}
+	var stats queryStats
+	if err := json.Unmarshal(data, &stats); err != nil {
+		t.Fatalf("decode %s: %v", data, err)
+	}
+	if len(stats.Pipelines) != 1 || len(stats.Readers) != 1 || stats.PeakHeapBytes == 0 {
+		t.Fatalf("unexpected stats %s", data)
+	}
+	project := stats.Pipelines[0]
+	order := project.Inputs[0]
+	scan := order.Inputs[0]
+	if project.Op != "ProjectOp" || project.RowsOut != 2 || order.RowsIn != 2 || scan.Op != "CSVReader" || scan.RowsIn != 3 || scan.RowsOut != 2 {
+		t.Fatalf("unexpected operators %s", data)
+	}
+	if r := stats.Readers[0]; r.Path != path || r.RowsParsed != 3 || r.Rows != 2 || r.ParseErrors != 0 || r.Bytes == 0 {
+		t.Fatalf("unexpected reader stats %+v", r)
+	}
+}
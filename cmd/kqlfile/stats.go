@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"kqlfile/pkg/exec"
+)
+
+// queryStats is the --stats-file document.
+type queryStats struct {
+	Pipelines     []*operatorStats `json:"pipelines"`
+	Readers       []readerStats    `json:"readers"`
+	PeakHeapBytes uint64           `json:"peak_heap_bytes"`
+}
+
+type operatorStats struct {
+	Op        string           `json:"op"`
+	Detail    string           `json:"detail,omitempty"`
+	RowsIn    int64            `json:"rows_in"`
+	RowsOut   int64            `json:"rows_out"`
+	BytesRead int64            `json:"bytes_read"`
+	TimeNanos int64            `json:"time_ns"`
+	Inputs    []*operatorStats `json:"inputs,omitempty"`
+	reads     bool
+}
+
+type readerStats struct {
+	Path        string  `json:"path"`
+	Format      string  `json:"format"`
+	RowsParsed  int64   `json:"rows_parsed"`
+	Rows        int64   `json:"rows"`
+	ParseErrors int64   `json:"parse_errors"`
+	Bytes       int64   `json:"bytes"`
+	TimeNanos   int64   `json:"time_ns"`
+	BytesPerSec float64 `json:"bytes_per_sec"`
+}
+
+// collectStats converts what the pipelines recorded; time is the time of
+// each operator without its inputs, and the rows in of an input reader are
+// the records it parsed.
+func collectStats(s *exec.Stats) *queryStats {
+	out := &queryStats{Pipelines: []*operatorStats{}, Readers: []readerStats{}, PeakHeapBytes: s.PeakHeap()}
+	var convert func(n *exec.OpStats) *operatorStats
+	convert = func(n *exec.OpStats) *operatorStats {
+		op := &operatorStats{Op: n.Op, Detail: n.Detail, RowsIn: n.RowsIn(), RowsOut: n.RowsOut, TimeNanos: int64(n.Self())}
+		if rs, ok := n.ReadStats(); ok {
+			op.RowsIn, op.BytesRead, op.reads = rs.Records, rs.Bytes, true
+			r := readerStats{Path: rs.Path, Format: rs.Format, RowsParsed: rs.Records, Rows: rs.Rows, ParseErrors: rs.ParseErrors, Bytes: rs.Bytes, TimeNanos: int64(n.Total)}
+			if n.Total > 0 {
+				r.BytesPerSec = float64(rs.Bytes) / n.Total.Seconds()
+			}
+			out.Readers = append(out.Readers, r)
+		}
+		for _, in := range n.Inputs {
+			op.Inputs = append(op.Inputs, convert(in))
+		}
+		return op
+	}
+	for _, p := range s.Pipelines() {
+		out.Pipelines = append(out.Pipelines, convert(p))
+	}
+	return out
+}
+
+// writeStats prints s as tables, one row per operator with its inputs
+// indented below it, followed by the peak heap of the query.
+func writeStats(w io.Writer, s *queryStats) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "operator\trows in\trows out\tbytes read\ttime\t")
+	var row func(op *operatorStats, depth int)
+	row = func(op *operatorStats, depth int) {
+		name := strings.Repeat("  ", depth) + op.Op
+		if op.Detail != "" {
+			name += ": " + op.Detail
+		}
+		read := "-"
+		if op.reads {
+			read = formatBytes(op.BytesRead)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\t\n", name, op.RowsIn, op.RowsOut, read, formatDuration(op.TimeNanos))
+		for _, in := range op.Inputs {
+			row(in, depth+1)
+		}
+	}
+	for _, p := range s.Pipelines {
+		row(p, 0)
+	}
+	tw.Flush()
+	fmt.Fprintf(w, "peak heap: %s\n", formatBytes(int64(s.PeakHeapBytes)))
+	if len(s.Readers) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "reader\trows parsed\trows\tparse errors\tbytes\tbytes/sec\t")
+	for _, r := range s.Readers {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\t%s\t\n", r.Path, r.RowsParsed, r.Rows, r.ParseErrors, formatBytes(r.Bytes), formatBytes(int64(r.BytesPerSec)))
+	}
+	tw.Flush()
+}
+
+// writeStatsFile writes s as JSON to path.
+func writeStatsFile(path string, s *queryStats) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func formatDuration(ns int64) string {
+	return time.Duration(ns).Round(time.Microsecond).String()
+}
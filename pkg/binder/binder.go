@@ -0,0 +1,400 @@
+// Package binder checks a parsed query against the schema of its input
+// before it runs. It resolves every column reference in the plan operators,
+// infers the column types each operator produces, and reports unknown
+// columns with their position in the query and the closest known name.
+package binder
+
+import (
+	"fmt"
+	"strings"
+
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/parser"
+	"kqlfile/pkg/plan"
+)
+
+// Error is a semantic error at a byte offset in the query.
+type Error struct {
+	Pos        int
+	Msg        string
+	Suggestion string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s at position %d", e.Msg, e.Pos)
+	if e.Suggestion != "" {
+		msg += fmt.Sprintf(" (did you mean %q?)", e.Suggestion)
+	}
+	return msg
+}
+
+// Tables resolves the schema of a join's right side by table name or path.
+// Joins against unresolved tables leave the following operators unchecked.
+type Tables func(name string) (model.Schema, bool)
+
+// Bind checks ops, parsed from query, against the input schema. It returns
+// the output schema of each operator, which is nil where the columns depend
+// on the data (evaluate plugins, or a join against an unresolved table); the
+// operators after such a step are not checked, nor are those over a nil
+// input.
+func Bind(query string, input *model.Schema, ops []plan.Operator, tables Tables) ([]*model.Schema, error) {
+	b := &binder{query: query, tables: tables, end: len(query)}
+	offsets := parser.OperatorOffsets(query)
+	if len(offsets) != len(ops) {
+		offsets = nil
+	}
+	return b.bindOps(input, ops, offsets)
+}
+
+type binder struct {
+	query  string
+	tables Tables
+	// start and end delimit the text of the operator being bound, so that
+	// errors point at the column reference inside it.
+	start, end int
+}
+
+func (b *binder) bindOps(in *model.Schema, ops []plan.Operator, offsets []int) ([]*model.Schema, error) {
+	out := make([]*model.Schema, len(ops))
+	cur := in
+	for i, op := range ops {
+		if offsets != nil {
+			b.start, b.end = offsets[i], len(b.query)
+			if i+1 < len(offsets) {
+				b.end = offsets[i+1]
+			}
+		}
+		next, err := b.bindOp(cur, op)
+		if err != nil {
+			return nil, err
+		}
+		out[i], cur = next, next
+	}
+	return out, nil
+}
+
+// bindOp returns the schema produced by op over the input schema s.
+func (b *binder) bindOp(s *model.Schema, op plan.Operator) (*model.Schema, error) {
+	empty := model.NewSchema(nil)
+	switch o := op.(type) {
+	case plan.RangeOp:
+		from, err := b.exprType(&empty, o.From)
+		if err != nil {
+			return nil, err
+		}
+		step, err := b.exprType(&empty, o.Step)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := b.exprType(&empty, o.To); err != nil {
+			return nil, err
+		}
+		if isNumeric(from) && isNumeric(step) && from != step {
+			from = model.TypeFloat
+		}
+		return schemaOf([]model.Column{{Name: o.Column, Type: from}}), nil
+	case plan.DatatableOp:
+		return schemaOf(o.Columns), nil
+	case plan.PrintOp:
+		cols := make([]model.Column, len(o.Columns))
+		for i, c := range o.Columns {
+			t, err := b.exprType(&empty, c.Value)
+			if err != nil {
+				return nil, err
+			}
+			cols[i] = model.Column{Name: c.Name, Type: t}
+		}
+		return schemaOf(cols), nil
+	}
+	if s == nil {
+		return nil, nil
+	}
+	switch o := op.(type) {
+	case plan.WhereOp:
+		if _, err := b.exprType(s, o.Predicate); err != nil {
+			return nil, err
+		}
+		return s, nil
+	case plan.SearchOp:
+		if _, err := b.exprType(s, o.Predicate); err != nil {
+			return nil, err
+		}
+		return s, nil
+	case plan.ProjectOp:
+		cols := make([]model.Column, len(o.Columns))
+		for i, name := range o.Columns {
+			t, err := b.column(s, name)
+			if err != nil {
+				return nil, err
+			}
+			cols[i] = model.Column{Name: name, Type: t}
+		}
+		return schemaOf(cols), nil
+	case plan.ExtendOp:
+		return b.extend(s, o)
+	case plan.SerializeOp:
+		for _, c := range o.Columns {
+			var err error
+			if s, err = b.extend(s, c); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	case plan.TakeOp, plan.SampleOp, plan.AsOp:
+		return s, nil
+	case plan.OrderByOp:
+		if _, err := b.column(s, o.Column); err != nil {
+			return nil, err
+		}
+		return s, nil
+	case plan.TopOp:
+		if _, err := b.column(s, o.Column); err != nil {
+			return nil, err
+		}
+		return s, nil
+	case plan.SampleDistinctOp:
+		t, err := b.column(s, o.Column)
+		if err != nil {
+			return nil, err
+		}
+		return schemaOf([]model.Column{{Name: o.Column, Type: t}}), nil
+	case plan.SummarizeOp:
+		return b.summarize(s, o)
+	case plan.JoinOp:
+		return b.join(s, o)
+	case plan.ParseOp:
+		if _, err := b.exprType(s, o.Source); err != nil {
+			return nil, err
+		}
+		cols := append([]model.Column(nil), s.Columns...)
+		for _, p := range o.Pattern {
+			if p.Column == "" {
+				continue
+			}
+			cols = setColumn(cols, s, model.Column{Name: p.Column, Type: p.ColType})
+		}
+		return schemaOf(cols), nil
+	case plan.MvExpandOp:
+		return b.expand(s, o.Columns, o.IndexName)
+	case plan.MvApplyOp:
+		expanded, err := b.expand(s, o.Columns, o.IndexName)
+		if err != nil {
+			return nil, err
+		}
+		subs, err := b.bindOps(expanded, o.Subquery, nil)
+		if err != nil {
+			return nil, err
+		}
+		sub := expanded
+		if len(subs) > 0 {
+			sub = subs[len(subs)-1]
+		}
+		if sub == nil {
+			return nil, nil
+		}
+		skip := map[string]bool{}
+		for _, c := range o.Columns {
+			skip[c.Name] = true
+		}
+		var cols []model.Column
+		for _, c := range s.Columns {
+			if _, produced := sub.Index[c.Name]; !skip[c.Name] && !produced {
+				cols = append(cols, c)
+			}
+		}
+		return schemaOf(append(cols, sub.Columns...)), nil
+	case plan.MakeSeriesOp:
+		return b.makeSeries(s, o)
+	case plan.GetSchemaOp:
+		return schemaOf([]model.Column{
+			{Name: "ColumnName", Type: model.TypeString},
+			{Name: "ColumnOrdinal", Type: model.TypeInt},
+			{Name: "DataType", Type: model.TypeString},
+		}), nil
+	case plan.EvaluateOp:
+		for _, a := range o.Args {
+			if _, err := b.exprType(s, a); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	case plan.ForkOp:
+		for _, br := range o.Branches {
+			if _, err := b.bindOps(s, br.Ops, nil); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	default:
+		return nil, nil
+	}
+}
+
+func (b *binder) extend(s *model.Schema, o plan.ExtendOp) (*model.Schema, error) {
+	t, err := b.exprType(s, o.Value)
+	if err != nil {
+		return nil, err
+	}
+	cols := append([]model.Column(nil), s.Columns...)
+	return schemaOf(append(cols, model.Column{Name: o.Name, Type: t})), nil
+}
+
+func (b *binder) summarize(s *model.Schema, o plan.SummarizeOp) (*model.Schema, error) {
+	var cols []model.Column
+	for i, name := range o.ByColumns {
+		var t model.Type
+		var err error
+		if o.Keys != nil {
+			t, err = b.exprType(s, o.Keys[i])
+		} else {
+			t, err = b.column(s, name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		cols = append(cols, model.Column{Name: name, Type: t})
+	}
+	aggs := o.Aggregates
+	if len(aggs) == 0 {
+		aggs = []plan.Aggregate{{Name: "count", Func: "count"}}
+	}
+	for _, a := range aggs {
+		args, err := b.argTypes(s, a.Args)
+		if err != nil {
+			return nil, err
+		}
+		cols = append(cols, model.Column{Name: a.Name, Type: aggregateType(a.Func, args)})
+	}
+	return schemaOf(cols), nil
+}
+
+func (b *binder) join(s *model.Schema, o plan.JoinOp) (*model.Schema, error) {
+	lt, err := b.column(s, o.LeftKey)
+	if err != nil {
+		return nil, err
+	}
+	if b.tables == nil {
+		return nil, nil
+	}
+	right, ok := b.tables(o.Right)
+	if !ok {
+		return nil, nil
+	}
+	if len(o.RightOps) > 0 {
+		outs, err := b.bindOps(&right, o.RightOps, nil)
+		if err != nil {
+			return nil, err
+		}
+		if outs[len(outs)-1] == nil {
+			return nil, nil
+		}
+		right = *outs[len(outs)-1]
+	}
+	rt, err := b.column(&right, o.RightKey)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := model.CompareType(lt, rt); !ok {
+		return nil, &Error{Pos: b.find(o.LeftKey), Msg: fmt.Sprintf("cannot join %s key %s with %s key %s", lt, o.LeftKey, rt, o.RightKey)}
+	}
+	cols := append([]model.Column(nil), s.Columns...)
+	for _, c := range right.Columns {
+		if _, clash := s.Index[c.Name]; clash {
+			c.Name = "right." + c.Name
+		}
+		cols = append(cols, c)
+	}
+	return schemaOf(cols), nil
+}
+
+// expand returns the schema of mv-expand: the expanded columns replace or
+// follow the input columns, then comes the optional index column.
+func (b *binder) expand(s *model.Schema, columns []plan.ExpandColumn, indexName string) (*model.Schema, error) {
+	cols := append([]model.Column(nil), s.Columns...)
+	for _, c := range columns {
+		if _, err := b.exprType(s, c.Value); err != nil {
+			return nil, err
+		}
+		t := c.ToType
+		if t == "" {
+			t = model.TypeDynamic
+		}
+		cols = setColumn(cols, s, model.Column{Name: c.Name, Type: t})
+	}
+	if indexName != "" {
+		cols = append(cols, model.Column{Name: indexName, Type: model.TypeInt})
+	}
+	return schemaOf(cols), nil
+}
+
+func (b *binder) makeSeries(s *model.Schema, o plan.MakeSeriesOp) (*model.Schema, error) {
+	if _, err := b.column(s, o.On); err != nil {
+		return nil, err
+	}
+	for _, e := range []plan.Expr{o.From, o.To, o.Step} {
+		if e == nil {
+			continue
+		}
+		if _, err := b.exprType(s, e); err != nil {
+			return nil, err
+		}
+	}
+	var cols []model.Column
+	for _, name := range o.ByColumns {
+		t, err := b.column(s, name)
+		if err != nil {
+			return nil, err
+		}
+		cols = append(cols, model.Column{Name: name, Type: t})
+	}
+	for _, a := range o.Aggregates {
+		if _, err := b.argTypes(s, a.Args); err != nil {
+			return nil, err
+		}
+		cols = append(cols, model.Column{Name: a.Name, Type: model.TypeDynamic})
+	}
+	return schemaOf(append(cols, model.Column{Name: o.On, Type: model.TypeDynamic})), nil
+}
+
+// column resolves a column name against s.
+func (b *binder) column(s *model.Schema, name string) (model.Type, error) {
+	if i, ok := s.Index[name]; ok {
+		return s.Columns[i].Type, nil
+	}
+	return "", b.unknownColumn(s, name)
+}
+
+func (b *binder) unknownColumn(s *model.Schema, name string) error {
+	names := make([]string, len(s.Columns))
+	for i, c := range s.Columns {
+		names[i] = c.Name
+	}
+	suggestion := closest(name, names)
+	if root, rest, ok := strings.Cut(name, "."); ok && suggestion == "" {
+		if c := closest(root, names); c != "" {
+			suggestion = c + "." + rest
+		}
+	}
+	return &Error{Pos: b.find(name), Msg: fmt.Sprintf("unknown column %q", name), Suggestion: suggestion}
+}
+
+func schemaOf(cols []model.Column) *model.Schema {
+	s := model.NewSchema(cols)
+	return &s
+}
+
+// setColumn replaces the column of c's name in cols, which starts with the
+// columns of s, or appends c.
+func setColumn(cols []model.Column, s *model.Schema, c model.Column) []model.Column {
+	if i, ok := s.Index[c.Name]; ok {
+		cols[i] = c
+		return cols
+	}
+	for i := len(s.Columns); i < len(cols); i++ {
+		if cols[i].Name == c.Name {
+			cols[i] = c
+			return cols
+		}
+	}
+	return append(cols, c)
+}
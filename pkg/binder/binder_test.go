@@ -0,0 +1,150 @@
+package binder
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/parser"
+)
+
+var people = model.NewSchema([]model.Column{
+	{Name: "name", Type: model.TypeString},
+	{Name: "age", Type: model.TypeInt},
+	{Name: "score", Type: model.TypeFloat},
+	{Name: "ts", Type: model.TypeDateTime},
+	{Name: "props", Type: model.TypeDynamic},
+})
+
+func bind(t *testing.T, query string, tables Tables) ([]*model.Schema, error) {
+	t.Helper()
+	ops, err := parser.Parse(query)
+	if err != nil {
+		t.Fatalf("parse %s: %v", query, err)
+	}
+	return Bind(query, &people, ops, tables)
+}
+
+func columns(s *model.Schema) string {
+	parts := make([]string, len(s.Columns))
+	for i, c := range s.Columns {
+		parts[i] = c.Name + ":" + string(c.Type)
+	}
+	return strings.Join(parts, ",")
+}
+
+func TestBindInfersSchemas(t *testing.T) {
+	schemas, err := bind(t, "T | where age > 30 | extend half = age / 2 | extend late = ts + 1h | extend region = props.user.region | summarize n = count(), avg(score), max(age), percentile(score, 50) by name | project name, n, max_age", nil)
+	if err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	if len(schemas) != 6 {
+		t.Fatalf("expected a schema per operator, got %d", len(schemas))
+	}
+	if got := columns(schemas[3]); !strings.HasSuffix(got, "half:int,late:datetime,region:dynamic") {
+		t.Fatalf("unexpected extend schema: %s", got)
+	}
+	if got := columns(schemas[4]); got != "name:string,n:int,avg_score:float,max_age:int,percentile_score_50:float" {
+		t.Fatalf("unexpected summarize schema: %s", got)
+	}
+	if got := columns(schemas[5]); got != "name:string,n:int,max_age:int" {
+		t.Fatalf("unexpected project schema: %s", got)
+	}
+}
+
+func TestBindUnknownColumns(t *testing.T) {
+	cases := []struct {
+		query string
+		msg   string
+	}{
+		{"T | where agee > 30", `unknown column "agee" at position 10 (did you mean "age"?)`},
+		{"T | project name, Age", `unknown column "Age" at position 18 (did you mean "age"?)`},
+		{`T | where name == "agee" or agee > 1`, `unknown column "agee" at position 28 (did you mean "age"?)`},
+		{"T | extend x = 1 | summarize count() by y", `unknown column "y" at position 40`},
+		{"T | where prop.user.region == 1", `unknown column "prop.user.region" at position 10 (did you mean "props.user.region"?)`},
+		{"T | order by scor desc", `unknown column "scor" at position 13 (did you mean "score"?)`},
+		{"T | summarize count() by name | where age > 1", `unknown column "age" at position 38`},
+		{"T | mv-apply props on (where nme == 1)", `unknown column "nme" at position 29 (did you mean "name"?)`},
+		{"T | fork (take 1) (project nam)", `unknown column "nam" at position 27 (did you mean "name"?)`},
+	}
+	for _, c := range cases {
+		_, err := bind(t, c.query, nil)
+		var be *Error
+		if !errors.As(err, &be) {
+			t.Fatalf("%s: expected a bind error, got %v", c.query, err)
+		}
+		if err.Error() != c.msg {
+			t.Fatalf("%s: got %q, want %q", c.query, err.Error(), c.msg)
+		}
+	}
+}
+
+func TestBindJoinsAndOpenSchemas(t *testing.T) {
+	depts := model.NewSchema([]model.Column{{Name: "name", Type: model.TypeString}, {Name: "dept", Type: model.TypeString}})
+	tables := func(name string) (model.Schema, bool) {
+		return depts, name == "D"
+	}
+	schemas, err := bind(t, "T | join kind=inner (D) on name == name | project age, right.name, dept", tables)
+	if err != nil {
+		t.Fatalf("bind: %v", err)
+	}
+	if got := columns(schemas[1]); got != "age:int,right.name:string,dept:string" {
+		t.Fatalf("unexpected join schema: %s", got)
+	}
+	if _, err := bind(t, "T | join kind=inner (D) on name == dep", tables); err == nil || !strings.Contains(err.Error(), `did you mean "dept"`) {
+		t.Fatalf("expected right key error, got %v", err)
+	}
+	// Columns after an unresolved join or a plugin depend on the data.
+	for _, q := range []string{"T | join kind=inner (other.csv) on name == x | where anything > 1", "T | evaluate bag_unpack(props) | where anything > 1"} {
+		schemas, err := bind(t, q, tables)
+		if err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+		if schemas[0] != nil {
+			t.Fatalf("%s: expected an open schema", q)
+		}
+	}
+	if _, err := bind(t, "T | evaluate pivot(cty)", nil); err == nil {
+		t.Fatalf("expected plugin argument error")
+	}
+}
+
+func TestClosest(t *testing.T) {
+	names := []string{"name", "age", "city", "created_at"}
+	for in, want := range map[string]string{"agee": "age", "NAME": "name", "cty": "city", "createdat": "created_at", "zzz": "", "x": ""} {
+		if got := closest(in, names); got != want {
+			t.Fatalf("closest(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestBindComparisonTypes(t *testing.T) {
+	for _, q := range []string{
+		"T | where age > 30.5",
+		`T | where ts > "2024-01-01"`,
+		`T | where ts - ts < "01:00:00"`,
+		"T | where props.n == 1",
+		`T | where name has "x"`,
+	} {
+		if _, err := bind(t, q, nil); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+	cases := map[string]string{
+		`T | where age > "abc"`:           `cannot compare int with string at position 10`,
+		`T | where name == 1`:             `cannot compare string with int at position 10`,
+		`T | where ts > "yesterday"`:      `invalid datetime literal "yesterday" at position 15`,
+		`T | where 1 < 2 and score > "1"`: `cannot compare float with string at position 20`,
+	}
+	for q, msg := range cases {
+		if _, err := bind(t, q, nil); err == nil || err.Error() != msg {
+			t.Fatalf("%s: got %v, want %s", q, err, msg)
+		}
+	}
+	codes := model.NewSchema([]model.Column{{Name: "code", Type: model.TypeString}})
+	_, err := bind(t, "T | join kind=inner (C) on age == code", func(string) (model.Schema, bool) { return codes, true })
+	if err == nil || err.Error() != "cannot join int key age with string key code at position 27" {
+		t.Fatalf("unexpected join error: %v", err)
+	}
+}
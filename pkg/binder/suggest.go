@@ -0,0 +1,102 @@
+package binder
+
+import (
+	"strings"
+
+	"kqlfile/pkg/plan"
+)
+
+// find returns the offset of the first reference to name in the text of the
+// operator being bound, skipping string literals, or the start of the
+// operator when there is none.
+func (b *binder) find(name string) int {
+	text := b.query[:b.end]
+	for i := b.start; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '"' || c == '\'':
+			i = skipString(text, i)
+		case strings.HasPrefix(text[i:], name):
+			end := i + len(name)
+			if (i == 0 || !isIdentByte(text[i-1])) && (end == len(text) || !isIdentByte(text[end])) {
+				return i
+			}
+		}
+	}
+	return b.start
+}
+
+// exprPos returns the offset of the first column referenced by exprs, or the
+// start of the operator.
+func (b *binder) exprPos(exprs ...plan.Expr) int {
+	for _, e := range exprs {
+		if name := rootName(e); name != "" {
+			return b.find(name)
+		}
+	}
+	return b.start
+}
+
+// findLiteral returns the offset of the string literal holding s in the
+// operator being bound, or the start of the operator.
+func (b *binder) findLiteral(s string) int {
+	text := b.query[:b.end]
+	for _, quoted := range []string{`"` + s + `"`, `'` + s + `'`} {
+		if i := strings.Index(text[b.start:], quoted); i >= 0 {
+			return b.start + i
+		}
+	}
+	return b.start
+}
+
+// skipString returns the offset of the quote closing the string that starts
+// at i.
+func skipString(s string, i int) int {
+	quote := s[i]
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case quote:
+			return j
+		}
+	}
+	return len(s)
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// closest returns the candidate nearest to name by edit distance, ignoring
+// case, or "" when none is close enough to be a likely typo.
+func closest(name string, candidates []string) string {
+	best, bestDist := "", len(name)/3
+	lower := strings.ToLower(name)
+	for _, c := range candidates {
+		if d := editDistance(lower, strings.ToLower(c)); d <= bestDist && (best == "" || d < bestDist) {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
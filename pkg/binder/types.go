@@ -0,0 +1,251 @@
+package binder
+
+import (
+	"fmt"
+
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+// callTypes holds the result type of functions whose result does not depend
+// on their arguments. Functions missing here produce dynamic values.
+var callTypes = map[string]model.Type{
+	"array_length":           model.TypeInt,
+	"bag_has_key":            model.TypeBool,
+	"now":                    model.TypeDateTime,
+	"ago":                    model.TypeDateTime,
+	"todatetime":             model.TypeDateTime,
+	"totimespan":             model.TypeTimespan,
+	"ipv4_is_in_range":       model.TypeBool,
+	"ipv4_is_private":        model.TypeBool,
+	"ipv4_is_match":          model.TypeBool,
+	"ipv4_compare":           model.TypeInt,
+	"ipv6_compare":           model.TypeInt,
+	"parse_ipv4":             model.TypeInt,
+	"format_ipv4_mask":       model.TypeString,
+	"has_any_ipv4_prefix":    model.TypeBool,
+	"url_decode":             model.TypeString,
+	"url_encode":             model.TypeString,
+	"base64_encode_tostring": model.TypeString,
+	"base64_decode_tostring": model.TypeString,
+	"hash_sha256":            model.TypeString,
+	"hash_md5":               model.TypeString,
+	"hash":                   model.TypeInt,
+	"geo_distance_2points":   model.TypeFloat,
+	"geo_point_in_circle":    model.TypeBool,
+	"geo_point_in_polygon":   model.TypeBool,
+	"geo_point_to_geohash":   model.TypeString,
+	"dcount_hll":             model.TypeInt,
+	"percentile_tdigest":     model.TypeFloat,
+	"row_number":             model.TypeInt,
+	"row_window_session":     model.TypeDateTime,
+}
+
+// exprType checks the column references of e against s and returns the type
+// of its value.
+func (b *binder) exprType(s *model.Schema, e plan.Expr) (model.Type, error) {
+	switch e := e.(type) {
+	case plan.ColumnRef:
+		return b.column(s, e.Name)
+	case plan.Literal:
+		return e.Value.Type, nil
+	case plan.CompareExpr:
+		args, err := b.argTypes(s, []plan.Expr{e.Left, e.Right})
+		if err != nil {
+			return "", err
+		}
+		if comparisonOps[e.Op] {
+			if err := b.checkComparison(e.Left, args[0], e.Right, args[1]); err != nil {
+				return "", err
+			}
+		}
+		return model.TypeBool, nil
+	case plan.LogicalExpr:
+		if _, err := b.argTypes(s, []plan.Expr{e.Left, e.Right}); err != nil {
+			return "", err
+		}
+		return model.TypeBool, nil
+	case plan.BinaryExpr:
+		args, err := b.argTypes(s, []plan.Expr{e.Left, e.Right})
+		if err != nil {
+			return "", err
+		}
+		return arithType(e.Op, args[0], args[1]), nil
+	case plan.MemberExpr:
+		// Flattened columns such as "a.b" take precedence over members.
+		if name, ok := dottedName(e); ok {
+			if i, ok := s.Index[name]; ok {
+				return s.Columns[i].Type, nil
+			}
+			if root := rootName(e); root != "" {
+				if _, ok := s.Index[root]; !ok {
+					return "", b.unknownColumn(s, name)
+				}
+			}
+		}
+		if _, err := b.exprType(s, e.Target); err != nil {
+			return "", err
+		}
+		return model.TypeDynamic, nil
+	case plan.IndexExpr:
+		if _, err := b.argTypes(s, []plan.Expr{e.Target, e.Index}); err != nil {
+			return "", err
+		}
+		return model.TypeDynamic, nil
+	case plan.CallExpr:
+		args, err := b.argTypes(s, e.Args)
+		if err != nil {
+			return "", err
+		}
+		return callType(e.Name, args), nil
+	case plan.SearchTerm:
+		if e.Column != "" {
+			if _, err := b.column(s, e.Column); err != nil {
+				return "", err
+			}
+		}
+		return model.TypeBool, nil
+	default:
+		return model.TypeDynamic, nil
+	}
+}
+
+// comparisonOps are the operators that follow the comparison matrix; the
+// string operators such as has and contains compare text.
+var comparisonOps = map[string]bool{"==": true, "=": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+// checkComparison rejects comparisons that model.CompareType does not allow
+// and string literals that do not parse as the datetime or timespan they are
+// compared with.
+func (b *binder) checkComparison(l plan.Expr, lt model.Type, r plan.Expr, rt model.Type) error {
+	t, ok := model.CompareType(lt, rt)
+	if !ok {
+		return &Error{Pos: b.exprPos(l, r), Msg: fmt.Sprintf("cannot compare %s with %s", lt, rt)}
+	}
+	if t != model.TypeDateTime && t != model.TypeTimespan {
+		return nil
+	}
+	for _, e := range []plan.Expr{l, r} {
+		lit, isLit := e.(plan.Literal)
+		if !isLit || lit.Value.Type != model.TypeString {
+			continue
+		}
+		raw := lit.Value.String()
+		valid := false
+		if t == model.TypeDateTime {
+			_, valid = model.ParseDateTime(raw)
+		} else {
+			_, err := model.ParseTimespan(raw)
+			valid = err == nil
+		}
+		if !valid {
+			return &Error{Pos: b.findLiteral(raw), Msg: fmt.Sprintf("invalid %s literal %q", t, raw)}
+		}
+	}
+	return nil
+}
+
+func (b *binder) argTypes(s *model.Schema, args []plan.Expr) ([]model.Type, error) {
+	types := make([]model.Type, len(args))
+	for i, a := range args {
+		t, err := b.exprType(s, a)
+		if err != nil {
+			return nil, err
+		}
+		types[i] = t
+	}
+	return types, nil
+}
+
+func callType(name string, args []model.Type) model.Type {
+	if t, ok := callTypes[name]; ok {
+		return t
+	}
+	switch name {
+	case "bin", "prev", "next", "row_cumsum":
+		if len(args) > 0 {
+			return args[0]
+		}
+	case "extract":
+		if len(args) < 4 {
+			return model.TypeString
+		}
+	}
+	return model.TypeDynamic
+}
+
+// aggregateType returns the type of a summarize aggregation over arguments
+// of the given types.
+func aggregateType(fn string, args []model.Type) model.Type {
+	switch fn {
+	case "count", "dcount":
+		return model.TypeInt
+	case "avg", "percentile":
+		return model.TypeFloat
+	case "sum", "min", "max":
+		if len(args) > 0 {
+			return args[0]
+		}
+	}
+	return model.TypeDynamic
+}
+
+// arithType mirrors the arithmetic rules of the executor: integers stay
+// integral, mixed numbers widen to float, and datetime/timespan follow the
+// calendar rules. Other combinations evaluate to null.
+func arithType(op string, l, r model.Type) model.Type {
+	switch {
+	case l == model.TypeInt && r == model.TypeInt:
+		return model.TypeInt
+	case isNumeric(l) && isNumeric(r):
+		return model.TypeFloat
+	case l == model.TypeDateTime && r == model.TypeDateTime && op == "-":
+		return model.TypeTimespan
+	case l == model.TypeDateTime && r == model.TypeTimespan && (op == "+" || op == "-"):
+		return model.TypeDateTime
+	case l == model.TypeTimespan && r == model.TypeDateTime && op == "+":
+		return model.TypeDateTime
+	case l == model.TypeTimespan && r == model.TypeTimespan:
+		if op == "/" {
+			return model.TypeFloat
+		}
+		if op != "*" {
+			return model.TypeTimespan
+		}
+	case l == model.TypeTimespan && isNumeric(r) && (op == "*" || op == "/"):
+		return model.TypeTimespan
+	case isNumeric(l) && r == model.TypeTimespan && op == "*":
+		return model.TypeTimespan
+	}
+	return model.TypeDynamic
+}
+
+func isNumeric(t model.Type) bool {
+	return t == model.TypeInt || t == model.TypeFloat
+}
+
+func dottedName(expr plan.Expr) (string, bool) {
+	switch e := expr.(type) {
+	case plan.ColumnRef:
+		return e.Name, true
+	case plan.MemberExpr:
+		prefix, ok := dottedName(e.Target)
+		if !ok {
+			return "", false
+		}
+		return prefix + "." + e.Name, true
+	default:
+		return "", false
+	}
+}
+
+func rootName(expr plan.Expr) string {
+	switch e := expr.(type) {
+	case plan.ColumnRef:
+		return e.Name
+	case plan.MemberExpr:
+		return rootName(e.Target)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,182 @@
+package csvio
+
+import (
+	"time"
+
+	"kqlfile/pkg/model"
+)
+
+// BatchSize is the number of rows readers put in a batch.
+const BatchSize = 2048
+
+// Vector holds the values of one column of a batch. Columns of type int,
+// float, string, bool and datetime keep their values unboxed in the slice of
+// that type. Other columns, and typed columns once a value of another type
+// or a null is appended, keep them in Values.
+type Vector struct {
+	Type    model.Type
+	Ints    []int64
+	Floats  []float64
+	Strings []string
+	Bools   []bool
+	Times   []time.Time
+	// Values is not nil when the vector holds boxed values.
+	Values []model.Value
+}
+
+// NewVector returns an empty vector of type t with room for n values.
+func NewVector(t model.Type, n int) *Vector {
+	v := &Vector{Type: t}
+	switch t {
+	case model.TypeInt:
+		v.Ints = make([]int64, 0, n)
+	case model.TypeFloat:
+		v.Floats = make([]float64, 0, n)
+	case model.TypeString:
+		v.Strings = make([]string, 0, n)
+	case model.TypeBool:
+		v.Bools = make([]bool, 0, n)
+	case model.TypeDateTime:
+		v.Times = make([]time.Time, 0, n)
+	default:
+		v.Values = make([]model.Value, 0, n)
+	}
+	return v
+}
+
+// Boxed reports whether the values are kept in Values.
+func (v *Vector) Boxed() bool {
+	return v.Values != nil
+}
+
+// Len returns the number of values.
+func (v *Vector) Len() int {
+	switch {
+	case v.Values != nil:
+		return len(v.Values)
+	case v.Type == model.TypeInt:
+		return len(v.Ints)
+	case v.Type == model.TypeFloat:
+		return len(v.Floats)
+	case v.Type == model.TypeString:
+		return len(v.Strings)
+	case v.Type == model.TypeBool:
+		return len(v.Bools)
+	default:
+		return len(v.Times)
+	}
+}
+
+// Value returns the value at position i.
+func (v *Vector) Value(i int) model.Value {
+	switch {
+	case v.Values != nil:
+		return v.Values[i]
+	case v.Type == model.TypeInt:
+		return model.NewInt(v.Ints[i])
+	case v.Type == model.TypeFloat:
+		return model.NewFloat(v.Floats[i])
+	case v.Type == model.TypeString:
+		return model.NewString(v.Strings[i])
+	case v.Type == model.TypeBool:
+		return model.NewBool(v.Bools[i])
+	default:
+		return model.NewDateTime(v.Times[i])
+	}
+}
+
+// Append adds x at the end of the vector.
+func (v *Vector) Append(x model.Value) {
+	if v.Values == nil && x.Type == v.Type {
+		switch v.Type {
+		case model.TypeInt:
+			v.Ints = append(v.Ints, x.Int())
+			return
+		case model.TypeFloat:
+			v.Floats = append(v.Floats, x.Float())
+			return
+		case model.TypeString:
+			v.Strings = append(v.Strings, x.String())
+			return
+		case model.TypeBool:
+			v.Bools = append(v.Bools, x.Bool())
+			return
+		case model.TypeDateTime:
+			v.Times = append(v.Times, x.DateTime())
+			return
+		}
+	}
+	if v.Values == nil {
+		v.box()
+	}
+	v.Values = append(v.Values, x)
+}
+
+// box moves the values of a typed vector to Values.
+func (v *Vector) box() {
+	n := v.Len()
+	vals := make([]model.Value, n, n+1)
+	for i := range vals {
+		vals[i] = v.Value(i)
+	}
+	v.Ints, v.Floats, v.Strings, v.Bools, v.Times = nil, nil, nil, nil, nil
+	v.Values = vals
+}
+
+// Batch holds rows sharing one schema, stored by column. When Sel is not
+// nil, only the rows at the positions it lists, in increasing order, are
+// part of the batch; the others were filtered out.
+type Batch struct {
+	Schema  model.Schema
+	Columns []*Vector
+	// Len is the number of values in each column.
+	Len int
+	Sel []int
+}
+
+// NewBatch returns an empty batch of schema s with room for n rows.
+func NewBatch(s model.Schema, n int) *Batch {
+	b := &Batch{Schema: s, Columns: make([]*Vector, len(s.Columns))}
+	for i, c := range s.Columns {
+		b.Columns[i] = NewVector(c.Type, n)
+	}
+	return b
+}
+
+// Rows returns the number of rows in the batch.
+func (b *Batch) Rows() int {
+	if b.Sel != nil {
+		return len(b.Sel)
+	}
+	return b.Len
+}
+
+// Pos returns the position in the columns of row i.
+func (b *Batch) Pos(i int) int {
+	if b.Sel != nil {
+		return b.Sel[i]
+	}
+	return i
+}
+
+// Row returns row i as a row of its own.
+func (b *Batch) Row(i int) *Row {
+	vals := make([]model.Value, len(b.Columns))
+	b.Load(vals, b.Pos(i))
+	return &Row{Schema: b.Schema, Values: vals}
+}
+
+// Load copies the values at position pos into vals.
+func (b *Batch) Load(vals []model.Value, pos int) {
+	for j, c := range b.Columns {
+		vals[j] = c.Value(pos)
+	}
+}
+
+// AppendRow adds a row with the values vals.
+func (b *Batch) AppendRow(vals []model.Value) {
+	for j, c := range b.Columns {
+		c.Append(vals[j])
+	}
+	b.Len++
+}
@@ -0,0 +1,115 @@
+package csvio
+
+import "kqlfile/pkg/model"
+
+// Filter drops records while they are read, before their row is built.
+// Keep sees a row in which only the columns named in Columns hold values.
+type Filter struct {
+	Columns []string
+	Keep    func(*Row) (bool, error)
+}
+
+// WithFilter makes a reader return only the rows f keeps. The columns the
+// filter does not read are parsed only for those rows.
+func WithFilter(f Filter) Option {
+	return func(o *Options) {
+		o.Filter = &f
+	}
+}
+
+// RowBuilder builds the rows of a reader from the values of their columns,
+// parsing the columns of its filter first and the others only for the rows
+// the filter keeps. Readers share it so that every input format filters the
+// same way.
+type RowBuilder struct {
+	schema model.Schema
+	filter *Filter
+	// probe is the row handed to the filter. Its values are reused from one
+	// record to the next; only the filter columns are set.
+	probe    Row
+	filtered []bool
+	first    []int
+	// row holds the values of the row Append is adding.
+	row []model.Value
+}
+
+// NewRowBuilder returns a builder of rows of schema s, filtered by f when it
+// is not nil.
+func NewRowBuilder(s model.Schema, f *Filter) *RowBuilder {
+	b := &RowBuilder{schema: s, filter: f}
+	if f == nil {
+		return b
+	}
+	b.probe = Row{Schema: s, Values: make([]model.Value, len(s.Columns))}
+	b.filtered = make([]bool, len(s.Columns))
+	for _, name := range f.Columns {
+		if j, ok := s.Index[name]; ok && !b.filtered[j] {
+			b.filtered[j] = true
+			b.first = append(b.first, j)
+		}
+	}
+	return b
+}
+
+// Build returns the row whose column j has the value parse(j), or nil when
+// the filter drops it.
+func (b *RowBuilder) Build(parse func(j int) (model.Value, error)) (*Row, error) {
+	if keep, err := b.keep(parse); err != nil || !keep {
+		return nil, err
+	}
+	vals := make([]model.Value, 0, len(b.schema.Columns))
+	for j := range b.schema.Columns {
+		v, err := b.value(parse, j)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, v)
+	}
+	return &Row{Schema: b.schema, Values: vals}, nil
+}
+
+// Append adds the row whose column j has the value parse(j) to batch,
+// unless the filter drops it, and reports whether it did. The batch must
+// have the schema of the builder.
+func (b *RowBuilder) Append(batch *Batch, parse func(j int) (model.Value, error)) (bool, error) {
+	if keep, err := b.keep(parse); err != nil || !keep {
+		return false, err
+	}
+	// Parse the whole row before appending, so that an error leaves the
+	// columns of the batch the same length.
+	if cap(b.row) < len(b.schema.Columns) {
+		b.row = make([]model.Value, len(b.schema.Columns))
+	}
+	for j := range b.schema.Columns {
+		v, err := b.value(parse, j)
+		if err != nil {
+			return false, err
+		}
+		b.row[j] = v
+	}
+	batch.AppendRow(b.row)
+	return true, nil
+}
+
+// keep parses the filter columns and runs the filter.
+func (b *RowBuilder) keep(parse func(j int) (model.Value, error)) (bool, error) {
+	if b.filter == nil {
+		return true, nil
+	}
+	for _, j := range b.first {
+		v, err := parse(j)
+		if err != nil {
+			return false, err
+		}
+		b.probe.Values[j] = v
+	}
+	return b.filter.Keep(&b.probe)
+}
+
+// value returns the value of column j of a kept row.
+func (b *RowBuilder) value(parse func(j int) (model.Value, error), j int) (model.Value, error) {
+	if b.filter != nil && b.filtered[j] {
+		return b.probe.Values[j], nil
+	}
+	return parse(j)
+}
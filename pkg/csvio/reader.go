@@ -24,15 +24,69 @@ func (r Row) Get(col string) (model.Value, bool) {
 	return r.Values[idx], true
 }
 
+// Options are the settings shared by the input readers.
+type Options struct {
+	// Columns, when not nil, lists the only columns to read. Rows and the
+	// reader's schema keep these columns in file order; the others are
+	// skipped without being parsed, so malformed values in them are ignored.
+	Columns []string
+	// Filter, when not nil, drops rows as they are read; see WithFilter.
+	Filter *Filter
+}
+
+// Option sets one of the Options of a reader.
+type Option func(*Options)
+
+// WithColumns makes a reader return only the named columns. Names that are
+// not columns of the input are ignored.
+func WithColumns(names ...string) Option {
+	return func(o *Options) {
+		o.Columns = append([]string{}, names...)
+	}
+}
+
+// NewOptions returns the Options set by opts.
+func NewOptions(opts ...Option) Options {
+	var o Options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// ReadStats counts the work done by a reader.
+type ReadStats struct {
+	Path   string
+	Format string
+	// Records is the number of records decoded, including those a filter
+	// dropped.
+	Records int64
+	// Rows is the number of rows returned.
+	Rows        int64
+	ParseErrors int64
+	Bytes       int64
+}
+
 type Reader struct {
-	file     *os.File
-	csvr     *csv.Reader
-	schema   model.Schema
+	file   *os.File
+	csvr   *csv.Reader
+	schema model.Schema
+	// fields holds the file position of each schema column when only some
+	// columns are read.
+	fields   []int
+	builder  *RowBuilder
+	rec      []string
+	parse    func(j int) (model.Value, error)
 	buffer   [][]string
 	bufferIx int
+	stats    ReadStats
+	// err is the error that ended the last batch.
+	err error
 }
 
-func NewReader(path string, schema *model.Schema) (*Reader, error) {
+// NewReader opens the CSV file at path. Without a schema the column types
+// are inferred from the first rows.
+func NewReader(path string, schema *model.Schema, opts ...Option) (*Reader, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -77,7 +131,34 @@ func NewReader(path string, schema *model.Schema) (*Reader, error) {
 		sch = model.NewSchema(inferred)
 	}
 
-	return &Reader{file: f, csvr: csvr, schema: sch, buffer: buffer}, nil
+	o := NewOptions(opts...)
+	r := &Reader{file: f, csvr: csvr, buffer: buffer, stats: ReadStats{Path: path, Format: "csv"}}
+	r.schema, r.fields = Select(sch, o.Columns)
+	r.builder = NewRowBuilder(r.schema, o.Filter)
+	r.parse = func(j int) (model.Value, error) { return r.parseField(r.rec, j) }
+	return r, nil
+}
+
+// Select returns the schema of the columns of s named in names, in the
+// order of s, along with their positions in s. A nil names selects every
+// column and returns nil positions.
+func Select(s model.Schema, names []string) (model.Schema, []int) {
+	if names == nil {
+		return s, nil
+	}
+	wanted := map[string]bool{}
+	for _, n := range names {
+		wanted[n] = true
+	}
+	var cols []model.Column
+	fields := []int{}
+	for i, c := range s.Columns {
+		if wanted[c.Name] {
+			cols = append(cols, c)
+			fields = append(fields, i)
+		}
+	}
+	return model.NewSchema(cols), fields
 }
 
 func (r *Reader) Schema() model.Schema {
@@ -88,31 +169,88 @@ func (r *Reader) Close() error {
 	return r.file.Close()
 }
 
-func (r *Reader) Next() (*Row, error) {
-	if r.bufferIx < len(r.buffer) {
-		rec := r.buffer[r.bufferIx]
-		r.bufferIx++
-		return r.parseRecord(rec)
+// Stats returns what the reader has read so far.
+func (r *Reader) Stats() ReadStats {
+	s := r.stats
+	s.Bytes = r.csvr.InputOffset()
+	return s
+}
+
+// NextBatch returns the next rows, up to BatchSize of them. A batch is cut
+// short by an error, which is returned by the following call.
+func (r *Reader) NextBatch() (*Batch, error) {
+	if r.err != nil {
+		return nil, r.err
 	}
-	rec, err := r.csvr.Read()
-	if err != nil {
-		return nil, err
+	b := NewBatch(r.schema, BatchSize)
+	for b.Len < BatchSize {
+		rec, err := r.record()
+		if err == nil {
+			r.stats.Records++
+			r.rec = rec
+			var kept bool
+			if kept, err = r.builder.Append(b, r.parse); kept {
+				r.stats.Rows++
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				r.stats.ParseErrors++
+			}
+			r.err = err
+			break
+		}
 	}
-	return r.parseRecord(rec)
+	if b.Len == 0 {
+		return nil, r.err
+	}
+	return b, nil
 }
 
-func (r *Reader) parseRecord(rec []string) (*Row, error) {
-	vals := make([]model.Value, len(r.schema.Columns))
-	for i, col := range r.schema.Columns {
-		if i >= len(rec) {
-			vals[i] = model.Value{Type: col.Type, V: ""}
-			continue
+func (r *Reader) Next() (*Row, error) {
+	for {
+		rec, err := r.record()
+		if err != nil {
+			if err != io.EOF {
+				r.stats.ParseErrors++
+			}
+			return nil, err
 		}
-		v, err := model.ParseValue(col.Type, rec[i])
+		r.stats.Records++
+		r.rec = rec
+		row, err := r.builder.Build(r.parse)
 		if err != nil {
-			return nil, fmt.Errorf("parse column %s: %w", col.Name, err)
+			r.stats.ParseErrors++
+			return nil, err
+		}
+		if row != nil {
+			r.stats.Rows++
+			return row, nil
 		}
-		vals[i] = v
 	}
-	return &Row{Schema: r.schema, Values: vals}, nil
+}
+
+func (r *Reader) record() ([]string, error) {
+	if r.bufferIx < len(r.buffer) {
+		r.bufferIx++
+		return r.buffer[r.bufferIx-1], nil
+	}
+	return r.csvr.Read()
+}
+
+// parseField parses the value of schema column j from rec.
+func (r *Reader) parseField(rec []string, j int) (model.Value, error) {
+	col := r.schema.Columns[j]
+	i := j
+	if r.fields != nil {
+		i = r.fields[j]
+	}
+	if i >= len(rec) {
+		return model.NullOf(col.Type), nil
+	}
+	v, err := model.ParseValue(col.Type, rec[i])
+	if err != nil {
+		return model.Value{}, fmt.Errorf("parse column %s: %w", col.Name, err)
+	}
+	return v, nil
 }
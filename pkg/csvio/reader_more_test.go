@@ -1,8 +1,10 @@
 package csvio
 
 import (
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"kqlfile/pkg/model"
@@ -61,7 +63,7 @@ func TestRowGetIndexTooLarge(t *testing.T) {
 func TestRowGetSuccess(t *testing.T) {
 	row := &Row{
 		Schema: model.NewSchema([]model.Column{{Name: "a", Type: model.TypeString}}),
-		Values: []model.Value{{Type: model.TypeString, V: "x"}},
+		Values: []model.Value{model.NewString("x")},
 	}
 	if v, ok := row.Get("a"); !ok || v.String() != "x" {
 		t.Fatalf("expected value")
@@ -89,18 +91,26 @@ func TestReaderBuffer(t *testing.T) {
 	}
 }
 
-func TestParseRecordMissingColumn(t *testing.T) {
+func TestReaderMissingColumn(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "short.csv")
+	if err := os.WriteFile(path, []byte("a\n1\n"), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
 	sch := model.NewSchema([]model.Column{
 		{Name: "a", Type: model.TypeInt},
 		{Name: "b", Type: model.TypeString},
 	})
-	r := &Reader{schema: sch}
-	row, err := r.parseRecord([]string{"1"})
+	reader, err := NewReader(path, &sch)
 	if err != nil {
-		t.Fatalf("parse: %v", err)
+		t.Fatalf("reader: %v", err)
 	}
-	if row.Values[1].String() != "" {
-		t.Fatalf("expected empty missing column")
+	defer reader.Close()
+	row, err := reader.Next()
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+	if row.Values[0].Int() != 1 || row.Values[1].String() != "" {
+		t.Fatalf("expected empty missing column, got %v", row.Values)
 	}
 }
 
@@ -147,3 +157,155 @@ func TestReaderEOF(t *testing.T) {
 		t.Fatalf("expected EOF")
 	}
 }
+
+func TestReaderWithColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wide.csv")
+	data := "name,age,score,city\nalice,30,1.5,Oslo\nbob,41,oops,Rome\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	sch := model.NewSchema([]model.Column{
+		{Name: "name", Type: model.TypeString},
+		{Name: "age", Type: model.TypeInt},
+		{Name: "score", Type: model.TypeFloat},
+		{Name: "city", Type: model.TypeString},
+	})
+	r, err := NewReader(path, &sch, WithColumns("city", "age", "missing"))
+	if err != nil {
+		t.Fatalf("reader: %v", err)
+	}
+	defer r.Close()
+	if got := r.Schema().Columns; len(got) != 2 || got[0].Name != "age" || got[1].Name != "city" {
+		t.Fatalf("expected age, city in file order, got %v", got)
+	}
+	var got []string
+	for {
+		row, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("the malformed score should not be parsed: %v", err)
+		}
+		got = append(got, row.Values[0].String()+" "+row.Values[1].String())
+	}
+	if strings.Join(got, ",") != "30 Oslo,41 Rome" {
+		t.Fatalf("unexpected rows %v", got)
+	}
+
+	r, err = NewReader(path, &sch, WithColumns())
+	if err != nil {
+		t.Fatalf("reader: %v", err)
+	}
+	defer r.Close()
+	row, err := r.Next()
+	if err != nil || len(row.Values) != 0 || len(r.Schema().Columns) != 0 {
+		t.Fatalf("expected empty rows, got %v, %v", row, err)
+	}
+}
+
+func TestReaderWithFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wide.csv")
+	data := "name,age,score\nalice,30,oops\nbob,41,2.5\ncarol,52,3.5\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	sch := model.NewSchema([]model.Column{
+		{Name: "name", Type: model.TypeString},
+		{Name: "age", Type: model.TypeInt},
+		{Name: "score", Type: model.TypeFloat},
+	})
+	calls := 0
+	keep := func(row *Row) (bool, error) {
+		calls++
+		if v, _ := row.Get("name"); v != (model.Value{}) {
+			t.Fatalf("the filter should only see age, got name %v", v)
+		}
+		age, _ := row.Get("age")
+		return age.Int() > 35, nil
+	}
+	r, err := NewReader(path, &sch, WithFilter(Filter{Columns: []string{"age"}, Keep: keep}))
+	if err != nil {
+		t.Fatalf("reader: %v", err)
+	}
+	defer r.Close()
+	var got []string
+	for {
+		row, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("the score of a dropped row should not be parsed: %v", err)
+		}
+		got = append(got, row.Values[0].String()+" "+row.Values[2].String())
+	}
+	if strings.Join(got, ",") != "bob 2.5,carol 3.5" || calls != 3 {
+		t.Fatalf("unexpected rows %v after %d filter calls", got, calls)
+	}
+	if st := r.Stats(); st.Records != 3 || st.Rows != 2 || st.ParseErrors != 0 || st.Bytes != int64(len(data)) || st.Format != "csv" {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+}
+
+func TestReaderNextBatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "batch.csv")
+	data := "age,name\n1,a\n2,b\nnotint,c\n4,d\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	sch := model.NewSchema([]model.Column{{Name: "age", Type: model.TypeInt}, {Name: "name", Type: model.TypeString}})
+	reader, err := NewReader(path, &sch)
+	if err != nil {
+		t.Fatalf("reader: %v", err)
+	}
+	defer reader.Close()
+	b, err := reader.NextBatch()
+	if err != nil {
+		t.Fatalf("next batch: %v", err)
+	}
+	if b.Rows() != 2 || b.Columns[0].Boxed() || b.Columns[0].Ints[1] != 2 || b.Columns[1].Strings[1] != "b" {
+		t.Fatalf("unexpected batch %+v", b.Columns)
+	}
+	// The parse error cut the batch short and ends the reading.
+	for i := 0; i < 2; i++ {
+		if _, err := reader.NextBatch(); err == nil || err == io.EOF {
+			t.Fatalf("expected parse error, got %v", err)
+		}
+	}
+	if st := reader.Stats(); st.Records != 3 || st.Rows != 2 || st.ParseErrors != 1 {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+}
+
+func TestVectorBoxesOtherValues(t *testing.T) {
+	v := NewVector(model.TypeInt, 4)
+	v.Append(model.NewInt(1))
+	if v.Boxed() {
+		t.Fatalf("int vector boxed")
+	}
+	null := model.Value{Type: model.TypeDynamic}
+	v.Append(null)
+	v.Append(model.NewInt(3))
+	if !v.Boxed() || v.Len() != 3 {
+		t.Fatalf("expected 3 boxed values, got %d", v.Len())
+	}
+	if got := v.Value(0); got.Type != model.TypeInt || got.Int() != 1 {
+		t.Fatalf("value 0 is %+v", got)
+	}
+	if got := v.Value(1); got != null {
+		t.Fatalf("value 1 is %+v", got)
+	}
+}
+
+func TestBatchSelection(t *testing.T) {
+	sch := model.NewSchema([]model.Column{{Name: "s", Type: model.TypeString}})
+	b := NewBatch(sch, 3)
+	for _, s := range []string{"a", "b", "c"} {
+		b.AppendRow([]model.Value{model.NewString(s)})
+	}
+	b.Sel = []int{0, 2}
+	if b.Rows() != 2 || b.Row(1).Values[0].String() != "c" {
+		t.Fatalf("unexpected selection %d %v", b.Rows(), b.Row(1).Values)
+	}
+}
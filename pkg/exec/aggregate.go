@@ -0,0 +1,145 @@
+package exec
+
+import (
+	"fmt"
+
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+// aggregator accumulates the argument values of one aggregation call for a
+// single group (or series bin).
+type aggregator interface {
+	add(args []model.Value) error
+	result() model.Value
+}
+
+type aggFunc struct {
+	minArgs int
+	maxArgs int
+	create  func() aggregator
+	check   func(args []plan.Expr) error
+}
+
+var aggFuncs = map[string]aggFunc{
+	"count": {minArgs: 0, maxArgs: 0, create: func() aggregator { return &countAgg{} }},
+	"sum":   {minArgs: 1, maxArgs: 1, create: func() aggregator { return &sumAgg{} }},
+	"avg":   {minArgs: 1, maxArgs: 1, create: func() aggregator { return &avgAgg{} }},
+	"min":   {minArgs: 1, maxArgs: 1, create: func() aggregator { return &extremeAgg{sign: -1} }},
+	"max":   {minArgs: 1, maxArgs: 1, create: func() aggregator { return &extremeAgg{sign: 1} }},
+
+	"dcount":        {minArgs: 1, maxArgs: 2, create: func() aggregator { return &hllAgg{} }, check: checkAccuracyArg},
+	"hll":           {minArgs: 1, maxArgs: 2, create: func() aggregator { return &hllAgg{sketch: true} }, check: checkAccuracyArg},
+	"hll_merge":     {minArgs: 1, maxArgs: 1, create: func() aggregator { return &hllMergeAgg{} }},
+	"percentile":    {minArgs: 2, maxArgs: 2, create: func() aggregator { return &percentileAgg{} }, check: checkPercentileArgs},
+	"tdigest":       {minArgs: 1, maxArgs: 1, create: func() aggregator { return &percentileAgg{sketch: true} }},
+	"tdigest_merge": {minArgs: 1, maxArgs: 1, create: func() aggregator { return &tdigestMergeAgg{} }},
+}
+
+func checkAggregate(name string, args []plan.Expr) error {
+	fn, ok := aggFuncs[name]
+	if !ok {
+		return fmt.Errorf("unknown aggregation: %s", name)
+	}
+	if len(args) < fn.minArgs || len(args) > fn.maxArgs {
+		return fmt.Errorf("%s: wrong number of arguments (%d)", name, len(args))
+	}
+	for _, a := range args {
+		if err := checkExpr(a); err != nil {
+			return err
+		}
+	}
+	if fn.check != nil {
+		return fn.check(args)
+	}
+	return nil
+}
+
+type countAgg struct {
+	n int64
+}
+
+func (c *countAgg) add([]model.Value) error {
+	c.n++
+	return nil
+}
+
+func (c *countAgg) result() model.Value {
+	return model.NewInt(c.n)
+}
+
+type sumAgg struct {
+	sum model.Value
+}
+
+func (s *sumAgg) add(args []model.Value) error {
+	v := scalarOf(args[0])
+	if v.IsNull() {
+		return nil
+	}
+	if s.sum.Type == "" {
+		s.sum = v
+		return nil
+	}
+	sum, err := arith("+", s.sum, v)
+	if err != nil {
+		return fmt.Errorf("sum: %w", err)
+	}
+	s.sum = sum
+	return nil
+}
+
+func (s *sumAgg) result() model.Value {
+	if s.sum.Type == "" {
+		return dynamicNull
+	}
+	return s.sum
+}
+
+type avgAgg struct {
+	sum float64
+	n   int64
+}
+
+func (a *avgAgg) add(args []model.Value) error {
+	v := scalarOf(args[0])
+	if v.IsNull() {
+		return nil
+	}
+	if !isNumeric(v) {
+		return fmt.Errorf("avg: expected a number, got %s", v.Type)
+	}
+	a.sum += toFloat64(v)
+	a.n++
+	return nil
+}
+
+func (a *avgAgg) result() model.Value {
+	if a.n == 0 {
+		return dynamicNull
+	}
+	return model.NewFloat(a.sum / float64(a.n))
+}
+
+type extremeAgg struct {
+	sign int
+	best model.Value
+}
+
+func (e *extremeAgg) add(args []model.Value) error {
+	v := scalarOf(args[0])
+	if v.IsNull() {
+		return nil
+	}
+	if e.best.Type == "" || compareValues(v, e.best)*e.sign > 0 {
+		e.best = v
+	}
+	return nil
+}
+
+func (e *extremeAgg) result() model.Value {
+	if e.best.Type == "" {
+		return dynamicNull
+	}
+	return e.best
+}
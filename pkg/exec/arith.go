@@ -0,0 +1,154 @@
+package exec
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+func evalBinaryExpr(row *csvio.Row, b plan.BinaryExpr) (model.Value, error) {
+	l, err := evalExpr(row, b.Left)
+	if err != nil {
+		return model.Value{}, err
+	}
+	r, err := evalExpr(row, b.Right)
+	if err != nil {
+		return model.Value{}, err
+	}
+	return arith(b.Op, l, r)
+}
+
+// arith applies a binary arithmetic operator. Integers stay integral, mixed
+// numbers widen to float, and datetime/timespan follow the usual calendar
+// rules. Nulls and division by zero yield null.
+func arith(op string, l, r model.Value) (model.Value, error) {
+	l, r = scalarOf(l), scalarOf(r)
+	if l.IsNull() || r.IsNull() {
+		return dynamicNull, nil
+	}
+	switch {
+	case l.Type == model.TypeInt && r.Type == model.TypeInt:
+		return intArith(op, l.Int(), r.Int())
+	case isNumeric(l) && isNumeric(r):
+		return floatArith(op, toFloat64(l), toFloat64(r))
+	case l.Type == model.TypeDateTime && r.Type == model.TypeDateTime && op == "-":
+		return model.NewTimespan(l.DateTime().Sub(r.DateTime())), nil
+	case l.Type == model.TypeDateTime && r.Type == model.TypeTimespan:
+		switch op {
+		case "+":
+			return model.NewDateTime(l.DateTime().Add(r.Timespan())), nil
+		case "-":
+			return model.NewDateTime(l.DateTime().Add(-r.Timespan())), nil
+		}
+	case l.Type == model.TypeTimespan && r.Type == model.TypeDateTime && op == "+":
+		return model.NewDateTime(r.DateTime().Add(l.Timespan())), nil
+	case l.Type == model.TypeTimespan && r.Type == model.TypeTimespan:
+		a, b := l.Timespan(), r.Timespan()
+		switch op {
+		case "+":
+			return model.NewTimespan(a + b), nil
+		case "-":
+			return model.NewTimespan(a - b), nil
+		case "/":
+			if b == 0 {
+				return dynamicNull, nil
+			}
+			return model.NewFloat(float64(a) / float64(b)), nil
+		case "%":
+			if b == 0 {
+				return dynamicNull, nil
+			}
+			return model.NewTimespan(a % b), nil
+		}
+	case l.Type == model.TypeTimespan && isNumeric(r):
+		d, f := float64(l.Timespan()), toFloat64(r)
+		switch op {
+		case "*":
+			return model.NewTimespan(time.Duration(d * f)), nil
+		case "/":
+			if f == 0 {
+				return dynamicNull, nil
+			}
+			return model.NewTimespan(time.Duration(d / f)), nil
+		}
+	case isNumeric(l) && r.Type == model.TypeTimespan && op == "*":
+		return model.NewTimespan(time.Duration(toFloat64(l) * float64(r.Timespan()))), nil
+	}
+	return model.Value{}, fmt.Errorf("cannot apply %s to %s and %s", op, l.Type, r.Type)
+}
+
+func intArith(op string, a, b int64) (model.Value, error) {
+	switch op {
+	case "+":
+		return model.NewInt(a + b), nil
+	case "-":
+		return model.NewInt(a - b), nil
+	case "*":
+		return model.NewInt(a * b), nil
+	case "/":
+		if b == 0 {
+			return dynamicNull, nil
+		}
+		return model.NewInt(a / b), nil
+	case "%":
+		if b == 0 {
+			return dynamicNull, nil
+		}
+		return model.NewInt(a % b), nil
+	default:
+		return model.Value{}, fmt.Errorf("unsupported arithmetic operator: %s", op)
+	}
+}
+
+func floatArith(op string, a, b float64) (model.Value, error) {
+	var out float64
+	switch op {
+	case "+":
+		out = a + b
+	case "-":
+		out = a - b
+	case "*":
+		out = a * b
+	case "/":
+		if b == 0 {
+			return dynamicNull, nil
+		}
+		out = a / b
+	case "%":
+		if b == 0 {
+			return dynamicNull, nil
+		}
+		out = math.Mod(a, b)
+	default:
+		return model.Value{}, fmt.Errorf("unsupported arithmetic operator: %s", op)
+	}
+	return model.NewFloat(out), nil
+}
+
+// scalarOf unwraps dynamic scalars (e.g. a JSON property) so they take part
+// in arithmetic like their typed counterparts.
+func scalarOf(v model.Value) model.Value {
+	if v.Type != model.TypeDynamic || v.IsNull() {
+		return v
+	}
+	switch v.Dynamic().(type) {
+	case []any, map[string]any:
+		return v
+	}
+	return model.FromDynamic(v.Dynamic())
+}
+
+func isNumeric(v model.Value) bool {
+	return v.Type == model.TypeInt || v.Type == model.TypeFloat
+}
+
+func toDuration(v model.Value) time.Duration {
+	if v.Type == model.TypeTimespan {
+		return v.Timespan()
+	}
+	return 0
+}
@@ -0,0 +1,526 @@
+package exec
+
+import (
+	"cmp"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+// BatchOperator returns the rows of a pipeline a batch at a time. Pipelines
+// run where, search, project, extend and take on batches read from their
+// input, so that these work on column vectors sharing one schema instead of
+// building a row per input row and operator; the operators that hold rows
+// read them from the batches through a row adapter.
+//
+// A returned batch belongs to its reader, which may set its selection
+// vector, but its columns may be shared with other batches and must not be
+// changed. An error cutting a batch short is returned by the following call.
+type BatchOperator interface {
+	NextBatch() (*csvio.Batch, error)
+}
+
+// runsOnBatches reports whether op has a batch operator. Window functions
+// need the rows around and run on rows.
+func runsOnBatches(op plan.Operator) bool {
+	switch o := op.(type) {
+	case plan.WhereOp:
+		return !HasWindowFunction(o.Predicate)
+	case plan.ExtendOp:
+		return !HasWindowFunction(o.Value)
+	case plan.SearchOp, plan.ProjectOp, plan.TakeOp:
+		return true
+	default:
+		return false
+	}
+}
+
+// batchOp builds the batch operator running op over in.
+func batchOp(in BatchOperator, op plan.Operator) (BatchOperator, error) {
+	switch o := op.(type) {
+	case plan.WhereOp:
+		if err := checkExpr(o.Predicate); err != nil {
+			return nil, err
+		}
+		return newBatchFilter(in, o.Predicate), nil
+	case plan.SearchOp:
+		if err := checkExpr(o.Predicate); err != nil {
+			return nil, err
+		}
+		return newBatchFilter(in, o.Predicate), nil
+	case plan.ProjectOp:
+		return &batchProject{in: in, columns: o.Columns}, nil
+	case plan.ExtendOp:
+		if err := checkExpr(o.Value); err != nil {
+			return nil, err
+		}
+		return &batchExtend{in: in, name: o.Name, value: o.Value}, nil
+	case plan.TakeOp:
+		return &batchTake{in: in, left: o.Count}, nil
+	default:
+		return nil, errors.New("unsupported operator")
+	}
+}
+
+// batchFilter keeps the rows matching a predicate by narrowing the selection
+// vector of each batch. The predicate is split into its and-ed conjuncts,
+// evaluated in turn on the rows the previous ones kept, which short-circuits
+// like the row filter; comparisons of a typed column with a literal run on
+// the unboxed values.
+type batchFilter struct {
+	in        BatchOperator
+	conjuncts []plan.Expr
+	kernels   []*compareKernel
+	view      rowView
+	err       error
+}
+
+func newBatchFilter(in BatchOperator, pred plan.Expr) *batchFilter {
+	f := &batchFilter{in: in, conjuncts: splitAnd(pred, nil)}
+	f.kernels = make([]*compareKernel, len(f.conjuncts))
+	for i, c := range f.conjuncts {
+		f.kernels[i] = newCompareKernel(c)
+	}
+	return f
+}
+
+func splitAnd(e plan.Expr, out []plan.Expr) []plan.Expr {
+	if l, ok := e.(plan.LogicalExpr); ok && l.Op == "and" {
+		return splitAnd(l.Right, splitAnd(l.Left, out))
+	}
+	return append(out, e)
+}
+
+func (f *batchFilter) NextBatch() (*csvio.Batch, error) {
+	for f.err == nil {
+		b, err := f.in.NextBatch()
+		if err != nil {
+			return nil, err
+		}
+		sel := make([]int, b.Rows())
+		for i := range sel {
+			sel[i] = b.Pos(i)
+		}
+		for i, c := range f.conjuncts {
+			if k := f.kernels[i]; k != nil && k.applies(b) {
+				sel = k.filter(b, sel)
+				continue
+			}
+			kept := sel[:0]
+			for _, pos := range sel {
+				ok, err := evalLogical(f.view.load(b, pos), c)
+				if err != nil {
+					return f.byRow(b)
+				}
+				if ok {
+					kept = append(kept, pos)
+				}
+			}
+			sel = kept
+		}
+		if len(sel) > 0 {
+			b.Sel = sel
+			return b, nil
+		}
+	}
+	return nil, f.err
+}
+
+// byRow filters a batch on which a conjunct failed one row at a time, so that
+// it stops at the first failing row: the rows before it are still returned
+// and the error follows them, as it would from the row filter.
+func (f *batchFilter) byRow(b *csvio.Batch) (*csvio.Batch, error) {
+	var sel []int
+rows:
+	for i := 0; i < b.Rows(); i++ {
+		pos := b.Pos(i)
+		row := f.view.load(b, pos)
+		for _, c := range f.conjuncts {
+			ok, err := evalLogical(row, c)
+			if err != nil {
+				f.err = err
+				break rows
+			}
+			if !ok {
+				continue rows
+			}
+		}
+		sel = append(sel, pos)
+	}
+	if len(sel) == 0 {
+		return nil, f.err
+	}
+	b.Sel = sel
+	return b, nil
+}
+
+// compareKernel evaluates a comparison of a column with a literal over the
+// unboxed values of a typed vector.
+type compareKernel struct {
+	column string
+	op     string
+	lit    model.Value
+}
+
+// mirrored is the operator comparing the operands the other way round.
+var mirrored = map[string]string{"==": "==", "=": "==", "!=": "!=", "<": ">", "<=": ">=", ">": "<", ">=": "<="}
+
+func newCompareKernel(e plan.Expr) *compareKernel {
+	c, ok := e.(plan.CompareExpr)
+	if !ok {
+		return nil
+	}
+	op, ok := mirrored[c.Op]
+	if !ok {
+		return nil
+	}
+	col, isCol := c.Left.(plan.ColumnRef)
+	lit, isLit := c.Right.(plan.Literal)
+	if isCol && isLit {
+		op = mirrored[op]
+	} else {
+		col, isCol = c.Right.(plan.ColumnRef)
+		lit, isLit = c.Left.(plan.Literal)
+		if !isCol || !isLit {
+			return nil
+		}
+	}
+	switch lit.Value.Type {
+	case model.TypeInt, model.TypeFloat, model.TypeString, model.TypeDateTime:
+	default:
+		return nil
+	}
+	return &compareKernel{column: col.Name, op: op, lit: lit.Value}
+}
+
+// applies reports whether the column is a typed vector the literal compares
+// with directly.
+func (k *compareKernel) applies(b *csvio.Batch) bool {
+	j, ok := b.Schema.Index[k.column]
+	if !ok {
+		return false
+	}
+	v := b.Columns[j]
+	if v.Boxed() {
+		return false
+	}
+	switch v.Type {
+	case model.TypeInt, model.TypeFloat:
+		return k.lit.Type == model.TypeInt || k.lit.Type == model.TypeFloat
+	case model.TypeString, model.TypeDateTime:
+		return k.lit.Type == v.Type
+	default:
+		return false
+	}
+}
+
+func (k *compareKernel) filter(b *csvio.Batch, sel []int) []int {
+	v := b.Columns[b.Schema.Index[k.column]]
+	kept := sel[:0]
+	switch {
+	case v.Type == model.TypeInt && k.lit.Type == model.TypeInt:
+		lit := k.lit.Int()
+		for _, pos := range sel {
+			if k.holds(cmp.Compare(v.Ints[pos], lit)) {
+				kept = append(kept, pos)
+			}
+		}
+	case v.Type == model.TypeInt:
+		lit := k.lit.Float()
+		for _, pos := range sel {
+			if k.holds(compareIntFloat(v.Ints[pos], lit)) {
+				kept = append(kept, pos)
+			}
+		}
+	case v.Type == model.TypeFloat && k.lit.Type == model.TypeInt:
+		lit := k.lit.Int()
+		for _, pos := range sel {
+			if k.holds(-compareIntFloat(lit, v.Floats[pos])) {
+				kept = append(kept, pos)
+			}
+		}
+	case v.Type == model.TypeFloat:
+		lit := k.lit.Float()
+		for _, pos := range sel {
+			if k.holds(cmp.Compare(v.Floats[pos], lit)) {
+				kept = append(kept, pos)
+			}
+		}
+	case v.Type == model.TypeString:
+		lit := k.lit.String()
+		for _, pos := range sel {
+			if k.holds(strings.Compare(v.Strings[pos], lit)) {
+				kept = append(kept, pos)
+			}
+		}
+	default:
+		lit := k.lit.DateTime()
+		for _, pos := range sel {
+			if k.holds(v.Times[pos].Compare(lit)) {
+				kept = append(kept, pos)
+			}
+		}
+	}
+	return kept
+}
+
+// holds reports whether the comparison is true for the column value
+// comparing as c with the literal.
+func (k *compareKernel) holds(c int) bool {
+	switch k.op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	default:
+		return c >= 0
+	}
+}
+
+// rowView is a row reused to evaluate expressions over the rows of batches.
+type rowView struct {
+	row csvio.Row
+}
+
+func (v *rowView) load(b *csvio.Batch, pos int) *csvio.Row {
+	if len(v.row.Values) != len(b.Columns) {
+		v.row.Values = make([]model.Value, len(b.Columns))
+	}
+	v.row.Schema = b.Schema
+	b.Load(v.row.Values, pos)
+	return &v.row
+}
+
+// batchProject keeps the named columns, sharing their vectors. A column
+// takes the type of the first value in the batch, as project gives each row
+// the types of its values.
+type batchProject struct {
+	in      BatchOperator
+	columns []string
+}
+
+func (p *batchProject) NextBatch() (*csvio.Batch, error) {
+	b, err := p.in.NextBatch()
+	if err != nil {
+		return nil, err
+	}
+	cols := make([]model.Column, 0, len(p.columns))
+	vecs := make([]*csvio.Vector, 0, len(p.columns))
+	for _, name := range p.columns {
+		j, ok := b.Schema.Index[name]
+		if !ok {
+			continue
+		}
+		col, vec := b.Schema.Columns[j], b.Columns[j]
+		if vec.Boxed() && b.Rows() > 0 {
+			col.Type = vec.Values[b.Pos(0)].Type
+		}
+		cols = append(cols, model.Column{Name: name, Type: col.Type})
+		vecs = append(vecs, vec)
+	}
+	return &csvio.Batch{Schema: model.NewSchema(cols), Columns: vecs, Len: b.Len, Sel: b.Sel}, nil
+}
+
+// batchExtend adds a column computed for the selected rows of each batch.
+// The column takes the type of its first value.
+type batchExtend struct {
+	in    BatchOperator
+	name  string
+	value plan.Expr
+	view  rowView
+	err   error
+}
+
+func (e *batchExtend) NextBatch() (*csvio.Batch, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	b, err := e.in.NextBatch()
+	if err != nil {
+		return nil, err
+	}
+	n := b.Rows()
+	vals := make([]model.Value, n)
+	for i := range vals {
+		if vals[i], err = evalExpr(e.view.load(b, b.Pos(i)), e.value); err != nil {
+			e.err, n = err, i
+			break
+		}
+	}
+	if n == 0 {
+		return nil, e.err
+	}
+	var typ model.Type
+	if n > 0 {
+		typ = vals[0].Type
+	}
+	// Rows outside the selection get a zero value, so that the vector lines
+	// up with the other columns.
+	vec := csvio.NewVector(typ, b.Len)
+	zero := unselectedValue(typ)
+	next := 0
+	for pos := 0; pos < b.Len; pos++ {
+		if next < n && b.Pos(next) == pos {
+			vec.Append(vals[next])
+			next++
+			continue
+		}
+		vec.Append(zero)
+	}
+	sel := b.Sel
+	if n < b.Rows() {
+		sel = make([]int, n)
+		for i := range sel {
+			sel[i] = b.Pos(i)
+		}
+	}
+	cols := append(append(make([]model.Column, 0, len(b.Schema.Columns)+1), b.Schema.Columns...), model.Column{Name: e.name, Type: typ})
+	vecs := append(append(make([]*csvio.Vector, 0, len(b.Columns)+1), b.Columns...), vec)
+	return &csvio.Batch{Schema: model.NewSchema(cols), Columns: vecs, Len: b.Len, Sel: sel}, nil
+}
+
+// unselectedValue returns the value stored in a vector of type t for the
+// rows outside the selection.
+func unselectedValue(t model.Type) model.Value {
+	switch t {
+	case model.TypeInt:
+		return model.NewInt(0)
+	case model.TypeFloat:
+		return model.NewFloat(0)
+	case model.TypeString:
+		return model.NewString("")
+	case model.TypeBool:
+		return model.NewBool(false)
+	case model.TypeDateTime:
+		return model.NewDateTime(time.Time{})
+	default:
+		return model.Value{}
+	}
+}
+
+// batchTake stops after its count of rows, without reading further batches.
+type batchTake struct {
+	in   BatchOperator
+	left int
+}
+
+func (t *batchTake) NextBatch() (*csvio.Batch, error) {
+	if t.left <= 0 {
+		return nil, io.EOF
+	}
+	b, err := t.in.NextBatch()
+	if err != nil {
+		return nil, err
+	}
+	if n := b.Rows(); n > t.left {
+		sel := make([]int, t.left)
+		for i := range sel {
+			sel[i] = b.Pos(i)
+		}
+		b.Sel = sel
+	}
+	t.left -= b.Rows()
+	return b, nil
+}
+
+// rowBatches groups the rows of a row reader into batches. A batch ends
+// where the schema of the rows changes.
+type rowBatches struct {
+	in   RowReader
+	next *csvio.Row
+	err  error
+}
+
+func (r *rowBatches) NextBatch() (*csvio.Batch, error) {
+	var b *csvio.Batch
+	for b == nil || b.Len < csvio.BatchSize {
+		row := r.next
+		r.next = nil
+		if row == nil {
+			if r.err != nil {
+				break
+			}
+			var err error
+			if row, err = r.in.Next(); err != nil {
+				r.err = err
+				break
+			}
+		}
+		if b == nil {
+			b = csvio.NewBatch(row.Schema, csvio.BatchSize)
+		} else if !sameColumns(b.Schema, row.Schema) {
+			r.next = row
+			break
+		}
+		appendRow(b, row)
+	}
+	if b == nil {
+		return nil, r.err
+	}
+	return b, nil
+}
+
+func appendRow(b *csvio.Batch, row *csvio.Row) {
+	for j, c := range b.Columns {
+		if j < len(row.Values) {
+			c.Append(row.Values[j])
+		} else {
+			c.Append(model.Value{})
+		}
+	}
+	b.Len++
+}
+
+// sameColumns reports whether rows of schemas a and b can share a batch.
+func sameColumns(a, b model.Schema) bool {
+	if len(a.Columns) != len(b.Columns) {
+		return false
+	}
+	if len(a.Columns) == 0 || &a.Columns[0] == &b.Columns[0] {
+		return true
+	}
+	for i, c := range a.Columns {
+		if b.Columns[i] != c {
+			return false
+		}
+	}
+	return true
+}
+
+// batchRows returns the rows of batches one at a time. The rows of a batch
+// are allocated together.
+type batchRows struct {
+	in   BatchOperator
+	rows []csvio.Row
+	i    int
+}
+
+func (r *batchRows) Next() (*csvio.Row, error) {
+	for r.i >= len(r.rows) {
+		b, err := r.in.NextBatch()
+		if err != nil {
+			return nil, err
+		}
+		n, width := b.Rows(), len(b.Columns)
+		r.rows, r.i = make([]csvio.Row, n), 0
+		vals := make([]model.Value, n*width)
+		for i := range r.rows {
+			row := vals[i*width : (i+1)*width : (i+1)*width]
+			b.Load(row, b.Pos(i))
+			r.rows[i] = csvio.Row{Schema: b.Schema, Values: row}
+		}
+	}
+	r.i++
+	return &r.rows[r.i-1], nil
+}
@@ -0,0 +1,179 @@
+package exec
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+// numberedRows returns n rows of int, float, string and datetime columns;
+// every seventh n is null.
+func numberedRows(n int) []*csvio.Row {
+	schema := model.NewSchema([]model.Column{
+		{Name: "id", Type: model.TypeInt},
+		{Name: "n", Type: model.TypeInt},
+		{Name: "f", Type: model.TypeFloat},
+		{Name: "s", Type: model.TypeString},
+		{Name: "ts", Type: model.TypeDateTime},
+	})
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := make([]*csvio.Row, n)
+	for i := range rows {
+		nv := model.NewInt(int64(i))
+		if i%7 == 6 {
+			nv = model.Value{Type: model.TypeDynamic}
+		}
+		rows[i] = &csvio.Row{Schema: schema, Values: []model.Value{
+			model.NewInt(int64(i)),
+			nv,
+			model.NewFloat(float64(i) / 2),
+			model.NewString(fmt.Sprintf("s%d", i%10)),
+			model.NewDateTime(base.Add(time.Duration(i) * time.Minute)),
+		}}
+	}
+	return rows
+}
+
+// runQueryRows runs the streaming operators of query row by row, as the
+// pipeline did before running them on batches.
+func runQueryRows(t *testing.T, rows []*csvio.Row, query string) []*csvio.Row {
+	t.Helper()
+	var op Operator = SourceOp{Reader: &sliceReader{rows: rows}}
+	for _, p := range mustParse(t, query) {
+		switch o := p.(type) {
+		case plan.WhereOp:
+			op = FilterOp{In: op, Expr: o.Predicate}
+		case plan.SearchOp:
+			op = FilterOp{In: op, Expr: o.Predicate}
+		case plan.ProjectOp:
+			op = &ProjectOp{In: op, Columns: o.Columns}
+		case plan.ExtendOp:
+			op = &ExtendOp{In: op, Name: o.Name, Value: o.Value}
+		case plan.TakeOp:
+			op = &TakeOp{In: op, Total: o.Count}
+		default:
+			t.Fatalf("%s does not run on batches", p.Type())
+		}
+	}
+	return drain(t, op)
+}
+
+func rowString(r *csvio.Row) string {
+	parts := make([]string, len(r.Values))
+	for i, v := range r.Values {
+		parts[i] = fmt.Sprintf("%s:%s=%v", r.Schema.Columns[i].Name, v.Type, v.Any())
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestBatchPipelineMatchesRows(t *testing.T) {
+	rows := numberedRows(5000)
+	queries := []string{
+		"T | where n > 100",
+		"T | where 100 < id and id <= 4000.5",
+		"T | where id != 7 and n > 5",
+		"T | where f >= 10 and s == 's3'",
+		"T | where s != 's1' | where ts < todatetime('2024-01-02')",
+		"T | where n % 3 == 0 or s startswith 's9'",
+		"T | search 's4'",
+		"T | project s, n | where n > 10 | take 3000",
+		"T | extend m = n * 2 | where m > 50 | project m, s",
+		"T | where n > 2040 | take 10",
+		"T | take 0",
+	}
+	for _, q := range queries {
+		got := runQuery(t, rows, q)
+		want := runQueryRows(t, rows, q)
+		if len(got) != len(want) {
+			t.Fatalf("%s: %d rows, want %d", q, len(got), len(want))
+		}
+		for i := range got {
+			if g, w := rowString(got[i]), rowString(want[i]); g != w {
+				t.Fatalf("%s: row %d is %s, want %s", q, i, g, w)
+			}
+		}
+	}
+}
+
+func TestBatchErrorFollowsEarlierRows(t *testing.T) {
+	schema := model.NewSchema([]model.Column{{Name: "v", Type: model.TypeDynamic}})
+	var rows []*csvio.Row
+	for i := 0; i < 10; i++ {
+		v := model.NewInt(int64(i))
+		if i == 5 {
+			v = model.NewString("x")
+		}
+		rows = append(rows, &csvio.Row{Schema: schema, Values: []model.Value{v}})
+	}
+	for q, before := range map[string]int{
+		"T | extend w = v + 1":               5,
+		"T | where v + 1 > 0":                5,
+		"T | where v + 1 > 0 and v < 3":      3,
+		"T | where v + 1 > 0 and v + 2 > 10": 0,
+	} {
+		op, err := BuildPipeline(&sliceReader{rows: rows}, mustParse(t, q))
+		if err != nil {
+			t.Fatalf("build: %v", err)
+		}
+		for i := 0; i < before; i++ {
+			if _, err := op.Next(); err != nil {
+				t.Fatalf("%s: row %d: %v", q, i, err)
+			}
+		}
+		if _, err := op.Next(); err == nil || err == io.EOF {
+			t.Fatalf("%s: expected the error of row 5, got %v", q, err)
+		}
+	}
+}
+
+func TestRowBatchesSplitOnSchemaChange(t *testing.T) {
+	rows := numberedRows(3)
+	other := model.NewSchema([]model.Column{{Name: "n", Type: model.TypeInt}})
+	rows = append(rows, &csvio.Row{Schema: other, Values: []model.Value{model.NewInt(9)}})
+	src := &rowBatches{in: &sliceReader{rows: rows}}
+	var sizes []int
+	for {
+		b, err := src.NextBatch()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next batch: %v", err)
+		}
+		sizes = append(sizes, b.Rows())
+	}
+	if fmt.Sprint(sizes) != "[3 1]" {
+		t.Fatalf("batch sizes %v", sizes)
+	}
+}
+
+func TestCompareKernelTypedColumns(t *testing.T) {
+	b := csvio.NewBatch(numberedRows(1)[0].Schema, 8)
+	for _, r := range numberedRows(8) {
+		b.AppendRow(r.Values)
+	}
+	// The null in row 6 boxes n; f, s and ts stay typed.
+	cases := map[string]bool{"id > 2": true, "n > 2": false, "f > 2": true, "2.5 <= f": true, "s == 's3'": true, "ts > todatetime('2024-01-01')": true, "f > n": false}
+	for src, applies := range cases {
+		where := mustParse(t, "T | where "+src)[0].(plan.WhereOp)
+		// Constants are folded into literals by the optimizer.
+		pred := where.Predicate.(plan.CompareExpr)
+		if IsConstant(pred.Right) {
+			v, err := EvalConstant(pred.Right)
+			if err != nil {
+				t.Fatalf("%s: %v", src, err)
+			}
+			pred.Right = plan.Literal{Value: v}
+		}
+		k := newCompareKernel(pred)
+		if got := k != nil && k.applies(b); got != applies {
+			t.Fatalf("%s: kernel applies %v, want %v", src, got, applies)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package exec
+
+import (
+	"time"
+
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+// Calls of now() and ago() are pinned to the start of the query before it
+// runs, so that every call sees the same instant. The pinned calls take the
+// instant as an extra first argument, under names queries cannot spell.
+const (
+	pinnedNow = "now@"
+	pinnedAgo = "ago@"
+)
+
+func init() {
+	scalarFuncs[pinnedNow] = scalarFunc{minArgs: 1, maxArgs: 2, eval: fnPinnedNow}
+	scalarFuncs[pinnedAgo] = scalarFunc{minArgs: 2, maxArgs: 2, eval: fnPinnedAgo}
+}
+
+func fnPinnedNow(args []model.Value) (model.Value, error) {
+	return clockNow(args[0].DateTime(), args[1:])
+}
+
+func fnPinnedAgo(args []model.Value) (model.Value, error) {
+	return clockAgo(args[0].DateTime(), args[1])
+}
+
+// PinClock returns ops with their now() and ago() calls evaluated at the
+// instant at. Calls pinned already are left alone, so the query entry points
+// pin every plan they get and callers building several plans for one query,
+// such as let bindings, pin them all first with one instant.
+func PinClock(ops []plan.Operator, at time.Time) []plan.Operator {
+	c := clockPin{at: plan.Literal{Value: model.NewDateTime(at)}}
+	return c.ops(ops)
+}
+
+type clockPin struct {
+	at plan.Literal
+}
+
+func (c clockPin) ops(ops []plan.Operator) []plan.Operator {
+	if ops == nil {
+		return nil
+	}
+	out := make([]plan.Operator, len(ops))
+	for i, op := range ops {
+		out[i] = c.op(op)
+	}
+	return out
+}
+
+func (c clockPin) op(op plan.Operator) plan.Operator {
+	switch o := op.(type) {
+	case plan.WhereOp:
+		o.Predicate = c.expr(o.Predicate)
+		return o
+	case plan.ExtendOp:
+		o.Value = c.expr(o.Value)
+		return o
+	case plan.SummarizeOp:
+		o.Aggregates = append([]plan.Aggregate(nil), o.Aggregates...)
+		for i := range o.Aggregates {
+			o.Aggregates[i].Args = c.exprs(o.Aggregates[i].Args)
+		}
+		o.Keys = c.exprs(o.Keys)
+		return o
+	case plan.JoinOp:
+		o.RightOps = c.ops(o.RightOps)
+		return o
+	case plan.MvExpandOp:
+		o.Columns = c.expandColumns(o.Columns)
+		return o
+	case plan.MvApplyOp:
+		o.Columns = c.expandColumns(o.Columns)
+		o.Subquery = c.ops(o.Subquery)
+		return o
+	case plan.ParseOp:
+		o.Source = c.expr(o.Source)
+		return o
+	case plan.SearchOp:
+		o.Predicate = c.expr(o.Predicate)
+		return o
+	case plan.SerializeOp:
+		o.Columns = c.assignments(o.Columns)
+		return o
+	case plan.MakeSeriesOp:
+		o.Aggregates = append([]plan.SeriesAggregate(nil), o.Aggregates...)
+		for i := range o.Aggregates {
+			o.Aggregates[i].Args = c.exprs(o.Aggregates[i].Args)
+			o.Aggregates[i].Default = c.expr(o.Aggregates[i].Default)
+		}
+		o.From, o.To, o.Step = c.expr(o.From), c.expr(o.To), c.expr(o.Step)
+		return o
+	case plan.RangeOp:
+		o.From, o.To, o.Step = c.expr(o.From), c.expr(o.To), c.expr(o.Step)
+		return o
+	case plan.DatatableOp:
+		o.Values = c.exprs(o.Values)
+		return o
+	case plan.PrintOp:
+		o.Columns = c.assignments(o.Columns)
+		return o
+	case plan.EvaluateOp:
+		o.Args = c.exprs(o.Args)
+		return o
+	case plan.ForkOp:
+		o.Branches = append([]plan.ForkBranch(nil), o.Branches...)
+		for i := range o.Branches {
+			o.Branches[i].Ops = c.ops(o.Branches[i].Ops)
+		}
+		return o
+	default:
+		return op
+	}
+}
+
+func (c clockPin) assignments(cols []plan.ExtendOp) []plan.ExtendOp {
+	out := append([]plan.ExtendOp(nil), cols...)
+	for i := range out {
+		out[i].Value = c.expr(out[i].Value)
+	}
+	return out
+}
+
+func (c clockPin) expandColumns(cols []plan.ExpandColumn) []plan.ExpandColumn {
+	out := append([]plan.ExpandColumn(nil), cols...)
+	for i := range out {
+		out[i].Value = c.expr(out[i].Value)
+	}
+	return out
+}
+
+func (c clockPin) exprs(es []plan.Expr) []plan.Expr {
+	if es == nil {
+		return nil
+	}
+	out := make([]plan.Expr, len(es))
+	for i, e := range es {
+		out[i] = c.expr(e)
+	}
+	return out
+}
+
+func (c clockPin) expr(e plan.Expr) plan.Expr {
+	switch x := e.(type) {
+	case plan.CompareExpr:
+		return plan.CompareExpr{Left: c.expr(x.Left), Op: x.Op, Right: c.expr(x.Right)}
+	case plan.LogicalExpr:
+		return plan.LogicalExpr{Left: c.expr(x.Left), Op: x.Op, Right: c.expr(x.Right)}
+	case plan.BinaryExpr:
+		return plan.BinaryExpr{Left: c.expr(x.Left), Op: x.Op, Right: c.expr(x.Right)}
+	case plan.MemberExpr:
+		return plan.MemberExpr{Target: c.expr(x.Target), Name: x.Name}
+	case plan.IndexExpr:
+		return plan.IndexExpr{Target: c.expr(x.Target), Index: c.expr(x.Index)}
+	case plan.CallExpr:
+		args := c.exprs(x.Args)
+		switch x.Name {
+		case "now":
+			return plan.CallExpr{Name: pinnedNow, Args: append([]plan.Expr{c.at}, args...)}
+		case "ago":
+			return plan.CallExpr{Name: pinnedAgo, Args: append([]plan.Expr{c.at}, args...)}
+		}
+		return plan.CallExpr{Name: x.Name, Args: args}
+	default:
+		return e
+	}
+}
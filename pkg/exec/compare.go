@@ -0,0 +1,198 @@
+package exec
+
+import (
+	"cmp"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"kqlfile/pkg/model"
+)
+
+// compareTyped compares a and b under the matrix of model.CompareType. ok is
+// false when either is null or their types cannot be compared, such as a
+// string and a number, or a string that is not a valid datetime against a
+// datetime.
+func compareTyped(a, b model.Value) (int, bool) {
+	a, b = scalarOf(a), scalarOf(b)
+	if a.IsNull() || b.IsNull() || a.Type == "" || b.Type == "" {
+		return 0, false
+	}
+	// Arrays and bags only compare with each other.
+	if (a.Type == model.TypeDynamic) != (b.Type == model.TypeDynamic) {
+		return 0, false
+	}
+	t, ok := model.CompareType(a.Type, b.Type)
+	if !ok {
+		return 0, false
+	}
+	switch t {
+	case model.TypeInt:
+		return cmp.Compare(a.Int(), b.Int()), true
+	case model.TypeFloat:
+		switch {
+		case a.Type == model.TypeInt:
+			return compareIntFloat(a.Int(), b.Float()), true
+		case b.Type == model.TypeInt:
+			return -compareIntFloat(b.Int(), a.Float()), true
+		}
+		return cmp.Compare(a.Float(), b.Float()), true
+	case model.TypeBool:
+		return cmp.Compare(toInt64(a), toInt64(b)), true
+	case model.TypeDateTime:
+		at, aok := asDateTime(a)
+		bt, bok := asDateTime(b)
+		if !aok || !bok {
+			return 0, false
+		}
+		return at.Compare(bt), true
+	case model.TypeTimespan:
+		ad, aok := asTimespan(a)
+		bd, bok := asTimespan(b)
+		if !aok || !bok {
+			return 0, false
+		}
+		return cmp.Compare(ad, bd), true
+	default:
+		return strings.Compare(a.String(), b.String()), true
+	}
+}
+
+// compareIntFloat compares an int with a float without rounding the int:
+// past 2^53 not every int64 is a float64, so a float in the int64 range is
+// compared by its whole part as an int64 and then by its fraction. Floats
+// outside the range are above or below every int, and NaN orders as
+// cmp.Compare puts it.
+func compareIntFloat(i int64, f float64) int {
+	switch {
+	case math.IsNaN(f):
+		return cmp.Compare(float64(i), f)
+	case f >= 1<<63:
+		return -1
+	case f < -(1 << 63):
+		return 1
+	}
+	whole := math.Trunc(f)
+	if c := cmp.Compare(i, int64(whole)); c != 0 || f == whole {
+		return c
+	}
+	if f > whole {
+		return -1
+	}
+	return 1
+}
+
+// incomparable is the result of a comparison between values that
+// compareTyped rejects: nulls compare false, and values of incompatible types
+// are only unequal.
+func incomparable(op string, l, r model.Value) (bool, error) {
+	switch op {
+	case "==", "=", ">", ">=", "<", "<=":
+		return false, nil
+	case "!=":
+		l, r = scalarOf(l), scalarOf(r)
+		return !l.IsNull() && !r.IsNull() && l.Type != "" && r.Type != "", nil
+	default:
+		return false, errors.New("unsupported operator")
+	}
+}
+
+// compareValues is a total order over all values, for sorting and min/max.
+// Values that compareTyped accepts keep its order, nulls come first, and
+// other mixes are ordered by type.
+func compareValues(a, b model.Value) int {
+	if c, ok := compareTyped(a, b); ok {
+		return c
+	}
+	return cmp.Compare(typeRank(scalarOf(a)), typeRank(scalarOf(b)))
+}
+
+func typeRank(v model.Value) int {
+	switch {
+	case v.IsNull() || v.Type == "":
+		return 0
+	case v.Type == model.TypeBool:
+		return 1
+	case isNumeric(v):
+		return 2
+	case v.Type == model.TypeTimespan:
+		return 3
+	case v.Type == model.TypeDateTime:
+		return 4
+	case v.Type == model.TypeString:
+		return 5
+	default:
+		return 6
+	}
+}
+
+func asDateTime(v model.Value) (time.Time, bool) {
+	switch v.Type {
+	case model.TypeDateTime:
+		return v.DateTime(), true
+	case model.TypeString:
+		return model.ParseDateTime(v.String())
+	}
+	return time.Time{}, false
+}
+
+func asTimespan(v model.Value) (time.Duration, bool) {
+	switch v.Type {
+	case model.TypeTimespan:
+		return v.Timespan(), true
+	case model.TypeString:
+		d, err := model.ParseTimespan(v.String())
+		return d, err == nil
+	}
+	return 0, false
+}
+
+// valueKey returns a hash key that is equal for values that compare equal,
+// for join and summarize keys: numbers share a key when numerically equal.
+// Strings keep their own keys even when they hold a datetime, since they only
+// equal a datetime when compared with one; joins look those up with
+// dateTimeKey. Values of incompatible types never share a key.
+func valueKey(v model.Value) string {
+	v = scalarOf(v)
+	switch v.Type {
+	case "":
+		return "z"
+	case model.TypeInt:
+		return "n" + strconv.FormatInt(v.Int(), 10)
+	case model.TypeFloat:
+		f := v.Float()
+		if f == float64(int64(f)) {
+			return "n" + strconv.FormatInt(int64(f), 10)
+		}
+		return "n" + strconv.FormatFloat(f, 'g', -1, 64)
+	case model.TypeDateTime:
+		return "t" + v.DateTime().UTC().Format(time.RFC3339Nano)
+	case model.TypeTimespan:
+		return "d" + strconv.FormatInt(int64(v.Timespan()), 10)
+	case model.TypeBool:
+		return "b" + v.String()
+	case model.TypeString:
+		return "s" + v.String()
+	}
+	if v.IsNull() {
+		return "z"
+	}
+	return "j" + v.String()
+}
+
+// dateTimeKey returns the valueKey of the datetime a string holds, which is
+// the key it matches when compared with a datetime. ok is false for other
+// values.
+func dateTimeKey(v model.Value) (string, bool) {
+	v = scalarOf(v)
+	if v.Type != model.TypeString {
+		return "", false
+	}
+	t, ok := model.ParseDateTime(v.String())
+	if !ok {
+		return "", false
+	}
+	return valueKey(model.NewDateTime(t)), true
+}
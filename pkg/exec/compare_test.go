@@ -0,0 +1,152 @@
+package exec
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+func TestCompareMatrix(t *testing.T) {
+	ts := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	str := func(s string) model.Value { return model.NewString(s) }
+	cases := []struct {
+		a, b model.Value
+		want int
+		ok   bool
+	}{
+		{model.NewInt(30), model.NewFloat(30.5), -1, true},
+		{model.NewInt(1<<53 + 1), model.NewFloat(1 << 53), 1, true},
+		{model.NewFloat(1 << 53), model.NewInt(1<<53 + 1), -1, true},
+		{model.NewInt(math.MaxInt64), model.NewFloat(1 << 63), -1, true},
+		{model.NewInt(math.MinInt64), model.NewFloat(-1 << 63), 0, true},
+		{model.NewInt(-3), model.NewFloat(-2.5), -1, true},
+		{model.NewDateTime(ts), str("2024-01-01"), 1, true},
+		{str("2024-01-02T00:00:00Z"), model.NewDateTime(ts), 0, true},
+		{model.NewTimespan(time.Hour), str("00:30:00"), 1, true},
+		{model.NewInt(30), str("abc"), 0, false},
+		{str("5"), model.NewInt(30), 0, false},
+		{model.NewDateTime(ts), str("soon"), 0, false},
+		{model.NewDynamic(float64(3)), model.NewInt(3), 0, true},
+		{model.NewDynamic([]any{1.0}), model.NewInt(1), 0, false},
+		{dynamicNull, dynamicNull, 0, false},
+	}
+	for i, c := range cases {
+		if got, ok := compareTyped(c.a, c.b); got != c.want || ok != c.ok {
+			t.Fatalf("case %d: compareTyped = %d, %v", i, got, ok)
+		}
+	}
+}
+
+func TestCompareKernelIntFloatIsExact(t *testing.T) {
+	schema := model.NewSchema([]model.Column{{Name: "i", Type: model.TypeInt}, {Name: "f", Type: model.TypeFloat}})
+	b := csvio.NewBatch(schema, 2)
+	b.AppendRow([]model.Value{model.NewInt(1 << 53), model.NewFloat(1 << 53)})
+	b.AppendRow([]model.Value{model.NewInt(1<<53 + 1), model.NewFloat(1<<53 + 2)})
+	cases := []struct {
+		pred plan.CompareExpr
+		want []int
+	}{
+		{plan.CompareExpr{Left: plan.ColumnRef{Name: "i"}, Op: "==", Right: plan.Literal{Value: model.NewFloat(1 << 53)}}, []int{0}},
+		{plan.CompareExpr{Left: plan.ColumnRef{Name: "i"}, Op: ">", Right: plan.Literal{Value: model.NewFloat(1 << 53)}}, []int{1}},
+		{plan.CompareExpr{Left: plan.ColumnRef{Name: "f"}, Op: "==", Right: plan.Literal{Value: model.NewInt(1<<53 + 1)}}, nil},
+		{plan.CompareExpr{Left: plan.ColumnRef{Name: "f"}, Op: ">", Right: plan.Literal{Value: model.NewInt(1<<53 + 1)}}, []int{1}},
+	}
+	for _, c := range cases {
+		k := newCompareKernel(c.pred)
+		if k == nil || !k.applies(b) {
+			t.Fatalf("%v: kernel does not apply", c.pred)
+		}
+		got := k.filter(b, []int{0, 1})
+		if len(got) != len(c.want) || (len(got) > 0 && got[0] != c.want[0]) {
+			t.Fatalf("%v: kept %v, want %v", c.pred, got, c.want)
+		}
+	}
+}
+
+func TestCompareIncompatibleValues(t *testing.T) {
+	row := &csvio.Row{Schema: model.NewSchema([]model.Column{{Name: "d", Type: model.TypeDynamic}}), Values: []model.Value{model.NewDynamic("abc")}}
+	for op, want := range map[string]bool{"==": false, ">": false, "<=": false, "!=": true} {
+		got, err := evalCompare(row, plan.CompareExpr{Left: plan.ColumnRef{Name: "d"}, Op: op, Right: plan.Literal{Value: model.NewInt(0)}})
+		if err != nil || got != want {
+			t.Fatalf("abc %s 0 = %v, %v", op, got, err)
+		}
+	}
+	row.Values[0] = dynamicNull
+	if got, _ := evalCompare(row, plan.CompareExpr{Left: plan.ColumnRef{Name: "d"}, Op: "!=", Right: plan.Literal{Value: model.NewInt(0)}}); got {
+		t.Fatalf("null should not compare unequal")
+	}
+}
+
+func TestOrderByMixedTypes(t *testing.T) {
+	schema := model.NewSchema([]model.Column{{Name: "v", Type: model.TypeDynamic}})
+	var rows []*csvio.Row
+	for _, v := range []any{"b", 10.0, nil, "a", 2.0, true} {
+		rows = append(rows, &csvio.Row{Schema: schema, Values: []model.Value{model.NewDynamic(v)}})
+	}
+	if got := columnStrings(runQuery(t, rows, "T | order by v asc"), "v"); got != ",true,2,10,a,b" {
+		t.Fatalf("unexpected order: %s", got)
+	}
+}
+
+func TestKeysFollowComparison(t *testing.T) {
+	schema := model.NewSchema([]model.Column{{Name: "k", Type: model.TypeDynamic}})
+	var rows []*csvio.Row
+	for _, v := range []any{1.0, "1", 1.0, "2024-01-01T00:00:00Z", "2024-01-01T00:00:00+00:00"} {
+		rows = append(rows, &csvio.Row{Schema: schema, Values: []model.Value{model.NewDynamic(v)}})
+	}
+	rows = append(rows, &csvio.Row{Schema: schema, Values: []model.Value{model.NewDateTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))}})
+	got := runQuery(t, rows, "T | summarize count() by k")
+	if c := columnStrings(got, "count"); c != "2,1,1,1,1" {
+		t.Fatalf("numbers, strings and datetimes should group apart: %s", c)
+	}
+
+	right := &sliceReader{rows: []*csvio.Row{
+		{Schema: model.NewSchema([]model.Column{{Name: "id", Type: model.TypeFloat}}), Values: []model.Value{model.NewFloat(1.0)}},
+	}}
+	join, err := newJoinOp(&sliceOp{rows: rows[:2]}, right, model.Schema{}, "k", "id")
+	if err != nil {
+		t.Fatalf("join: %v", err)
+	}
+	if n := len(drain(t, join)); n != 1 {
+		t.Fatalf("only the numeric key should match, got %d rows", n)
+	}
+
+	// A datetime key matches the strings holding it, and a string key the
+	// datetime it holds.
+	right = &sliceReader{rows: rows[3:]}
+	join, err = newJoinOp(&sliceOp{rows: rows[5:]}, right, model.Schema{}, "k", "k")
+	if err != nil {
+		t.Fatalf("join: %v", err)
+	}
+	if n := len(drain(t, join)); n != 3 {
+		t.Fatalf("a datetime should match both strings and itself, got %d rows", n)
+	}
+	right = &sliceReader{rows: rows[5:]}
+	join, err = newJoinOp(&sliceOp{rows: rows[3:5]}, right, model.Schema{}, "k", "k")
+	if err != nil {
+		t.Fatalf("join: %v", err)
+	}
+	if n := len(drain(t, join)); n != 2 {
+		t.Fatalf("each string should match the datetime, got %d rows", n)
+	}
+}
+
+func TestSummarizeKeepsDateLikeStringsApart(t *testing.T) {
+	schema := model.NewSchema([]model.Column{{Name: "d", Type: model.TypeString}})
+	var rows []*csvio.Row
+	for _, s := range []string{"2024-01-01", "2024-01-01 00:00:00", "2024-01-01T00:00:00Z", "2024-01-01"} {
+		rows = append(rows, &csvio.Row{Schema: schema, Values: []model.Value{model.NewString(s)}})
+	}
+	got := runQuery(t, rows, "T | summarize n = count() by d")
+	if c := columnStrings(got, "n"); c != "2,1,1" {
+		t.Fatalf("date-like strings should group as strings: %s", c)
+	}
+	got = runQuery(t, rows, `T | where d == "2024-01-01" | summarize n = count()`)
+	if c := columnStrings(got, "n"); c != "2" {
+		t.Fatalf("where should match the group's rows: %s", c)
+	}
+}
@@ -0,0 +1,67 @@
+package exec
+
+import (
+	"fmt"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+// evalConst evaluates an expression that must not depend on the input rows.
+func evalConst(row *csvio.Row, expr plan.Expr) (model.Value, error) {
+	if err := checkExpr(expr); err != nil {
+		return model.Value{}, err
+	}
+	v, err := evalExpr(row, expr)
+	if err != nil {
+		return model.Value{}, err
+	}
+	if v.Type == "" {
+		return model.Value{}, fmt.Errorf("expected a constant expression")
+	}
+	return v, nil
+}
+
+// IsConstant reports whether expr has the same value for every row of a
+// query: it reads no column and calls neither a window function nor a
+// clock not yet pinned to the query start.
+func IsConstant(expr plan.Expr) bool {
+	switch e := expr.(type) {
+	case plan.Literal:
+		return true
+	case plan.CompareExpr:
+		return IsConstant(e.Left) && IsConstant(e.Right)
+	case plan.LogicalExpr:
+		return IsConstant(e.Left) && IsConstant(e.Right)
+	case plan.BinaryExpr:
+		return IsConstant(e.Left) && IsConstant(e.Right)
+	case plan.MemberExpr:
+		return IsConstant(e.Target)
+	case plan.IndexExpr:
+		return IsConstant(e.Target) && IsConstant(e.Index)
+	case plan.CallExpr:
+		if _, ok := windowFuncs[e.Name]; ok || e.Name == "now" || e.Name == "ago" {
+			return false
+		}
+		for _, a := range e.Args {
+			if !IsConstant(a) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// EvalConstant evaluates an expression for which IsConstant holds.
+func EvalConstant(expr plan.Expr) (model.Value, error) {
+	return evalConst(&csvio.Row{}, expr)
+}
+
+// HasWindowFunction reports whether expr calls a window function, whose
+// value depends on the rows around the current one.
+func HasWindowFunction(expr plan.Expr) bool {
+	return windowCall(expr) != ""
+}
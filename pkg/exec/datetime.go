@@ -0,0 +1,64 @@
+package exec
+
+import (
+	"time"
+
+	"kqlfile/pkg/model"
+)
+
+// nowFunc reads the clock a query is pinned to; tests replace it.
+var nowFunc = func() time.Time { return time.Now().UTC() }
+
+func fnNow(args []model.Value) (model.Value, error) {
+	return clockNow(nowFunc(), args)
+}
+
+func fnAgo(args []model.Value) (model.Value, error) {
+	return clockAgo(nowFunc(), args[0])
+}
+
+// clockNow is now() at the instant t, shifted by the optional offset.
+func clockNow(t time.Time, args []model.Value) (model.Value, error) {
+	if len(args) == 1 {
+		t = t.Add(toDuration(args[0]))
+	}
+	return model.NewDateTime(t), nil
+}
+
+// clockAgo is ago(d) at the instant t.
+func clockAgo(t time.Time, d model.Value) (model.Value, error) {
+	if d.Type != model.TypeTimespan {
+		return dynamicNull, nil
+	}
+	return model.NewDateTime(t.Add(-toDuration(d))), nil
+}
+
+func fnBin(args []model.Value) (model.Value, error) {
+	return binValue(scalarOf(args[0]), scalarOf(args[1])), nil
+}
+
+func fnToDatetime(args []model.Value) (model.Value, error) {
+	v := scalarOf(args[0])
+	if v.Type == model.TypeDateTime {
+		return v, nil
+	}
+	if v.Type != model.TypeString {
+		return dynamicNull, nil
+	}
+	if t, ok := model.ParseDateTime(v.String()); ok {
+		return model.NewDateTime(t), nil
+	}
+	return dynamicNull, nil
+}
+
+func fnToTimespan(args []model.Value) (model.Value, error) {
+	v := scalarOf(args[0])
+	if v.Type == model.TypeTimespan {
+		return v, nil
+	}
+	d, err := model.ParseTimespan(v.String())
+	if err != nil {
+		return dynamicNull, nil
+	}
+	return model.NewTimespan(d), nil
+}
@@ -2,6 +2,7 @@ package exec
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"sort"
 	"time"
@@ -48,36 +49,66 @@ func (f FilterOp) Next() (*csvio.Row, error) {
 	}
 }
 
+// NewRowFilter returns a function evaluating the where predicate pred on a
+// row, for readers that drop records before building their rows. The
+// predicate must not call window functions, which need the rows around.
+func NewRowFilter(pred plan.Expr) (func(*csvio.Row) (bool, error), error) {
+	if HasWindowFunction(pred) {
+		return nil, fmt.Errorf("window function %s cannot filter rows while reading", windowCall(pred))
+	}
+	if err := checkExpr(pred); err != nil {
+		return nil, err
+	}
+	return func(row *csvio.Row) (bool, error) { return evalLogical(row, pred) }, nil
+}
+
+// ProjectOp keeps the named columns. Rows take the types of their values,
+// so the output schema is rebuilt only when the columns or types change.
 type ProjectOp struct {
 	In      Operator
 	Columns []string
+	schema  model.Schema
 }
 
-func (p ProjectOp) Next() (*csvio.Row, error) {
+func (p *ProjectOp) Next() (*csvio.Row, error) {
 	row, err := p.In.Next()
 	if err != nil {
 		return nil, err
 	}
-	cols := make([]model.Column, 0, len(p.Columns))
 	vals := make([]model.Value, 0, len(p.Columns))
+	same := p.schema.Index != nil
 	for _, name := range p.Columns {
 		v, ok := row.Get(name)
 		if !ok {
 			continue
 		}
-		cols = append(cols, model.Column{Name: name, Type: v.Type})
+		if k := len(vals); same && (k == len(p.schema.Columns) || p.schema.Columns[k] != model.Column{Name: name, Type: v.Type}) {
+			same = false
+		}
 		vals = append(vals, v)
 	}
-	return &csvio.Row{Schema: model.NewSchema(cols), Values: vals}, nil
+	if !same || len(vals) != len(p.schema.Columns) {
+		cols := make([]model.Column, 0, len(vals))
+		for _, name := range p.Columns {
+			if _, ok := row.Get(name); ok {
+				cols = append(cols, model.Column{Name: name, Type: vals[len(cols)].Type})
+			}
+		}
+		p.schema = model.NewSchema(cols)
+	}
+	return &csvio.Row{Schema: p.schema, Values: vals}, nil
 }
 
+// ExtendOp appends a computed column, reusing the output schema while the
+// input columns and the type of the value stay the same.
 type ExtendOp struct {
-	In    Operator
-	Name  string
-	Value plan.Expr
+	In     Operator
+	Name   string
+	Value  plan.Expr
+	schema model.Schema
 }
 
-func (e ExtendOp) Next() (*csvio.Row, error) {
+func (e *ExtendOp) Next() (*csvio.Row, error) {
 	row, err := e.In.Next()
 	if err != nil {
 		return nil, err
@@ -86,11 +117,28 @@ func (e ExtendOp) Next() (*csvio.Row, error) {
 	if err != nil {
 		return nil, err
 	}
-	cols := append([]model.Column(nil), row.Schema.Columns...)
-	cols = append(cols, model.Column{Name: e.Name, Type: val.Type})
+	col := model.Column{Name: e.Name, Type: val.Type}
+	if !e.extends(row.Schema.Columns, col) {
+		cols := append([]model.Column(nil), row.Schema.Columns...)
+		e.schema = model.NewSchema(append(cols, col))
+	}
 	vals := append([]model.Value(nil), row.Values...)
 	vals = append(vals, val)
-	return &csvio.Row{Schema: model.NewSchema(cols), Values: vals}, nil
+	return &csvio.Row{Schema: e.schema, Values: vals}, nil
+}
+
+// extends reports whether the cached schema is in followed by col.
+func (e *ExtendOp) extends(in []model.Column, col model.Column) bool {
+	out := e.schema.Columns
+	if e.schema.Index == nil || len(out) != len(in)+1 || out[len(in)] != col {
+		return false
+	}
+	for i, c := range in {
+		if out[i] != c {
+			return false
+		}
+	}
+	return true
 }
 
 type TakeOp struct {
@@ -158,8 +206,45 @@ type SummarizeOp struct {
 }
 
 func NewSummarizeOp(in Operator, by []string) (SummarizeOp, error) {
-	counts := make(map[string]int)
-	keys := make(map[string][]model.Value)
+	return newSummarizeOp(in, plan.SummarizeOp{ByColumns: by})
+}
+
+type summarizeGroup struct {
+	keys []model.Value
+	aggs []aggregator
+}
+
+// newSummarizeOp aggregates the input per group, in order of first
+// appearance.
+func newSummarizeOp(in Operator, o plan.SummarizeOp) (SummarizeOp, error) {
+	aggs := o.Aggregates
+	if len(aggs) == 0 {
+		aggs = []plan.Aggregate{{Name: "count", Func: "count"}}
+	}
+	for _, k := range o.Keys {
+		if err := checkExpr(k); err != nil {
+			return SummarizeOp{}, err
+		}
+	}
+	for _, a := range aggs {
+		if err := checkAggregate(a.Func, a.Args); err != nil {
+			return SummarizeOp{}, err
+		}
+	}
+	newGroup := func(keys []model.Value) *summarizeGroup {
+		g := &summarizeGroup{keys: keys, aggs: make([]aggregator, len(aggs))}
+		for i, a := range aggs {
+			g.aggs[i] = aggFuncs[a.Func].create()
+		}
+		return g
+	}
+
+	var groups []*summarizeGroup
+	index := make(map[string]int)
+	args := make([][]model.Value, len(aggs))
+	for i, a := range aggs {
+		args[i] = make([]model.Value, len(a.Args))
+	}
 	for {
 		row, err := in.Next()
 		if err == io.EOF {
@@ -168,29 +253,50 @@ func NewSummarizeOp(in Operator, by []string) (SummarizeOp, error) {
 		if err != nil {
 			return SummarizeOp{}, err
 		}
-		vals := make([]model.Value, 0, len(by))
-		keyParts := make([]string, 0, len(by))
-		for _, name := range by {
+		vals := make([]model.Value, 0, len(o.ByColumns))
+		keyParts := make([]string, 0, len(o.ByColumns))
+		for i, name := range o.ByColumns {
 			v, _ := row.Get(name)
+			if o.Keys != nil {
+				if v, err = evalExpr(row, o.Keys[i]); err != nil {
+					return SummarizeOp{}, err
+				}
+			}
 			vals = append(vals, v)
-			keyParts = append(keyParts, v.String())
+			keyParts = append(keyParts, valueKey(v))
 		}
-		k := stringsJoin(keyParts, "|")
-		counts[k]++
-		if _, ok := keys[k]; !ok {
-			keys[k] = vals
+		k := stringsJoin(keyParts, "\x00")
+		gi, ok := index[k]
+		if !ok {
+			gi = len(groups)
+			index[k] = gi
+			groups = append(groups, newGroup(vals))
+		}
+		for i, a := range aggs {
+			for j, e := range a.Args {
+				if args[i][j], err = evalExpr(row, e); err != nil {
+					return SummarizeOp{}, err
+				}
+			}
+			if err := groups[gi].aggs[i].add(args[i]); err != nil {
+				return SummarizeOp{}, err
+			}
 		}
 	}
-	rows := make([]*csvio.Row, 0, len(counts))
-	for k, cnt := range counts {
-		_ = k
-		vals := keys[k]
-		cols := make([]model.Column, 0, len(by)+1)
-		for i, name := range by {
-			cols = append(cols, model.Column{Name: name, Type: vals[i].Type})
+
+	rows := make([]*csvio.Row, 0, len(groups))
+	for _, g := range groups {
+		cols := make([]model.Column, 0, len(o.ByColumns)+len(aggs))
+		vals := make([]model.Value, 0, cap(cols))
+		for i, name := range o.ByColumns {
+			cols = append(cols, model.Column{Name: name, Type: g.keys[i].Type})
+			vals = append(vals, g.keys[i])
+		}
+		for i, a := range aggs {
+			v := g.aggs[i].result()
+			cols = append(cols, model.Column{Name: a.Name, Type: v.Type})
+			vals = append(vals, v)
 		}
-		cols = append(cols, model.Column{Name: "count", Type: model.TypeInt})
-		vals = append(vals, model.Value{Type: model.TypeInt, V: int64(cnt)})
 		rows = append(rows, &csvio.Row{Schema: model.NewSchema(cols), Values: vals})
 	}
 	return SummarizeOp{rows: rows}, nil
@@ -206,15 +312,97 @@ func (s *SummarizeOp) Next() (*csvio.Row, error) {
 }
 
 func BuildPipeline(reader RowReader, ops []plan.Operator) (Operator, error) {
-	var current Operator = SourceOp{Reader: reader}
-	for _, op := range ops {
+	return Tables(nil).Pipeline(reader, ops)
+}
+
+// Pipeline builds ops over reader, resolving join inputs against t before
+// falling back to file paths.
+func (t Tables) Pipeline(reader RowReader, ops []plan.Operator) (Operator, error) {
+	return t.pipeline(reader, PinClock(ops, nowFunc()), nil)
+}
+
+// pipeline builds ops over reader, profiling every operator when stats is
+// not nil.
+func (t Tables) pipeline(reader RowReader, ops []plan.Operator, stats *Stats) (Operator, error) {
+	// The streaming operators at the start of a pipeline reading an input
+	// run on batches; the first other operator reads rows from them.
+	var current Operator
+	var batches BatchOperator
+	if reader != nil && len(ops) > 0 && runsOnBatches(ops[0]) {
+		batches = stats.batchSource(reader)
+	} else {
+		current = stats.source(reader)
+	}
+	serialized := false
+	for i, op := range ops {
+		if IsGenerator(op) && i > 0 {
+			return nil, fmt.Errorf("%s must be the first operator", op.Type())
+		}
+		if _, ok := op.(plan.AsOp); ok && batches != nil {
+			continue
+		}
+		if batches != nil && runsOnBatches(op) {
+			in := profiled(batches)
+			mark := stats.mark(in)
+			b, err := batchOp(batches, op)
+			if err != nil {
+				return nil, err
+			}
+			batches = stats.wrapBatches(op, b, mark, in)
+			serialized = keepsOrder(op, serialized)
+			continue
+		}
+		if batches != nil {
+			current, batches = &batchRows{in: batches}, nil
+		}
+		in := profiled(current)
+		mark := stats.mark(in)
+		var build *OpStats
 		switch o := op.(type) {
+		case plan.RangeOp:
+			gen, err := NewRangeOp(o)
+			if err != nil {
+				return nil, err
+			}
+			current = gen
+		case plan.DatatableOp:
+			gen, err := newDatatable(o)
+			if err != nil {
+				return nil, err
+			}
+			current = gen
+		case plan.PrintOp:
+			gen, err := newPrint(o)
+			if err != nil {
+				return nil, err
+			}
+			current = gen
 		case plan.WhereOp:
-			current = FilterOp{In: current, Expr: o.Predicate}
+			in, pred, err := bindWindow(current, o.Predicate, serialized)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkExpr(pred); err != nil {
+				return nil, err
+			}
+			current = FilterOp{In: in, Expr: pred}
 		case plan.ProjectOp:
-			current = ProjectOp{In: current, Columns: o.Columns}
+			current = &ProjectOp{In: current, Columns: o.Columns}
 		case plan.ExtendOp:
-			current = ExtendOp{In: current, Name: o.Name, Value: o.Value}
+			ext, err := buildExtend(current, o, serialized)
+			if err != nil {
+				return nil, err
+			}
+			current = ext
+		case plan.SerializeOp:
+			serialized = true
+			for _, c := range o.Columns {
+				ext, err := buildExtend(current, c, serialized)
+				if err != nil {
+					return nil, err
+				}
+				current = ext
+			}
 		case plan.TakeOp:
 			current = &TakeOp{In: current, Total: o.Count}
 		case plan.OrderByOp:
@@ -224,24 +412,114 @@ func BuildPipeline(reader RowReader, ops []plan.Operator) (Operator, error) {
 			}
 			current = &ord
 		case plan.SummarizeOp:
-			sum, err := NewSummarizeOp(current, o.ByColumns)
+			sum, err := newSummarizeOp(current, o)
 			if err != nil {
 				return nil, err
 			}
 			current = &sum
 		case plan.JoinOp:
-			join, err := NewJoinOp(current, o.Right, o.LeftKey, o.RightKey)
+			join, right, err := t.join(current, o, stats)
+			if err != nil {
+				return nil, err
+			}
+			current, build = join, right
+		case plan.SearchOp:
+			if err := checkExpr(o.Predicate); err != nil {
+				return nil, err
+			}
+			current = FilterOp{In: current, Expr: o.Predicate}
+		case plan.ParseOp:
+			parse, err := NewParseOp(current, o)
+			if err != nil {
+				return nil, err
+			}
+			current = parse
+		case plan.MvExpandOp:
+			if err := checkExpandColumns(o.Columns); err != nil {
+				return nil, err
+			}
+			current = &MvExpandOp{In: current, Columns: o.Columns, IndexName: o.IndexName, Limit: o.Limit}
+		case plan.MakeSeriesOp:
+			series, err := NewMakeSeriesOp(current, o)
+			if err != nil {
+				return nil, err
+			}
+			current = series
+		case plan.GetSchemaOp:
+			schema, err := NewGetSchemaOp(current)
+			if err != nil {
+				return nil, err
+			}
+			current = schema
+		case plan.EvaluateOp:
+			eval, err := buildEvaluate(current, o)
+			if err != nil {
+				return nil, err
+			}
+			current = eval
+		case plan.SampleOp:
+			current = &SampleOp{In: current, Count: o.Count, Seed: o.Seed}
+		case plan.SampleDistinctOp:
+			current = &SampleDistinctOp{In: current, Count: o.Count, Column: o.Column, Seed: o.Seed}
+		case plan.TopOp:
+			top, err := NewTopOp(current, o.Count, o.Column, o.Desc)
 			if err != nil {
 				return nil, err
 			}
-			current = join
+			current = top
+		case plan.AsOp:
+		case plan.ForkOp:
+			return nil, errors.New("fork produces several results; build it with BuildResults")
+		case plan.MvApplyOp:
+			if err := checkExpandColumns(o.Columns); err != nil {
+				return nil, err
+			}
+			apply, err := NewMvApplyOp(current, o)
+			if err != nil {
+				return nil, err
+			}
+			current = apply
 		default:
 			return nil, errors.New("unsupported operator")
 		}
+		serialized = keepsOrder(op, serialized)
+		if _, ok := op.(plan.AsOp); stats != nil && !ok {
+			if IsGenerator(op) {
+				in = nil
+			}
+			current = stats.wrap(op, current, mark, in, build)
+		}
+	}
+	if batches != nil {
+		current = &batchRows{in: batches}
 	}
 	return current, nil
 }
 
+func buildExtend(in Operator, o plan.ExtendOp, serialized bool) (Operator, error) {
+	in, value, err := bindWindow(in, o.Value, serialized)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkExpr(value); err != nil {
+		return nil, err
+	}
+	return &ExtendOp{In: in, Name: o.Name, Value: value}, nil
+}
+
+// keepsOrder reports whether the output of op is still serialized, i.e. has
+// a well-defined row order that window functions can rely on.
+func keepsOrder(op plan.Operator, serialized bool) bool {
+	switch op.(type) {
+	case plan.OrderByOp, plan.SerializeOp, plan.RangeOp, plan.DatatableOp, plan.PrintOp, plan.TopOp:
+		return true
+	case plan.AsOp, plan.WhereOp, plan.ProjectOp, plan.ExtendOp, plan.TakeOp, plan.ParseOp, plan.MvExpandOp, plan.SearchOp:
+		return serialized
+	default:
+		return false
+	}
+}
+
 func evalExpr(row *csvio.Row, expr plan.Expr) (model.Value, error) {
 	switch e := expr.(type) {
 	case plan.ColumnRef:
@@ -249,6 +527,41 @@ func evalExpr(row *csvio.Row, expr plan.Expr) (model.Value, error) {
 		return v, nil
 	case plan.Literal:
 		return e.Value, nil
+	case plan.CompareExpr, plan.LogicalExpr:
+		ok, err := evalLogical(row, e)
+		if err != nil {
+			return model.Value{}, err
+		}
+		return model.NewBool(ok), nil
+	case plan.MemberExpr:
+		if name, ok := dottedName(e); ok {
+			if v, ok := row.Get(name); ok {
+				return v, nil
+			}
+		}
+		target, err := evalExpr(row, e.Target)
+		if err != nil {
+			return model.Value{}, err
+		}
+		return dynamicMember(target, e.Name), nil
+	case plan.IndexExpr:
+		target, err := evalExpr(row, e.Target)
+		if err != nil {
+			return model.Value{}, err
+		}
+		idx, err := evalExpr(row, e.Index)
+		if err != nil {
+			return model.Value{}, err
+		}
+		return dynamicIndex(target, idx)
+	case plan.CallExpr:
+		return evalCall(row, e)
+	case plan.SearchTerm:
+		return model.NewBool(evalSearchTerm(row, e)), nil
+	case plan.BinaryExpr:
+		return evalBinaryExpr(row, e)
+	case *windowExpr:
+		return e.eval(row)
 	default:
 		return model.Value{}, errors.New("unsupported expression")
 	}
@@ -263,7 +576,13 @@ func evalCompare(row *csvio.Row, cmp plan.CompareExpr) (bool, error) {
 	if err != nil {
 		return false, err
 	}
-	c := compareValues(l, r)
+	if ok, handled := evalStringOp(cmp.Op, l, r); handled {
+		return ok, nil
+	}
+	c, ok := compareTyped(l, r)
+	if !ok {
+		return incomparable(cmp.Op, l, r)
+	}
 	switch cmp.Op {
 	case "==", "=":
 		return c == 0, nil
@@ -309,73 +628,25 @@ func evalLogical(row *csvio.Row, expr plan.Expr) (bool, error) {
 		}
 		return false, errors.New("unsupported logical operator")
 	default:
-		return false, errors.New("unsupported expression")
-	}
-}
-
-func compareValues(a, b model.Value) int {
-	switch a.Type {
-	case model.TypeInt:
-		ai := a.V.(int64)
-		bi := toInt64(b)
-		if ai < bi {
-			return -1
-		}
-		if ai > bi {
-			return 1
-		}
-		return 0
-	case model.TypeFloat:
-		af := a.V.(float64)
-		bf := toFloat64(b)
-		if af < bf {
-			return -1
-		}
-		if af > bf {
-			return 1
-		}
-		return 0
-	case model.TypeBool:
-		ab := a.V.(bool)
-		bb := toBool(b)
-		if !ab && bb {
-			return -1
-		}
-		if ab && !bb {
-			return 1
-		}
-		return 0
-	case model.TypeDateTime:
-		at := a.V.(time.Time)
-		bt := toTime(b)
-		if at.Before(bt) {
-			return -1
-		}
-		if at.After(bt) {
-			return 1
-		}
-		return 0
-	default:
-		as := a.String()
-		bs := b.String()
-		if as < bs {
-			return -1
+		v, err := evalExpr(row, expr)
+		if err != nil {
+			return false, err
 		}
-		if as > bs {
-			return 1
+		if v.Type != model.TypeBool {
+			return false, fmt.Errorf("expression of type %s is not boolean", v.Type)
 		}
-		return 0
+		return v.Bool(), nil
 	}
 }
 
 func toInt64(v model.Value) int64 {
 	switch v.Type {
 	case model.TypeInt:
-		return v.V.(int64)
+		return v.Int()
 	case model.TypeFloat:
-		return int64(v.V.(float64))
+		return int64(v.Float())
 	case model.TypeBool:
-		if v.V.(bool) {
+		if v.Bool() {
 			return 1
 		}
 		return 0
@@ -387,11 +658,11 @@ func toInt64(v model.Value) int64 {
 func toFloat64(v model.Value) float64 {
 	switch v.Type {
 	case model.TypeFloat:
-		return v.V.(float64)
+		return v.Float()
 	case model.TypeInt:
-		return float64(v.V.(int64))
+		return float64(v.Int())
 	case model.TypeBool:
-		if v.V.(bool) {
+		if v.Bool() {
 			return 1
 		}
 		return 0
@@ -403,11 +674,11 @@ func toFloat64(v model.Value) float64 {
 func toBool(v model.Value) bool {
 	switch v.Type {
 	case model.TypeBool:
-		return v.V.(bool)
+		return v.Bool()
 	case model.TypeInt:
-		return v.V.(int64) != 0
+		return v.Int() != 0
 	case model.TypeFloat:
-		return v.V.(float64) != 0
+		return v.Float() != 0
 	default:
 		return v.String() == "true"
 	}
@@ -415,7 +686,7 @@ func toBool(v model.Value) bool {
 
 func toTime(v model.Value) time.Time {
 	if v.Type == model.TypeDateTime {
-		return v.V.(time.Time)
+		return v.DateTime()
 	}
 	return time.Time{}
 }
@@ -439,6 +710,11 @@ type JoinOp struct {
 	RightKey    string
 	pending     []*csvio.Row
 	pendingIdx  int
+	// rightStrings are the right rows with a string key, in input order;
+	// once a left datetime needs them, rightTimes holds those whose string
+	// holds a datetime by the key of that datetime.
+	rightStrings []*csvio.Row
+	rightTimes   map[string][]*csvio.Row
 }
 
 func NewJoinOp(in Operator, rightPath, leftKey, rightKey string) (*JoinOp, error) {
@@ -447,28 +723,40 @@ func NewJoinOp(in Operator, rightPath, leftKey, rightKey string) (*JoinOp, error
 		return nil, err
 	}
 	defer reader.Close()
+	return newJoinOp(in, reader, reader.Schema(), leftKey, rightKey)
+}
 
-	rightSchema := reader.Schema()
+// newJoinOp hashes the right input. Inputs without a header take their
+// schema from the first row.
+func newJoinOp(in Operator, right RowReader, rightSchema model.Schema, leftKey, rightKey string) (*JoinOp, error) {
 	rightRows := make(map[string][]*csvio.Row)
+	var rightStrings []*csvio.Row
 	for {
-		row, err := reader.Next()
+		row, err := right.Next()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
 			return nil, err
 		}
+		if rightSchema.Index == nil {
+			rightSchema = row.Schema
+		}
 		v, _ := row.Get(rightKey)
-		key := v.String()
+		key := valueKey(v)
 		rightRows[key] = append(rightRows[key], row)
+		if scalarOf(v).Type == model.TypeString {
+			rightStrings = append(rightStrings, row)
+		}
 	}
 
 	return &JoinOp{
-		In:          in,
-		Right:       rightRows,
-		RightSchema: rightSchema,
-		LeftKey:     leftKey,
-		RightKey:    rightKey,
+		In:           in,
+		Right:        rightRows,
+		RightSchema:  rightSchema,
+		LeftKey:      leftKey,
+		RightKey:     rightKey,
+		rightStrings: rightStrings,
 	}, nil
 }
 
@@ -487,7 +775,7 @@ func (j *JoinOp) Next() (*csvio.Row, error) {
 			return nil, err
 		}
 		lv, _ := leftRow.Get(j.LeftKey)
-		matches := j.Right[lv.String()]
+		matches := j.matches(lv)
 		if len(matches) == 0 {
 			continue
 		}
@@ -495,6 +783,33 @@ func (j *JoinOp) Next() (*csvio.Row, error) {
 	}
 }
 
+// matches returns the right rows whose key equals v. A string and a
+// datetime are equal when the string holds that datetime, so each is also
+// looked up among the keys of the other type.
+func (j *JoinOp) matches(v model.Value) []*csvio.Row {
+	key := valueKey(v)
+	rows := j.Right[key]
+	var more []*csvio.Row
+	if scalarOf(v).Type == model.TypeDateTime {
+		if j.rightTimes == nil {
+			j.rightTimes = map[string][]*csvio.Row{}
+			for _, r := range j.rightStrings {
+				rv, _ := r.Get(j.RightKey)
+				if k, ok := dateTimeKey(rv); ok {
+					j.rightTimes[k] = append(j.rightTimes[k], r)
+				}
+			}
+		}
+		more = j.rightTimes[key]
+	} else if k, ok := dateTimeKey(v); ok {
+		more = j.Right[k]
+	}
+	if len(more) == 0 {
+		return rows
+	}
+	return append(append([]*csvio.Row(nil), rows...), more...)
+}
+
 func buildJoinedRows(left *csvio.Row, rights []*csvio.Row, rightSchema model.Schema) []*csvio.Row {
 	cols := make([]model.Column, 0, len(left.Schema.Columns)+len(rightSchema.Columns))
 	cols = append(cols, left.Schema.Columns...)
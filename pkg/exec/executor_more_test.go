@@ -45,70 +45,70 @@ type badOp struct{}
 func (badOp) Type() string { return "bad" }
 
 func TestCompareValues(t *testing.T) {
-	if compareValues(model.Value{Type: model.TypeInt, V: int64(1)}, model.Value{Type: model.TypeInt, V: int64(2)}) >= 0 {
+	if compareValues(model.NewInt(1), model.NewInt(2)) >= 0 {
 		t.Fatalf("int compare")
 	}
-	if compareValues(model.Value{Type: model.TypeInt, V: int64(3)}, model.Value{Type: model.TypeInt, V: int64(2)}) <= 0 {
+	if compareValues(model.NewInt(3), model.NewInt(2)) <= 0 {
 		t.Fatalf("int compare greater")
 	}
-	if compareValues(model.Value{Type: model.TypeFloat, V: 2.0}, model.Value{Type: model.TypeFloat, V: 1.0}) <= 0 {
+	if compareValues(model.NewFloat(2.0), model.NewFloat(1.0)) <= 0 {
 		t.Fatalf("float compare")
 	}
-	if compareValues(model.Value{Type: model.TypeFloat, V: 1.0}, model.Value{Type: model.TypeFloat, V: 2.0}) >= 0 {
+	if compareValues(model.NewFloat(1.0), model.NewFloat(2.0)) >= 0 {
 		t.Fatalf("float compare less")
 	}
-	if compareValues(model.Value{Type: model.TypeBool, V: false}, model.Value{Type: model.TypeBool, V: true}) >= 0 {
+	if compareValues(model.NewBool(false), model.NewBool(true)) >= 0 {
 		t.Fatalf("bool compare")
 	}
-	if compareValues(model.Value{Type: model.TypeBool, V: true}, model.Value{Type: model.TypeBool, V: false}) <= 0 {
+	if compareValues(model.NewBool(true), model.NewBool(false)) <= 0 {
 		t.Fatalf("bool compare greater")
 	}
-	if compareValues(model.Value{Type: model.TypeBool, V: true}, model.Value{Type: model.TypeBool, V: true}) != 0 {
+	if compareValues(model.NewBool(true), model.NewBool(true)) != 0 {
 		t.Fatalf("bool equal")
 	}
 	tm := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
-	if compareValues(model.Value{Type: model.TypeDateTime, V: tm}, model.Value{Type: model.TypeDateTime, V: tm.Add(time.Hour)}) >= 0 {
+	if compareValues(model.NewDateTime(tm), model.NewDateTime(tm.Add(time.Hour))) >= 0 {
 		t.Fatalf("time compare")
 	}
-	if compareValues(model.Value{Type: model.TypeDateTime, V: tm.Add(time.Hour)}, model.Value{Type: model.TypeDateTime, V: tm}) <= 0 {
+	if compareValues(model.NewDateTime(tm.Add(time.Hour)), model.NewDateTime(tm)) <= 0 {
 		t.Fatalf("time compare greater")
 	}
-	if compareValues(model.Value{Type: model.TypeDateTime, V: tm}, model.Value{Type: model.TypeDateTime, V: tm}) != 0 {
+	if compareValues(model.NewDateTime(tm), model.NewDateTime(tm)) != 0 {
 		t.Fatalf("time equal")
 	}
-	if compareValues(model.Value{Type: model.TypeString, V: "a"}, model.Value{Type: model.TypeString, V: "b"}) >= 0 {
+	if compareValues(model.NewString("a"), model.NewString("b")) >= 0 {
 		t.Fatalf("string compare")
 	}
-	if compareValues(model.Value{Type: model.TypeString, V: "b"}, model.Value{Type: model.TypeString, V: "a"}) <= 0 {
+	if compareValues(model.NewString("b"), model.NewString("a")) <= 0 {
 		t.Fatalf("string compare greater")
 	}
-	if compareValues(model.Value{Type: model.TypeString, V: "a"}, model.Value{Type: model.TypeString, V: "a"}) != 0 {
+	if compareValues(model.NewString("a"), model.NewString("a")) != 0 {
 		t.Fatalf("string equal")
 	}
-	if compareValues(model.Value{Type: model.TypeInt, V: int64(1)}, model.Value{Type: model.TypeFloat, V: float64(2)}) >= 0 {
+	if compareValues(model.NewInt(1), model.NewFloat(2)) >= 0 {
 		t.Fatalf("mixed compare")
 	}
-	if compareValues(model.Value{Type: model.TypeFloat, V: float64(2)}, model.Value{Type: model.TypeInt, V: int64(1)}) <= 0 {
+	if compareValues(model.NewFloat(2), model.NewInt(1)) <= 0 {
 		t.Fatalf("mixed compare float")
 	}
-	if compareValues(model.Value{Type: model.TypeInt, V: int64(2)}, model.Value{Type: model.TypeInt, V: int64(2)}) != 0 {
+	if compareValues(model.NewInt(2), model.NewInt(2)) != 0 {
 		t.Fatalf("int equal")
 	}
-	if compareValues(model.Value{Type: model.TypeFloat, V: float64(2)}, model.Value{Type: model.TypeFloat, V: float64(2)}) != 0 {
+	if compareValues(model.NewFloat(2), model.NewFloat(2)) != 0 {
 		t.Fatalf("float equal")
 	}
-	if compareValues(model.Value{Type: model.Type("other"), V: 1}, model.Value{Type: model.Type("other"), V: 2}) >= 0 {
+	if compareValues(model.NewDynamic([]any{1.0}), model.NewDynamic([]any{2.0})) >= 0 {
 		t.Fatalf("default compare")
 	}
 }
 
 func TestEvalCompareErrors(t *testing.T) {
 	row := sampleRow()
-	_, err := evalCompare(row, plan.CompareExpr{Left: plan.ColumnRef{Name: "age"}, Op: "?", Right: plan.Literal{Value: model.Value{Type: model.TypeInt, V: int64(1)}}})
+	_, err := evalCompare(row, plan.CompareExpr{Left: plan.ColumnRef{Name: "age"}, Op: "?", Right: plan.Literal{Value: model.NewInt(1)}})
 	if err == nil {
 		t.Fatalf("expected operator error")
 	}
-	_, err = evalCompare(row, plan.CompareExpr{Left: badExpr{}, Op: "==", Right: plan.Literal{Value: model.Value{Type: model.TypeInt, V: int64(1)}}})
+	_, err = evalCompare(row, plan.CompareExpr{Left: badExpr{}, Op: "==", Right: plan.Literal{Value: model.NewInt(1)}})
 	if err == nil {
 		t.Fatalf("expected eval error")
 	}
@@ -128,14 +128,14 @@ func TestEvalCompareErrors(t *testing.T) {
 
 func TestEvalLogicalAndOr(t *testing.T) {
 	row := sampleRow()
-	left := plan.CompareExpr{Left: plan.ColumnRef{Name: "age"}, Op: ">", Right: plan.Literal{Value: model.Value{Type: model.TypeInt, V: int64(1)}}}
-	right := plan.CompareExpr{Left: plan.ColumnRef{Name: "age"}, Op: "<", Right: plan.Literal{Value: model.Value{Type: model.TypeInt, V: int64(10)}}}
+	left := plan.CompareExpr{Left: plan.ColumnRef{Name: "age"}, Op: ">", Right: plan.Literal{Value: model.NewInt(1)}}
+	right := plan.CompareExpr{Left: plan.ColumnRef{Name: "age"}, Op: "<", Right: plan.Literal{Value: model.NewInt(10)}}
 	expr := plan.LogicalExpr{Left: left, Op: "and", Right: right}
 	ok, err := evalLogical(row, expr)
 	if err != nil || !ok {
 		t.Fatalf("expected true and")
 	}
-	rightFalse := plan.CompareExpr{Left: plan.ColumnRef{Name: "age"}, Op: ">", Right: plan.Literal{Value: model.Value{Type: model.TypeInt, V: int64(100)}}}
+	rightFalse := plan.CompareExpr{Left: plan.ColumnRef{Name: "age"}, Op: ">", Right: plan.Literal{Value: model.NewInt(100)}}
 	exprFalse := plan.LogicalExpr{Left: left, Op: "and", Right: rightFalse}
 	ok, err = evalLogical(row, exprFalse)
 	if err != nil || ok {
@@ -154,13 +154,13 @@ func TestEvalLogicalAndOr(t *testing.T) {
 
 func TestEvalLogicalShortCircuit(t *testing.T) {
 	row := sampleRow()
-	leftFalse := plan.CompareExpr{Left: plan.ColumnRef{Name: "age"}, Op: ">", Right: plan.Literal{Value: model.Value{Type: model.TypeInt, V: int64(100)}}}
+	leftFalse := plan.CompareExpr{Left: plan.ColumnRef{Name: "age"}, Op: ">", Right: plan.Literal{Value: model.NewInt(100)}}
 	exprAnd := plan.LogicalExpr{Left: leftFalse, Op: "and", Right: badExpr{}}
 	ok, err := evalLogical(row, exprAnd)
 	if err != nil || ok {
 		t.Fatalf("expected short-circuit false")
 	}
-	leftTrue := plan.CompareExpr{Left: plan.ColumnRef{Name: "age"}, Op: ">", Right: plan.Literal{Value: model.Value{Type: model.TypeInt, V: int64(1)}}}
+	leftTrue := plan.CompareExpr{Left: plan.ColumnRef{Name: "age"}, Op: ">", Right: plan.Literal{Value: model.NewInt(1)}}
 	exprOr := plan.LogicalExpr{Left: leftTrue, Op: "or", Right: badExpr{}}
 	ok, err = evalLogical(row, exprOr)
 	if err != nil || !ok {
@@ -183,56 +183,56 @@ func TestEvalLogicalShortCircuit(t *testing.T) {
 
 func TestEvalLogicalCompareExpr(t *testing.T) {
 	row := sampleRow()
-	ok, err := evalLogical(row, plan.CompareExpr{Left: plan.ColumnRef{Name: "age"}, Op: "==", Right: plan.Literal{Value: model.Value{Type: model.TypeInt, V: int64(3)}}})
+	ok, err := evalLogical(row, plan.CompareExpr{Left: plan.ColumnRef{Name: "age"}, Op: "==", Right: plan.Literal{Value: model.NewInt(3)}})
 	if err != nil || !ok {
 		t.Fatalf("expected compare expr true")
 	}
 }
 
 func TestHelpers(t *testing.T) {
-	if toInt64(model.Value{Type: model.TypeBool, V: true}) != 1 {
+	if toInt64(model.NewBool(true)) != 1 {
 		t.Fatalf("toInt64 bool")
 	}
-	if toInt64(model.Value{Type: model.TypeBool, V: false}) != 0 {
+	if toInt64(model.NewBool(false)) != 0 {
 		t.Fatalf("toInt64 bool false")
 	}
-	if toInt64(model.Value{Type: model.TypeFloat, V: 1.5}) != 1 {
+	if toInt64(model.NewFloat(1.5)) != 1 {
 		t.Fatalf("toInt64 float")
 	}
-	if toInt64(model.Value{Type: model.TypeInt, V: int64(2)}) != 2 {
+	if toInt64(model.NewInt(2)) != 2 {
 		t.Fatalf("toInt64 int")
 	}
-	if toInt64(model.Value{Type: model.TypeString, V: "x"}) != 0 {
+	if toInt64(model.NewString("x")) != 0 {
 		t.Fatalf("toInt64 default")
 	}
-	if toFloat64(model.Value{Type: model.TypeBool, V: false}) != 0 {
+	if toFloat64(model.NewBool(false)) != 0 {
 		t.Fatalf("toFloat64 bool")
 	}
-	if toFloat64(model.Value{Type: model.TypeBool, V: true}) != 1 {
+	if toFloat64(model.NewBool(true)) != 1 {
 		t.Fatalf("toFloat64 bool true")
 	}
-	if toFloat64(model.Value{Type: model.TypeInt, V: int64(2)}) != 2 {
+	if toFloat64(model.NewInt(2)) != 2 {
 		t.Fatalf("toFloat64 int")
 	}
-	if toFloat64(model.Value{Type: model.TypeFloat, V: 1.25}) != 1.25 {
+	if toFloat64(model.NewFloat(1.25)) != 1.25 {
 		t.Fatalf("toFloat64 float")
 	}
-	if toFloat64(model.Value{Type: model.TypeString, V: "x"}) != 0 {
+	if toFloat64(model.NewString("x")) != 0 {
 		t.Fatalf("toFloat64 default")
 	}
-	if toBool(model.Value{Type: model.TypeString, V: "true"}) != true {
+	if toBool(model.NewString("true")) != true {
 		t.Fatalf("toBool string")
 	}
-	if toBool(model.Value{Type: model.TypeInt, V: int64(0)}) != false {
+	if toBool(model.NewInt(0)) != false {
 		t.Fatalf("toBool int")
 	}
-	if toBool(model.Value{Type: model.TypeFloat, V: float64(0)}) != false {
+	if toBool(model.NewFloat(0)) != false {
 		t.Fatalf("toBool float")
 	}
-	if !toTime(model.Value{Type: model.TypeDateTime, V: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}).Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+	if !toTime(model.NewDateTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))).Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
 		t.Fatalf("toTime datetime")
 	}
-	if !toTime(model.Value{Type: model.TypeString, V: "x"}).IsZero() {
+	if !toTime(model.NewString("x")).IsZero() {
 		t.Fatalf("toTime default")
 	}
 	if stringsJoin([]string{}, "|") != "" {
@@ -246,7 +246,7 @@ func TestHelpers(t *testing.T) {
 func TestEvalCompareOps(t *testing.T) {
 	row := sampleRow()
 	cmp := func(op string, right int64) bool {
-		ok, err := evalCompare(row, plan.CompareExpr{Left: plan.ColumnRef{Name: "age"}, Op: op, Right: plan.Literal{Value: model.Value{Type: model.TypeInt, V: right}}})
+		ok, err := evalCompare(row, plan.CompareExpr{Left: plan.ColumnRef{Name: "age"}, Op: op, Right: plan.Literal{Value: model.NewInt(right)}})
 		if err != nil {
 			t.Fatalf("compare %s: %v", op, err)
 		}
@@ -264,7 +264,7 @@ func TestEvalCompareOps(t *testing.T) {
 	if !cmp("<", 5) || !cmp("<=", 3) {
 		t.Fatalf("lt/le failed")
 	}
-	if ok, err := evalCompare(row, plan.CompareExpr{Left: plan.ColumnRef{Name: "age"}, Op: "=", Right: plan.Literal{Value: model.Value{Type: model.TypeInt, V: int64(3)}}}); err != nil || !ok {
+	if ok, err := evalCompare(row, plan.CompareExpr{Left: plan.ColumnRef{Name: "age"}, Op: "=", Right: plan.Literal{Value: model.NewInt(3)}}); err != nil || !ok {
 		t.Fatalf("expected = compare")
 	}
 }
@@ -276,7 +276,7 @@ func TestOrderBy(t *testing.T) {
 		t.Fatalf("orderby: %v", err)
 	}
 	row, _ := ord.Next()
-	if row.Values[0].V.(int64) != 1 {
+	if row.Values[0].Int() != 1 {
 		t.Fatalf("expected asc")
 	}
 }
@@ -288,7 +288,7 @@ func TestOrderByDesc(t *testing.T) {
 		t.Fatalf("orderby: %v", err)
 	}
 	row, _ := ord.Next()
-	if row.Values[0].V.(int64) != 2 {
+	if row.Values[0].Int() != 2 {
 		t.Fatalf("expected desc")
 	}
 }
@@ -321,7 +321,7 @@ func TestSummarize(t *testing.T) {
 	if err != nil {
 		t.Fatalf("sum next: %v", err)
 	}
-	if row.Values[1].V.(int64) != 2 {
+	if row.Values[1].Int() != 2 {
 		t.Fatalf("expected count 2")
 	}
 	if _, err := sum.Next(); !errors.Is(err, io.EOF) {
@@ -339,7 +339,7 @@ func TestSummarizeNoBy(t *testing.T) {
 	if err != nil {
 		t.Fatalf("sum next: %v", err)
 	}
-	if row.Values[0].V.(int64) != 1 {
+	if row.Values[0].Int() != 1 {
 		t.Fatalf("expected count 1")
 	}
 }
@@ -353,14 +353,14 @@ func TestSummarizeInputError(t *testing.T) {
 
 func TestFilterNoMatch(t *testing.T) {
 	op := &sliceOp{rows: []*csvio.Row{rowWithInt(1)}}
-	filter := FilterOp{In: op, Expr: plan.CompareExpr{Left: plan.ColumnRef{Name: "n"}, Op: ">", Right: plan.Literal{Value: model.Value{Type: model.TypeInt, V: int64(10)}}}}
+	filter := FilterOp{In: op, Expr: plan.CompareExpr{Left: plan.ColumnRef{Name: "n"}, Op: ">", Right: plan.Literal{Value: model.NewInt(10)}}}
 	if _, err := filter.Next(); err != io.EOF {
 		t.Fatalf("expected EOF")
 	}
 }
 
 func TestFilterInputError(t *testing.T) {
-	filter := FilterOp{In: &errOp{err: errors.New("boom")}, Expr: plan.CompareExpr{Left: plan.ColumnRef{Name: "n"}, Op: ">", Right: plan.Literal{Value: model.Value{Type: model.TypeInt, V: int64(10)}}}}
+	filter := FilterOp{In: &errOp{err: errors.New("boom")}, Expr: plan.CompareExpr{Left: plan.ColumnRef{Name: "n"}, Op: ">", Right: plan.Literal{Value: model.NewInt(10)}}}
 	if _, err := filter.Next(); err == nil {
 		t.Fatalf("expected filter error")
 	}
@@ -375,7 +375,7 @@ func TestFilterExprError(t *testing.T) {
 
 func TestProjectMissingColumn(t *testing.T) {
 	op := &sliceOp{rows: []*csvio.Row{rowWithInt(1)}}
-	proj := ProjectOp{In: op, Columns: []string{"missing"}}
+	proj := &ProjectOp{In: op, Columns: []string{"missing"}}
 	row, err := proj.Next()
 	if err != nil {
 		t.Fatalf("project: %v", err)
@@ -387,19 +387,19 @@ func TestProjectMissingColumn(t *testing.T) {
 
 func TestExtendOp(t *testing.T) {
 	op := &sliceOp{rows: []*csvio.Row{rowWithInt(1)}}
-	ext := ExtendOp{In: op, Name: "x", Value: plan.Literal{Value: model.Value{Type: model.TypeInt, V: int64(5)}}}
+	ext := &ExtendOp{In: op, Name: "x", Value: plan.Literal{Value: model.NewInt(5)}}
 	row, err := ext.Next()
 	if err != nil {
 		t.Fatalf("extend: %v", err)
 	}
-	if row.Values[len(row.Values)-1].V.(int64) != 5 {
+	if row.Values[len(row.Values)-1].Int() != 5 {
 		t.Fatalf("extend value")
 	}
 }
 
 func TestExtendOpError(t *testing.T) {
 	op := &sliceOp{rows: []*csvio.Row{rowWithInt(1)}}
-	ext := ExtendOp{In: op, Name: "x", Value: badExpr{}}
+	ext := &ExtendOp{In: op, Name: "x", Value: badExpr{}}
 	if _, err := ext.Next(); err == nil {
 		t.Fatalf("expected extend error")
 	}
@@ -407,18 +407,39 @@ func TestExtendOpError(t *testing.T) {
 
 func TestExtendOpColumnRef(t *testing.T) {
 	op := &sliceOp{rows: []*csvio.Row{rowWithInt(7)}}
-	ext := ExtendOp{In: op, Name: "copy", Value: plan.ColumnRef{Name: "n"}}
+	ext := &ExtendOp{In: op, Name: "copy", Value: plan.ColumnRef{Name: "n"}}
 	row, err := ext.Next()
 	if err != nil {
 		t.Fatalf("extend: %v", err)
 	}
-	if row.Values[len(row.Values)-1].V.(int64) != 7 {
+	if row.Values[len(row.Values)-1].Int() != 7 {
 		t.Fatalf("extend column ref")
 	}
 }
 
+func TestProjectAndExtendReuseSchemas(t *testing.T) {
+	null := &csvio.Row{Schema: rowWithInt(0).Schema, Values: []model.Value{model.Null}}
+	rows := []*csvio.Row{rowWithInt(1), rowWithInt(2), null}
+	ext := &ExtendOp{In: &sliceOp{rows: rows}, Name: "copy", Value: plan.ColumnRef{Name: "n"}}
+	proj := &ProjectOp{In: ext, Columns: []string{"copy"}}
+	var got []*csvio.Row
+	for range rows {
+		row, err := proj.Next()
+		if err != nil {
+			t.Fatalf("project: %v", err)
+		}
+		got = append(got, row)
+	}
+	if &got[0].Schema.Columns[0] != &got[1].Schema.Columns[0] {
+		t.Fatalf("expected rows of the same types to share a schema")
+	}
+	if got[1].Schema.Columns[0].Type != model.TypeInt || got[2].Schema.Columns[0].Type != model.TypeDynamic {
+		t.Fatalf("expected the schema to follow the value types: %v %v", got[1].Schema.Columns, got[2].Schema.Columns)
+	}
+}
+
 func TestExtendOpInputError(t *testing.T) {
-	ext := ExtendOp{In: &errOp{err: errors.New("boom")}, Name: "x", Value: plan.Literal{Value: model.Value{Type: model.TypeInt, V: int64(1)}}}
+	ext := &ExtendOp{In: &errOp{err: errors.New("boom")}, Name: "x", Value: plan.Literal{Value: model.NewInt(1)}}
 	if _, err := ext.Next(); err == nil {
 		t.Fatalf("expected extend input error")
 	}
@@ -463,8 +484,8 @@ func TestBuildPipelineAllOps(t *testing.T) {
 	defer reader.Close()
 
 	ops := []plan.Operator{
-		plan.WhereOp{Predicate: plan.CompareExpr{Left: plan.ColumnRef{Name: "age"}, Op: ">", Right: plan.Literal{Value: model.Value{Type: model.TypeInt, V: int64(0)}}}},
-		plan.ExtendOp{Name: "x", Value: plan.Literal{Value: model.Value{Type: model.TypeInt, V: int64(1)}}},
+		plan.WhereOp{Predicate: plan.CompareExpr{Left: plan.ColumnRef{Name: "age"}, Op: ">", Right: plan.Literal{Value: model.NewInt(0)}}},
+		plan.ExtendOp{Name: "x", Value: plan.Literal{Value: model.NewInt(1)}},
 		plan.ProjectOp{Columns: []string{"name", "age"}},
 		plan.TakeOp{Count: 1},
 	}
@@ -669,10 +690,10 @@ func TestJoinNoMatch(t *testing.T) {
 
 func rowWithInt(n int64) *csvio.Row {
 	schema := model.NewSchema([]model.Column{{Name: "n", Type: model.TypeInt}})
-	return &csvio.Row{Schema: schema, Values: []model.Value{{Type: model.TypeInt, V: n}}}
+	return &csvio.Row{Schema: schema, Values: []model.Value{model.NewInt(n)}}
 }
 
 func sampleRow() *csvio.Row {
 	schema := model.NewSchema([]model.Column{{Name: "age", Type: model.TypeInt}})
-	return &csvio.Row{Schema: schema, Values: []model.Value{{Type: model.TypeInt, V: int64(3)}}}
+	return &csvio.Row{Schema: schema, Values: []model.Value{model.NewInt(3)}}
 }
@@ -0,0 +1,248 @@
+package exec
+
+import (
+	"io"
+	"sort"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+type MvExpandOp struct {
+	In         Operator
+	Columns    []plan.ExpandColumn
+	IndexName  string
+	Limit      int
+	pending    []*csvio.Row
+	pendingIdx int
+}
+
+func (m *MvExpandOp) Next() (*csvio.Row, error) {
+	for {
+		if m.pendingIdx < len(m.pending) {
+			row := m.pending[m.pendingIdx]
+			m.pendingIdx++
+			return row, nil
+		}
+		row, err := m.In.Next()
+		if err != nil {
+			return nil, err
+		}
+		m.pending, err = expandRow(row, m.Columns, m.IndexName, m.Limit)
+		if err != nil {
+			return nil, err
+		}
+		m.pendingIdx = 0
+	}
+}
+
+type MvApplyOp struct {
+	In         Operator
+	Columns    []plan.ExpandColumn
+	IndexName  string
+	Limit      int
+	Subquery   []plan.Operator
+	pending    []*csvio.Row
+	pendingIdx int
+}
+
+func NewMvApplyOp(in Operator, o plan.MvApplyOp) (*MvApplyOp, error) {
+	if _, err := BuildPipeline(&sliceReader{}, o.Subquery); err != nil {
+		return nil, err
+	}
+	return &MvApplyOp{In: in, Columns: o.Columns, IndexName: o.IndexName, Limit: o.Limit, Subquery: o.Subquery}, nil
+}
+
+func (m *MvApplyOp) Next() (*csvio.Row, error) {
+	for {
+		if m.pendingIdx < len(m.pending) {
+			row := m.pending[m.pendingIdx]
+			m.pendingIdx++
+			return row, nil
+		}
+		row, err := m.In.Next()
+		if err != nil {
+			return nil, err
+		}
+		m.pending, err = m.apply(row)
+		if err != nil {
+			return nil, err
+		}
+		m.pendingIdx = 0
+	}
+}
+
+func (m *MvApplyOp) apply(row *csvio.Row) ([]*csvio.Row, error) {
+	expanded, err := expandRow(row, m.Columns, m.IndexName, m.Limit)
+	if err != nil {
+		return nil, err
+	}
+	pipe, err := BuildPipeline(&sliceReader{rows: expanded}, m.Subquery)
+	if err != nil {
+		return nil, err
+	}
+	var out []*csvio.Row
+	for {
+		sub, err := pipe.Next()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, mergeApplied(row, sub, m.Columns))
+	}
+}
+
+// mergeApplied keeps the outer row's columns that the subquery neither
+// expanded nor produced, followed by the subquery output.
+func mergeApplied(outer, sub *csvio.Row, cols []plan.ExpandColumn) *csvio.Row {
+	skip := make(map[string]bool, len(cols))
+	for _, c := range cols {
+		skip[c.Name] = true
+	}
+	outCols := make([]model.Column, 0, len(outer.Schema.Columns)+len(sub.Schema.Columns))
+	vals := make([]model.Value, 0, cap(outCols))
+	for i, c := range outer.Schema.Columns {
+		if skip[c.Name] {
+			continue
+		}
+		if _, ok := sub.Schema.Index[c.Name]; ok {
+			continue
+		}
+		outCols = append(outCols, c)
+		vals = append(vals, outer.Values[i])
+	}
+	outCols = append(outCols, sub.Schema.Columns...)
+	vals = append(vals, sub.Values...)
+	return &csvio.Row{Schema: model.NewSchema(outCols), Values: vals}
+}
+
+func checkExpandColumns(cols []plan.ExpandColumn) error {
+	for _, c := range cols {
+		if err := checkExpr(c.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func expandRow(row *csvio.Row, cols []plan.ExpandColumn, indexName string, limit int) ([]*csvio.Row, error) {
+	items := make([][]any, len(cols))
+	n := 0
+	for i, c := range cols {
+		v, err := evalExpr(row, c.Value)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = expandItems(v)
+		if len(items[i]) > n {
+			n = len(items[i])
+		}
+	}
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	if n == 0 {
+		return nil, nil
+	}
+
+	schemaCols := append([]model.Column(nil), row.Schema.Columns...)
+	positions := make([]int, len(cols))
+	for i, c := range cols {
+		typ := c.ToType
+		if typ == "" {
+			typ = model.TypeDynamic
+		}
+		if idx, ok := row.Schema.Index[c.Name]; ok {
+			schemaCols[idx] = model.Column{Name: c.Name, Type: typ}
+			positions[i] = idx
+			continue
+		}
+		positions[i] = len(schemaCols)
+		schemaCols = append(schemaCols, model.Column{Name: c.Name, Type: typ})
+	}
+	if indexName != "" {
+		schemaCols = append(schemaCols, model.Column{Name: indexName, Type: model.TypeInt})
+	}
+	schema := model.NewSchema(schemaCols)
+
+	rows := make([]*csvio.Row, 0, n)
+	for j := 0; j < n; j++ {
+		vals := make([]model.Value, len(schemaCols))
+		copy(vals, row.Values)
+		for i, c := range cols {
+			var item any
+			if j < len(items[i]) {
+				item = items[i][j]
+			}
+			vals[positions[i]] = convertItem(item, c.ToType)
+		}
+		if indexName != "" {
+			vals[len(vals)-1] = model.NewInt(int64(j))
+		}
+		rows = append(rows, &csvio.Row{Schema: schema, Values: vals})
+	}
+	return rows, nil
+}
+
+func expandItems(v model.Value) []any {
+	if v.Type != model.TypeDynamic {
+		return []any{model.ToDynamic(v)}
+	}
+	switch raw := v.Dynamic().(type) {
+	case nil:
+		return nil
+	case []any:
+		return raw
+	case map[string]any:
+		keys := make([]string, 0, len(raw))
+		for k := range raw {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := make([]any, len(keys))
+		for i, k := range keys {
+			out[i] = map[string]any{k: raw[k]}
+		}
+		return out
+	default:
+		return []any{raw}
+	}
+}
+
+func convertItem(item any, typ model.Type) model.Value {
+	if typ == "" || typ == model.TypeDynamic {
+		return model.NewDynamic(item)
+	}
+	if item == nil {
+		return dynamicNull
+	}
+	v := model.FromDynamic(item)
+	if v.Type == typ {
+		return v
+	}
+	if typ == model.TypeFloat && v.Type == model.TypeInt {
+		return model.NewFloat(float64(v.Int()))
+	}
+	conv, err := model.ParseValue(typ, v.String())
+	if err != nil {
+		return dynamicNull
+	}
+	return conv
+}
+
+type sliceReader struct {
+	rows []*csvio.Row
+	idx  int
+}
+
+func (s *sliceReader) Next() (*csvio.Row, error) {
+	if s.idx >= len(s.rows) {
+		return nil, io.EOF
+	}
+	row := s.rows[s.idx]
+	s.idx++
+	return row, nil
+}
@@ -0,0 +1,144 @@
+package exec
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+func tagsRow(id int64, tags string) *csvio.Row {
+	raw, _ := model.ParseDynamic(tags)
+	schema := model.NewSchema([]model.Column{{Name: "id", Type: model.TypeInt}, {Name: "tags", Type: model.TypeDynamic}})
+	return &csvio.Row{Schema: schema, Values: []model.Value{model.NewInt(id), model.NewDynamic(raw)}}
+}
+
+func drain(t *testing.T, op Operator) []*csvio.Row {
+	t.Helper()
+	var rows []*csvio.Row
+	for {
+		row, err := op.Next()
+		if err == io.EOF {
+			return rows
+		}
+		if err != nil {
+			t.Fatalf("next: %v", err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestMvExpand(t *testing.T) {
+	in := &sliceOp{rows: []*csvio.Row{tagsRow(1, `["a","b"]`), tagsRow(2, `[]`), tagsRow(3, `["c"]`)}}
+	op := &MvExpandOp{In: in, Columns: []plan.ExpandColumn{{Name: "tags", Value: plan.ColumnRef{Name: "tags"}, ToType: model.TypeString}}, IndexName: "i"}
+	rows := drain(t, op)
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(rows))
+	}
+	tag, _ := rows[1].Get("tags")
+	idx, _ := rows[1].Get("i")
+	if tag.Type != model.TypeString || tag.String() != "b" || idx.Int() != 1 {
+		t.Fatalf("unexpected expanded row: %v %v", tag, idx)
+	}
+	if rows[2].Values[0].Int() != 3 {
+		t.Fatalf("expected empty array row to be dropped")
+	}
+}
+
+func TestMvExpandLimitAndParallel(t *testing.T) {
+	in := &sliceOp{rows: []*csvio.Row{tagsRow(1, `[1,2,3]`)}}
+	cols := []plan.ExpandColumn{
+		{Name: "tags", Value: plan.ColumnRef{Name: "tags"}, ToType: model.TypeInt},
+		{Name: "k", Value: plan.CallExpr{Name: "parse_json", Args: []plan.Expr{plan.Literal{Value: model.NewString(`{"x":1}`)}}}},
+	}
+	rows := drain(t, &MvExpandOp{In: in, Columns: cols, Limit: 2})
+	if len(rows) != 2 {
+		t.Fatalf("expected limit of 2, got %d", len(rows))
+	}
+	if v, _ := rows[0].Get("k"); v.String() != `{"x":1}` {
+		t.Fatalf("expected bag pair, got %s", v.String())
+	}
+	if v, _ := rows[1].Get("k"); !v.IsNull() {
+		t.Fatalf("expected null for shorter column")
+	}
+	if v, _ := rows[1].Get("tags"); v.Type != model.TypeInt || v.Int() != 2 {
+		t.Fatalf("expected typed int, got %v", v)
+	}
+}
+
+func TestMvExpandErrors(t *testing.T) {
+	op := &MvExpandOp{In: &errOp{err: errors.New("boom")}, Columns: []plan.ExpandColumn{{Name: "tags", Value: plan.ColumnRef{Name: "tags"}}}}
+	if _, err := op.Next(); err == nil {
+		t.Fatalf("expected input error")
+	}
+	op = &MvExpandOp{In: &sliceOp{rows: []*csvio.Row{tagsRow(1, `[1]`)}}, Columns: []plan.ExpandColumn{{Name: "tags", Value: badExpr{}}}}
+	if _, err := op.Next(); err == nil {
+		t.Fatalf("expected expr error")
+	}
+}
+
+func TestConvertItem(t *testing.T) {
+	if v := convertItem(float64(2), model.TypeFloat); v.Type != model.TypeFloat {
+		t.Fatalf("expected float conversion")
+	}
+	if v := convertItem("12", model.TypeInt); v.Type != model.TypeInt || v.Int() != 12 {
+		t.Fatalf("expected parsed int")
+	}
+	if v := convertItem("x", model.TypeInt); v.Type != model.TypeDynamic || !v.IsNull() {
+		t.Fatalf("expected null on failed conversion")
+	}
+	if v := convertItem(nil, model.TypeString); !v.IsNull() {
+		t.Fatalf("expected null item")
+	}
+}
+
+func TestMvApply(t *testing.T) {
+	in := &sliceOp{rows: []*csvio.Row{tagsRow(1, `["a","b","a"]`), tagsRow(2, `["b"]`)}}
+	o := plan.MvApplyOp{
+		Columns: []plan.ExpandColumn{{Name: "tags", Value: plan.ColumnRef{Name: "tags"}, ToType: model.TypeString}},
+		Subquery: []plan.Operator{
+			plan.WhereOp{Predicate: plan.CompareExpr{Left: plan.ColumnRef{Name: "tags"}, Op: "==", Right: plan.Literal{Value: model.NewString("a")}}},
+			plan.SummarizeOp{},
+		},
+	}
+	apply, err := NewMvApplyOp(in, o)
+	if err != nil {
+		t.Fatalf("mv-apply: %v", err)
+	}
+	rows := drain(t, apply)
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(rows))
+	}
+	if rows[0].Schema.Columns[0].Name != "id" || rows[0].Schema.Columns[1].Name != "count" {
+		t.Fatalf("unexpected columns: %v", rows[0].Schema.Columns)
+	}
+	if rows[0].Values[1].Int() != 2 {
+		t.Fatalf("expected count 2")
+	}
+}
+
+func TestMvApplyErrors(t *testing.T) {
+	o := plan.MvApplyOp{Columns: []plan.ExpandColumn{{Name: "tags", Value: plan.ColumnRef{Name: "tags"}}}, Subquery: []plan.Operator{badOp{}}}
+	if _, err := NewMvApplyOp(&sliceOp{}, o); err == nil {
+		t.Fatalf("expected subquery plan error")
+	}
+	o.Subquery = []plan.Operator{plan.TakeOp{Count: 1}}
+	apply, err := NewMvApplyOp(&errOp{err: errors.New("boom")}, o)
+	if err != nil {
+		t.Fatalf("mv-apply: %v", err)
+	}
+	if _, err := apply.Next(); err == nil {
+		t.Fatalf("expected input error")
+	}
+	o.Columns[0].Value = badExpr{}
+	apply, _ = NewMvApplyOp(&sliceOp{rows: []*csvio.Row{tagsRow(1, `[1]`)}}, o)
+	if _, err := apply.Next(); err == nil {
+		t.Fatalf("expected expand error")
+	}
+	if _, err := BuildPipeline(&sliceReader{}, []plan.Operator{plan.MvApplyOp{Columns: []plan.ExpandColumn{{Value: plan.CallExpr{Name: "nope"}}}}}); err == nil {
+		t.Fatalf("expected check error")
+	}
+}
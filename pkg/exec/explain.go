@@ -0,0 +1,181 @@
+package exec
+
+import (
+	"fmt"
+
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+// Node describes an executor operator without running it, for explain
+// output.
+type Node struct {
+	// Op names the executor type, such as FilterOp.
+	Op string
+	// Detail is the plan operator it runs, in query syntax.
+	Detail string
+	// Materializes says what the operator holds in memory, or is empty when
+	// it streams rows through.
+	Materializes string
+	// Batch is set for operators running on column batches.
+	Batch bool
+	// Schema is the schema of the rows it returns, when known.
+	Schema *model.Schema
+	// Inputs are the operators it reads from; a join's second input is its
+	// build side.
+	Inputs []*Node
+	// Branches are the pipelines of a fork, each reading the rows the fork
+	// passes on.
+	Branches []*Node
+}
+
+// Describe returns the operator tree Pipeline builds for ops over source,
+// which may be nil for queries starting with a generator. The streaming
+// operators at the start of a pipeline over a source run on batches. schemas, when not
+// nil, holds the output schema of each of ops. input, when not nil, describes
+// the right side of a join by name, so that it can carry its schema.
+func (t Tables) Describe(source *Node, ops []plan.Operator, schemas []*model.Schema, input func(name string) *Node) *Node {
+	cur := source
+	batched := source != nil
+	for i, op := range ops {
+		if _, ok := op.(plan.AsOp); ok {
+			// as only names the result; it has no executor operator.
+			continue
+		}
+		batched = batched && runsOnBatches(op)
+		n := &Node{Op: physicalName(op), Detail: plan.Format(op), Materializes: materializes(op), Batch: batched}
+		if i < len(schemas) {
+			n.Schema = schemas[i]
+		}
+		var schema *model.Schema
+		if cur != nil {
+			schema = cur.Schema
+		}
+		switch o := op.(type) {
+		case plan.RangeOp, plan.DatatableOp, plan.PrintOp:
+		case plan.WhereOp:
+			n.Inputs = []*Node{describeWindow(cur, schema, o.Predicate)}
+		case plan.ExtendOp:
+			n.Inputs = []*Node{describeWindow(cur, schema, o.Value)}
+		case plan.SerializeOp:
+			// serialize runs one extend per column, or nothing.
+			in := cur
+			for _, c := range o.Columns {
+				in = &Node{Op: "ExtendOp", Detail: plan.Format(c), Inputs: []*Node{describeWindow(in, schema, c.Value)}}
+			}
+			if in != cur {
+				in.Schema = n.Schema
+			}
+			n = in
+		case plan.JoinOp:
+			var build *Node
+			switch _, ok := t[o.Right]; {
+			case input != nil:
+				build = input(o.Right)
+			case ok:
+				build = &Node{Op: "Table", Detail: o.Right}
+			default:
+				build = &Node{Op: "CSVReader", Detail: o.Right}
+			}
+			if len(o.RightOps) > 0 {
+				build = t.Describe(build, o.RightOps, nil, input)
+			}
+			n.Inputs = []*Node{cur, build}
+		case plan.ForkOp:
+			n.Inputs = []*Node{cur}
+			for _, b := range o.Branches {
+				n.Branches = append(n.Branches, t.Describe(&Node{Op: "TeeReader", Schema: schema}, b.Ops, nil, input))
+			}
+		default:
+			n.Inputs = []*Node{cur}
+		}
+		if n.Inputs != nil && n.Inputs[0] == nil {
+			n.Inputs = nil
+		}
+		cur = n
+	}
+	return cur
+}
+
+// describeWindow adds the window frame that bindWindow puts below an
+// operator whose expression calls window functions.
+func describeWindow(in *Node, schema *model.Schema, e plan.Expr) *Node {
+	name := windowCall(e)
+	if name == "" {
+		return in
+	}
+	return &Node{Op: "windowFrame", Detail: name + "()", Materializes: "the rows prev() and next() reach", Schema: schema, Inputs: []*Node{in}}
+}
+
+func physicalName(op plan.Operator) string {
+	switch op.(type) {
+	case plan.WhereOp, plan.SearchOp:
+		return "FilterOp"
+	case plan.ProjectOp:
+		return "ProjectOp"
+	case plan.ExtendOp, plan.SerializeOp:
+		return "ExtendOp"
+	case plan.TakeOp:
+		return "TakeOp"
+	case plan.OrderByOp:
+		return "OrderByOp"
+	case plan.SummarizeOp:
+		return "SummarizeOp"
+	case plan.JoinOp:
+		return "JoinOp"
+	case plan.ParseOp:
+		return "ParseOp"
+	case plan.MvExpandOp:
+		return "MvExpandOp"
+	case plan.MvApplyOp:
+		return "MvApplyOp"
+	case plan.MakeSeriesOp:
+		return "MakeSeriesOp"
+	case plan.RangeOp:
+		return "RangeOp"
+	case plan.DatatableOp:
+		return "DatatableOp"
+	case plan.PrintOp:
+		return "PrintOp"
+	case plan.GetSchemaOp:
+		return "GetSchemaOp"
+	case plan.EvaluateOp:
+		return "EvaluateOp"
+	case plan.SampleOp:
+		return "SampleOp"
+	case plan.SampleDistinctOp:
+		return "SampleDistinctOp"
+	case plan.TopOp:
+		return "TopOp"
+	case plan.ForkOp:
+		return "ForkOp"
+	default:
+		return op.Type()
+	}
+}
+
+// materializes describes what the executor operator for op keeps in memory.
+func materializes(op plan.Operator) string {
+	switch o := op.(type) {
+	case plan.OrderByOp:
+		return "all input rows"
+	case plan.SummarizeOp:
+		return "one entry per group"
+	case plan.JoinOp:
+		return "hash table of the build side"
+	case plan.TopOp:
+		return fmt.Sprintf("the best %d rows", o.Count)
+	case plan.MakeSeriesOp:
+		return "one series per group"
+	case plan.EvaluateOp:
+		return "all input rows"
+	case plan.SampleOp:
+		return fmt.Sprintf("a reservoir of %d rows", o.Count)
+	case plan.SampleDistinctOp:
+		return "the distinct values seen"
+	case plan.ForkOp:
+		return "up to 64 rows per branch until every branch has read them"
+	default:
+		return ""
+	}
+}
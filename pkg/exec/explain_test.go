@@ -0,0 +1,84 @@
+package exec
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"kqlfile/pkg/parser"
+)
+
+// describe renders the tree of n as op names, inputs in parentheses and
+// fork branches in brackets.
+func describe(n *Node) string {
+	if n == nil {
+		return ""
+	}
+	parts := make([]string, 0, len(n.Inputs))
+	for _, in := range n.Inputs {
+		parts = append(parts, describe(in))
+	}
+	s := n.Op
+	if len(parts) > 0 {
+		s += "(" + strings.Join(parts, ", ") + ")"
+	}
+	for _, b := range n.Branches {
+		s += "[" + describe(b) + "]"
+	}
+	return s
+}
+
+func TestDescribe(t *testing.T) {
+	cases := []struct{ query, want string }{
+		{"T | where n > 1 | as x | project n", "ProjectOp(FilterOp(CSVReader))"},
+		{"T | extend p = prev(n) | order by p", "OrderByOp(ExtendOp(windowFrame(CSVReader)))"},
+		{"T | serialize a = 1, b = 2", "ExtendOp(ExtendOp(CSVReader))"},
+		{"T | join kind=inner (lookup) on n == n", "JoinOp(CSVReader, Table)"},
+		{"T | fork (take 1) (summarize count())", "ForkOp(CSVReader)[TakeOp(TeeReader)][SummarizeOp(TeeReader)]"},
+		{"range x from 1 to 3 step 1 | take 1", "TakeOp(RangeOp)"},
+	}
+	tables := Tables{"lookup": nil}
+	for _, c := range cases {
+		ops, err := parser.Parse(c.query)
+		if err != nil {
+			t.Fatalf("parse %s: %v", c.query, err)
+		}
+		source := &Node{Op: "CSVReader"}
+		if IsGenerator(ops[0]) {
+			source = nil
+		}
+		if got := describe(tables.Describe(source, ops, nil, nil)); got != c.want {
+			t.Fatalf("%s: got %s, want %s", c.query, got, c.want)
+		}
+	}
+	ops, _ := parser.Parse("T | order by n | summarize count() by g | join kind=inner (lookup) on g == g")
+	n := tables.Describe(&Node{Op: "CSVReader"}, ops, nil, nil)
+	for n != nil && n.Op != "CSVReader" {
+		if n.Materializes == "" {
+			t.Fatalf("expected %s to materialize", n.Op)
+		}
+		n = n.Inputs[0]
+	}
+}
+
+func TestDescribeBatches(t *testing.T) {
+	ops, err := parser.Parse("T | where n > 1 | project n | extend p = prev(n) | take 1")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	var got []string
+	for n := Tables(nil).Describe(&Node{Op: "CSVReader"}, ops, nil, nil); n != nil; n = n.Inputs[0] {
+		got = append(got, fmt.Sprintf("%s=%v", n.Op, n.Batch))
+		if len(n.Inputs) == 0 {
+			break
+		}
+	}
+	want := "TakeOp=false ExtendOp=false windowFrame=false ProjectOp=true FilterOp=true CSVReader=false"
+	if strings.Join(got, " ") != want {
+		t.Fatalf("got %s", strings.Join(got, " "))
+	}
+	ops, _ = parser.Parse("range x from 1 to 3 step 1 | take 1")
+	if n := Tables(nil).Describe(nil, ops, nil, nil); n.Batch {
+		t.Fatalf("take over a generator runs on batches")
+	}
+}
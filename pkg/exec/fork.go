@@ -0,0 +1,234 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/plan"
+)
+
+// Result is one named output of a query. Queries produce a single result
+// unless they end in fork.
+type Result struct {
+	Name string
+	Op   Operator
+}
+
+// BuildResults builds the pipelines for every result set of a query. The
+// branches of a trailing fork share one pass over the upstream rows and must
+// be consumed concurrently.
+func BuildResults(reader RowReader, ops []plan.Operator) ([]Result, error) {
+	return Tables(nil).Results(reader, ops)
+}
+
+// Results is BuildResults with join inputs resolved against t.
+func (t Tables) Results(reader RowReader, ops []plan.Operator) ([]Result, error) {
+	return t.results(reader, PinClock(ops, nowFunc()), nil)
+}
+
+func (t Tables) results(reader RowReader, ops []plan.Operator, stats *Stats) ([]Result, error) {
+	var fork plan.ForkOp
+	ok := false
+	if len(ops) > 0 {
+		fork, ok = ops[len(ops)-1].(plan.ForkOp)
+	}
+	if !ok {
+		op, err := t.pipeline(reader, ops, stats)
+		if err != nil {
+			return nil, err
+		}
+		stats.add(op)
+		return []Result{{Name: resultName(ops), Op: op}}, nil
+	}
+	upstream, err := t.pipeline(reader, ops[:len(ops)-1], stats)
+	if err != nil {
+		return nil, err
+	}
+	stats.add(upstream)
+	for i, b := range fork.Branches {
+		if IsGenerator(b.Ops[0]) {
+			return nil, fmt.Errorf("fork branch %d: %s must be the first operator", i+1, b.Ops[0].Type())
+		}
+		if _, err := t.empty().Pipeline(&sliceReader{}, b.Ops); err != nil {
+			return nil, fmt.Errorf("fork branch %d: %w", i+1, err)
+		}
+	}
+	tee := newTee(upstream, len(fork.Branches))
+	results := make([]Result, len(fork.Branches))
+	for i, b := range fork.Branches {
+		results[i] = Result{Name: b.ResultName(i), Op: &forkBranch{tee: tee, idx: i, ops: b.Ops, tables: t, stats: stats}}
+	}
+	return results, nil
+}
+
+// resultName returns the name given by a trailing as operator.
+func resultName(ops []plan.Operator) string {
+	if len(ops) == 0 {
+		return ""
+	}
+	if as, ok := ops[len(ops)-1].(plan.AsOp); ok {
+		return as.Name
+	}
+	return ""
+}
+
+type teeItem struct {
+	row *csvio.Row
+	err error
+}
+
+// tee reads its input once and hands every row to each branch. The pump
+// starts with the first branch read; a branch that stops reading early is
+// detached so that it does not hold the others back.
+type tee struct {
+	in    Operator
+	outs  []chan teeItem
+	done  []chan struct{}
+	start sync.Once
+}
+
+func newTee(in Operator, n int) *tee {
+	t := &tee{in: in, outs: make([]chan teeItem, n), done: make([]chan struct{}, n)}
+	for i := range t.outs {
+		t.outs[i] = make(chan teeItem, 64)
+		t.done[i] = make(chan struct{})
+	}
+	return t
+}
+
+func (t *tee) pump() {
+	defer func() {
+		for _, ch := range t.outs {
+			close(ch)
+		}
+	}()
+	detached := make([]bool, len(t.outs))
+	for {
+		row, err := t.in.Next()
+		if err == io.EOF {
+			return
+		}
+		item := teeItem{row: row, err: err}
+		for i, ch := range t.outs {
+			if detached[i] {
+				continue
+			}
+			select {
+			case ch <- item:
+			case <-t.done[i]:
+				detached[i] = true
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// teeReader is the source of one fork branch.
+type teeReader struct {
+	tee *tee
+	idx int
+}
+
+func (r *teeReader) Next() (*csvio.Row, error) {
+	r.tee.start.Do(func() { go r.tee.pump() })
+	item, ok := <-r.tee.outs[r.idx]
+	if !ok {
+		return nil, io.EOF
+	}
+	return item.row, item.err
+}
+
+// forkBranch builds its pipeline on first use, because operators such as
+// summarize consume their input while being built and so must run on the
+// goroutine that reads this branch.
+type forkBranch struct {
+	tee      *tee
+	idx      int
+	ops      []plan.Operator
+	tables   Tables
+	stats    *Stats
+	pipe     Operator
+	finished bool
+}
+
+func (b *forkBranch) Next() (*csvio.Row, error) {
+	if b.finished {
+		return nil, io.EOF
+	}
+	if b.pipe == nil {
+		pipe, err := b.tables.pipeline(&teeReader{tee: b.tee, idx: b.idx}, b.ops, b.stats)
+		if err != nil {
+			b.finish()
+			return nil, err
+		}
+		b.stats.add(pipe)
+		b.pipe = pipe
+	}
+	row, err := b.pipe.Next()
+	if err != nil {
+		b.finish()
+	}
+	return row, err
+}
+
+func (b *forkBranch) finish() {
+	b.finished = true
+	close(b.tee.done[b.idx])
+}
+
+type TopOp struct {
+	rows []*csvio.Row
+	idx  int
+}
+
+// NewTopOp keeps the count best rows by column while reading the input,
+// without sorting all of it.
+func NewTopOp(in Operator, count int, column string, desc bool) (*TopOp, error) {
+	before := func(a, b *csvio.Row) bool {
+		va, _ := a.Get(column)
+		vb, _ := b.Get(column)
+		c := compareValues(va, vb)
+		if desc {
+			return c > 0
+		}
+		return c < 0
+	}
+	best := make([]*csvio.Row, 0, count)
+	for {
+		row, err := in.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			continue
+		}
+		if len(best) == count && !before(row, best[len(best)-1]) {
+			continue
+		}
+		i := sort.Search(len(best), func(i int) bool { return before(row, best[i]) })
+		if len(best) < count {
+			best = append(best, nil)
+		}
+		copy(best[i+1:], best[i:len(best)-1])
+		best[i] = row
+	}
+	return &TopOp{rows: best}, nil
+}
+
+func (t *TopOp) Next() (*csvio.Row, error) {
+	if t.idx >= len(t.rows) {
+		return nil, io.EOF
+	}
+	row := t.rows[t.idx]
+	t.idx++
+	return row, nil
+}
@@ -0,0 +1,100 @@
+package exec
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/plan"
+)
+
+func TestTopOp(t *testing.T) {
+	rows := runQuery(t, numberRows(20), "T | top 3 by n")
+	if got := columnStrings(rows, "n"); got != "19,18,17" {
+		t.Fatalf("unexpected top desc: %v", got)
+	}
+	rows = runQuery(t, numberRows(20), "T | top 2 by g asc")
+	if got := columnStrings(rows, "g"); got != "0,0" {
+		t.Fatalf("unexpected top asc: %v", got)
+	}
+	if rows := runQuery(t, numberRows(5), "T | top 0 by n"); len(rows) != 0 {
+		t.Fatalf("expected no rows, got %d", len(rows))
+	}
+	if _, err := NewTopOp(&errOp{err: errors.New("boom")}, 1, "n", true); err == nil {
+		t.Fatalf("expected input error")
+	}
+}
+
+func TestBuildResultsFork(t *testing.T) {
+	ops := mustParse(t, "T | where n > 10 | fork (summarize count()) top2=(top 2 by n) (take 1 | as first) (take 100)")
+	results, err := BuildResults(&sliceReader{rows: numberRows(1000)}, ops)
+	if err != nil {
+		t.Fatalf("build results: %v", err)
+	}
+	names := make([]string, len(results))
+	for i, r := range results {
+		names[i] = r.Name
+	}
+	if strings.Join(names, ",") != "fork1,top2,first,fork4" {
+		t.Fatalf("unexpected names: %v", names)
+	}
+
+	out := make([][]*csvio.Row, len(results))
+	var wg sync.WaitGroup
+	for i, r := range results {
+		wg.Add(1)
+		go func(i int, op Operator) {
+			defer wg.Done()
+			out[i] = drain(t, op)
+		}(i, r.Op)
+	}
+	wg.Wait()
+	if got := columnStrings(out[0], "count"); got != "989" {
+		t.Fatalf("unexpected count: %v", got)
+	}
+	if got := columnStrings(out[1], "n"); got != "999,998" {
+		t.Fatalf("unexpected top: %v", got)
+	}
+	if got := columnStrings(out[2], "n"); got != "11" {
+		t.Fatalf("unexpected first: %v", got)
+	}
+	if len(out[3]) != 100 {
+		t.Fatalf("expected 100 rows, got %d", len(out[3]))
+	}
+}
+
+func TestBuildResultsSingle(t *testing.T) {
+	results, err := BuildResults(&sliceReader{rows: numberRows(3)}, mustParse(t, "T | take 2 | as small"))
+	if err != nil {
+		t.Fatalf("build results: %v", err)
+	}
+	if len(results) != 1 || results[0].Name != "small" || len(drain(t, results[0].Op)) != 2 {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+}
+
+func TestBuildResultsErrors(t *testing.T) {
+	if _, err := BuildResults(&sliceReader{}, mustParse(t, "T | fork (where nope(n))")); err == nil {
+		t.Fatalf("expected branch plan error")
+	}
+	fork := plan.ForkOp{Branches: []plan.ForkBranch{{Ops: []plan.Operator{plan.PrintOp{}}}}}
+	if _, err := BuildResults(&sliceReader{}, []plan.Operator{fork}); err == nil {
+		t.Fatalf("expected generator error")
+	}
+	if _, err := BuildPipeline(&sliceReader{}, []plan.Operator{fork}); err == nil {
+		t.Fatalf("expected fork error from BuildPipeline")
+	}
+
+	results, err := BuildResults(&errOp{err: errors.New("boom")}, mustParse(t, "T | fork (take 1) (take 1)"))
+	if err != nil {
+		t.Fatalf("build results: %v", err)
+	}
+	for _, r := range results {
+		if _, err := r.Op.Next(); err == nil || err == io.EOF {
+			t.Fatalf("expected input error, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package exec
+
+import (
+	"fmt"
+	"sort"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+type scalarFunc struct {
+	minArgs int
+	maxArgs int
+	eval    func(args []model.Value) (model.Value, error)
+	check   func(args []plan.Expr) error
+}
+
+var scalarFuncs = map[string]scalarFunc{
+	"parse_json":   {minArgs: 1, maxArgs: 1, eval: fnParseJSON},
+	"todynamic":    {minArgs: 1, maxArgs: 1, eval: fnParseJSON},
+	"array_length": {minArgs: 1, maxArgs: 1, eval: fnArrayLength},
+	"bag_keys":     {minArgs: 1, maxArgs: 1, eval: fnBagKeys},
+	"bag_has_key":  {minArgs: 2, maxArgs: 2, eval: fnBagHasKey},
+	"extract":      {minArgs: 3, maxArgs: 4, eval: fnExtract, check: checkRegexArg},
+	"extract_all":  {minArgs: 2, maxArgs: 3, eval: fnExtractAll, check: checkRegexArg},
+	"now":          {minArgs: 0, maxArgs: 1, eval: fnNow},
+	"ago":          {minArgs: 1, maxArgs: 1, eval: fnAgo},
+	"bin":          {minArgs: 2, maxArgs: 2, eval: fnBin},
+	"todatetime":   {minArgs: 1, maxArgs: 1, eval: fnToDatetime},
+	"totimespan":   {minArgs: 1, maxArgs: 1, eval: fnToTimespan},
+
+	"ipv4_is_in_range":    {minArgs: 2, maxArgs: 2, eval: fnIPv4IsInRange},
+	"ipv4_is_private":     {minArgs: 1, maxArgs: 1, eval: fnIPv4IsPrivate},
+	"ipv4_is_match":       {minArgs: 2, maxArgs: 3, eval: fnIPv4IsMatch},
+	"ipv4_compare":        {minArgs: 2, maxArgs: 3, eval: fnIPv4Compare},
+	"ipv6_compare":        {minArgs: 2, maxArgs: 3, eval: fnIPv6Compare},
+	"parse_ipv4":          {minArgs: 1, maxArgs: 1, eval: fnParseIPv4},
+	"format_ipv4_mask":    {minArgs: 1, maxArgs: 2, eval: fnFormatIPv4Mask},
+	"has_any_ipv4_prefix": {minArgs: 2, maxArgs: -1, eval: fnHasAnyIPv4Prefix, check: checkIPv4Prefixes},
+
+	"parse_url":              {minArgs: 1, maxArgs: 1, eval: fnParseURL},
+	"parse_urlquery":         {minArgs: 1, maxArgs: 1, eval: fnParseURLQuery},
+	"url_decode":             {minArgs: 1, maxArgs: 1, eval: fnURLDecode},
+	"url_encode":             {minArgs: 1, maxArgs: 1, eval: fnURLEncode},
+	"parse_path":             {minArgs: 1, maxArgs: 1, eval: fnParsePath},
+	"base64_encode_tostring": {minArgs: 1, maxArgs: 1, eval: fnBase64Encode},
+	"base64_decode_tostring": {minArgs: 1, maxArgs: 1, eval: fnBase64Decode},
+	"hash_sha256":            {minArgs: 1, maxArgs: 1, eval: fnHashSHA256},
+	"hash_md5":               {minArgs: 1, maxArgs: 1, eval: fnHashMD5},
+	"hash":                   {minArgs: 1, maxArgs: 2, eval: fnHash},
+
+	"geo_distance_2points":         {minArgs: 4, maxArgs: 4, eval: fnGeoDistance2Points},
+	"geo_point_in_circle":          {minArgs: 5, maxArgs: 5, eval: fnGeoPointInCircle},
+	"geo_point_in_polygon":         {minArgs: 3, maxArgs: 3, eval: fnGeoPointInPolygon, check: checkPolygonArg},
+	"geo_point_to_geohash":         {minArgs: 2, maxArgs: 3, eval: fnGeoPointToGeohash},
+	"geo_geohash_to_central_point": {minArgs: 1, maxArgs: 1, eval: fnGeoGeohashToCentralPoint},
+
+	"dcount_hll":         {minArgs: 1, maxArgs: 1, eval: fnDcountHLL},
+	"percentile_tdigest": {minArgs: 2, maxArgs: 2, eval: fnPercentileTDigest},
+
+	"series_stats":               {minArgs: 1, maxArgs: 2, eval: fnSeriesStats},
+	"series_stats_dynamic":       {minArgs: 1, maxArgs: 2, eval: fnSeriesStats},
+	"series_fill_forward":        {minArgs: 1, maxArgs: 2, eval: fnSeriesFillForward},
+	"series_fill_linear":         {minArgs: 1, maxArgs: 3, eval: fnSeriesFillLinear},
+	"series_decompose_anomalies": {minArgs: 1, maxArgs: 4, eval: fnSeriesDecomposeAnomalies},
+}
+
+var dynamicNull = model.Null
+
+func checkExpr(expr plan.Expr) error {
+	switch e := expr.(type) {
+	case plan.CompareExpr:
+		if err := checkExpr(e.Left); err != nil {
+			return err
+		}
+		return checkExpr(e.Right)
+	case plan.LogicalExpr:
+		if err := checkExpr(e.Left); err != nil {
+			return err
+		}
+		return checkExpr(e.Right)
+	case plan.BinaryExpr:
+		if err := checkExpr(e.Left); err != nil {
+			return err
+		}
+		return checkExpr(e.Right)
+	case *windowExpr:
+		for _, a := range e.call.Args {
+			if err := checkExpr(a); err != nil {
+				return err
+			}
+		}
+	case plan.MemberExpr:
+		return checkExpr(e.Target)
+	case plan.IndexExpr:
+		if err := checkExpr(e.Target); err != nil {
+			return err
+		}
+		return checkExpr(e.Index)
+	case plan.CallExpr:
+		if _, ok := windowFuncs[e.Name]; ok {
+			return fmt.Errorf("%s() is only supported in where, extend and serialize", e.Name)
+		}
+		fn, ok := scalarFuncs[e.Name]
+		if !ok {
+			return fmt.Errorf("unknown function: %s", e.Name)
+		}
+		if len(e.Args) < fn.minArgs || (fn.maxArgs >= 0 && len(e.Args) > fn.maxArgs) {
+			return fmt.Errorf("%s: wrong number of arguments (%d)", e.Name, len(e.Args))
+		}
+		for _, a := range e.Args {
+			if err := checkExpr(a); err != nil {
+				return err
+			}
+		}
+		if fn.check != nil {
+			return fn.check(e.Args)
+		}
+	}
+	return nil
+}
+
+func evalCall(row *csvio.Row, call plan.CallExpr) (model.Value, error) {
+	fn, ok := scalarFuncs[call.Name]
+	if !ok {
+		return model.Value{}, fmt.Errorf("unknown function: %s", call.Name)
+	}
+	args := make([]model.Value, len(call.Args))
+	for i, a := range call.Args {
+		v, err := evalExpr(row, a)
+		if err != nil {
+			return model.Value{}, err
+		}
+		args[i] = v
+	}
+	return fn.eval(args)
+}
+
+func dottedName(expr plan.Expr) (string, bool) {
+	switch e := expr.(type) {
+	case plan.ColumnRef:
+		return e.Name, true
+	case plan.MemberExpr:
+		prefix, ok := dottedName(e.Target)
+		if !ok {
+			return "", false
+		}
+		return prefix + "." + e.Name, true
+	default:
+		return "", false
+	}
+}
+
+func dynamicMember(v model.Value, name string) model.Value {
+	if v.Type != model.TypeDynamic {
+		return dynamicNull
+	}
+	bag, ok := v.Dynamic().(map[string]any)
+	if !ok {
+		return dynamicNull
+	}
+	raw, ok := bag[name]
+	if !ok {
+		return dynamicNull
+	}
+	return model.FromDynamic(raw)
+}
+
+func dynamicIndex(v, idx model.Value) (model.Value, error) {
+	switch idx.Type {
+	case model.TypeString:
+		return dynamicMember(v, idx.String()), nil
+	case model.TypeInt:
+		if v.Type != model.TypeDynamic {
+			return dynamicNull, nil
+		}
+		arr, ok := v.Dynamic().([]any)
+		if !ok {
+			return dynamicNull, nil
+		}
+		i := idx.Int()
+		if i < 0 {
+			i += int64(len(arr))
+		}
+		if i < 0 || i >= int64(len(arr)) {
+			return dynamicNull, nil
+		}
+		return model.FromDynamic(arr[i]), nil
+	default:
+		return model.Value{}, fmt.Errorf("index must be string or int, got %s", idx.Type)
+	}
+}
+
+func fnParseJSON(args []model.Value) (model.Value, error) {
+	v := args[0]
+	switch v.Type {
+	case model.TypeDynamic:
+		return v, nil
+	case model.TypeString:
+		raw, err := model.ParseDynamic(v.String())
+		if err != nil {
+			return model.NewDynamic(v.String()), nil
+		}
+		return model.NewDynamic(raw), nil
+	default:
+		return model.NewDynamic(model.ToDynamic(v)), nil
+	}
+}
+
+func fnArrayLength(args []model.Value) (model.Value, error) {
+	arr, ok := args[0].Dynamic().([]any)
+	if args[0].Type != model.TypeDynamic || !ok {
+		return dynamicNull, nil
+	}
+	return model.NewInt(int64(len(arr))), nil
+}
+
+func fnBagKeys(args []model.Value) (model.Value, error) {
+	bag, ok := args[0].Dynamic().(map[string]any)
+	if args[0].Type != model.TypeDynamic || !ok {
+		return dynamicNull, nil
+	}
+	keys := make([]string, 0, len(bag))
+	for k := range bag {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]any, len(keys))
+	for i, k := range keys {
+		out[i] = k
+	}
+	return model.NewDynamic(out), nil
+}
+
+func fnBagHasKey(args []model.Value) (model.Value, error) {
+	bag, ok := args[0].Dynamic().(map[string]any)
+	if args[0].Type != model.TypeDynamic || !ok {
+		return model.NewBool(false), nil
+	}
+	_, has := bag[args[1].String()]
+	return model.NewBool(has), nil
+}
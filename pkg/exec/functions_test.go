@@ -0,0 +1,114 @@
+package exec
+
+import (
+	"testing"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+func dynamicRow(t *testing.T) *csvio.Row {
+	props, err := model.ParseDynamic(`{"user":{"name":"alice"},"status":200,"tags":["a","b"]}`)
+	if err != nil {
+		t.Fatalf("parse dynamic: %v", err)
+	}
+	schema := model.NewSchema([]model.Column{
+		{Name: "props", Type: model.TypeDynamic},
+		{Name: "right.id", Type: model.TypeInt},
+	})
+	return &csvio.Row{Schema: schema, Values: []model.Value{
+		model.NewDynamic(props),
+		model.NewInt(9),
+	}}
+}
+
+func TestEvalDynamicAccess(t *testing.T) {
+	row := dynamicRow(t)
+	name := plan.MemberExpr{Target: plan.MemberExpr{Target: plan.ColumnRef{Name: "props"}, Name: "user"}, Name: "name"}
+	v, err := evalExpr(row, name)
+	if err != nil || v.Type != model.TypeString || v.String() != "alice" {
+		t.Fatalf("member access: %v %v", v, err)
+	}
+	status := plan.IndexExpr{Target: plan.ColumnRef{Name: "props"}, Index: plan.Literal{Value: model.NewString("status")}}
+	v, err = evalExpr(row, status)
+	if err != nil || v.Type != model.TypeInt || v.Int() != 200 {
+		t.Fatalf("string index: %v %v", v, err)
+	}
+	last := plan.IndexExpr{Target: plan.MemberExpr{Target: plan.ColumnRef{Name: "props"}, Name: "tags"}, Index: plan.Literal{Value: model.NewInt(-1)}}
+	v, err = evalExpr(row, last)
+	if err != nil || v.String() != "b" {
+		t.Fatalf("negative index: %v %v", v, err)
+	}
+	out := plan.IndexExpr{Target: plan.ColumnRef{Name: "props"}, Index: plan.Literal{Value: model.NewInt(5)}}
+	if v, _ = evalExpr(row, out); v.Type != model.TypeDynamic || !v.IsNull() {
+		t.Fatalf("expected null for non-array index")
+	}
+	bad := plan.IndexExpr{Target: plan.ColumnRef{Name: "props"}, Index: plan.Literal{Value: model.NewBool(true)}}
+	if _, err := evalExpr(row, bad); err == nil {
+		t.Fatalf("expected index type error")
+	}
+	dotted := plan.MemberExpr{Target: plan.ColumnRef{Name: "right"}, Name: "id"}
+	if v, _ = evalExpr(row, dotted); v.String() != "9" {
+		t.Fatalf("expected dotted column lookup, got %v", v)
+	}
+}
+
+func TestDynamicFunctions(t *testing.T) {
+	row := dynamicRow(t)
+	call := func(name string, args ...plan.Expr) model.Value {
+		v, err := evalExpr(row, plan.CallExpr{Name: name, Args: args})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return v
+	}
+	props := plan.ColumnRef{Name: "props"}
+	tags := plan.MemberExpr{Target: props, Name: "tags"}
+	if v := call("array_length", tags); v.Int() != 2 {
+		t.Fatalf("array_length: %v", v)
+	}
+	if v := call("array_length", props); !v.IsNull() {
+		t.Fatalf("array_length of bag should be null")
+	}
+	if v := call("bag_keys", props); v.String() != `["status","tags","user"]` {
+		t.Fatalf("bag_keys: %v", v)
+	}
+	key := plan.Literal{Value: model.NewString("user")}
+	if v := call("bag_has_key", props, key); !v.Bool() {
+		t.Fatalf("bag_has_key")
+	}
+	raw := plan.Literal{Value: model.NewString(`{"a":[1,2,3]}`)}
+	parsed := plan.MemberExpr{Target: plan.CallExpr{Name: "parse_json", Args: []plan.Expr{raw}}, Name: "a"}
+	v, err := evalExpr(row, plan.CallExpr{Name: "array_length", Args: []plan.Expr{parsed}})
+	if err != nil || v.Int() != 3 {
+		t.Fatalf("parse_json: %v %v", v, err)
+	}
+	text := plan.Literal{Value: model.NewString("not json")}
+	if v := call("todynamic", text); v.Type != model.TypeDynamic || v.String() != "not json" {
+		t.Fatalf("todynamic fallback: %v", v)
+	}
+}
+
+func TestCheckExpr(t *testing.T) {
+	if err := checkExpr(plan.CallExpr{Name: "nope"}); err == nil {
+		t.Fatalf("expected unknown function error")
+	}
+	if err := checkExpr(plan.CallExpr{Name: "bag_keys"}); err == nil {
+		t.Fatalf("expected arity error")
+	}
+	nested := plan.CompareExpr{Left: plan.CallExpr{Name: "array_length", Args: []plan.Expr{plan.CallExpr{Name: "nope"}}}, Op: ">", Right: plan.Literal{}}
+	if err := checkExpr(nested); err == nil {
+		t.Fatalf("expected nested error")
+	}
+	if _, err := evalExpr(sampleRow(), plan.CallExpr{Name: "nope"}); err == nil {
+		t.Fatalf("expected runtime unknown function error")
+	}
+}
+
+func TestWhereNonBoolean(t *testing.T) {
+	filter := FilterOp{In: &sliceOp{rows: []*csvio.Row{rowWithInt(1)}}, Expr: plan.CallExpr{Name: "array_length", Args: []plan.Expr{plan.ColumnRef{Name: "n"}}}}
+	if _, err := filter.Next(); err == nil {
+		t.Fatalf("expected non-boolean predicate error")
+	}
+}
@@ -0,0 +1,166 @@
+package exec
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+// IsGenerator reports whether op produces rows on its own, without an input.
+func IsGenerator(op plan.Operator) bool {
+	switch op.(type) {
+	case plan.RangeOp, plan.DatatableOp, plan.PrintOp:
+		return true
+	default:
+		return false
+	}
+}
+
+// RangeOp yields from, from+step, ... up to to. Row i is computed as
+// from + i*step rather than by adding step to the previous row, so float
+// steps do not accumulate rounding error, and the range ends when the next
+// value would overflow.
+type RangeOp struct {
+	schema model.Schema
+	from   model.Value
+	to     model.Value
+	step   model.Value
+	desc   bool
+	i      int64
+}
+
+func NewRangeOp(o plan.RangeOp) (*RangeOp, error) {
+	empty := &csvio.Row{}
+	from, err := evalConst(empty, o.From)
+	if err != nil {
+		return nil, fmt.Errorf("range from: %w", err)
+	}
+	to, err := evalConst(empty, o.To)
+	if err != nil {
+		return nil, fmt.Errorf("range to: %w", err)
+	}
+	step, err := evalConst(empty, o.Step)
+	if err != nil {
+		return nil, fmt.Errorf("range step: %w", err)
+	}
+	if _, err := arith("+", from, step); err != nil {
+		return nil, fmt.Errorf("range: %w", err)
+	}
+	sign := compareValues(step, zeroOf(step.Type))
+	if sign == 0 {
+		return nil, fmt.Errorf("range: step must not be zero")
+	}
+	if isNumeric(from) && from.Type != step.Type {
+		from = model.NewFloat(toFloat64(from))
+	}
+	schema := model.NewSchema([]model.Column{{Name: o.Column, Type: from.Type}})
+	return &RangeOp{schema: schema, from: from, to: to, step: step, desc: sign < 0}, nil
+}
+
+func (r *RangeOp) Next() (*csvio.Row, error) {
+	cur, ok := r.at(r.i)
+	if !ok {
+		return nil, io.EOF
+	}
+	c := compareValues(cur, r.to)
+	if r.desc && c < 0 || !r.desc && c > 0 {
+		return nil, io.EOF
+	}
+	r.i++
+	return &csvio.Row{Schema: r.schema, Values: []model.Value{cur}}, nil
+}
+
+// at returns from + i*step, or false when it overflows or the operands have
+// no range arithmetic.
+func (r *RangeOp) at(i int64) (model.Value, bool) {
+	switch {
+	case r.from.Type == model.TypeFloat:
+		return model.NewFloat(r.from.Float() + float64(i)*toFloat64(r.step)), true
+	case r.from.Type == model.TypeInt && r.step.Type == model.TypeInt:
+		n, ok := mulAdd(r.from.Int(), i, r.step.Int())
+		return model.NewInt(n), ok
+	case r.from.Type == model.TypeTimespan && r.step.Type == model.TypeTimespan:
+		n, ok := mulAdd(int64(r.from.Timespan()), i, int64(r.step.Timespan()))
+		return model.NewTimespan(time.Duration(n)), ok
+	case r.from.Type == model.TypeDateTime && r.step.Type == model.TypeTimespan:
+		off, ok := mulAdd(0, i, int64(r.step.Timespan()))
+		return model.NewDateTime(r.from.DateTime().Add(time.Duration(off))), ok
+	default:
+		return model.Value{}, false
+	}
+}
+
+// mulAdd returns a + i*step and whether it fits in an int64.
+func mulAdd(a, i, step int64) (int64, bool) {
+	off := i * step
+	if i != 0 && (off/i != step || i == -1 && step == math.MinInt64) {
+		return 0, false
+	}
+	n := a + off
+	if off > 0 && n < a || off < 0 && n > a {
+		return 0, false
+	}
+	return n, true
+}
+
+func newDatatable(o plan.DatatableOp) (Operator, error) {
+	schema := model.NewSchema(o.Columns)
+	empty := &csvio.Row{}
+	var rows []*csvio.Row
+	for i := 0; i < len(o.Values); i += len(o.Columns) {
+		vals := make([]model.Value, len(o.Columns))
+		for j, c := range o.Columns {
+			v, err := evalConst(empty, o.Values[i+j])
+			if err != nil {
+				return nil, fmt.Errorf("datatable: %w", err)
+			}
+			if vals[j], err = convertValue(v, c.Type); err != nil {
+				return nil, fmt.Errorf("datatable column %s: %w", c.Name, err)
+			}
+		}
+		rows = append(rows, &csvio.Row{Schema: schema, Values: vals})
+	}
+	return &sliceReader{rows: rows}, nil
+}
+
+func newPrint(o plan.PrintOp) (Operator, error) {
+	cols := make([]model.Column, len(o.Columns))
+	vals := make([]model.Value, len(o.Columns))
+	empty := &csvio.Row{}
+	for i, c := range o.Columns {
+		v, err := evalConst(empty, c.Value)
+		if err != nil {
+			return nil, fmt.Errorf("print %s: %w", c.Name, err)
+		}
+		cols[i] = model.Column{Name: c.Name, Type: v.Type}
+		vals[i] = v
+	}
+	return &sliceReader{rows: []*csvio.Row{{Schema: model.NewSchema(cols), Values: vals}}}, nil
+}
+
+// convertValue coerces a literal to a declared column type.
+func convertValue(v model.Value, typ model.Type) (model.Value, error) {
+	switch {
+	case v.Type == typ:
+		return v, nil
+	case v.IsNull():
+		return model.NullOf(typ), nil
+	case typ == model.TypeDynamic:
+		return model.NewDynamic(model.ToDynamic(v)), nil
+	case typ == model.TypeFloat && v.Type == model.TypeInt:
+		return model.NewFloat(float64(v.Int())), nil
+	case typ == model.TypeDateTime && v.Type == model.TypeString:
+		t, _ := fnToDatetime([]model.Value{v})
+		if t.IsNull() {
+			return model.Value{}, fmt.Errorf("invalid datetime %q", v.String())
+		}
+		return t, nil
+	default:
+		return model.ParseValue(typ, v.String())
+	}
+}
@@ -0,0 +1,59 @@
+package exec
+
+import (
+	"strings"
+	"testing"
+
+	"kqlfile/pkg/parser"
+	"kqlfile/pkg/plan"
+)
+
+func TestGenerators(t *testing.T) {
+	cases := map[string]string{
+		"range x from 1 to 10 step 4":                                                "1,5,9",
+		"range x from 5 to 1 step -2":                                                "5,3,1",
+		"range x from 1 to 2 step 0.5":                                               "1,1.5,2",
+		"range x from 0 to 1 step 0.1 | where x > 0.9":                               "1",
+		"range x from 1 to 9223372036854775807 step 4000000000000000000":             "1,4000000000000000001,8000000000000000001",
+		"range x from -9223372036854775807 to -9223372036854775808 step -1":          "-9223372036854775807,-9223372036854775808",
+		"range x from 0h to 3h step 1h | where x > 1h":                               "02:00:00,03:00:00",
+		`range x from todatetime("2024-01-01") to todatetime("2024-01-02") step 12h`: "2024-01-01T00:00:00Z,2024-01-01T12:00:00Z,2024-01-02T00:00:00Z",
+		`datatable(x:float, s:string)[1, "a", 2.5, "b"]`:                             "1,2.5",
+		`datatable(x:datetime)["2024-01-01"] | extend y = x + 1d`:                    "2024-01-01T00:00:00Z",
+		"print x = 6 * 7":                          "42",
+		"print x = 1 | serialize n = row_number()": "1",
+	}
+	for query, want := range cases {
+		if got := columnStrings(runQuery(t, nil, query), "x"); got != want {
+			t.Fatalf("%s: got %s, want %s", query, got, want)
+		}
+	}
+	rows := runQuery(t, nil, `datatable(x:int, d:dynamic)[1, "a"]`)
+	if v, _ := rows[0].Get("d"); v.String() != "a" {
+		t.Fatalf("unexpected dynamic value: %s", v.String())
+	}
+}
+
+func TestGeneratorErrors(t *testing.T) {
+	cases := map[string]string{
+		"range x from 1 to 10 step 0":    "step must not be zero",
+		"range x from 1 to 10 step y":    "constant",
+		`range x from "a" to "b" step 1`: "cannot apply",
+		`datatable(x:int)["a"]`:          "column x",
+		`datatable(x:datetime)["soon"]`:  "invalid datetime",
+		"print x = y":                    "constant",
+	}
+	for query, want := range cases {
+		ops, err := parser.Parse(query)
+		if err != nil {
+			t.Fatalf("%s: parse: %v", query, err)
+		}
+		if _, err := BuildPipeline(&sliceReader{}, ops); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected %q, got %v", query, want, err)
+		}
+	}
+	ops := []plan.Operator{plan.TakeOp{Count: 1}, plan.PrintOp{}}
+	if _, err := BuildPipeline(&sliceReader{}, ops); err == nil || !strings.Contains(err.Error(), "must be the first operator") {
+		t.Fatalf("expected position error, got %v", err)
+	}
+}
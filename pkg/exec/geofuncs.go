@@ -0,0 +1,253 @@
+package exec
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+// earthRadius is the mean Earth radius in meters. Distances use the
+// haversine formula on a sphere of this radius.
+const earthRadius = 6371008.8
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// coords reads a longitude and latitude, reporting false for nulls and
+// values outside [-180, 180] and [-90, 90].
+func coords(lng, lat model.Value) (float64, float64, bool) {
+	lng, lat = scalarOf(lng), scalarOf(lat)
+	if !isNumeric(lng) || !isNumeric(lat) {
+		return 0, 0, false
+	}
+	x, y := toFloat64(lng), toFloat64(lat)
+	if math.Abs(x) > 180 || math.Abs(y) > 90 || math.IsNaN(x) || math.IsNaN(y) {
+		return 0, 0, false
+	}
+	return x, y, true
+}
+
+func haversine(lng1, lat1, lng2, lat2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLng := (lng2 - lng1) * rad
+	a := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Pow(math.Sin(dLng/2), 2)
+	return 2 * earthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+func fnGeoDistance2Points(args []model.Value) (model.Value, error) {
+	lng1, lat1, ok1 := coords(args[0], args[1])
+	lng2, lat2, ok2 := coords(args[2], args[3])
+	if !ok1 || !ok2 {
+		return dynamicNull, nil
+	}
+	return model.NewFloat(haversine(lng1, lat1, lng2, lat2)), nil
+}
+
+func fnGeoPointInCircle(args []model.Value) (model.Value, error) {
+	lng, lat, ok1 := coords(args[0], args[1])
+	clng, clat, ok2 := coords(args[2], args[3])
+	radius := scalarOf(args[4])
+	if !ok1 || !ok2 || !isNumeric(radius) || toFloat64(radius) < 0 {
+		return dynamicNull, nil
+	}
+	return model.NewBool(haversine(lng, lat, clng, clat) <= toFloat64(radius)), nil
+}
+
+// geoPolygon is a GeoJSON polygon: an outer ring followed by holes, each a
+// list of [lng, lat] vertices.
+type geoPolygon [][][2]float64
+
+// parsePolygons accepts a GeoJSON Polygon or MultiPolygon, as a dynamic bag
+// or as JSON text.
+func parsePolygons(v model.Value) ([]geoPolygon, error) {
+	v = scalarOf(v)
+	raw := v.Dynamic()
+	if v.Type == model.TypeString {
+		parsed, err := model.ParseDynamic(v.String())
+		if err != nil {
+			return nil, fmt.Errorf("geo_point_in_polygon: invalid GeoJSON: %w", err)
+		}
+		raw = parsed
+	} else if v.Type != model.TypeDynamic {
+		return nil, fmt.Errorf("geo_point_in_polygon: polygon must be dynamic, got %s", v.Type)
+	}
+	bag, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("geo_point_in_polygon: polygon must be a GeoJSON object")
+	}
+	coordinates, _ := bag["coordinates"].([]any)
+	switch bag["type"] {
+	case "Polygon":
+		p, err := parsePolygon(coordinates)
+		if err != nil {
+			return nil, err
+		}
+		return []geoPolygon{p}, nil
+	case "MultiPolygon":
+		out := make([]geoPolygon, 0, len(coordinates))
+		for _, c := range coordinates {
+			rings, _ := c.([]any)
+			p, err := parsePolygon(rings)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, p)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("geo_point_in_polygon: expected a Polygon or MultiPolygon, got %v", bag["type"])
+	}
+}
+
+func parsePolygon(rings []any) (geoPolygon, error) {
+	if len(rings) == 0 {
+		return nil, fmt.Errorf("geo_point_in_polygon: polygon has no rings")
+	}
+	poly := make(geoPolygon, len(rings))
+	for i, r := range rings {
+		points, _ := r.([]any)
+		if len(points) < 4 {
+			return nil, fmt.Errorf("geo_point_in_polygon: a ring needs at least 4 positions")
+		}
+		ring := make([][2]float64, len(points))
+		for j, p := range points {
+			pair, _ := p.([]any)
+			if len(pair) < 2 {
+				return nil, fmt.Errorf("geo_point_in_polygon: invalid position %v", p)
+			}
+			lng, lat, ok := coords(model.FromDynamic(pair[0]), model.FromDynamic(pair[1]))
+			if !ok {
+				return nil, fmt.Errorf("geo_point_in_polygon: invalid position %v", p)
+			}
+			ring[j] = [2]float64{lng, lat}
+		}
+		poly[i] = ring
+	}
+	return poly, nil
+}
+
+// inRing is the even-odd ray casting test, treating edges as straight lines
+// in longitude/latitude.
+func inRing(ring [][2]float64, lng, lat float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a[1] > lat) != (b[1] > lat) && lng < (b[0]-a[0])*(lat-a[1])/(b[1]-a[1])+a[0] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func (p geoPolygon) contains(lng, lat float64) bool {
+	if !inRing(p[0], lng, lat) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if inRing(hole, lng, lat) {
+			return false
+		}
+	}
+	return true
+}
+
+func fnGeoPointInPolygon(args []model.Value) (model.Value, error) {
+	polys, err := parsePolygons(args[2])
+	if err != nil {
+		return model.Value{}, err
+	}
+	lng, lat, ok := coords(args[0], args[1])
+	if !ok {
+		return dynamicNull, nil
+	}
+	for _, p := range polys {
+		if p.contains(lng, lat) {
+			return model.NewBool(true), nil
+		}
+	}
+	return model.NewBool(false), nil
+}
+
+func checkPolygonArg(args []plan.Expr) error {
+	lit, ok := args[2].(plan.Literal)
+	if !ok {
+		return nil
+	}
+	_, err := parsePolygons(lit.Value)
+	return err
+}
+
+func fnGeoPointToGeohash(args []model.Value) (model.Value, error) {
+	lng, lat, ok := coords(args[0], args[1])
+	if !ok {
+		return stringValue(""), nil
+	}
+	precision := int64(5)
+	if len(args) == 3 {
+		p := scalarOf(args[2])
+		if p.Type != model.TypeInt || p.Int() < 1 || p.Int() > 18 {
+			return model.Value{}, fmt.Errorf("geo_point_to_geohash: accuracy must be an integer from 1 to 18")
+		}
+		precision = p.Int()
+	}
+	lngRange, latRange := [2]float64{-180, 180}, [2]float64{-90, 90}
+	var sb strings.Builder
+	even := true
+	bit, ch := 0, 0
+	for sb.Len() < int(precision) {
+		r, v := &latRange, lat
+		if even {
+			r, v = &lngRange, lng
+		}
+		mid := (r[0] + r[1]) / 2
+		ch <<= 1
+		if v >= mid {
+			ch |= 1
+			r[0] = mid
+		} else {
+			r[1] = mid
+		}
+		even = !even
+		if bit++; bit == 5 {
+			sb.WriteByte(geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return stringValue(sb.String()), nil
+}
+
+func fnGeoGeohashToCentralPoint(args []model.Value) (model.Value, error) {
+	s, ok := stringArg(args[0])
+	if !ok || s == "" || len(s) > 18 {
+		return dynamicNull, nil
+	}
+	lngRange, latRange := [2]float64{-180, 180}, [2]float64{-90, 90}
+	even := true
+	for _, c := range strings.ToLower(s) {
+		idx := strings.IndexRune(geohashAlphabet, c)
+		if idx < 0 {
+			return dynamicNull, nil
+		}
+		for mask := 16; mask > 0; mask >>= 1 {
+			r := &latRange
+			if even {
+				r = &lngRange
+			}
+			mid := (r[0] + r[1]) / 2
+			if idx&mask != 0 {
+				r[0] = mid
+			} else {
+				r[1] = mid
+			}
+			even = !even
+		}
+	}
+	point := map[string]any{
+		"type":        "Point",
+		"coordinates": []any{(lngRange[0] + lngRange[1]) / 2, (latRange[0] + latRange[1]) / 2},
+	}
+	return model.NewDynamic(point), nil
+}
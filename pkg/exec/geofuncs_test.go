@@ -0,0 +1,59 @@
+package exec
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const square = `dynamic({"type":"Polygon","coordinates":[[[0,0],[10,0],[10,10],[0,10],[0,0]],[[4,4],[6,4],[6,6],[4,6],[4,4]]]})`
+
+func TestGeoFunctions(t *testing.T) {
+	cases := map[string]string{
+		`geo_point_in_circle(-122.3, 47.6, -122.33, 47.61, 5000)`: "true",
+		`geo_point_in_circle(-122.3, 47.6, -73.99, 40.73, 5000)`:  "false",
+		`geo_point_in_circle(0, 91, 0, 0, 10)`:                    "",
+		`geo_point_in_polygon(1, 1, ` + square + `)`:              "true",
+		`geo_point_in_polygon(5, 5, ` + square + `)`:              "false",
+		`geo_point_in_polygon(11, 5, ` + square + `)`:             "false",
+		`geo_point_in_polygon(1, 1, "{\"type\":\"MultiPolygon\",\"coordinates\":[[[[20,20],[30,20],[30,30],[20,20]]],[[[0,0],[2,0],[2,2],[0,0]]]]}")`: "true",
+		`geo_point_to_geohash(-122.4194, 37.7749)`:     "9q8yy",
+		`geo_point_to_geohash(10.40744, 57.64911, 11)`: "u4pruydqqvj",
+		`geo_point_to_geohash(200, 0)`:                 "",
+		`geo_geohash_to_central_point("9q8yy").type`:   "Point",
+		`geo_geohash_to_central_point("a")`:            "",
+	}
+	for expr, want := range cases {
+		rows := runQuery(t, nil, "print x = "+expr)
+		if got := columnStrings(rows, "x"); got != want {
+			t.Fatalf("%s: got %q, want %q", expr, got, want)
+		}
+	}
+
+	rows := runQuery(t, nil, `print d = geo_distance_2points(-122.3321, 47.6062, -73.9857, 40.7484), c = geo_geohash_to_central_point("9q8yyk8yt").coordinates`)
+	d, _ := strconv.ParseFloat(columnStrings(rows, "d"), 64)
+	if math.Abs(d-3866e3) > 5e3 {
+		t.Fatalf("unexpected distance: %v", d)
+	}
+	c := strings.Trim(columnStrings(rows, "c"), "[]")
+	parts := strings.Split(c, ",")
+	lng, _ := strconv.ParseFloat(parts[0], 64)
+	lat, _ := strconv.ParseFloat(parts[1], 64)
+	if math.Abs(lng+122.4194) > 1e-4 || math.Abs(lat-37.7749) > 1e-4 {
+		t.Fatalf("unexpected central point: %s", c)
+	}
+
+	for _, q := range []string{`T | where geo_point_in_polygon(1, 1, dynamic({"type":"Point","coordinates":[1,1]}))`, `T | where geo_point_in_polygon(1, 1, "not json")`} {
+		if _, err := BuildPipeline(&sliceReader{}, mustParse(t, q)); err == nil {
+			t.Fatalf("%s: expected polygon error", q)
+		}
+	}
+}
+
+func TestSummarizeByGeohash(t *testing.T) {
+	rows := runQuery(t, nil, `datatable(lon:float, lat:float)[-122.41, 37.77, -122.42, 37.78, 2.35, 48.85] | summarize count() by cell = geo_point_to_geohash(lon, lat, 3) | order by cell asc`)
+	if got := columnStrings(rows, "cell") + " " + columnStrings(rows, "count"); got != "9q8,u09 2,1" {
+		t.Fatalf("unexpected cells: %s", got)
+	}
+}
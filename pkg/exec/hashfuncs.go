@@ -0,0 +1,106 @@
+package exec
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"math/bits"
+
+	"kqlfile/pkg/model"
+)
+
+func fnHashSHA256(args []model.Value) (model.Value, error) {
+	s, _ := stringArg(args[0])
+	sum := sha256.Sum256([]byte(s))
+	return stringValue(hex.EncodeToString(sum[:])), nil
+}
+
+func fnHashMD5(args []model.Value) (model.Value, error) {
+	s, _ := stringArg(args[0])
+	sum := md5.Sum([]byte(s))
+	return stringValue(hex.EncodeToString(sum[:])), nil
+}
+
+// fnHash returns the xxHash64 of the value's text, reduced modulo the
+// optional second argument.
+func fnHash(args []model.Value) (model.Value, error) {
+	s, ok := stringArg(args[0])
+	if !ok {
+		return dynamicNull, nil
+	}
+	h := xxhash64([]byte(s))
+	if len(args) == 2 {
+		mod := scalarOf(args[1])
+		if mod.Type != model.TypeInt || mod.Int() <= 0 {
+			return dynamicNull, nil
+		}
+		return model.NewInt(int64(h % uint64(mod.Int()))), nil
+	}
+	return model.NewInt(int64(h)), nil
+}
+
+var (
+	xxPrime1 uint64 = 11400714785074694791
+	xxPrime2 uint64 = 14029467366897019727
+	xxPrime3 uint64 = 1609587929392839161
+	xxPrime4 uint64 = 9650029242287828579
+	xxPrime5 uint64 = 2870177450012600261
+)
+
+// xxhash64 is XXH64 with a zero seed.
+func xxhash64(b []byte) uint64 {
+	n := uint64(len(b))
+	var h uint64
+	if len(b) >= 32 {
+		v1 := xxPrime1 + xxPrime2
+		v2 := xxPrime2
+		v3 := uint64(0)
+		v4 := -xxPrime1
+		for len(b) >= 32 {
+			v1 = xxRound(v1, binary.LittleEndian.Uint64(b[0:8]))
+			v2 = xxRound(v2, binary.LittleEndian.Uint64(b[8:16]))
+			v3 = xxRound(v3, binary.LittleEndian.Uint64(b[16:24]))
+			v4 = xxRound(v4, binary.LittleEndian.Uint64(b[24:32]))
+			b = b[32:]
+		}
+		h = bits.RotateLeft64(v1, 1) + bits.RotateLeft64(v2, 7) + bits.RotateLeft64(v3, 12) + bits.RotateLeft64(v4, 18)
+		h = xxMerge(h, v1)
+		h = xxMerge(h, v2)
+		h = xxMerge(h, v3)
+		h = xxMerge(h, v4)
+	} else {
+		h = xxPrime5
+	}
+	h += n
+	for ; len(b) >= 8; b = b[8:] {
+		h ^= xxRound(0, binary.LittleEndian.Uint64(b[:8]))
+		h = bits.RotateLeft64(h, 27)*xxPrime1 + xxPrime4
+	}
+	if len(b) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(b[:4])) * xxPrime1
+		h = bits.RotateLeft64(h, 23)*xxPrime2 + xxPrime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		h ^= uint64(c) * xxPrime5
+		h = bits.RotateLeft64(h, 11) * xxPrime1
+	}
+	h ^= h >> 33
+	h *= xxPrime2
+	h ^= h >> 29
+	h *= xxPrime3
+	h ^= h >> 32
+	return h
+}
+
+func xxRound(acc, input uint64) uint64 {
+	acc += input * xxPrime2
+	acc = bits.RotateLeft64(acc, 31)
+	return acc * xxPrime1
+}
+
+func xxMerge(acc, val uint64) uint64 {
+	acc ^= xxRound(0, val)
+	return acc*xxPrime1 + xxPrime4
+}
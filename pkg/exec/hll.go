@@ -0,0 +1,137 @@
+package exec
+
+import (
+	"encoding/base64"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+
+	"kqlfile/pkg/model"
+)
+
+const defaultHLLPrecision = 14
+
+// hyperLogLog estimates the number of distinct values seen using 2^p one-byte
+// registers.
+type hyperLogLog struct {
+	p   uint8
+	reg []uint8
+}
+
+func newHyperLogLog(p uint8) *hyperLogLog {
+	return &hyperLogLog{p: p, reg: make([]uint8, 1<<p)}
+}
+
+func (h *hyperLogLog) add(v model.Value) {
+	h.addHash(hashValue(v))
+}
+
+func (h *hyperLogLog) addHash(x uint64) {
+	idx := x >> (64 - h.p)
+	rank := uint8(bits.LeadingZeros64(x<<h.p|1<<(h.p-1))) + 1
+	if rank > h.reg[idx] {
+		h.reg[idx] = rank
+	}
+}
+
+func (h *hyperLogLog) count() int64 {
+	m := float64(len(h.reg))
+	sum := 0.0
+	zeros := 0
+	for _, r := range h.reg {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+	alpha := 0.7213 / (1 + 1.079/m)
+	est := alpha * m * m / sum
+	if est <= 2.5*m && zeros > 0 {
+		est = m * math.Log(m/float64(zeros))
+	}
+	return int64(math.Round(est))
+}
+
+// hashValue hashes a value's type and text with FNV-1a and a 64-bit
+// finalizer so that similar inputs spread across registers.
+func hashValue(v model.Value) uint64 {
+	f := fnv.New64a()
+	f.Write([]byte(v.Type))
+	f.Write([]byte{0})
+	f.Write([]byte(v.String()))
+	return mix64(f.Sum64())
+}
+
+// mix64 is the 64-bit finalizer of MurmurHash3, spreading every input bit
+// over the output. hashValue and the sampling operators share it.
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
+
+// hllPrecisions maps dcount's accuracy levels 0-4 to register counts, from
+// about 1.6% down to 0.2% standard error.
+var hllPrecisions = []uint8{12, 14, 16, 17, 18}
+
+func (h *hyperLogLog) merge(o *hyperLogLog) error {
+	if o.p != h.p {
+		return fmt.Errorf("hll_merge: cannot merge sketches of different accuracy")
+	}
+	for i, r := range o.reg {
+		if r > h.reg[i] {
+			h.reg[i] = r
+		}
+	}
+	return nil
+}
+
+// sketch returns the dynamic form of h, which survives a round trip through
+// csv or json output.
+func (h *hyperLogLog) sketch() model.Value {
+	return model.NewDynamic(map[string]any{
+		"kind":      "hll",
+		"precision": float64(h.p),
+		"registers": base64.StdEncoding.EncodeToString(h.reg),
+	})
+}
+
+func hllFromSketch(v model.Value) (*hyperLogLog, error) {
+	bag, err := sketchBag(v, "hll")
+	if err != nil || bag == nil {
+		return nil, err
+	}
+	p, _ := bag["precision"].(float64)
+	raw, _ := bag["registers"].(string)
+	reg, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil || p < 4 || p > 18 || len(reg) != 1<<int(p) {
+		return nil, fmt.Errorf("invalid hll sketch")
+	}
+	return &hyperLogLog{p: uint8(p), reg: reg}, nil
+}
+
+// sketchBag unwraps a sketch produced by hll() or tdigest(), given as a
+// dynamic bag or as its JSON text. Null values yield a nil bag.
+func sketchBag(v model.Value, kind string) (map[string]any, error) {
+	v = scalarOf(v)
+	if v.IsNull() {
+		return nil, nil
+	}
+	raw := v.Dynamic()
+	if v.Type == model.TypeString {
+		parsed, err := model.ParseDynamic(v.String())
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s sketch", kind)
+		}
+		raw = parsed
+	}
+	bag, ok := raw.(map[string]any)
+	if !ok || bag["kind"] != kind {
+		return nil, fmt.Errorf("invalid %s sketch", kind)
+	}
+	return bag, nil
+}
@@ -0,0 +1,267 @@
+package exec
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+var privateIPv4 = []netip.Prefix{
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+}
+
+// ipPrefixCache holds the parsed has_any_ipv4_prefix prefixes, so that the
+// per-row work is a lookup and an allocation-free address parse.
+var ipPrefixCache = struct {
+	sync.RWMutex
+	m map[string]netip.Prefix
+}{m: map[string]netip.Prefix{}}
+
+// ipArg parses an address with an optional "/bits" suffix. IPv4 addresses
+// are returned as such; ipv6 reports whether to map them into IPv6 space.
+func ipArg(v model.Value, ipv6 bool) (netip.Prefix, bool) {
+	v = scalarOf(v)
+	if v.Type != model.TypeString {
+		return netip.Prefix{}, false
+	}
+	s := strings.TrimSpace(v.String())
+	var p netip.Prefix
+	if strings.IndexByte(s, '/') >= 0 {
+		var err error
+		if p, err = netip.ParsePrefix(s); err != nil {
+			return netip.Prefix{}, false
+		}
+	} else {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, false
+		}
+		p = netip.PrefixFrom(addr, addr.BitLen())
+	}
+	addr := p.Addr().WithZone("")
+	switch {
+	case ipv6 && addr.Is4():
+		return netip.PrefixFrom(netip.AddrFrom16(addr.As16()), p.Bits()+96), true
+	case !ipv6 && addr.Is4In6():
+		if p.Bits() < 96 {
+			return netip.Prefix{}, false
+		}
+		return netip.PrefixFrom(addr.Unmap(), p.Bits()-96), true
+	case !ipv6 && !addr.Is4():
+		return netip.Prefix{}, false
+	}
+	return netip.PrefixFrom(addr, p.Bits()), true
+}
+
+// compareIPs compares two addresses on their leading bits: the shorter of
+// their own prefixes and the optional mask argument.
+func compareIPs(args []model.Value, ipv6 bool) (int, bool) {
+	a, ok := ipArg(args[0], ipv6)
+	if !ok {
+		return 0, false
+	}
+	b, ok := ipArg(args[1], ipv6)
+	if !ok {
+		return 0, false
+	}
+	bits := min(a.Bits(), b.Bits())
+	if len(args) == 3 {
+		mask := scalarOf(args[2])
+		if mask.Type != model.TypeInt {
+			return 0, false
+		}
+		m := int(mask.Int())
+		if ipv6 && a.Addr().Is4In6() && b.Addr().Is4In6() && m <= 32 {
+			m += 96
+		}
+		if m < 0 || m > a.Addr().BitLen() {
+			return 0, false
+		}
+		bits = min(bits, m)
+	}
+	pa, _ := a.Addr().Prefix(bits)
+	pb, _ := b.Addr().Prefix(bits)
+	return pa.Addr().Compare(pb.Addr()), true
+}
+
+func fnIPv4Compare(args []model.Value) (model.Value, error) {
+	c, ok := compareIPs(args, false)
+	if !ok {
+		return dynamicNull, nil
+	}
+	return model.NewInt(int64(c)), nil
+}
+
+func fnIPv6Compare(args []model.Value) (model.Value, error) {
+	c, ok := compareIPs(args, true)
+	if !ok {
+		return dynamicNull, nil
+	}
+	return model.NewInt(int64(c)), nil
+}
+
+func fnIPv4IsMatch(args []model.Value) (model.Value, error) {
+	c, ok := compareIPs(args, false)
+	if !ok {
+		return dynamicNull, nil
+	}
+	return model.NewBool(c == 0), nil
+}
+
+func fnIPv4IsInRange(args []model.Value) (model.Value, error) {
+	ip, ok := ipArg(args[0], false)
+	if !ok {
+		return dynamicNull, nil
+	}
+	r, ok := ipArg(args[1], false)
+	if !ok {
+		return dynamicNull, nil
+	}
+	return model.NewBool(r.Masked().Contains(ip.Addr())), nil
+}
+
+func fnIPv4IsPrivate(args []model.Value) (model.Value, error) {
+	ip, ok := ipArg(args[0], false)
+	if !ok {
+		return dynamicNull, nil
+	}
+	for _, r := range privateIPv4 {
+		if r.Contains(ip.Addr()) && ip.Bits() >= r.Bits() {
+			return model.NewBool(true), nil
+		}
+	}
+	return model.NewBool(false), nil
+}
+
+func fnParseIPv4(args []model.Value) (model.Value, error) {
+	ip, ok := ipArg(args[0], false)
+	if !ok {
+		return dynamicNull, nil
+	}
+	b := ip.Masked().Addr().As4()
+	n := int64(b[0])<<24 | int64(b[1])<<16 | int64(b[2])<<8 | int64(b[3])
+	return model.NewInt(n), nil
+}
+
+func fnFormatIPv4Mask(args []model.Value) (model.Value, error) {
+	empty := model.NewString("")
+	ip, ok := ipArg(args[0], false)
+	if !ok {
+		return empty, nil
+	}
+	bits := ip.Bits()
+	if len(args) == 2 {
+		mask := scalarOf(args[1])
+		if mask.Type != model.TypeInt || mask.Int() < 0 || mask.Int() > 32 {
+			return empty, nil
+		}
+		bits = min(bits, int(mask.Int()))
+	}
+	p, _ := ip.Addr().Prefix(bits)
+	return model.NewString(p.String()), nil
+}
+
+// fnHasAnyIPv4Prefix reports whether the text holds an IPv4 address starting
+// with one of the prefixes, given as arguments or as one dynamic array.
+func fnHasAnyIPv4Prefix(args []model.Value) (model.Value, error) {
+	var buf [8]netip.Prefix
+	prefixes := buf[:0]
+	for _, a := range args[1:] {
+		if arr, ok := a.Dynamic().([]any); ok && a.Type == model.TypeDynamic {
+			for _, e := range arr {
+				p, err := ipv4Prefix(model.FromDynamic(e).String())
+				if err != nil {
+					return model.Value{}, err
+				}
+				prefixes = append(prefixes, p)
+			}
+			continue
+		}
+		p, err := ipv4Prefix(a.String())
+		if err != nil {
+			return model.Value{}, err
+		}
+		prefixes = append(prefixes, p)
+	}
+	text := scalarOf(args[0]).String()
+	for start := 0; start < len(text); {
+		end := start
+		for end < len(text) && (isDigitByte(text[end]) || text[end] == '.') {
+			end++
+		}
+		if end == start {
+			start++
+			continue
+		}
+		// Only whole dotted quads are parsed, since ParseAddr allocates its
+		// errors.
+		token := strings.TrimRight(text[start:end], ".")
+		after := start + len(token)
+		bounded := (start == 0 || !isWordByte(text[start-1])) && (after == len(text) || text[after] == '.' || !isWordByte(text[after]))
+		if bounded && strings.Count(token, ".") == 3 {
+			if addr, err := netip.ParseAddr(token); err == nil && addr.Is4() {
+				for _, p := range prefixes {
+					if p.Contains(addr) {
+						return model.NewBool(true), nil
+					}
+				}
+			}
+		}
+		start = end
+	}
+	return model.NewBool(false), nil
+}
+
+// ipv4Prefix converts a textual prefix such as "10." or "192.168.1." (or a
+// full address) into the network it denotes.
+func ipv4Prefix(raw string) (netip.Prefix, error) {
+	ipPrefixCache.RLock()
+	p, ok := ipPrefixCache.m[raw]
+	ipPrefixCache.RUnlock()
+	if ok {
+		return p, nil
+	}
+	octets := strings.Split(strings.TrimSuffix(raw, "."), ".")
+	if len(octets) > 4 || len(octets) < 4 && !strings.HasSuffix(raw, ".") {
+		return netip.Prefix{}, fmt.Errorf("has_any_ipv4_prefix: invalid prefix %q", raw)
+	}
+	var b [4]byte
+	for i, o := range octets {
+		n, err := strconv.ParseUint(o, 10, 8)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("has_any_ipv4_prefix: invalid prefix %q", raw)
+		}
+		b[i] = byte(n)
+	}
+	p = netip.PrefixFrom(netip.AddrFrom4(b), 8*len(octets))
+	ipPrefixCache.Lock()
+	ipPrefixCache.m[raw] = p
+	ipPrefixCache.Unlock()
+	return p, nil
+}
+
+func checkIPv4Prefixes(args []plan.Expr) error {
+	for _, a := range args[1:] {
+		if lit, ok := a.(plan.Literal); ok && lit.Value.Type == model.TypeString {
+			if _, err := ipv4Prefix(lit.Value.String()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func isDigitByte(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWordByte(c byte) bool {
+	return isDigitByte(c) || c == '.' || c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
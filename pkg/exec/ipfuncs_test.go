@@ -0,0 +1,64 @@
+package exec
+
+import (
+	"strings"
+	"testing"
+
+	"kqlfile/pkg/model"
+)
+
+func TestIPFunctions(t *testing.T) {
+	cases := map[string]string{
+		`ipv4_is_in_range("10.1.2.3", "10.0.0.0/8")`:                              "true",
+		`ipv4_is_in_range("11.1.2.3", "10.0.0.0/8")`:                              "false",
+		`ipv4_is_in_range("10.1.2.3", "10.1.2.3")`:                                "true",
+		`ipv4_is_in_range("bogus", "10.0.0.0/8")`:                                 "",
+		`ipv4_is_private("172.20.0.1")`:                                           "true",
+		`ipv4_is_private("172.32.0.1")`:                                           "false",
+		`ipv4_is_private("192.168.0.0/8")`:                                        "false",
+		`parse_ipv4("127.0.0.1")`:                                                 "2130706433",
+		`parse_ipv4("192.168.1.7/24")`:                                            "3232235776",
+		`parse_ipv4("::1")`:                                                       "",
+		`ipv4_compare("10.0.0.1", "10.0.0.2")`:                                    "-1",
+		`ipv4_compare("10.0.0.9", "10.0.0.2", 24)`:                                "0",
+		`ipv4_compare("10.0.1.0/24", "10.0.1.200")`:                               "0",
+		`ipv4_is_match("192.168.1.1/24", "192.168.1.255")`:                        "true",
+		`ipv4_is_match("192.168.1.1", "192.168.2.1", 24)`:                         "false",
+		`ipv6_compare("::ffff:7f00:1", "127.0.0.1")`:                              "0",
+		`ipv6_compare("fe80::85d:e82c:9446:7994", "fe80::1")`:                     "1",
+		`ipv6_compare("fe80::85d:e82c:9446:7994/64", "fe80::")`:                   "0",
+		`format_ipv4_mask("192.168.1.77", 24)`:                                    "192.168.1.0/24",
+		`format_ipv4_mask("10.1.2.3")`:                                            "10.1.2.3/32",
+		`format_ipv4_mask("nope")`:                                                "",
+		`has_any_ipv4_prefix("from 10.0.0.5:443", "10.", "192.168.")`:             "true",
+		`has_any_ipv4_prefix("src=110.0.0.5", "10.")`:                             "false",
+		`has_any_ipv4_prefix("hosts 1.2.3.4 and 192.168.1.9.", "192.168.1.")`:     "true",
+		`has_any_ipv4_prefix("v1.2.3.4", "1.2.")`:                                 "false",
+		`has_any_ipv4_prefix("a 8.8.8.8 b", parse_json("[\"1.\", \"8.8.8.8\"]"))`: "true",
+	}
+	for expr, want := range cases {
+		rows := runQuery(t, nil, "print x = "+expr)
+		if got := columnStrings(rows, "x"); got != want {
+			t.Fatalf("%s: got %q, want %q", expr, got, want)
+		}
+	}
+	for _, expr := range []string{`has_any_ipv4_prefix("x", "10")`, `has_any_ipv4_prefix("x", "300.")`, `has_any_ipv4_prefix("x", "1.2.3.4.5")`} {
+		ops := mustParse(t, "T | where "+expr)
+		if _, err := BuildPipeline(&sliceReader{}, ops); err == nil || !strings.Contains(err.Error(), "invalid prefix") {
+			t.Fatalf("%s: expected invalid prefix, got %v", expr, err)
+		}
+	}
+}
+
+func TestIPFunctionsDoNotAllocate(t *testing.T) {
+	args := []model.Value{model.NewString("10.20.30.40"), model.NewString("10.0.0.0/8")}
+	text := []model.Value{model.NewString("deny 10.20.30.40:53 -> 8.8.8.8"), model.NewString("8.8.")}
+	allocs := testing.AllocsPerRun(100, func() {
+		fnIPv4IsInRange(args)
+		fnIPv4Compare(args)
+		fnHasAnyIPv4Prefix(text)
+	})
+	if allocs > 0 {
+		t.Fatalf("expected no per-row allocations, got %.0f", allocs)
+	}
+}
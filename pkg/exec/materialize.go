@@ -0,0 +1,117 @@
+package exec
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+// Tables maps the table names bound by let statements to functions opening a
+// fresh reader over them.
+type Tables map[string]func() (RowReader, error)
+
+// join builds o over in. With stats it also returns the statistics of the
+// build side.
+func (t Tables) join(in Operator, o plan.JoinOp, stats *Stats) (*JoinOp, *OpStats, error) {
+	open, ok := t[o.Right]
+	if !ok && len(o.RightOps) == 0 && stats == nil {
+		join, err := NewJoinOp(in, o.Right, o.LeftKey, o.RightKey)
+		return join, nil, err
+	}
+	var right RowReader
+	var rightSchema model.Schema
+	if ok {
+		var err error
+		if right, err = open(); err != nil {
+			return nil, nil, fmt.Errorf("join %s: %w", o.Right, err)
+		}
+	} else {
+		reader, err := csvio.NewReader(o.Right, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer reader.Close()
+		right = reader
+		if len(o.RightOps) == 0 {
+			rightSchema = reader.Schema()
+		}
+	}
+	if len(o.RightOps) > 0 || stats != nil {
+		pipe, err := t.pipeline(right, o.RightOps, stats)
+		if err != nil {
+			return nil, nil, fmt.Errorf("join %s: %w", o.Right, err)
+		}
+		right = pipe
+	}
+	join, err := newJoinOp(in, right, rightSchema, o.LeftKey, o.RightKey)
+	return join, profiled(right), err
+}
+
+// empty returns tables with the same names that read no rows, for checking a
+// plan without evaluating the bindings.
+func (t Tables) empty() Tables {
+	out := make(Tables, len(t))
+	for name := range t {
+		out[name] = func() (RowReader, error) { return &sliceReader{}, nil }
+	}
+	return out
+}
+
+// Materialized evaluates a subquery once, on first use, and replays the
+// buffered rows to every reader.
+type Materialized struct {
+	build func() (Operator, error)
+	once  sync.Once
+	rows  []*csvio.Row
+	err   error
+}
+
+func NewMaterialized(build func() (Operator, error)) *Materialized {
+	return &Materialized{build: build}
+}
+
+// Reader returns a new iterator positioned at the first row.
+func (m *Materialized) Reader() RowReader {
+	return &replayReader{m: m}
+}
+
+func (m *Materialized) fill() {
+	op, err := m.build()
+	if err != nil {
+		m.err = err
+		return
+	}
+	for {
+		row, err := op.Next()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			m.err = err
+			return
+		}
+		m.rows = append(m.rows, row)
+	}
+}
+
+type replayReader struct {
+	m   *Materialized
+	idx int
+}
+
+func (r *replayReader) Next() (*csvio.Row, error) {
+	r.m.once.Do(r.m.fill)
+	if r.m.err != nil {
+		return nil, r.m.err
+	}
+	if r.idx >= len(r.m.rows) {
+		return nil, io.EOF
+	}
+	row := r.m.rows[r.idx]
+	r.idx++
+	return row, nil
+}
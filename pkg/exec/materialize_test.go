@@ -0,0 +1,48 @@
+package exec
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMaterializedReplays(t *testing.T) {
+	builds := 0
+	m := NewMaterialized(func() (Operator, error) {
+		builds++
+		return BuildPipeline(&sliceReader{rows: numberRows(10)}, mustParse(t, "T | where n % 2 == 0"))
+	})
+	first, second := m.Reader(), m.Reader()
+	if got := columnStrings(drain(t, first), "n"); got != "0,2,4,6,8" {
+		t.Fatalf("unexpected rows: %s", got)
+	}
+	if got := columnStrings(drain(t, second), "n"); got != "0,2,4,6,8" {
+		t.Fatalf("unexpected replay: %s", got)
+	}
+	if builds != 1 {
+		t.Fatalf("expected one evaluation, got %d", builds)
+	}
+
+	failing := NewMaterialized(func() (Operator, error) { return nil, errors.New("boom") })
+	if _, err := failing.Reader().Next(); err == nil || err.Error() != "boom" {
+		t.Fatalf("expected build error, got %v", err)
+	}
+}
+
+func TestTablesJoin(t *testing.T) {
+	m := NewMaterialized(func() (Operator, error) {
+		return BuildPipeline(&sliceReader{rows: numberRows(6)}, mustParse(t, "T | where g > 2 | project g, n"))
+	})
+	tables := Tables{"small": func() (RowReader, error) { return m.Reader(), nil }}
+	results, err := tables.Results(m.Reader(), mustParse(t, "T | join kind=inner (small) on n == n | project n, right.g"))
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if got := columnStrings(drain(t, results[0].Op), "right.g"); got != "3,4,5" {
+		t.Fatalf("unexpected join: %s", got)
+	}
+
+	failing := Tables{"bad": func() (RowReader, error) { return nil, errors.New("boom") }}
+	if _, err := failing.Pipeline(&sliceReader{}, mustParse(t, "T | join kind=inner (bad) on n == n")); err == nil {
+		t.Fatalf("expected table error")
+	}
+}
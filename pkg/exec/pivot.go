@@ -0,0 +1,204 @@
+package exec
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+// newPivotOp implements pivot(col[, agg(...)][, groupcols...]): one output
+// row per group and one column per distinct value of col holding the
+// aggregation over the matching rows (count() by default).
+func newPivotOp(in Operator, args []plan.Expr) (Operator, error) {
+	pivotCol, ok := args[0].(plan.ColumnRef)
+	if !ok {
+		return nil, fmt.Errorf("pivot: first argument must be a column")
+	}
+	agg := plan.CallExpr{Name: "count"}
+	rest := args[1:]
+	if len(rest) > 0 {
+		if call, ok := rest[0].(plan.CallExpr); ok {
+			agg = call
+			rest = rest[1:]
+		}
+	}
+	if err := checkAggregate(agg.Name, agg.Args); err != nil {
+		return nil, fmt.Errorf("pivot: %w", err)
+	}
+	groupCols := make([]string, len(rest))
+	for i, a := range rest {
+		col, ok := a.(plan.ColumnRef)
+		if !ok {
+			return nil, fmt.Errorf("pivot: group arguments must be columns")
+		}
+		for _, prev := range groupCols[:i] {
+			if prev == col.Name {
+				return nil, fmt.Errorf("pivot: group column %s is repeated", col.Name)
+			}
+		}
+		groupCols[i] = col.Name
+	}
+
+	type pivotGroup struct {
+		keys  []model.Value
+		cells map[string]aggregator
+	}
+	var groups []*pivotGroup
+	index := make(map[string]*pivotGroup)
+	pivotValues := make(map[string]bool)
+	for {
+		row, err := in.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys := make([]model.Value, len(groupCols))
+		parts := make([]string, len(groupCols))
+		for i, name := range groupCols {
+			keys[i], _ = row.Get(name)
+			parts[i] = valueKey(keys[i])
+		}
+		k := stringsJoin(parts, "\x00")
+		g, ok := index[k]
+		if !ok {
+			g = &pivotGroup{keys: keys, cells: make(map[string]aggregator)}
+			index[k] = g
+			groups = append(groups, g)
+		}
+		pv, _ := row.Get(pivotCol.Name)
+		cell := pv.String()
+		pivotValues[cell] = true
+		acc, ok := g.cells[cell]
+		if !ok {
+			acc = aggFuncs[agg.Name].create()
+			g.cells[cell] = acc
+		}
+		vals := make([]model.Value, len(agg.Args))
+		for i, a := range agg.Args {
+			if vals[i], err = evalExpr(row, a); err != nil {
+				return nil, err
+			}
+		}
+		if err := acc.add(vals); err != nil {
+			return nil, err
+		}
+	}
+
+	names := make([]string, 0, len(pivotValues))
+	for name := range pivotValues {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	taken := make(map[string]bool, len(groupCols))
+	for _, name := range groupCols {
+		taken[name] = true
+	}
+	for _, name := range names {
+		if name == "" {
+			return nil, fmt.Errorf("pivot: an empty %s value cannot name a column", pivotCol.Name)
+		}
+		if taken[name] {
+			return nil, fmt.Errorf("pivot: value %s conflicts with a group column", name)
+		}
+	}
+	empty := dynamicNull
+	if agg.Name == "count" {
+		empty = model.NewInt(0)
+	}
+	rows := make([]*csvio.Row, len(groups))
+	for i, g := range groups {
+		cols := make([]model.Column, 0, len(groupCols)+len(names))
+		vals := make([]model.Value, 0, cap(cols))
+		for j, name := range groupCols {
+			cols = append(cols, model.Column{Name: name, Type: g.keys[j].Type})
+			vals = append(vals, g.keys[j])
+		}
+		for _, name := range names {
+			v := empty
+			if acc, ok := g.cells[name]; ok {
+				v = acc.result()
+			}
+			cols = append(cols, model.Column{Name: name, Type: v.Type})
+			vals = append(vals, v)
+		}
+		rows[i] = &csvio.Row{Schema: model.NewSchema(cols), Values: vals}
+	}
+	return &sliceReader{rows: rows}, nil
+}
+
+// newBagUnpackOp implements bag_unpack(col[, prefix]): the bag column is
+// replaced by one column per property found in any row, so the output schema
+// is only known after the whole input has been read.
+func newBagUnpackOp(in Operator, args []plan.Expr) (Operator, error) {
+	bagCol, ok := args[0].(plan.ColumnRef)
+	if !ok {
+		return nil, fmt.Errorf("bag_unpack: first argument must be a column")
+	}
+	prefix := ""
+	if len(args) == 2 {
+		lit, ok := args[1].(plan.Literal)
+		if !ok || lit.Value.Type != model.TypeString {
+			return nil, fmt.Errorf("bag_unpack: prefix must be a string literal")
+		}
+		prefix = lit.Value.String()
+	}
+
+	var rows []*csvio.Row
+	keys := make(map[string]bool)
+	for {
+		row, err := in.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+		v, _ := row.Get(bagCol.Name)
+		if bag, ok := v.Dynamic().(map[string]any); ok && v.Type == model.TypeDynamic {
+			for k := range bag {
+				keys[k] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(keys))
+	for k := range keys {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	out := make([]*csvio.Row, len(rows))
+	for i, row := range rows {
+		cols := make([]model.Column, 0, len(row.Schema.Columns)+len(names))
+		vals := make([]model.Value, 0, cap(cols))
+		for j, c := range row.Schema.Columns {
+			if c.Name == bagCol.Name {
+				continue
+			}
+			cols = append(cols, c)
+			vals = append(vals, row.Values[j])
+		}
+		v, _ := row.Get(bagCol.Name)
+		bag, _ := v.Dynamic().(map[string]any)
+		for _, k := range names {
+			name := prefix + k
+			if _, clash := row.Schema.Index[name]; clash && name != bagCol.Name {
+				return nil, fmt.Errorf("bag_unpack: property %s conflicts with an existing column", name)
+			}
+			item := dynamicNull
+			if raw, ok := bag[k]; ok {
+				item = model.FromDynamic(raw)
+			}
+			cols = append(cols, model.Column{Name: name, Type: item.Type})
+			vals = append(vals, item)
+		}
+		out[i] = &csvio.Row{Schema: model.NewSchema(cols), Values: vals}
+	}
+	return &sliceReader{rows: out}, nil
+}
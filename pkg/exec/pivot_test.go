@@ -0,0 +1,119 @@
+package exec
+
+import (
+	"strings"
+	"testing"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+)
+
+func salesRows() []*csvio.Row {
+	schema := model.NewSchema([]model.Column{{Name: "region", Type: model.TypeString}, {Name: "product", Type: model.TypeString}, {Name: "amount", Type: model.TypeInt}})
+	data := []struct {
+		region, product string
+		amount          int64
+	}{{"eu", "a", 10}, {"us", "b", 5}, {"eu", "b", 7}, {"eu", "a", 3}}
+	rows := make([]*csvio.Row, len(data))
+	for i, d := range data {
+		rows[i] = &csvio.Row{Schema: schema, Values: []model.Value{
+			model.NewString(d.region),
+			model.NewString(d.product),
+			model.NewInt(d.amount),
+		}}
+	}
+	return rows
+}
+
+func TestEvaluatePivot(t *testing.T) {
+	rows := runQuery(t, salesRows(), "T | evaluate pivot(product, sum(amount), region)")
+	if len(rows) != 2 {
+		t.Fatalf("expected a row per region, got %d", len(rows))
+	}
+	names := make([]string, len(rows[0].Schema.Columns))
+	for i, c := range rows[0].Schema.Columns {
+		names[i] = c.Name
+	}
+	if strings.Join(names, ",") != "region,a,b" {
+		t.Fatalf("unexpected columns: %v", names)
+	}
+	if got := columnStrings(rows, "a"); got != "13," {
+		t.Fatalf("sum a: %s", got)
+	}
+	if got := columnStrings(rows, "b"); got != "7,5" {
+		t.Fatalf("sum b: %s", got)
+	}
+	rows = runQuery(t, salesRows(), "T | evaluate pivot(region)")
+	if len(rows) != 1 || columnStrings(rows, "eu") != "3" || columnStrings(rows, "us") != "1" {
+		t.Fatalf("unexpected default count pivot")
+	}
+	rows = runQuery(t, salesRows(), "T | evaluate pivot(region, count(), product)")
+	if got := columnStrings(rows, "us"); got != "0,1" {
+		t.Fatalf("missing cells should count as 0: %s", got)
+	}
+	for q, want := range map[string]string{
+		"T | extend eu = 1 | evaluate pivot(region, count(), eu)": "conflicts",
+		`datatable(r:string)["", "x"] | evaluate pivot(r)`:        "empty",
+		"T | evaluate pivot(region, count(), product, product)":   "repeated",
+	} {
+		if _, err := BuildPipeline(&sliceReader{rows: salesRows()}, mustParse(t, q)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected %q, got %v", q, want, err)
+		}
+	}
+	schema := model.NewSchema([]model.Column{{Name: "a", Type: model.TypeString}, {Name: "b", Type: model.TypeString}, {Name: "p", Type: model.TypeString}})
+	var keyed []*csvio.Row
+	for _, r := range [][3]string{{"a|b", "c", "x"}, {"a", "b|c", "x"}} {
+		keyed = append(keyed, &csvio.Row{Schema: schema, Values: []model.Value{model.NewString(r[0]), model.NewString(r[1]), model.NewString(r[2])}})
+	}
+	if rows := runQuery(t, keyed, "T | evaluate pivot(p, count(), a, b)"); len(rows) != 2 {
+		t.Fatalf("expected two groups, got %d", len(rows))
+	}
+}
+
+func TestEvaluateBagUnpack(t *testing.T) {
+	schema := model.NewSchema([]model.Column{{Name: "id", Type: model.TypeInt}, {Name: "props", Type: model.TypeDynamic}})
+	var rows []*csvio.Row
+	for i, raw := range []string{`{"a":1,"b":"x"}`, `{"c":true}`, `[1]`} {
+		v, _ := model.ParseDynamic(raw)
+		rows = append(rows, &csvio.Row{Schema: schema, Values: []model.Value{model.NewInt(int64(i)), model.NewDynamic(v)}})
+	}
+	out := runQuery(t, rows, `T | evaluate bag_unpack(props, "p_")`)
+	if len(out) != 3 {
+		t.Fatalf("expected every row, got %d", len(out))
+	}
+	names := make([]string, len(out[1].Schema.Columns))
+	for i, c := range out[1].Schema.Columns {
+		names[i] = c.Name
+	}
+	if strings.Join(names, ",") != "id,p_a,p_b,p_c" {
+		t.Fatalf("unexpected columns: %v", names)
+	}
+	if got := columnStrings(out, "p_a"); got != "1,," {
+		t.Fatalf("p_a: %s", got)
+	}
+	if v, _ := out[0].Get("p_a"); v.Type != model.TypeInt {
+		t.Fatalf("expected typed property, got %s", v.Type)
+	}
+	if got := columnStrings(out, "p_c"); got != ",true," {
+		t.Fatalf("p_c: %s", got)
+	}
+	if _, err := BuildPipeline(&sliceReader{rows: rows}, mustParse(t, `T | extend a = 1 | evaluate bag_unpack(props)`)); err == nil || !strings.Contains(err.Error(), "conflicts") {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+}
+
+func TestEvaluatePluginErrors(t *testing.T) {
+	for _, q := range []string{
+		"T | evaluate pivot(1)",
+		"T | evaluate pivot(product, median(amount))",
+		"T | evaluate pivot(product, count(), 1)",
+		"T | evaluate bag_unpack(1)",
+		"T | evaluate bag_unpack(props, 1)",
+		"T | evaluate bag_unpack(props, \"a\", \"b\")",
+		"T | evaluate pivot()",
+	} {
+		if _, err := BuildPipeline(&sliceReader{rows: salesRows()}, mustParse(t, q)); err == nil {
+			t.Fatalf("%s: expected error", q)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package exec
+
+import (
+	"fmt"
+
+	"kqlfile/pkg/plan"
+)
+
+// plugin is an evaluate extension. build receives the plugin arguments
+// unevaluated so that plugins can treat them as column names or
+// aggregations as needed.
+type plugin struct {
+	minArgs int
+	maxArgs int
+	build   func(in Operator, args []plan.Expr) (Operator, error)
+}
+
+var plugins = map[string]plugin{
+	"profile":    {minArgs: 0, maxArgs: 1, build: newProfileOp},
+	"pivot":      {minArgs: 1, maxArgs: -1, build: newPivotOp},
+	"bag_unpack": {minArgs: 1, maxArgs: 2, build: newBagUnpackOp},
+}
+
+func buildEvaluate(in Operator, o plan.EvaluateOp) (Operator, error) {
+	p, ok := plugins[o.Plugin]
+	if !ok {
+		return nil, fmt.Errorf("unknown plugin: %s", o.Plugin)
+	}
+	if len(o.Args) < p.minArgs || (p.maxArgs >= 0 && len(o.Args) > p.maxArgs) {
+		return nil, fmt.Errorf("%s: wrong number of arguments (%d)", o.Plugin, len(o.Args))
+	}
+	return p.build(in, o.Args)
+}
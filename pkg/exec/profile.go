@@ -0,0 +1,263 @@
+package exec
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"sort"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+const (
+	defaultTopValues = 5
+	topValueCounters = 256
+)
+
+// schemaSource is implemented by readers that know their schema before the
+// first row, so getschema also works on empty inputs.
+type schemaSource interface {
+	Schema() model.Schema
+}
+
+func (s SourceOp) Schema() model.Schema {
+	if src, ok := s.Reader.(schemaSource); ok {
+		return src.Schema()
+	}
+	return model.Schema{}
+}
+
+// NewGetSchemaOp describes the columns of its input, taken from the first row.
+func NewGetSchemaOp(in Operator) (*sliceReader, error) {
+	var schema model.Schema
+	row, err := in.Next()
+	switch {
+	case err == io.EOF:
+		if src, ok := in.(schemaSource); ok {
+			schema = src.Schema()
+		}
+	case err != nil:
+		return nil, err
+	default:
+		schema = row.Schema
+	}
+	out := model.NewSchema([]model.Column{
+		{Name: "ColumnName", Type: model.TypeString},
+		{Name: "ColumnOrdinal", Type: model.TypeInt},
+		{Name: "DataType", Type: model.TypeString},
+	})
+	rows := make([]*csvio.Row, len(schema.Columns))
+	for i, c := range schema.Columns {
+		rows[i] = &csvio.Row{Schema: out, Values: []model.Value{
+			model.NewString(c.Name),
+			model.NewInt(int64(i)),
+			model.NewString(string(c.Type)),
+		}}
+	}
+	return &sliceReader{rows: rows}, nil
+}
+
+type columnProfile struct {
+	name     string
+	typ      model.Type
+	count    int64
+	nulls    int64
+	distinct *hyperLogLog
+	min, max model.Value
+	sum      float64
+	numeric  int64
+	top      *topValues
+}
+
+func (p *columnProfile) add(v model.Value) {
+	p.count++
+	if v.Type == "" || v.IsNull() || v.Type == model.TypeString && v.String() == "" || v.Type == model.TypeDynamic && v.Dynamic() == "" {
+		p.nulls++
+		return
+	}
+	v = scalarOf(v)
+	p.distinct.add(v)
+	p.top.add(v)
+	if isNumeric(v) {
+		p.sum += toFloat64(v)
+		p.numeric++
+	}
+	if v.Type == model.TypeDynamic {
+		return
+	}
+	if p.min.Type == "" || compareValues(v, p.min) < 0 {
+		p.min = v
+	}
+	if p.max.Type == "" || compareValues(v, p.max) > 0 {
+		p.max = v
+	}
+}
+
+// newProfileOp summarizes every column in one pass: null count, an HLL
+// distinct estimate, min, max, mean of numeric values and the most frequent
+// values (approximated with a space-saving counter set).
+func newProfileOp(in Operator, args []plan.Expr) (Operator, error) {
+	top := defaultTopValues
+	if len(args) == 1 {
+		lit, ok := args[0].(plan.Literal)
+		if !ok || lit.Value.Type != model.TypeInt || lit.Value.Int() < 1 {
+			return nil, fmt.Errorf("profile: top values must be a positive integer constant")
+		}
+		top = int(lit.Value.Int())
+	}
+	var cols []*columnProfile
+	index := make(map[string]*columnProfile)
+	for {
+		row, err := in.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for i, c := range row.Schema.Columns {
+			p, ok := index[c.Name]
+			if !ok {
+				p = &columnProfile{name: c.Name, typ: c.Type, distinct: newHyperLogLog(defaultHLLPrecision), top: newTopValues(max(top, topValueCounters))}
+				index[c.Name] = p
+				cols = append(cols, p)
+			}
+			p.add(row.Values[i])
+		}
+	}
+
+	out := model.NewSchema([]model.Column{
+		{Name: "ColumnName", Type: model.TypeString},
+		{Name: "DataType", Type: model.TypeString},
+		{Name: "Count", Type: model.TypeInt},
+		{Name: "NullCount", Type: model.TypeInt},
+		{Name: "DistinctCount", Type: model.TypeInt},
+		{Name: "Min", Type: model.TypeDynamic},
+		{Name: "Max", Type: model.TypeDynamic},
+		{Name: "Mean", Type: model.TypeDynamic},
+		{Name: "TopValues", Type: model.TypeDynamic},
+	})
+	rows := make([]*csvio.Row, len(cols))
+	for i, p := range cols {
+		mean := dynamicNull
+		if p.numeric > 0 {
+			mean = model.NewDynamic(p.sum / float64(p.numeric))
+		}
+		rows[i] = &csvio.Row{Schema: out, Values: []model.Value{
+			model.NewString(p.name),
+			model.NewString(string(p.typ)),
+			model.NewInt(p.count),
+			model.NewInt(p.nulls),
+			model.NewInt(p.distinct.count()),
+			profileBound(p.min),
+			profileBound(p.max),
+			mean,
+			model.NewDynamic(p.top.list(top)),
+		}}
+	}
+	return &sliceReader{rows: rows}, nil
+}
+
+func profileBound(v model.Value) model.Value {
+	if v.Type == "" {
+		return dynamicNull
+	}
+	return model.NewDynamic(model.ToDynamic(v))
+}
+
+// topValues is a space-saving heavy-hitter counter: it tracks at most capacity
+// values, evicting the least frequent one for each unseen value. Counts are
+// reported as guaranteed lower bounds (count minus the inherited error). The
+// entries are also kept in a min-heap on count, so finding the one to evict
+// does not scan them all.
+type topValues struct {
+	capacity int
+	entries  map[string]*topEntry
+	heap     topHeap
+}
+
+type topEntry struct {
+	key   string
+	value model.Value
+	count int64
+	err   int64
+	index int
+}
+
+// topHeap is a min-heap on count, ties broken by key, so the root is the
+// first to evict.
+type topHeap []*topEntry
+
+func (h topHeap) Len() int { return len(h) }
+func (h topHeap) Less(i, j int) bool {
+	if h[i].count != h[j].count {
+		return h[i].count < h[j].count
+	}
+	return h[i].key < h[j].key
+}
+func (h topHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+func (h *topHeap) Push(x any) {
+	e := x.(*topEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+func (h *topHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+func newTopValues(capacity int) *topValues {
+	return &topValues{capacity: capacity, entries: make(map[string]*topEntry)}
+}
+
+func (t *topValues) add(v model.Value) {
+	key := v.String()
+	if e, ok := t.entries[key]; ok {
+		e.count++
+		heap.Fix(&t.heap, e.index)
+		return
+	}
+	if len(t.entries) < t.capacity {
+		e := &topEntry{key: key, value: v, count: 1}
+		t.entries[key] = e
+		heap.Push(&t.heap, e)
+		return
+	}
+	// The evicted entry is reused in place: its count only grows, so it
+	// moves down from the root.
+	e := t.heap[0]
+	delete(t.entries, e.key)
+	e.key, e.value, e.err = key, v, e.count
+	e.count++
+	t.entries[key] = e
+	heap.Fix(&t.heap, 0)
+}
+
+func (t *topValues) list(n int) []any {
+	entries := make([]*topEntry, 0, len(t.entries))
+	for _, e := range t.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		ci, cj := entries[i].count-entries[i].err, entries[j].count-entries[j].err
+		if ci != cj {
+			return ci > cj
+		}
+		return entries[i].value.String() < entries[j].value.String()
+	})
+	if len(entries) > n {
+		entries = entries[:n]
+	}
+	out := make([]any, len(entries))
+	for i, e := range entries {
+		out[i] = map[string]any{"value": model.ToDynamic(e.value), "count": float64(e.count - e.err)}
+	}
+	return out
+}
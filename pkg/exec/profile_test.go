@@ -0,0 +1,148 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/parser"
+	"kqlfile/pkg/plan"
+)
+
+type schemaReader struct {
+	sliceReader
+	schema model.Schema
+}
+
+func (s *schemaReader) Schema() model.Schema { return s.schema }
+
+func TestGetSchema(t *testing.T) {
+	rows := runQuery(t, eventRows(0), "T | extend d = ts - ts | getschema")
+	if got := columnStrings(rows, "ColumnName"); got != "ts,n,d" {
+		t.Fatalf("names: %s", got)
+	}
+	if got := columnStrings(rows, "DataType"); got != "datetime,int,timespan" {
+		t.Fatalf("types: %s", got)
+	}
+	if got := columnStrings(rows, "ColumnOrdinal"); got != "0,1,2" {
+		t.Fatalf("ordinals: %s", got)
+	}
+
+	empty := &schemaReader{schema: model.NewSchema([]model.Column{{Name: "a", Type: model.TypeFloat}})}
+	op, err := BuildPipeline(empty, []plan.Operator{plan.GetSchemaOp{}})
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if got := columnStrings(drain(t, op), "DataType"); got != "float" {
+		t.Fatalf("expected reader schema for empty input, got %q", got)
+	}
+	if _, err := NewGetSchemaOp(&errOp{err: errors.New("boom")}); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestProfile(t *testing.T) {
+	schema := model.NewSchema([]model.Column{{Name: "city", Type: model.TypeString}, {Name: "score", Type: model.TypeFloat}})
+	var rows []*csvio.Row
+	for i, city := range []string{"seoul", "busan", "seoul", "", "seoul"} {
+		rows = append(rows, &csvio.Row{Schema: schema, Values: []model.Value{
+			model.NewString(city),
+			model.NewFloat(float64(i)),
+		}})
+	}
+	out := runQuery(t, rows, "T | evaluate profile(1)")
+	if len(out) != 2 {
+		t.Fatalf("expected a row per column, got %d", len(out))
+	}
+	want := map[string]string{"ColumnName": "city", "Count": "5", "NullCount": "1", "DistinctCount": "2", "Min": "busan", "Max": "seoul", "Mean": "", "TopValues": `[{"count":3,"value":"seoul"}]`}
+	for col, w := range want {
+		if v, _ := out[0].Get(col); v.String() != w {
+			t.Fatalf("%s: got %s, want %s", col, v.String(), w)
+		}
+	}
+	if v, _ := out[1].Get("Mean"); v.String() != "2" {
+		t.Fatalf("mean: %s", v.String())
+	}
+	for _, q := range []string{"T | evaluate profile(0)", "T | evaluate profile(1, 2)", "T | evaluate nope()"} {
+		ops, err := parser.Parse(q)
+		if err != nil {
+			t.Fatalf("%s: parse: %v", q, err)
+		}
+		if _, err := BuildPipeline(&sliceReader{}, ops); err == nil {
+			t.Fatalf("%s: expected error", q)
+		}
+	}
+}
+
+func TestHyperLogLogAndTopValues(t *testing.T) {
+	h := newHyperLogLog(defaultHLLPrecision)
+	for i := 0; i < 100000; i++ {
+		h.add(model.NewString(fmt.Sprintf("v%d", i%50000)))
+	}
+	if est := h.count(); math.Abs(float64(est)-50000)/50000 > 0.03 {
+		t.Fatalf("estimate too far off: %d", est)
+	}
+	if newHyperLogLog(defaultHLLPrecision).count() != 0 {
+		t.Fatalf("expected zero for empty sketch")
+	}
+
+	top := newTopValues(4)
+	for i := 0; i < 1000; i++ {
+		top.add(model.NewInt(int64(i % 10)))
+		if i%2 == 0 {
+			top.add(model.NewString("hot"))
+		}
+	}
+	list := top.list(1)
+	if entry := list[0].(map[string]any); entry["value"] != "hot" || entry["count"].(float64) < 400 {
+		t.Fatalf("expected heavy hitter, got %v", list)
+	}
+	if s := fmt.Sprint(top.list(10)); !strings.Contains(s, "hot") || len(top.list(10)) != 4 {
+		t.Fatalf("unexpected list: %s", s)
+	}
+}
+
+func TestTopValuesEvictsLeastFrequent(t *testing.T) {
+	// The reference scans every counter for the least frequent one, ties
+	// broken by the smaller key, as the heap must.
+	type counter struct{ count, err int64 }
+	ref := map[string]*counter{}
+	top := newTopValues(8)
+	for i := 0; i < 5000; i++ {
+		key := fmt.Sprint((i * 7919) % 37)
+		if i%3 == 0 {
+			key = "hot"
+		}
+		top.add(model.NewString(key))
+		if c, ok := ref[key]; ok {
+			c.count++
+			continue
+		}
+		if len(ref) < 8 {
+			ref[key] = &counter{count: 1}
+			continue
+		}
+		var minKey string
+		for k, c := range ref {
+			if minKey == "" || c.count < ref[minKey].count || c.count == ref[minKey].count && k < minKey {
+				minKey = k
+			}
+		}
+		min := ref[minKey]
+		delete(ref, minKey)
+		ref[key] = &counter{count: min.count + 1, err: min.count}
+	}
+	if len(top.entries) != len(ref) {
+		t.Fatalf("tracked %d values, want %d", len(top.entries), len(ref))
+	}
+	for k, c := range ref {
+		e, ok := top.entries[k]
+		if !ok || e.count != c.count || e.err != c.err {
+			t.Fatalf("%s: got %+v, want %+v", k, e, c)
+		}
+	}
+}
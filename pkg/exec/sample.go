@@ -0,0 +1,165 @@
+package exec
+
+import (
+	"container/heap"
+	"io"
+	"math/rand"
+	"sort"
+	"time"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+)
+
+// seedOrNow returns seed, or a time-based seed when none was given.
+func seedOrNow(seed int64) int64 {
+	if seed != 0 {
+		return seed
+	}
+	return time.Now().UnixNano()
+}
+
+// SampleOp keeps a uniform reservoir of Count rows in one pass and returns
+// them in input order once the input is exhausted.
+type SampleOp struct {
+	In    Operator
+	Count int
+	Seed  int64
+	rows  []*csvio.Row
+	idx   int
+	done  bool
+}
+
+type sampledRow struct {
+	pos int
+	row *csvio.Row
+}
+
+func (s *SampleOp) Next() (*csvio.Row, error) {
+	if !s.done {
+		if err := s.fill(); err != nil {
+			return nil, err
+		}
+		s.done = true
+	}
+	if s.idx >= len(s.rows) {
+		return nil, io.EOF
+	}
+	row := s.rows[s.idx]
+	s.idx++
+	return row, nil
+}
+
+func (s *SampleOp) fill() error {
+	rng := rand.New(rand.NewSource(seedOrNow(s.Seed)))
+	reservoir := make([]sampledRow, 0, s.Count)
+	for pos := 0; ; pos++ {
+		row, err := s.In.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if len(reservoir) < s.Count {
+			reservoir = append(reservoir, sampledRow{pos: pos, row: row})
+			continue
+		}
+		if j := rng.Intn(pos + 1); j < s.Count {
+			reservoir[j] = sampledRow{pos: pos, row: row}
+		}
+	}
+	sort.Slice(reservoir, func(i, j int) bool { return reservoir[i].pos < reservoir[j].pos })
+	s.rows = make([]*csvio.Row, len(reservoir))
+	for i, r := range reservoir {
+		s.rows[i] = r.row
+	}
+	return nil
+}
+
+// SampleDistinctOp returns up to Count distinct values of Column. It keeps
+// the values with the smallest seeded hashes (a bottom-k sketch), which is a
+// uniform sample of the distinct values in bounded memory.
+type SampleDistinctOp struct {
+	In     Operator
+	Count  int
+	Column string
+	Seed   int64
+	rows   []*csvio.Row
+	idx    int
+	done   bool
+}
+
+func (s *SampleDistinctOp) Next() (*csvio.Row, error) {
+	if !s.done {
+		if err := s.fill(); err != nil {
+			return nil, err
+		}
+		s.done = true
+	}
+	if s.idx >= len(s.rows) {
+		return nil, io.EOF
+	}
+	row := s.rows[s.idx]
+	s.idx++
+	return row, nil
+}
+
+func (s *SampleDistinctOp) fill() error {
+	salt := uint64(seedOrNow(s.Seed))
+	h := &hashHeap{}
+	members := make(map[string]bool)
+	for {
+		row, err := s.In.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		v, ok := row.Get(s.Column)
+		if !ok || s.Count == 0 {
+			continue
+		}
+		key := string(v.Type) + "\x00" + v.String()
+		if members[key] {
+			continue
+		}
+		x := mix64(hashValue(v) ^ salt)
+		if h.Len() == s.Count {
+			if x >= (*h)[0].hash {
+				continue
+			}
+			delete(members, heap.Pop(h).(hashedValue).key)
+		}
+		heap.Push(h, hashedValue{hash: x, key: key, value: v})
+		members[key] = true
+	}
+	sort.Slice(*h, func(i, j int) bool { return (*h)[i].hash < (*h)[j].hash })
+	s.rows = make([]*csvio.Row, h.Len())
+	for i, hv := range *h {
+		schema := model.NewSchema([]model.Column{{Name: s.Column, Type: hv.value.Type}})
+		s.rows[i] = &csvio.Row{Schema: schema, Values: []model.Value{hv.value}}
+	}
+	return nil
+}
+
+type hashedValue struct {
+	hash  uint64
+	key   string
+	value model.Value
+}
+
+// hashHeap is a max-heap on hash, so the root is the first to evict.
+type hashHeap []hashedValue
+
+func (h hashHeap) Len() int           { return len(h) }
+func (h hashHeap) Less(i, j int) bool { return h[i].hash > h[j].hash }
+func (h hashHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *hashHeap) Push(x any)        { *h = append(*h, x.(hashedValue)) }
+func (h *hashHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
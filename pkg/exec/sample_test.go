@@ -0,0 +1,87 @@
+package exec
+
+import (
+	"errors"
+	"testing"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+)
+
+func numberRows(n int) []*csvio.Row {
+	schema := model.NewSchema([]model.Column{{Name: "n", Type: model.TypeInt}, {Name: "g", Type: model.TypeInt}})
+	rows := make([]*csvio.Row, n)
+	for i := range rows {
+		rows[i] = &csvio.Row{Schema: schema, Values: []model.Value{model.NewInt(int64(i)), model.NewInt(int64(i % 7))}}
+	}
+	return rows
+}
+
+func TestSampleIsSeededAndOrdered(t *testing.T) {
+	first := columnStrings(drain(t, &SampleOp{In: &sliceOp{rows: numberRows(1000)}, Count: 10, Seed: 42}), "n")
+	again := columnStrings(drain(t, &SampleOp{In: &sliceOp{rows: numberRows(1000)}, Count: 10, Seed: 42}), "n")
+	other := columnStrings(drain(t, &SampleOp{In: &sliceOp{rows: numberRows(1000)}, Count: 10, Seed: 7}), "n")
+	if first != again {
+		t.Fatalf("same seed gave %s and %s", first, again)
+	}
+	if first == other {
+		t.Fatalf("different seeds gave the same sample %s", first)
+	}
+	rows := drain(t, &SampleOp{In: &sliceOp{rows: numberRows(1000)}, Count: 10, Seed: 42})
+	for i := 1; i < len(rows); i++ {
+		if compareValues(rows[i-1].Values[0], rows[i].Values[0]) >= 0 {
+			t.Fatalf("expected input order, got %s", first)
+		}
+	}
+	if got := len(drain(t, &SampleOp{In: &sliceOp{rows: numberRows(3)}, Count: 10, Seed: 1})); got != 3 {
+		t.Fatalf("expected all rows of a short input, got %d", got)
+	}
+	if _, err := (&SampleOp{In: &errOp{err: errors.New("boom")}, Count: 1}).Next(); err == nil {
+		t.Fatalf("expected input error")
+	}
+}
+
+func TestSampleIsUniform(t *testing.T) {
+	hits := make([]int, 10)
+	for seed := int64(1); seed <= 2000; seed++ {
+		for _, row := range drain(t, &SampleOp{In: &sliceOp{rows: numberRows(10)}, Count: 2, Seed: seed}) {
+			hits[row.Values[0].Int()]++
+		}
+	}
+	for i, h := range hits {
+		if h < 300 || h > 500 {
+			t.Fatalf("row %d sampled %d times out of 4000 draws", i, h)
+		}
+	}
+}
+
+func TestSampleDistinct(t *testing.T) {
+	rows := drain(t, &SampleDistinctOp{In: &sliceOp{rows: numberRows(1000)}, Count: 3, Column: "g", Seed: 42})
+	if len(rows) != 3 || rows[0].Schema.Columns[0].Name != "g" || len(rows[0].Values) != 1 {
+		t.Fatalf("unexpected sample: %d rows", len(rows))
+	}
+	seen := map[string]bool{}
+	for _, r := range rows {
+		seen[r.Values[0].String()] = true
+	}
+	if len(seen) != 3 {
+		t.Fatalf("expected distinct values, got %s", columnStrings(rows, "g"))
+	}
+	again := drain(t, &SampleDistinctOp{In: &sliceOp{rows: numberRows(1000)}, Count: 3, Column: "g", Seed: 42})
+	if columnStrings(rows, "g") != columnStrings(again, "g") {
+		t.Fatalf("expected a reproducible sample")
+	}
+	all := drain(t, &SampleDistinctOp{In: &sliceOp{rows: numberRows(1000)}, Count: 100, Column: "g", Seed: 1})
+	if len(all) != 7 {
+		t.Fatalf("expected every distinct value, got %d", len(all))
+	}
+	if got := runQuery(t, numberRows(10), "T | sample-distinct 0 of g"); len(got) != 0 {
+		t.Fatalf("expected no rows")
+	}
+	if got := runQuery(t, numberRows(10), "T | sample 4"); len(got) != 4 {
+		t.Fatalf("expected 4 rows, got %d", len(got))
+	}
+	if _, err := (&SampleDistinctOp{In: &errOp{err: errors.New("boom")}, Count: 1, Column: "g"}).Next(); err == nil {
+		t.Fatalf("expected input error")
+	}
+}
@@ -0,0 +1,186 @@
+package exec
+
+import (
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+type NamedReader struct {
+	Name   string
+	Reader RowReader
+	Schema model.Schema
+}
+
+// UnionSource reads each input to the end in turn. Rows are widened to the
+// union of the input schemas and prefixed with a $table column naming the
+// input they came from.
+type UnionSource struct {
+	Inputs []NamedReader
+	idx    int
+	schema *model.Schema
+}
+
+// Schema returns the widened schema of the union.
+func (u *UnionSource) Schema() model.Schema {
+	if u.schema == nil {
+		cols := []model.Column{{Name: "$table", Type: model.TypeString}}
+		seen := map[string]bool{"$table": true}
+		for _, in := range u.Inputs {
+			for _, c := range in.Schema.Columns {
+				if !seen[c.Name] {
+					seen[c.Name] = true
+					cols = append(cols, c)
+				}
+			}
+		}
+		sch := model.NewSchema(cols)
+		u.schema = &sch
+	}
+	return *u.schema
+}
+
+func (u *UnionSource) Next() (*csvio.Row, error) {
+	u.Schema()
+	for u.idx < len(u.Inputs) {
+		in := u.Inputs[u.idx]
+		row, err := in.Reader.Next()
+		if err == io.EOF {
+			u.idx++
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		vals := make([]model.Value, len(u.schema.Columns))
+		vals[0] = model.NewString(in.Name)
+		for i, c := range u.schema.Columns[1:] {
+			v, ok := row.Get(c.Name)
+			if !ok {
+				v = model.NullOf(c.Type)
+			}
+			vals[i+1] = v
+		}
+		return &csvio.Row{Schema: *u.schema, Values: vals}, nil
+	}
+	return nil, io.EOF
+}
+
+func evalSearchTerm(row *csvio.Row, term plan.SearchTerm) bool {
+	if term.Column != "" {
+		v, ok := row.Get(term.Column)
+		return ok && matchTerm(v.String(), term.Term, term.CaseSensitive)
+	}
+	for i, c := range row.Schema.Columns {
+		if c.Name == "$table" {
+			continue
+		}
+		if matchTerm(row.Values[i].String(), term.Term, term.CaseSensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTerm applies search semantics: a leading or trailing * turns the term
+// into a suffix, prefix or substring match, otherwise it must be a whole term.
+func matchTerm(text, term string, caseSensitive bool) bool {
+	if !caseSensitive {
+		text = strings.ToLower(text)
+		term = strings.ToLower(term)
+	}
+	prefix := strings.HasSuffix(term, "*")
+	suffix := strings.HasPrefix(term, "*")
+	term = strings.Trim(term, "*")
+	switch {
+	case term == "":
+		return true
+	case prefix && suffix:
+		return strings.Contains(text, term)
+	case prefix:
+		return findTerm(text, term, true, false)
+	case suffix:
+		return findTerm(text, term, false, true)
+	default:
+		return findTerm(text, term, true, true)
+	}
+}
+
+func hasTerm(text, term string, caseSensitive bool) bool {
+	if !caseSensitive {
+		text = strings.ToLower(text)
+		term = strings.ToLower(term)
+	}
+	return findTerm(text, term, true, true)
+}
+
+func findTerm(text, term string, leftBound, rightBound bool) bool {
+	if term == "" {
+		return true
+	}
+	for start := 0; start <= len(text)-len(term); {
+		i := strings.Index(text[start:], term)
+		if i == -1 {
+			return false
+		}
+		i += start
+		end := i + len(term)
+		okLeft := !leftBound || i == 0 || !isTermRune(lastRune(text[:i]))
+		okRight := !rightBound || end == len(text) || !isTermRune(firstRune(text[end:]))
+		if okLeft && okRight {
+			return true
+		}
+		start = i + 1
+	}
+	return false
+}
+
+func isTermRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func firstRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
+}
+
+func lastRune(s string) rune {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return r
+}
+
+// evalStringOp evaluates the string comparison operators; the second result
+// is false for operators it does not handle.
+func evalStringOp(op string, l, r model.Value) (bool, bool) {
+	negate := strings.HasPrefix(op, "!")
+	base := strings.TrimPrefix(op, "!")
+	if op == "!~" {
+		base = "=~"
+	}
+	ls, rs := l.String(), r.String()
+	var ok bool
+	switch base {
+	case "=~":
+		ok = strings.EqualFold(ls, rs)
+	case "has":
+		ok = hasTerm(ls, rs, false)
+	case "has_cs":
+		ok = hasTerm(ls, rs, true)
+	case "contains":
+		ok = strings.Contains(strings.ToLower(ls), strings.ToLower(rs))
+	case "contains_cs":
+		ok = strings.Contains(ls, rs)
+	case "startswith":
+		ok = strings.HasPrefix(strings.ToLower(ls), strings.ToLower(rs))
+	case "endswith":
+		ok = strings.HasSuffix(strings.ToLower(ls), strings.ToLower(rs))
+	default:
+		return false, false
+	}
+	return ok != negate, true
+}
@@ -0,0 +1,114 @@
+package exec
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+func TestMatchTerm(t *testing.T) {
+	cases := []struct {
+		text, term string
+		cs, want   bool
+	}{
+		{"connection timeout", "timeout", false, true},
+		{"connection timeouts", "timeout", false, false},
+		{"Connection TIMEOUT", "timeout", false, true},
+		{"Connection TIMEOUT", "timeout", true, false},
+		{"src=10.0.0.5:443", "10.0.0.5", false, true},
+		{"src=10.0.0.55", "10.0.0.5", false, false},
+		{"timeouts", "time*", false, true},
+		{"overtime", "*time", false, true},
+		{"overtimes", "*time*", false, true},
+		{"anything", "*", false, true},
+		{"", "x", false, false},
+	}
+	for _, c := range cases {
+		if got := matchTerm(c.text, c.term, c.cs); got != c.want {
+			t.Fatalf("matchTerm(%q, %q, %v) = %v", c.text, c.term, c.cs, got)
+		}
+	}
+}
+
+func TestEvalStringOps(t *testing.T) {
+	s := func(v string) model.Value { return model.NewString(v) }
+	cases := []struct {
+		op   string
+		l, r string
+		want bool
+	}{
+		{"=~", "ABC", "abc", true},
+		{"!~", "ABC", "abc", false},
+		{"has", "a timeout here", "TIMEOUT", true},
+		{"!has", "a timeout here", "time", true},
+		{"has_cs", "a Timeout", "timeout", false},
+		{"contains", "Timeout", "MEO", true},
+		{"contains_cs", "Timeout", "MEO", false},
+		{"!contains", "Timeout", "x", true},
+		{"startswith", "Timeout", "time", true},
+		{"endswith", "Timeout", "OUT", true},
+		{"!endswith", "Timeout", "OUT", false},
+	}
+	for _, c := range cases {
+		got, handled := evalStringOp(c.op, s(c.l), s(c.r))
+		if !handled || got != c.want {
+			t.Fatalf("%s %s %s = %v (handled %v)", c.l, c.op, c.r, got, handled)
+		}
+	}
+	if _, handled := evalStringOp("!=", s("a"), s("b")); handled {
+		t.Fatalf("expected != to fall through")
+	}
+}
+
+func TestSearchOp(t *testing.T) {
+	schema := model.NewSchema([]model.Column{{Name: "host", Type: model.TypeString}, {Name: "code", Type: model.TypeInt}})
+	row := func(host string, code int64) *csvio.Row {
+		return &csvio.Row{Schema: schema, Values: []model.Value{model.NewString(host), model.NewInt(code)}}
+	}
+	in := &sliceReader{rows: []*csvio.Row{row("web-1", 500), row("db", 200), row("web-2", 200)}}
+	pred := plan.LogicalExpr{Left: plan.SearchTerm{Term: "200"}, Op: "and", Right: plan.SearchTerm{Column: "host", Term: "web*"}}
+	pipe, err := BuildPipeline(in, []plan.Operator{plan.SearchOp{Predicate: pred}})
+	if err != nil {
+		t.Fatalf("pipeline: %v", err)
+	}
+	rows := drain(t, pipe)
+	if len(rows) != 1 || rows[0].Values[0].String() != "web-2" {
+		t.Fatalf("unexpected search result: %v", rows)
+	}
+	if _, err := BuildPipeline(in, []plan.Operator{plan.SearchOp{Predicate: plan.CallExpr{Name: "nope"}}}); err == nil {
+		t.Fatalf("expected check error")
+	}
+}
+
+func TestUnionSource(t *testing.T) {
+	a := model.NewSchema([]model.Column{{Name: "ip", Type: model.TypeString}})
+	b := model.NewSchema([]model.Column{{Name: "host", Type: model.TypeString}, {Name: "ip", Type: model.TypeString}})
+	u := &UnionSource{Inputs: []NamedReader{
+		{Name: "A", Schema: a, Reader: &sliceReader{rows: []*csvio.Row{{Schema: a, Values: []model.Value{model.NewString("10.0.0.5")}}}}},
+		{Name: "B", Schema: b, Reader: &sliceReader{rows: []*csvio.Row{{Schema: b, Values: []model.Value{model.NewString("web"), model.NewString("10.0.0.6")}}}}},
+	}}
+	rows := drain(t, u)
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+	if len(rows[0].Schema.Columns) != 3 || rows[0].Schema.Columns[0].Name != "$table" {
+		t.Fatalf("unexpected union schema: %v", rows[0].Schema.Columns)
+	}
+	if v, _ := rows[0].Get("host"); v.String() != "" {
+		t.Fatalf("expected empty host for A")
+	}
+	if v, _ := rows[1].Get("$table"); v.String() != "B" {
+		t.Fatalf("expected B table tag")
+	}
+	if !evalSearchTerm(rows[1], plan.SearchTerm{Term: "web"}) || evalSearchTerm(rows[1], plan.SearchTerm{Term: "B"}) {
+		t.Fatalf("search should skip the $table column")
+	}
+	bad := &UnionSource{Inputs: []NamedReader{{Name: "A", Reader: &errOp{err: errors.New("boom")}}}}
+	if _, err := bad.Next(); err == nil || err == io.EOF {
+		t.Fatalf("expected reader error")
+	}
+}
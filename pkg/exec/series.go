@@ -0,0 +1,281 @@
+package exec
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+const maxSeriesBins = 1 << 20
+
+type MakeSeriesOp struct {
+	rows []*csvio.Row
+	idx  int
+}
+
+type seriesPoint struct {
+	group int
+	axis  model.Value
+	args  [][]model.Value
+}
+
+type seriesGroup struct {
+	keys []model.Value
+	bins [][]aggregator
+}
+
+// NewMakeSeriesOp aggregates the input into one row per group, holding a
+// dynamic array per aggregation with one element per step of the axis.
+// Bins without input get the aggregation's default value.
+func NewMakeSeriesOp(in Operator, o plan.MakeSeriesOp) (*MakeSeriesOp, error) {
+	for _, a := range o.Aggregates {
+		if err := checkAggregate(a.Func, a.Args); err != nil {
+			return nil, err
+		}
+	}
+	empty := &csvio.Row{}
+	step, err := evalConst(empty, o.Step)
+	if err != nil {
+		return nil, err
+	}
+	if !isNumeric(step) && step.Type != model.TypeTimespan || compareValues(step, zeroOf(step.Type)) <= 0 {
+		return nil, fmt.Errorf("make-series: step must be a positive number or timespan")
+	}
+	defaults := make([]any, len(o.Aggregates))
+	for i, a := range o.Aggregates {
+		def, err := evalConst(empty, a.Default)
+		if err != nil {
+			return nil, err
+		}
+		defaults[i] = model.ToDynamic(def)
+	}
+
+	var groups []*seriesGroup
+	index := make(map[string]int)
+	var points []seriesPoint
+	var lo, hi model.Value
+	for {
+		row, err := in.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		axis, _ := row.Get(o.On)
+		axis = scalarOf(axis)
+		if axis.IsNull() {
+			continue
+		}
+		if axis.Type != model.TypeDateTime && !isNumeric(axis) {
+			return nil, fmt.Errorf("make-series: axis column %s must be datetime or numeric, got %s", o.On, axis.Type)
+		}
+		keys := make([]model.Value, len(o.ByColumns))
+		parts := make([]string, len(o.ByColumns))
+		for i, name := range o.ByColumns {
+			keys[i], _ = row.Get(name)
+			parts[i] = keys[i].String()
+		}
+		k := stringsJoin(parts, "|")
+		g, ok := index[k]
+		if !ok {
+			g = len(groups)
+			index[k] = g
+			groups = append(groups, &seriesGroup{keys: keys})
+		}
+		args := make([][]model.Value, len(o.Aggregates))
+		for i, a := range o.Aggregates {
+			args[i] = make([]model.Value, len(a.Args))
+			for j, e := range a.Args {
+				if args[i][j], err = evalExpr(row, e); err != nil {
+					return nil, err
+				}
+			}
+		}
+		points = append(points, seriesPoint{group: g, axis: axis, args: args})
+		if lo.Type == "" || compareValues(axis, lo) < 0 {
+			lo = axis
+		}
+		if hi.Type == "" || compareValues(axis, hi) > 0 {
+			hi = axis
+		}
+	}
+
+	from, to := lo, model.Value{}
+	if o.From != nil {
+		if from, err = evalConst(empty, o.From); err != nil {
+			return nil, err
+		}
+	} else if lo.Type != "" {
+		from = binValue(lo, step)
+	}
+	if o.To != nil {
+		if to, err = evalConst(empty, o.To); err != nil {
+			return nil, err
+		}
+	}
+	n := 0
+	if from.Type != "" && (to.Type != "" || hi.Type != "") {
+		end := to
+		if end.Type == "" {
+			end = hi
+		}
+		span, err := seriesOffset(end, from, step)
+		if err != nil {
+			return nil, err
+		}
+		if to.Type == "" {
+			n = int(math.Floor(span)) + 1
+		} else {
+			n = int(math.Ceil(span))
+		}
+	}
+	if n > maxSeriesBins {
+		return nil, fmt.Errorf("make-series: too many bins (%d)", n)
+	}
+	n = max(n, 0)
+
+	for _, p := range points {
+		off, err := seriesOffset(p.axis, from, step)
+		if err != nil {
+			return nil, err
+		}
+		bin := int(math.Floor(off))
+		if bin < 0 || bin >= n {
+			continue
+		}
+		g := groups[p.group]
+		if g.bins == nil {
+			g.bins = make([][]aggregator, n)
+		}
+		if g.bins[bin] == nil {
+			g.bins[bin] = make([]aggregator, len(o.Aggregates))
+			for i, a := range o.Aggregates {
+				g.bins[bin][i] = aggFuncs[a.Func].create()
+			}
+		}
+		for i, agg := range g.bins[bin] {
+			if err := agg.add(p.args[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	axisValues := make([]any, n)
+	for i := range axisValues {
+		axisValues[i] = model.ToDynamic(seriesAxis(from, step, i))
+	}
+	if len(groups) == 0 && len(o.ByColumns) == 0 && n > 0 {
+		groups = append(groups, &seriesGroup{})
+	}
+	rows := make([]*csvio.Row, 0, len(groups))
+	for _, g := range groups {
+		if g.bins == nil {
+			g.bins = make([][]aggregator, n)
+		}
+		cols := make([]model.Column, 0, len(o.ByColumns)+len(o.Aggregates)+1)
+		vals := make([]model.Value, 0, cap(cols))
+		for i, name := range o.ByColumns {
+			cols = append(cols, model.Column{Name: name, Type: g.keys[i].Type})
+			vals = append(vals, g.keys[i])
+		}
+		for i, a := range o.Aggregates {
+			series := make([]any, n)
+			for b := range series {
+				if g.bins[b] == nil {
+					series[b] = defaults[i]
+					continue
+				}
+				series[b] = model.ToDynamic(g.bins[b][i].result())
+			}
+			cols = append(cols, model.Column{Name: a.Name, Type: model.TypeDynamic})
+			vals = append(vals, model.NewDynamic(series))
+		}
+		cols = append(cols, model.Column{Name: o.On, Type: model.TypeDynamic})
+		vals = append(vals, model.NewDynamic(axisValues))
+		rows = append(rows, &csvio.Row{Schema: model.NewSchema(cols), Values: vals})
+	}
+	return &MakeSeriesOp{rows: rows}, nil
+}
+
+func (m *MakeSeriesOp) Next() (*csvio.Row, error) {
+	if m.idx >= len(m.rows) {
+		return nil, io.EOF
+	}
+	row := m.rows[m.idx]
+	m.idx++
+	return row, nil
+}
+
+func zeroOf(t model.Type) model.Value {
+	switch t {
+	case model.TypeInt:
+		return model.NewInt(0)
+	case model.TypeFloat:
+		return model.NewFloat(0)
+	default:
+		return model.NewTimespan(0)
+	}
+}
+
+// seriesOffset returns how many steps v lies after from.
+func seriesOffset(v, from, step model.Value) (float64, error) {
+	if v.Type == model.TypeDateTime && from.Type == model.TypeDateTime && step.Type == model.TypeTimespan {
+		return float64(v.DateTime().Sub(from.DateTime())) / float64(step.Timespan()), nil
+	}
+	if isNumeric(v) && isNumeric(from) && isNumeric(step) {
+		return (toFloat64(v) - toFloat64(from)) / toFloat64(step), nil
+	}
+	return 0, fmt.Errorf("make-series: cannot step %s axis from %s by %s", v.Type, from.Type, step.Type)
+}
+
+func seriesAxis(from, step model.Value, i int) model.Value {
+	switch {
+	case from.Type == model.TypeDateTime:
+		return model.NewDateTime(from.DateTime().Add(time.Duration(i) * step.Timespan()))
+	case from.Type == model.TypeInt && step.Type == model.TypeInt:
+		return model.NewInt(from.Int() + int64(i)*step.Int())
+	default:
+		return model.NewFloat(toFloat64(from) + float64(i)*toFloat64(step))
+	}
+}
+
+// binValue rounds v down to a multiple of size, like KQL's bin().
+func binValue(v, size model.Value) model.Value {
+	switch {
+	case v.Type == model.TypeDateTime && size.Type == model.TypeTimespan:
+		d := size.Timespan()
+		if d <= 0 {
+			return dynamicNull
+		}
+		t := v.DateTime()
+		ns := t.UnixNano()
+		floor := ns - ((ns%int64(d))+int64(d))%int64(d)
+		return model.NewDateTime(time.Unix(0, floor).In(t.Location()))
+	case v.Type == model.TypeTimespan && size.Type == model.TypeTimespan:
+		d, s := v.Timespan(), size.Timespan()
+		if s <= 0 {
+			return dynamicNull
+		}
+		return model.NewTimespan(d - ((d%s)+s)%s)
+	case v.Type == model.TypeInt && size.Type == model.TypeInt:
+		n, s := v.Int(), size.Int()
+		if s <= 0 {
+			return dynamicNull
+		}
+		return model.NewInt(n - ((n%s)+s)%s)
+	case isNumeric(v) && isNumeric(size):
+		s := toFloat64(size)
+		if s <= 0 {
+			return dynamicNull
+		}
+		return model.NewFloat(math.Floor(toFloat64(v)/s) * s)
+	default:
+		return dynamicNull
+	}
+}
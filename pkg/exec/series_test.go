@@ -0,0 +1,154 @@
+package exec
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/parser"
+)
+
+func dyn(t *testing.T, raw string) model.Value {
+	t.Helper()
+	v, err := model.ParseValue(model.TypeDynamic, raw)
+	if err != nil {
+		t.Fatalf("dynamic: %v", err)
+	}
+	return v
+}
+
+func TestMakeSeries(t *testing.T) {
+	rows := eventRows(0, 1, 2, 7, 61)
+	got := runQuery(t, rows, `T | make-series c = count() default=0, s = sum(n) default=-1 on ts from todatetime("2024-01-01") to todatetime("2024-01-01T00:20:00Z") step 5m`)
+	if len(got) != 1 {
+		t.Fatalf("expected one series, got %d", len(got))
+	}
+	if v, _ := got[0].Get("c"); v.String() != "[3,1,0,0]" {
+		t.Fatalf("count series: %s", v.String())
+	}
+	if v, _ := got[0].Get("s"); v.String() != "[6,4,-1,-1]" {
+		t.Fatalf("sum series: %s", v.String())
+	}
+	if v, _ := got[0].Get("ts"); !strings.HasPrefix(v.String(), `["2024-01-01T00:00:00Z","2024-01-01T00:05:00Z"`) {
+		t.Fatalf("axis: %s", v.String())
+	}
+
+	got = runQuery(t, rows, "T | extend g = n % 2 | make-series max(n) on ts step 30m by g")
+	if len(got) != 2 {
+		t.Fatalf("expected two groups, got %d", len(got))
+	}
+	if v, _ := got[0].Get("max_n"); v.String() != "[3,0,5]" {
+		t.Fatalf("odd group: %s", v.String())
+	}
+	if v, _ := got[1].Get("max_n"); v.String() != "[4,0,0]" {
+		t.Fatalf("even group: %s", v.String())
+	}
+
+	got = runQuery(t, rows, "T | make-series avg(n) on n from 0 to 6 step 2")
+	if v, _ := got[0].Get("avg_n"); v.String() != "[1,2.5,4.5]" {
+		t.Fatalf("numeric axis: %s", v.String())
+	}
+	if v, _ := got[0].Get("n"); v.String() != "[0,2,4]" {
+		t.Fatalf("numeric axis values: %s", v.String())
+	}
+}
+
+func TestMakeSeriesNowAndErrors(t *testing.T) {
+	defer func(orig func() time.Time) { nowFunc = orig }(nowFunc)
+	nowFunc = func() time.Time { return time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC) }
+	got := runQuery(t, eventRows(0, 59), "T | make-series count() on ts from ago(1h) to now() step 30m")
+	if v, _ := got[0].Get("count"); v.String() != "[1,1]" {
+		t.Fatalf("ago/now series: %s", v.String())
+	}
+	for query, want := range map[string]string{
+		"T | make-series count() on ts step 0m":       "step must be",
+		"T | make-series count() on ts step n":        "constant",
+		"T | make-series median(n) on ts step 1h":     "unknown aggregation",
+		"T | make-series sum() on ts step 1h":         "wrong number of arguments",
+		"T | make-series count() on ts from 1 step 1": "cannot step",
+	} {
+		ops, err := parser.Parse(query)
+		if err != nil {
+			t.Fatalf("%s: parse: %v", query, err)
+		}
+		if _, err := BuildPipeline(&sliceReader{rows: eventRows(0)}, ops); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected %q, got %v", query, want, err)
+		}
+	}
+}
+
+func TestClockIsReadOncePerQuery(t *testing.T) {
+	got := runQuery(t, eventRows(0), "T | make-series count() on ts from ago(7d) to now() step 1h")
+	if v, _ := got[0].Get("count"); len(v.Dynamic().([]any)) != 168 {
+		t.Fatalf("expected 168 bins, got %s", v.String())
+	}
+	got = runQuery(t, nil, "print a = now(), b = ago(0s) | extend same = a == b")
+	if v, _ := got[0].Get("same"); !v.Bool() {
+		t.Fatalf("now() and ago(0s) differ within a query")
+	}
+}
+
+func TestSeriesFunctions(t *testing.T) {
+	cases := []struct {
+		fn   func([]model.Value) (model.Value, error)
+		args []model.Value
+		want string
+	}{
+		{fnSeriesFillForward, []model.Value{dyn(t, "[null,1,null,3,null]")}, "[null,1,1,3,3]"},
+		{fnSeriesFillForward, []model.Value{dyn(t, "[1,0,2]"), model.NewInt(0)}, "[1,1,2]"},
+		{fnSeriesFillLinear, []model.Value{dyn(t, "[null,1,null,null,4,null]")}, "[1,1,2,3,4,4]"},
+		{fnSeriesFillLinear, []model.Value{dyn(t, "[null,2,null,4,null]"), dynamicNull, model.NewBool(false)}, "[null,2,3,4,null]"},
+		{fnSeriesStats, []model.Value{dyn(t, "[2,4,null,6]")}, `{"avg":4,"max":6,"max_idx":3,"min":2,"min_idx":0,"stdev":2,"variance":4}`},
+		{fnSeriesStats, []model.Value{dyn(t, `"x"`)}, ""},
+	}
+	for i, c := range cases {
+		got, err := c.fn(c.args)
+		if err != nil || got.String() != c.want {
+			t.Fatalf("case %d: got %s (%v), want %s", i, got.String(), err, c.want)
+		}
+	}
+
+	got, err := fnSeriesDecomposeAnomalies([]model.Value{dyn(t, "[1,2,1,2,1,2,1,9,1,2,1,2]"), model.NewFloat(1.5), model.NewInt(2)})
+	if err != nil {
+		t.Fatalf("decompose: %v", err)
+	}
+	flags := got.Dynamic().(map[string]any)["ad_flag"].([]any)
+	for i, f := range flags {
+		want := 0.0
+		if i == 7 {
+			want = 1
+		}
+		if f.(float64) != want {
+			t.Fatalf("unexpected flags: %v", flags)
+		}
+	}
+	for _, trend := range []string{"none", "linefit"} {
+		if _, err := fnSeriesDecomposeAnomalies([]model.Value{dyn(t, "[1,2,3,4,5,6,7,8]"), model.NewFloat(1.5), model.NewInt(-1), model.NewString(trend)}); err != nil {
+			t.Fatalf("%s: %v", trend, err)
+		}
+	}
+}
+
+func TestDatetimeFunctions(t *testing.T) {
+	v, _ := fnToDatetime([]model.Value{model.NewString("2024-01-02 03:04:05")})
+	if v.String() != "2024-01-02T03:04:05Z" {
+		t.Fatalf("todatetime: %s", v.String())
+	}
+	if v, _ := fnToDatetime([]model.Value{model.NewString("soon")}); !v.IsNull() {
+		t.Fatalf("expected null datetime")
+	}
+	if v, _ := fnToTimespan([]model.Value{model.NewString("1.5h")}); v.String() != "01:30:00" {
+		t.Fatalf("totimespan: %s", v.String())
+	}
+	ts := model.NewDateTime(time.Date(2024, 1, 1, 10, 47, 0, 0, time.UTC))
+	if v, _ := fnBin([]model.Value{ts, model.NewTimespan(15 * time.Minute)}); v.String() != "2024-01-01T10:45:00Z" {
+		t.Fatalf("bin datetime: %s", v.String())
+	}
+	if v, _ := fnBin([]model.Value{model.NewInt(-7), model.NewInt(5)}); v.String() != "-10" {
+		t.Fatalf("bin int: %s", v.String())
+	}
+	if v, _ := fnBin([]model.Value{model.NewFloat(7.5), model.NewFloat(2.0)}); v.String() != "6" {
+		t.Fatalf("bin float: %s", v.String())
+	}
+}
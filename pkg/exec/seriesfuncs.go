@@ -0,0 +1,286 @@
+package exec
+
+import (
+	"math"
+	"sort"
+
+	"kqlfile/pkg/model"
+)
+
+// seriesValues reads a dynamic numeric array; missing (null or non-numeric)
+// elements are reported as NaN.
+func seriesValues(v model.Value) ([]float64, bool) {
+	arr, ok := v.Dynamic().([]any)
+	if v.Type != model.TypeDynamic || !ok {
+		return nil, false
+	}
+	out := make([]float64, len(arr))
+	for i, item := range arr {
+		f, ok := item.(float64)
+		if !ok {
+			f = math.NaN()
+		}
+		out[i] = f
+	}
+	return out, true
+}
+
+func seriesResult(values []float64) model.Value {
+	out := make([]any, len(values))
+	for i, f := range values {
+		if math.IsNaN(f) {
+			continue
+		}
+		out[i] = f
+	}
+	return model.NewDynamic(out)
+}
+
+// markMissing turns elements equal to the placeholder into NaN.
+func markMissing(values []float64, args []model.Value, at int) {
+	if at >= len(args) || args[at].IsNull() {
+		return
+	}
+	placeholder := toFloat64(scalarOf(args[at]))
+	for i, f := range values {
+		if f == placeholder {
+			values[i] = math.NaN()
+		}
+	}
+}
+
+func fnSeriesStats(args []model.Value) (model.Value, error) {
+	values, ok := seriesValues(args[0])
+	if !ok {
+		return dynamicNull, nil
+	}
+	markMissing(values, args, 1)
+	stats := map[string]any{}
+	var sum, sumSq float64
+	n := 0
+	for i, f := range values {
+		if math.IsNaN(f) {
+			continue
+		}
+		if n == 0 || f < stats["min"].(float64) {
+			stats["min"], stats["min_idx"] = f, float64(i)
+		}
+		if n == 0 || f > stats["max"].(float64) {
+			stats["max"], stats["max_idx"] = f, float64(i)
+		}
+		sum += f
+		sumSq += f * f
+		n++
+	}
+	if n == 0 {
+		return dynamicNull, nil
+	}
+	avg := sum / float64(n)
+	variance := 0.0
+	if n > 1 {
+		variance = (sumSq - float64(n)*avg*avg) / float64(n-1)
+	}
+	stats["avg"] = avg
+	stats["variance"] = variance
+	stats["stdev"] = math.Sqrt(variance)
+	return model.NewDynamic(stats), nil
+}
+
+func fnSeriesFillForward(args []model.Value) (model.Value, error) {
+	values, ok := seriesValues(args[0])
+	if !ok {
+		return dynamicNull, nil
+	}
+	markMissing(values, args, 1)
+	last := math.NaN()
+	for i, f := range values {
+		if math.IsNaN(f) {
+			values[i] = last
+			continue
+		}
+		last = f
+	}
+	return seriesResult(values), nil
+}
+
+func fnSeriesFillLinear(args []model.Value) (model.Value, error) {
+	values, ok := seriesValues(args[0])
+	if !ok {
+		return dynamicNull, nil
+	}
+	markMissing(values, args, 1)
+	fillLinear(values, len(args) < 3 || toBool(args[2]))
+	return seriesResult(values), nil
+}
+
+// fillLinear interpolates missing elements between known neighbours. Leading
+// and trailing gaps take the nearest known value when fillEdges is set.
+func fillLinear(values []float64, fillEdges bool) {
+	prev := -1
+	for i, f := range values {
+		if math.IsNaN(f) {
+			continue
+		}
+		if prev == -1 {
+			if fillEdges {
+				for j := 0; j < i; j++ {
+					values[j] = f
+				}
+			}
+		} else {
+			step := (f - values[prev]) / float64(i-prev)
+			for j := prev + 1; j < i; j++ {
+				values[j] = values[prev] + step*float64(j-prev)
+			}
+		}
+		prev = i
+	}
+	if fillEdges && prev != -1 {
+		for j := prev + 1; j < len(values); j++ {
+			values[j] = values[prev]
+		}
+	}
+}
+
+// fnSeriesDecomposeAnomalies splits the series into trend and seasonal
+// baseline and flags residuals outside Tukey's fences scaled by threshold.
+// It returns a bag with the ad_flag, ad_score and baseline arrays.
+func fnSeriesDecomposeAnomalies(args []model.Value) (model.Value, error) {
+	values, ok := seriesValues(args[0])
+	if !ok {
+		return dynamicNull, nil
+	}
+	threshold := 1.5
+	if len(args) > 1 {
+		threshold = toFloat64(scalarOf(args[1]))
+	}
+	period := -1
+	if len(args) > 2 {
+		period = int(toInt64(scalarOf(args[2])))
+	}
+	trend := "avg"
+	if len(args) > 3 {
+		trend = args[3].String()
+	}
+	fillLinear(values, true)
+	n := len(values)
+	if n == 0 || math.IsNaN(values[0]) {
+		return dynamicNull, nil
+	}
+
+	baseline := seriesTrend(values, trend)
+	detrended := make([]float64, n)
+	for i := range values {
+		detrended[i] = values[i] - baseline[i]
+	}
+	if period < 0 {
+		period = detectPeriod(detrended)
+	}
+	if period > 1 {
+		sums := make([]float64, period)
+		counts := make([]float64, period)
+		for i, f := range detrended {
+			sums[i%period] += f
+			counts[i%period]++
+		}
+		for i := range baseline {
+			baseline[i] += sums[i%period] / counts[i%period]
+		}
+	}
+
+	residuals := make([]float64, n)
+	for i := range values {
+		residuals[i] = values[i] - baseline[i]
+	}
+	sorted := append([]float64(nil), residuals...)
+	sort.Float64s(sorted)
+	q1, q3 := quantile(sorted, 0.25), quantile(sorted, 0.75)
+	iqr := q3 - q1
+	flags := make([]any, n)
+	scores := make([]any, n)
+	base := make([]any, n)
+	for i, r := range residuals {
+		score := 0.0
+		if iqr > 0 {
+			switch {
+			case r > q3:
+				score = (r - q3) / iqr
+			case r < q1:
+				score = (r - q1) / iqr
+			}
+		}
+		flag := 0.0
+		if score > threshold {
+			flag = 1
+		} else if score < -threshold {
+			flag = -1
+		}
+		flags[i], scores[i], base[i] = flag, score, baseline[i]
+	}
+	return model.NewDynamic(map[string]any{"ad_flag": flags, "ad_score": scores, "baseline": base}), nil
+}
+
+func seriesTrend(values []float64, kind string) []float64 {
+	n := len(values)
+	out := make([]float64, n)
+	switch kind {
+	case "none":
+		return out
+	case "linefit":
+		var sx, sy, sxx, sxy float64
+		for i, f := range values {
+			x := float64(i)
+			sx, sy, sxx, sxy = sx+x, sy+f, sxx+x*x, sxy+x*f
+		}
+		fn := float64(n)
+		slope := 0.0
+		if d := fn*sxx - sx*sx; d != 0 {
+			slope = (fn*sxy - sx*sy) / d
+		}
+		intercept := (sy - slope*sx) / fn
+		for i := range out {
+			out[i] = intercept + slope*float64(i)
+		}
+	default:
+		var sum float64
+		for _, f := range values {
+			sum += f
+		}
+		for i := range out {
+			out[i] = sum / float64(n)
+		}
+	}
+	return out
+}
+
+// detectPeriod picks the lag with the strongest autocorrelation, or 0 when no
+// lag is clearly periodic.
+func detectPeriod(values []float64) int {
+	n := len(values)
+	var energy float64
+	for _, f := range values {
+		energy += f * f
+	}
+	if energy == 0 {
+		return 0
+	}
+	best, bestACF := 0, 0.5
+	for lag := 2; lag <= n/2; lag++ {
+		var acf float64
+		for i := lag; i < n; i++ {
+			acf += values[i] * values[i-lag]
+		}
+		acf /= energy
+		if acf > bestACF {
+			best, bestACF = lag, acf
+		}
+	}
+	return best
+}
+
+func quantile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo))
+}
@@ -0,0 +1,210 @@
+package exec
+
+import (
+	"fmt"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+// constArg evaluates a constant aggregation argument such as an accuracy
+// level or a percentile.
+func constArg(name string, expr plan.Expr) (model.Value, error) {
+	v, err := evalConst(&csvio.Row{}, expr)
+	if err != nil {
+		return model.Value{}, fmt.Errorf("%s: %w", name, err)
+	}
+	return v, nil
+}
+
+func checkAccuracyArg(args []plan.Expr) error {
+	if len(args) < 2 {
+		return nil
+	}
+	v, err := constArg("dcount", args[1])
+	if err != nil {
+		return err
+	}
+	_, err = hllPrecision(v)
+	return err
+}
+
+func hllPrecision(v model.Value) (uint8, error) {
+	if v.Type != model.TypeInt || v.Int() < 0 || v.Int() >= int64(len(hllPrecisions)) {
+		return 0, fmt.Errorf("dcount: accuracy must be an integer from 0 to %d", len(hllPrecisions)-1)
+	}
+	return hllPrecisions[v.Int()], nil
+}
+
+func checkPercentileArgs(args []plan.Expr) error {
+	v, err := constArg("percentile", args[1])
+	if err != nil {
+		return err
+	}
+	_, err = percentileArg(v)
+	return err
+}
+
+func percentileArg(v model.Value) (float64, error) {
+	v = scalarOf(v)
+	if !isNumeric(v) || toFloat64(v) < 0 || toFloat64(v) > 100 {
+		return 0, fmt.Errorf("percentile: expected a number from 0 to 100")
+	}
+	return toFloat64(v), nil
+}
+
+// hllAgg backs dcount() and hll(). The optional accuracy argument is
+// constant, so the sketch is sized on the first value.
+type hllAgg struct {
+	sketch bool
+	h      *hyperLogLog
+}
+
+func (a *hllAgg) add(args []model.Value) error {
+	if a.h == nil {
+		p := uint8(defaultHLLPrecision)
+		if len(args) == 2 {
+			var err error
+			if p, err = hllPrecision(args[1]); err != nil {
+				return err
+			}
+		}
+		a.h = newHyperLogLog(p)
+	}
+	v := scalarOf(args[0])
+	if !v.IsNull() {
+		a.h.add(v)
+	}
+	return nil
+}
+
+func (a *hllAgg) result() model.Value {
+	h := a.h
+	if h == nil {
+		h = newHyperLogLog(defaultHLLPrecision)
+	}
+	if a.sketch {
+		return h.sketch()
+	}
+	return model.NewInt(h.count())
+}
+
+type hllMergeAgg struct {
+	h *hyperLogLog
+}
+
+func (a *hllMergeAgg) add(args []model.Value) error {
+	h, err := hllFromSketch(args[0])
+	if err != nil || h == nil {
+		return err
+	}
+	if a.h == nil {
+		a.h = h
+		return nil
+	}
+	return a.h.merge(h)
+}
+
+func (a *hllMergeAgg) result() model.Value {
+	if a.h == nil {
+		return newHyperLogLog(defaultHLLPrecision).sketch()
+	}
+	return a.h.sketch()
+}
+
+// percentileAgg backs percentile() and tdigest().
+type percentileAgg struct {
+	sketch bool
+	p      float64
+	d      *tDigest
+}
+
+func (a *percentileAgg) add(args []model.Value) error {
+	if a.d == nil {
+		a.d = newTDigest()
+		if len(args) == 2 {
+			var err error
+			if a.p, err = percentileArg(args[1]); err != nil {
+				return err
+			}
+		}
+	}
+	v := scalarOf(args[0])
+	if v.IsNull() {
+		return nil
+	}
+	if !isNumeric(v) {
+		return fmt.Errorf("percentile: expected a number, got %s", v.Type)
+	}
+	a.d.add(toFloat64(v), 1)
+	return nil
+}
+
+func (a *percentileAgg) result() model.Value {
+	d := a.d
+	if d == nil {
+		d = newTDigest()
+	}
+	if a.sketch {
+		return d.sketch()
+	}
+	return quantileValue(d, a.p)
+}
+
+func quantileValue(d *tDigest, p float64) model.Value {
+	q, ok := d.quantile(p / 100)
+	if !ok {
+		return dynamicNull
+	}
+	return model.NewFloat(q)
+}
+
+type tdigestMergeAgg struct {
+	d *tDigest
+}
+
+func (a *tdigestMergeAgg) add(args []model.Value) error {
+	d, err := tdigestFromSketch(args[0])
+	if err != nil || d == nil {
+		return err
+	}
+	if a.d == nil {
+		a.d = newTDigest()
+	}
+	a.d.merge(d)
+	return nil
+}
+
+func (a *tdigestMergeAgg) result() model.Value {
+	if a.d == nil {
+		return newTDigest().sketch()
+	}
+	return a.d.sketch()
+}
+
+func fnDcountHLL(args []model.Value) (model.Value, error) {
+	h, err := hllFromSketch(args[0])
+	if err != nil {
+		return model.Value{}, fmt.Errorf("dcount_hll: %w", err)
+	}
+	if h == nil {
+		return dynamicNull, nil
+	}
+	return model.NewInt(h.count()), nil
+}
+
+func fnPercentileTDigest(args []model.Value) (model.Value, error) {
+	d, err := tdigestFromSketch(args[0])
+	if err != nil {
+		return model.Value{}, fmt.Errorf("percentile_tdigest: %w", err)
+	}
+	p, err := percentileArg(args[1])
+	if err != nil {
+		return model.Value{}, err
+	}
+	if d == nil {
+		return dynamicNull, nil
+	}
+	return quantileValue(d, p), nil
+}
@@ -0,0 +1,84 @@
+package exec
+
+import (
+	"math"
+	"testing"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+)
+
+func TestSummarizeAggregates(t *testing.T) {
+	rows := runQuery(t, numberRows(700), "T | summarize count(), total = sum(n), dcount(n), percentiles(n, 50, 90) by g")
+	if len(rows) != 7 {
+		t.Fatalf("expected 7 groups, got %d", len(rows))
+	}
+	if got := columnStrings(rows, "g"); got != "0,1,2,3,4,5,6" {
+		t.Fatalf("groups should keep first-seen order: %s", got)
+	}
+	if got := columnStrings(rows[:1], "count") + "/" + columnStrings(rows[:1], "total") + "/" + columnStrings(rows[:1], "dcount_n"); got != "100/34650/100" {
+		t.Fatalf("unexpected aggregates: %s", got)
+	}
+	if got := columnStrings(rows[:1], "percentile_n_50") + "/" + columnStrings(rows[:1], "percentile_n_90"); got != "343/623" {
+		t.Fatalf("unexpected percentiles: %s", got)
+	}
+}
+
+func TestDcountAccuracy(t *testing.T) {
+	rows := make([]*csvio.Row, 50000)
+	schema := model.NewSchema([]model.Column{{Name: "u", Type: model.TypeInt}})
+	for i := range rows {
+		rows[i] = &csvio.Row{Schema: schema, Values: []model.Value{model.NewInt(int64(i % 20000))}}
+	}
+	for _, acc := range []string{"0", "1", "4"} {
+		got := runQuery(t, rows, "T | summarize d = dcount(u, "+acc+")")[0].Values[0].Int()
+		if math.Abs(float64(got)-20000)/20000 > 0.05 {
+			t.Fatalf("dcount accuracy %s: got %d", acc, got)
+		}
+	}
+	if _, err := BuildPipeline(&sliceReader{rows: rows}, mustParse(t, "T | summarize dcount(u, 9)")); err == nil {
+		t.Fatalf("expected accuracy error")
+	}
+	if _, err := BuildPipeline(&sliceReader{rows: rows}, mustParse(t, "T | summarize percentile(u, 150)")); err == nil {
+		t.Fatalf("expected percentile error")
+	}
+}
+
+func TestPercentileLargeInput(t *testing.T) {
+	rows := runQuery(t, numberRows(100000), "T | summarize percentiles(n, 1, 50, 99)")
+	for i, want := range []float64{1000, 50000, 99000} {
+		got := rows[0].Values[i].Float()
+		if math.Abs(got-want) > 500 {
+			t.Fatalf("percentile %d: got %v, want about %v", i, got, want)
+		}
+	}
+}
+
+func TestSketchesMerge(t *testing.T) {
+	rows := runQuery(t, numberRows(7000), "T | summarize h = hll(n), d = tdigest(n) by g | summarize h = hll_merge(h), d = tdigest_merge(d) | extend users = dcount_hll(h) | extend median = percentile_tdigest(d, 50)")
+	if len(rows) != 1 {
+		t.Fatalf("expected one row, got %d", len(rows))
+	}
+	users, _ := rows[0].Get("users")
+	if n := users.Int(); math.Abs(float64(n)-7000) > 200 {
+		t.Fatalf("dcount_hll: got %d", n)
+	}
+	median, _ := rows[0].Get("median")
+	if m := median.Float(); math.Abs(m-3500) > 100 {
+		t.Fatalf("percentile_tdigest: got %v", m)
+	}
+	// Sketches survive a round trip through their JSON text, as when they are
+	// stored in a file and read back.
+	h, _ := rows[0].Get("h")
+	back := runQuery(t, []*csvio.Row{{Schema: model.NewSchema([]model.Column{{Name: "s", Type: model.TypeString}}), Values: []model.Value{model.NewString(h.String())}}}, "T | extend users = dcount_hll(s)")
+	if got, _ := back[0].Get("users"); got != users {
+		t.Fatalf("round trip: got %v, want %v", got, users)
+	}
+	rows = runQuery(t, numberRows(70), "T | summarize h = hll(n, 0) by g | summarize hll_merge(h)")
+	if len(rows) != 1 {
+		t.Fatalf("expected merged row")
+	}
+	if _, err := fnDcountHLL([]model.Value{model.NewString(`{"kind":"tdigest"}`)}); err == nil {
+		t.Fatalf("expected kind error")
+	}
+}
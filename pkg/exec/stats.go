@@ -0,0 +1,348 @@
+package exec
+
+import (
+	"runtime/metrics"
+	"strings"
+	"sync"
+	"time"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+// heapSampleRows is how many rows an operator returns between two samples
+// of the heap.
+const heapSampleRows = 1024
+
+// OpStats records what one operator of a profiled pipeline did.
+type OpStats struct {
+	// Op and Detail name the operator as in Node.
+	Op     string
+	Detail string
+	// RowsOut is the number of rows the operator returned.
+	RowsOut int64
+	// Total is the time spent building the operator and reading its rows,
+	// including the time of its inputs.
+	Total time.Duration
+	// Inputs are the operators it reads from; a join's second input is its
+	// build side.
+	Inputs []*OpStats
+
+	read func() csvio.ReadStats
+}
+
+// RowsIn returns the number of rows the operator read from its inputs.
+func (s *OpStats) RowsIn() int64 {
+	var n int64
+	for _, in := range s.Inputs {
+		n += in.RowsOut
+	}
+	return n
+}
+
+// Self returns the time spent in the operator itself, without its inputs.
+func (s *OpStats) Self() time.Duration {
+	d := s.Total
+	for _, in := range s.Inputs {
+		d -= in.Total
+	}
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+// ReadStats returns the counters of the readers an input operator reads
+// from. ok is false for other operators.
+func (s *OpStats) ReadStats() (csvio.ReadStats, bool) {
+	if s.read == nil {
+		return csvio.ReadStats{}, false
+	}
+	return s.read(), true
+}
+
+// Stats collects the statistics of the pipelines built with
+// PipelineWithStats and ResultsWithStats. The counters of a pipeline are
+// complete once it has returned io.EOF or an error.
+type Stats struct {
+	mu       sync.Mutex
+	roots    []*OpStats
+	peakHeap uint64
+}
+
+// PeakHeap returns the largest heap seen while the pipelines ran: the bytes
+// of all heap objects of the process, reachable or not yet collected. The
+// heap is shared by every operator, so it is not split among them. It is
+// sampled as operators are built and every heapSampleRows rows.
+func (s *Stats) PeakHeap() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.peakHeap
+}
+
+func (s *Stats) sampleHeap() {
+	h := heapInUse()
+	s.mu.Lock()
+	if h > s.peakHeap {
+		s.peakHeap = h
+	}
+	s.mu.Unlock()
+}
+
+// Pipelines returns the operator trees recorded, leaving out those that
+// became the input of another pipeline, such as let bindings.
+func (s *Stats) Pipelines() []*OpStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inner := map[*OpStats]bool{}
+	var mark func(n *OpStats)
+	mark = func(n *OpStats) {
+		for _, in := range n.Inputs {
+			inner[in] = true
+			mark(in)
+		}
+	}
+	for _, r := range s.roots {
+		mark(r)
+	}
+	var out []*OpStats
+	for _, r := range s.roots {
+		if !inner[r] {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func (s *Stats) add(op Operator) {
+	if st := profiled(op); st != nil {
+		s.mu.Lock()
+		s.roots = append(s.roots, st)
+		s.mu.Unlock()
+	}
+}
+
+// PipelineWithStats is Pipeline recording what each operator does in stats.
+func (t Tables) PipelineWithStats(reader RowReader, ops []plan.Operator, stats *Stats) (Operator, error) {
+	op, err := t.pipeline(reader, PinClock(ops, nowFunc()), stats)
+	if err != nil {
+		return nil, err
+	}
+	stats.add(op)
+	return op, nil
+}
+
+// ResultsWithStats is Results recording what each operator does in stats.
+func (t Tables) ResultsWithStats(reader RowReader, ops []plan.Operator, stats *Stats) ([]Result, error) {
+	return t.results(reader, PinClock(ops, nowFunc()), stats)
+}
+
+// profiledOp counts the rows and time of the operator it wraps, which runs
+// on rows or, when batches is set, on batches.
+type profiledOp struct {
+	in      Operator
+	batches BatchOperator
+	stats   *OpStats
+	owner   *Stats
+	calls   int
+}
+
+func (p *profiledOp) Next() (*csvio.Row, error) {
+	start := time.Now()
+	row, err := p.in.Next()
+	p.stats.Total += time.Since(start)
+	if err == nil {
+		p.stats.RowsOut++
+	}
+	p.calls++
+	if err != nil || p.calls%heapSampleRows == 0 {
+		p.owner.sampleHeap()
+	}
+	return row, err
+}
+
+func (p *profiledOp) NextBatch() (*csvio.Batch, error) {
+	start := time.Now()
+	b, err := p.batches.NextBatch()
+	p.stats.Total += time.Since(start)
+	if err == nil {
+		p.stats.RowsOut += int64(b.Rows())
+	}
+	p.owner.sampleHeap()
+	return b, err
+}
+
+// Schema keeps getschema working over profiled inputs.
+func (p *profiledOp) Schema() model.Schema {
+	if src, ok := p.in.(schemaSource); ok {
+		return src.Schema()
+	}
+	return model.Schema{}
+}
+
+// profiled returns the statistics of op, a row or batch operator, or nil
+// when it is not profiled.
+func profiled(op any) *OpStats {
+	switch o := op.(type) {
+	case *profiledOp:
+		return o.stats
+	case *batchRows:
+		return profiled(o.in)
+	case *rowBatches:
+		return profiled(o.in)
+	default:
+		return nil
+	}
+}
+
+// buildMark is taken before an operator is built, since operators such as
+// order by read all of their input while being built. It keeps the time its
+// input has already taken, being built, so that the total of an operator
+// includes the totals of its inputs.
+type buildMark struct {
+	start time.Time
+	input time.Duration
+}
+
+func (s *Stats) mark(in *OpStats) buildMark {
+	if s == nil {
+		return buildMark{}
+	}
+	s.sampleHeap()
+	m := buildMark{start: time.Now()}
+	if in != nil {
+		m.input = in.Total
+	}
+	return m
+}
+
+// wrap profiles op, built from the plan operator planOp since m, reading
+// from inputs.
+func (s *Stats) wrap(planOp plan.Operator, op Operator, m buildMark, inputs ...*OpStats) Operator {
+	st := &OpStats{Op: physicalName(planOp), Detail: plan.Format(planOp), Total: time.Since(m.start) + m.input}
+	for _, in := range inputs {
+		if in != nil {
+			st.Inputs = append(st.Inputs, in)
+		}
+	}
+	s.sampleHeap()
+	return &profiledOp{in: op, stats: st, owner: s}
+}
+
+// wrapBatches is wrap for a batch operator.
+func (s *Stats) wrapBatches(planOp plan.Operator, op BatchOperator, m buildMark, in *OpStats) BatchOperator {
+	if s == nil {
+		return op
+	}
+	p := s.wrap(planOp, nil, m, in).(*profiledOp)
+	p.batches = op
+	return p
+}
+
+// source returns the operator reading reader. A profiled pipeline used as
+// reader, such as a let binding, becomes the input of the new one.
+func (s *Stats) source(reader RowReader) Operator {
+	if s == nil {
+		return SourceOp{Reader: reader}
+	}
+	if profiled(reader) != nil {
+		return reader.(Operator)
+	}
+	return &profiledOp{in: SourceOp{Reader: reader}, stats: sourceStats(reader), owner: s}
+}
+
+// batchSource is source for a pipeline starting on batches. Readers that
+// return batches are read through NextBatch; the rows of other readers are
+// put in batches.
+func (s *Stats) batchSource(reader RowReader) BatchOperator {
+	if r, ok := reader.(*batchRows); ok && r.rows == nil {
+		return r.in
+	}
+	if s != nil && profiled(reader) != nil {
+		return &rowBatches{in: reader}
+	}
+	var batches BatchOperator = &rowBatches{in: reader}
+	if b, ok := reader.(BatchOperator); ok {
+		batches = b
+	}
+	if s == nil {
+		return batches
+	}
+	return &profiledOp{batches: batches, stats: sourceStats(reader), owner: s}
+}
+
+func sourceStats(reader RowReader) *OpStats {
+	st := &OpStats{Op: sourceName(reader)}
+	var readers []readStatser
+	switch r := reader.(type) {
+	case readStatser:
+		readers = append(readers, r)
+		st.Detail = r.Stats().Path
+	case *UnionSource:
+		var paths []string
+		for _, in := range r.Inputs {
+			if rs, ok := in.Reader.(readStatser); ok {
+				readers = append(readers, rs)
+				paths = append(paths, rs.Stats().Path)
+			}
+		}
+		st.Detail = strings.Join(paths, ", ")
+	}
+	if len(readers) > 0 {
+		st.read = func() csvio.ReadStats { return sumReadStats(readers) }
+	}
+	return st
+}
+
+// readStatser is implemented by the input readers.
+type readStatser interface {
+	Stats() csvio.ReadStats
+}
+
+func sumReadStats(readers []readStatser) csvio.ReadStats {
+	var sum csvio.ReadStats
+	for i, r := range readers {
+		rs := r.Stats()
+		if i == 0 {
+			sum.Path, sum.Format = rs.Path, rs.Format
+		} else {
+			sum.Path += ", " + rs.Path
+		}
+		sum.Records += rs.Records
+		sum.Rows += rs.Rows
+		sum.ParseErrors += rs.ParseErrors
+		sum.Bytes += rs.Bytes
+	}
+	return sum
+}
+
+func sourceName(reader RowReader) string {
+	switch r := reader.(type) {
+	case readStatser:
+		return strings.ToUpper(r.Stats().Format) + "Reader"
+	case *UnionSource:
+		return "UnionSource"
+	case *teeReader:
+		return "TeeReader"
+	case *replayReader:
+		return "Table"
+	default:
+		return "SourceOp"
+	}
+}
+
+var heapSample = []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+var heapSampleMu sync.Mutex
+
+// heapInUse returns the bytes of the heap objects of the process, including
+// those no garbage collection has freed yet.
+func heapInUse() uint64 {
+	heapSampleMu.Lock()
+	defer heapSampleMu.Unlock()
+	metrics.Read(heapSample)
+	if heapSample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return heapSample[0].Value.Uint64()
+}
@@ -0,0 +1,84 @@
+package exec
+
+import (
+	"testing"
+
+	"kqlfile/pkg/plan"
+)
+
+func TestPipelineWithStats(t *testing.T) {
+	stats := &Stats{}
+	tables := Tables{"lookup": func() (RowReader, error) { return &sliceReader{rows: numberRows(3)}, nil }}
+	ops := mustParse(t, "T | where n >= 10 | as x | join kind=inner (lookup) on g == g | order by n | take 5")
+	join := ops[2].(plan.JoinOp)
+	join.RightOps = mustParse(t, "T | where n > 0")
+	ops[2] = join
+	op, err := tables.PipelineWithStats(&sliceReader{rows: numberRows(100)}, ops, stats)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	if rows := drain(t, op); len(rows) != 5 {
+		t.Fatalf("expected 5 rows, got %d", len(rows))
+	}
+	pipes := stats.Pipelines()
+	if len(pipes) != 1 {
+		t.Fatalf("expected one pipeline, got %d", len(pipes))
+	}
+	if got := describeStats(pipes[0]); got != "TakeOp(OrderByOp(JoinOp(FilterOp(SourceOp), FilterOp(SourceOp))))" {
+		t.Fatalf("unexpected tree %s", got)
+	}
+	take := pipes[0]
+	order := take.Inputs[0]
+	joined := order.Inputs[0]
+	filter := joined.Inputs[0]
+	if take.RowsOut != 5 || take.RowsIn() != 5 || order.RowsIn() != joined.RowsOut {
+		t.Fatalf("unexpected rows: take %d/%d, order in %d, join out %d", take.RowsIn(), take.RowsOut, order.RowsIn(), joined.RowsOut)
+	}
+	if filter.RowsIn() != 100 || filter.RowsOut != 90 || joined.Inputs[1].RowsOut != 2 {
+		t.Fatalf("unexpected filter rows %d/%d, build side %d", filter.RowsIn(), filter.RowsOut, joined.Inputs[1].RowsOut)
+	}
+	// order by reads its input while it is built, which counts as its time.
+	if order.Total < joined.Total {
+		t.Fatalf("order by time %v should include its input %v", order.Total, joined.Total)
+	}
+}
+
+func TestResultsWithStatsFork(t *testing.T) {
+	stats := &Stats{}
+	results, err := Tables(nil).ResultsWithStats(&sliceReader{rows: numberRows(10)}, mustParse(t, "T | where n > 2 | fork (take 2) (summarize count())"), stats)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	done := make(chan struct{})
+	for _, r := range results {
+		go func(op Operator) {
+			drain(t, op)
+			done <- struct{}{}
+		}(r.Op)
+	}
+	<-done
+	<-done
+	var got []string
+	for _, p := range stats.Pipelines() {
+		got = append(got, describeStats(p))
+	}
+	if len(got) != 3 || got[0] != "FilterOp(SourceOp)" {
+		t.Fatalf("unexpected pipelines %v", got)
+	}
+}
+
+func describeStats(s *OpStats) string {
+	out := s.Op
+	for i, in := range s.Inputs {
+		if i == 0 {
+			out += "("
+		} else {
+			out += ", "
+		}
+		out += describeStats(in)
+	}
+	if len(s.Inputs) > 0 {
+		out += ")"
+	}
+	return out
+}
@@ -0,0 +1,150 @@
+package exec
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"kqlfile/pkg/model"
+)
+
+const defaultCompression = 100
+
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+// tDigest is a merging t-digest. Points are buffered and periodically merged
+// into centroids whose size shrinks towards the tails, so extreme quantiles
+// stay accurate in bounded memory.
+type tDigest struct {
+	compression float64
+	centroids   []centroid
+	buffer      []centroid
+	total       float64
+	min, max    float64
+}
+
+func newTDigest() *tDigest {
+	return &tDigest{compression: defaultCompression, min: math.Inf(1), max: math.Inf(-1)}
+}
+
+func (d *tDigest) add(x, w float64) {
+	d.buffer = append(d.buffer, centroid{mean: x, weight: w})
+	d.total += w
+	d.min = math.Min(d.min, x)
+	d.max = math.Max(d.max, x)
+	if len(d.buffer) >= 5*int(d.compression) {
+		d.compress()
+	}
+}
+
+func (d *tDigest) merge(o *tDigest) {
+	for _, c := range o.centroids {
+		d.add(c.mean, c.weight)
+	}
+	for _, c := range o.buffer {
+		d.add(c.mean, c.weight)
+	}
+	d.min = math.Min(d.min, o.min)
+	d.max = math.Max(d.max, o.max)
+}
+
+func (d *tDigest) compress() {
+	if len(d.buffer) == 0 {
+		return
+	}
+	all := append(d.centroids, d.buffer...)
+	sort.Slice(all, func(i, j int) bool { return all[i].mean < all[j].mean })
+	out := all[:1]
+	seen := 0.0
+	for _, c := range all[1:] {
+		cur := &out[len(out)-1]
+		q := (seen + (cur.weight+c.weight)/2) / d.total
+		limit := math.Max(1, 4*d.total*q*(1-q)/d.compression)
+		if cur.weight+c.weight <= limit {
+			w := cur.weight + c.weight
+			cur.mean += (c.mean - cur.mean) * c.weight / w
+			cur.weight = w
+			continue
+		}
+		seen += cur.weight
+		out = append(out, c)
+	}
+	d.centroids = out
+	d.buffer = nil
+}
+
+// quantile estimates the q-th quantile, 0 <= q <= 1. While no points have
+// been merged it returns the nearest-rank value, so small inputs give exact
+// results from the input.
+func (d *tDigest) quantile(q float64) (float64, bool) {
+	d.compress()
+	n := len(d.centroids)
+	if n == 0 {
+		return 0, false
+	}
+	if float64(n) == d.total {
+		i := int(math.Ceil(q*d.total)) - 1
+		return d.centroids[max(0, min(i, n-1))].mean, true
+	}
+	target := q * d.total
+	seen := 0.0
+	prevCenter, prevMean := 0.0, d.min
+	for _, c := range d.centroids {
+		center := seen + c.weight/2
+		if target < center {
+			return interpolate(target, prevCenter, center, prevMean, c.mean), true
+		}
+		seen += c.weight
+		prevCenter, prevMean = center, c.mean
+	}
+	return interpolate(target, prevCenter, d.total, prevMean, d.max), true
+}
+
+func interpolate(x, x0, x1, y0, y1 float64) float64 {
+	if x1 <= x0 {
+		return y1
+	}
+	return y0 + (y1-y0)*(x-x0)/(x1-x0)
+}
+
+func (d *tDigest) sketch() model.Value {
+	d.compress()
+	flat := make([]any, 0, 2*len(d.centroids))
+	for _, c := range d.centroids {
+		flat = append(flat, c.mean, c.weight)
+	}
+	bag := map[string]any{"kind": "tdigest", "compression": d.compression, "centroids": flat}
+	if len(flat) > 0 {
+		bag["min"], bag["max"] = d.min, d.max
+	}
+	return model.NewDynamic(bag)
+}
+
+func tdigestFromSketch(v model.Value) (*tDigest, error) {
+	bag, err := sketchBag(v, "tdigest")
+	if err != nil || bag == nil {
+		return nil, err
+	}
+	d := newTDigest()
+	flat, _ := bag["centroids"].([]any)
+	if len(flat)%2 != 0 {
+		return nil, fmt.Errorf("invalid tdigest sketch")
+	}
+	for i := 0; i < len(flat); i += 2 {
+		mean, ok1 := flat[i].(float64)
+		weight, ok2 := flat[i+1].(float64)
+		if !ok1 || !ok2 || weight <= 0 {
+			return nil, fmt.Errorf("invalid tdigest sketch")
+		}
+		d.centroids = append(d.centroids, centroid{mean: mean, weight: weight})
+		d.total += weight
+	}
+	if len(flat) > 0 {
+		d.min, _ = bag["min"].(float64)
+		d.max, _ = bag["max"].(float64)
+	}
+	return d, nil
+}
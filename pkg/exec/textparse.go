@@ -0,0 +1,237 @@
+package exec
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+type ParseOp struct {
+	In      Operator
+	Source  plan.Expr
+	Relaxed bool
+	re      *regexp.Regexp
+	columns []plan.ParsePart
+	// groups holds the submatch index of each column.
+	groups []int
+	// schema is the output schema for rows of the input columns in, and
+	// slots the position of each parsed column in it.
+	in     model.Schema
+	schema model.Schema
+	slots  []int
+}
+
+func NewParseOp(in Operator, o plan.ParseOp) (*ParseOp, error) {
+	if err := checkExpr(o.Source); err != nil {
+		return nil, err
+	}
+	re, err := compileParsePattern(o.Kind, o.Pattern)
+	if err != nil {
+		return nil, err
+	}
+	var cols []plan.ParsePart
+	var groups []int
+	for _, p := range o.Pattern {
+		if p.Column != "" {
+			groups = append(groups, re.SubexpIndex(parseGroup(len(cols))))
+			cols = append(cols, p)
+		}
+	}
+	return &ParseOp{In: in, Source: o.Source, Relaxed: o.Kind == "relaxed", re: re, columns: cols, groups: groups}, nil
+}
+
+// parseGroup names the regex group of the i-th column, so that groups in
+// regex literals do not shift the columns.
+func parseGroup(i int) string {
+	return fmt.Sprintf("kqlcol%d", i)
+}
+
+func compileParsePattern(kind string, pattern []plan.ParsePart) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?s)^")
+	col := 0
+	for _, p := range pattern {
+		switch {
+		case p.Wildcard:
+			b.WriteString(".*?")
+		case p.Column != "":
+			b.WriteString("(?P<" + parseGroup(col) + ">")
+			col++
+			switch p.ColType {
+			case model.TypeInt:
+				b.WriteString(`-?\d+)`)
+			case model.TypeFloat:
+				b.WriteString(`-?\d+(?:\.\d+)?(?:[eE][-+]?\d+)?)`)
+			default:
+				b.WriteString(".*?)")
+			}
+		case kind == "regex":
+			b.WriteString("(?:" + p.Literal + ")")
+		default:
+			b.WriteString(regexp.QuoteMeta(p.Literal))
+		}
+	}
+	b.WriteString("$")
+	re, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("parse pattern: %w", err)
+	}
+	return re, nil
+}
+
+func (p *ParseOp) Next() (*csvio.Row, error) {
+	row, err := p.In.Next()
+	if err != nil {
+		return nil, err
+	}
+	src, err := evalExpr(row, p.Source)
+	if err != nil {
+		return nil, err
+	}
+	extracted := make([]model.Value, len(p.columns))
+	for i, c := range p.columns {
+		extracted[i] = model.NullOf(c.ColType)
+	}
+	if m := p.re.FindStringSubmatch(src.String()); m != nil {
+		parsed := make([]model.Value, len(p.columns))
+		complete := true
+		for i, c := range p.columns {
+			v, err := model.ParseValue(c.ColType, m[p.groups[i]])
+			if err != nil {
+				complete = false
+				parsed[i] = extracted[i]
+				continue
+			}
+			parsed[i] = v
+		}
+		if complete || p.Relaxed {
+			extracted = parsed
+		}
+	}
+
+	if p.schema.Index == nil || !sameColumns(p.in, row.Schema) {
+		p.buildSchema(row.Schema)
+	}
+	vals := make([]model.Value, len(p.schema.Columns))
+	copy(vals, row.Values)
+	for i, slot := range p.slots {
+		vals[slot] = extracted[i]
+	}
+	return &csvio.Row{Schema: p.schema, Values: vals}, nil
+}
+
+// buildSchema sets the output schema for rows of schema in: parsed columns
+// replace input columns of the same name and follow the others.
+func (p *ParseOp) buildSchema(in model.Schema) {
+	cols := append([]model.Column(nil), in.Columns...)
+	p.slots = make([]int, len(p.columns))
+	for i, c := range p.columns {
+		col := model.Column{Name: c.Column, Type: c.ColType}
+		if idx, ok := in.Index[c.Column]; ok {
+			cols[idx] = col
+			p.slots[i] = idx
+			continue
+		}
+		p.slots[i] = len(cols)
+		cols = append(cols, col)
+	}
+	p.in, p.schema = in, model.NewSchema(cols)
+}
+
+var regexCache sync.Map
+
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex %q: %w", pattern, err)
+	}
+	regexCache.Store(pattern, re)
+	return re, nil
+}
+
+func checkRegexArg(args []plan.Expr) error {
+	lit, ok := args[0].(plan.Literal)
+	if !ok || lit.Value.Type != model.TypeString {
+		return nil
+	}
+	_, err := compileRegex(lit.Value.String())
+	return err
+}
+
+func fnExtract(args []model.Value) (model.Value, error) {
+	re, err := compileRegex(args[0].String())
+	if err != nil {
+		return model.Value{}, err
+	}
+	group := toInt64(args[1])
+	if group < 0 || group > int64(re.NumSubexp()) {
+		return model.Value{}, fmt.Errorf("extract: capture group %d out of range", group)
+	}
+	typ := model.TypeString
+	if len(args) == 4 {
+		typ = model.Type(args[3].String())
+	}
+	m := re.FindStringSubmatch(args[2].String())
+	if m == nil {
+		return model.NullOf(typ), nil
+	}
+	v, err := model.ParseValue(typ, m[group])
+	if err != nil {
+		return model.NullOf(typ), nil
+	}
+	return v, nil
+}
+
+func fnExtractAll(args []model.Value) (model.Value, error) {
+	re, err := compileRegex(args[0].String())
+	if err != nil {
+		return model.Value{}, err
+	}
+	src := args[len(args)-1].String()
+	var groups []int
+	if len(args) == 3 {
+		list, ok := args[1].Dynamic().([]any)
+		if args[1].Type != model.TypeDynamic || !ok {
+			return model.Value{}, fmt.Errorf("extract_all: capture groups must be a dynamic array")
+		}
+		for _, g := range list {
+			n, ok := g.(float64)
+			if !ok || n < 0 || int(n) > re.NumSubexp() {
+				return model.Value{}, fmt.Errorf("extract_all: invalid capture group %v", g)
+			}
+			groups = append(groups, int(n))
+		}
+	} else {
+		for i := 1; i <= re.NumSubexp(); i++ {
+			groups = append(groups, i)
+		}
+	}
+	matches := re.FindAllStringSubmatch(src, -1)
+	if len(matches) == 0 {
+		return dynamicNull, nil
+	}
+	out := make([]any, 0, len(matches))
+	for _, m := range matches {
+		switch len(groups) {
+		case 0:
+			out = append(out, m[0])
+		case 1:
+			out = append(out, m[groups[0]])
+		default:
+			vals := make([]any, len(groups))
+			for i, g := range groups {
+				vals[i] = m[g]
+			}
+			out = append(out, vals)
+		}
+	}
+	return model.NewDynamic(out), nil
+}
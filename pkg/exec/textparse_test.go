@@ -0,0 +1,173 @@
+package exec
+
+import (
+	"errors"
+	"testing"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+func messageRows(msgs ...string) *sliceOp {
+	schema := model.NewSchema([]model.Column{{Name: "message", Type: model.TypeString}})
+	rows := make([]*csvio.Row, len(msgs))
+	for i, m := range msgs {
+		rows[i] = &csvio.Row{Schema: schema, Values: []model.Value{model.NewString(m)}}
+	}
+	return &sliceOp{rows: rows}
+}
+
+func strLit(s string) plan.Literal {
+	return plan.Literal{Value: model.NewString(s)}
+}
+
+func TestParseOpSimple(t *testing.T) {
+	pattern := []plan.ParsePart{
+		{Literal: "user="},
+		{Column: "user", ColType: model.TypeString},
+		{Literal: " took "},
+		{Column: "ms", ColType: model.TypeInt},
+		{Literal: "ms"},
+		{Wildcard: true},
+	}
+	op, err := NewParseOp(messageRows("user=alice took 12ms ok", "no match"), plan.ParseOp{Source: plan.ColumnRef{Name: "message"}, Kind: "simple", Pattern: pattern})
+	if err != nil {
+		t.Fatalf("parse op: %v", err)
+	}
+	rows := drain(t, op)
+	user, _ := rows[0].Get("user")
+	ms, _ := rows[0].Get("ms")
+	if user.String() != "alice" || ms.Type != model.TypeInt || ms.Int() != 12 {
+		t.Fatalf("unexpected extraction: %v %v", user, ms)
+	}
+	if ms, _ := rows[1].Get("ms"); !ms.IsNull() {
+		t.Fatalf("expected null for unmatched row")
+	}
+}
+
+func TestParseOpReusesSchema(t *testing.T) {
+	pattern := []plan.ParsePart{{Column: "message", ColType: model.TypeString}, {Literal: "="}, {Column: "n", ColType: model.TypeInt}}
+	in := messageRows("a=1", "b=2")
+	other := model.NewSchema([]model.Column{{Name: "id", Type: model.TypeInt}, {Name: "message", Type: model.TypeString}})
+	in.rows = append(in.rows, &csvio.Row{Schema: other, Values: []model.Value{model.NewInt(7), model.NewString("c=3")}})
+	op, err := NewParseOp(in, plan.ParseOp{Source: plan.ColumnRef{Name: "message"}, Kind: "simple", Pattern: pattern})
+	if err != nil {
+		t.Fatalf("parse op: %v", err)
+	}
+	rows := drain(t, op)
+	if &rows[0].Schema.Columns[0] != &rows[1].Schema.Columns[0] {
+		t.Fatalf("expected rows of one input schema to share the output schema")
+	}
+	if got := rowString(rows[1]); got != "message:string=b n:int=2" {
+		t.Fatalf("unexpected row %s", got)
+	}
+	if got := rowString(rows[2]); got != "id:int=7 message:string=c n:int=3" {
+		t.Fatalf("expected a new schema for the new input schema, got %s", got)
+	}
+}
+
+func TestParseOpRegexAndRelaxed(t *testing.T) {
+	pattern := []plan.ParsePart{
+		{Literal: `id=`},
+		{Column: "id", ColType: model.TypeString},
+		{Literal: `\s+n=`},
+		{Column: "n", ColType: model.TypeBool},
+	}
+	o := plan.ParseOp{Source: plan.ColumnRef{Name: "message"}, Kind: "regex", Pattern: pattern}
+	op, err := NewParseOp(messageRows("id=x   n=maybe"), o)
+	if err != nil {
+		t.Fatalf("parse op: %v", err)
+	}
+	rows := drain(t, op)
+	if id, _ := rows[0].Get("id"); id.String() != "" {
+		t.Fatalf("expected strict mode to null all columns, got %v", id)
+	}
+	o.Kind = "relaxed"
+	o.Pattern[2].Literal = "   n="
+	op, _ = NewParseOp(messageRows("id=x   n=maybe"), o)
+	rows = drain(t, op)
+	if id, _ := rows[0].Get("id"); id.String() != "x" {
+		t.Fatalf("expected relaxed mode to keep id, got %v", id)
+	}
+}
+
+func TestParseOpRegexGroupsInLiterals(t *testing.T) {
+	pattern := []plan.ParsePart{
+		{Literal: `user=([a-z]+) `},
+		{Column: "rest", ColType: model.TypeString},
+	}
+	o := plan.ParseOp{Source: plan.ColumnRef{Name: "message"}, Kind: "regex", Pattern: pattern}
+	op, err := NewParseOp(messageRows("user=alice logged in"), o)
+	if err != nil {
+		t.Fatalf("parse op: %v", err)
+	}
+	if rest, _ := drain(t, op)[0].Get("rest"); rest.String() != "logged in" {
+		t.Fatalf("expected the column after the literal group, got %q", rest.String())
+	}
+}
+
+func TestParseOpErrors(t *testing.T) {
+	bad := plan.ParseOp{Source: plan.ColumnRef{Name: "message"}, Kind: "regex", Pattern: []plan.ParsePart{{Literal: "("}, {Column: "x"}}}
+	if _, err := NewParseOp(&sliceOp{}, bad); err == nil {
+		t.Fatalf("expected regex compile error")
+	}
+	if _, err := NewParseOp(&sliceOp{}, plan.ParseOp{Source: plan.CallExpr{Name: "nope"}}); err == nil {
+		t.Fatalf("expected source check error")
+	}
+	op, _ := NewParseOp(&errOp{err: errors.New("boom")}, plan.ParseOp{Source: plan.ColumnRef{Name: "message"}, Pattern: []plan.ParsePart{{Column: "x"}}})
+	if _, err := op.Next(); err == nil {
+		t.Fatalf("expected input error")
+	}
+	op, _ = NewParseOp(messageRows("x"), plan.ParseOp{Source: badExpr{}, Pattern: []plan.ParsePart{{Column: "x"}}})
+	if _, err := op.Next(); err == nil {
+		t.Fatalf("expected source error")
+	}
+}
+
+func TestExtractFunctions(t *testing.T) {
+	row := messageRows("a=1 b=22 c=x").rows[0]
+	eval := func(name string, args ...plan.Expr) model.Value {
+		v, err := evalExpr(row, plan.CallExpr{Name: name, Args: args})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		return v
+	}
+	msg := plan.ColumnRef{Name: "message"}
+	one := plan.Literal{Value: model.NewInt(1)}
+	if v := eval("extract", strLit(`b=(\d+)`), one, msg, strLit("int")); v.Type != model.TypeInt || v.Int() != 22 {
+		t.Fatalf("typed extract: %v", v)
+	}
+	if v := eval("extract", strLit(`c=(\d+)`), one, msg); v.String() != "" {
+		t.Fatalf("expected empty extract, got %v", v)
+	}
+	if v := eval("extract", strLit(`c=(\w+)`), one, msg, strLit("int")); !v.IsNull() {
+		t.Fatalf("expected null on conversion failure")
+	}
+	if v := eval("extract_all", strLit(`=(\d+)`), msg); v.String() != `["1","22"]` {
+		t.Fatalf("extract_all: %s", v.String())
+	}
+	if v := eval("extract_all", strLit(`(\w)=(\w+)`), msg); v.String() != `[["a","1"],["b","22"],["c","x"]]` {
+		t.Fatalf("extract_all groups: %s", v.String())
+	}
+	groups := plan.CallExpr{Name: "parse_json", Args: []plan.Expr{strLit("[2]")}}
+	if v := eval("extract_all", strLit(`(\w)=(\w+)`), groups, msg); v.String() != `["1","22","x"]` {
+		t.Fatalf("extract_all selected group: %s", v.String())
+	}
+	if v := eval("extract_all", strLit(`z`), msg); !v.IsNull() {
+		t.Fatalf("expected null without matches")
+	}
+	if _, err := evalExpr(row, plan.CallExpr{Name: "extract", Args: []plan.Expr{strLit(`(a)`), plan.Literal{Value: model.NewInt(3)}, msg}}); err == nil {
+		t.Fatalf("expected group range error")
+	}
+	if _, err := evalExpr(row, plan.CallExpr{Name: "extract_all", Args: []plan.Expr{strLit(`(a)`), one, msg}}); err == nil {
+		t.Fatalf("expected group list error")
+	}
+	if err := checkExpr(plan.CallExpr{Name: "extract", Args: []plan.Expr{strLit("("), one, msg}}); err == nil {
+		t.Fatalf("expected plan-time regex error")
+	}
+	if _, err := evalExpr(messageRows("(").rows[0], plan.CallExpr{Name: "extract_all", Args: []plan.Expr{msg, msg}}); err == nil {
+		t.Fatalf("expected runtime regex error")
+	}
+}
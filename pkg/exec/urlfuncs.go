@@ -0,0 +1,152 @@
+package exec
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strings"
+
+	"kqlfile/pkg/model"
+)
+
+func stringArg(v model.Value) (string, bool) {
+	v = scalarOf(v)
+	if v.IsNull() {
+		return "", false
+	}
+	return v.String(), true
+}
+
+func stringValue(s string) model.Value {
+	return model.NewString(s)
+}
+
+func bagValue(bag map[string]any) model.Value {
+	return model.NewDynamic(bag)
+}
+
+// queryBag flattens query parameters into a bag, keeping the first value of
+// repeated keys.
+func queryBag(q url.Values) map[string]any {
+	bag := make(map[string]any, len(q))
+	for k, vs := range q {
+		bag[k] = vs[0]
+	}
+	return bag
+}
+
+// fnParseURL splits an absolute URL into the same property bag as KQL's
+// parse_url, or returns an empty bag.
+func fnParseURL(args []model.Value) (model.Value, error) {
+	s, ok := stringArg(args[0])
+	if !ok {
+		return bagValue(map[string]any{}), nil
+	}
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return bagValue(map[string]any{}), nil
+	}
+	password, _ := u.User.Password()
+	return bagValue(map[string]any{
+		"Scheme":           u.Scheme,
+		"Host":             u.Hostname(),
+		"Port":             u.Port(),
+		"Path":             u.Path,
+		"Username":         u.User.Username(),
+		"Password":         password,
+		"Query Parameters": queryBag(u.Query()),
+		"Fragment":         u.Fragment,
+	}), nil
+}
+
+func fnParseURLQuery(args []model.Value) (model.Value, error) {
+	s, _ := stringArg(args[0])
+	if i := strings.IndexByte(s, '?'); i >= 0 {
+		s = s[i+1:]
+	}
+	s, _, _ = strings.Cut(s, "#")
+	q, err := url.ParseQuery(s)
+	if err != nil {
+		return bagValue(map[string]any{}), nil
+	}
+	return bagValue(map[string]any{"Query Parameters": queryBag(q)}), nil
+}
+
+func fnURLDecode(args []model.Value) (model.Value, error) {
+	s, _ := stringArg(args[0])
+	out, err := url.QueryUnescape(s)
+	if err != nil {
+		return stringValue(""), nil
+	}
+	return stringValue(out), nil
+}
+
+// fnURLEncode percent-encodes s as KQL does, spaces included: QueryEscape
+// turns them into '+' and encodes a literal '+', so every '+' left is a space.
+func fnURLEncode(args []model.Value) (model.Value, error) {
+	s, _ := stringArg(args[0])
+	return stringValue(strings.ReplaceAll(url.QueryEscape(s), "+", "%20")), nil
+}
+
+// fnParsePath splits a Windows or Unix path, optionally prefixed with a
+// scheme, into the bag returned by KQL's parse_path.
+func fnParsePath(args []model.Value) (model.Value, error) {
+	s, _ := stringArg(args[0])
+	bag := map[string]any{
+		"Scheme": "", "RootPath": "", "DirectoryPath": "", "DirectoryName": "",
+		"Filename": "", "Extension": "", "AlternateDataStreamName": "",
+	}
+	rest := s
+	if scheme, after, ok := strings.Cut(rest, "://"); ok && scheme != "" && !strings.ContainsAny(scheme, `/\`) {
+		bag["Scheme"] = scheme
+		rest = after
+	}
+	switch {
+	case len(rest) >= 2 && rest[1] == ':' && isLetterByte(rest[0]):
+		bag["RootPath"] = rest[:2]
+	case strings.HasPrefix(rest, `\\`):
+		end := strings.IndexAny(rest[2:], `/\`)
+		if end == -1 {
+			bag["RootPath"] = rest
+		} else {
+			bag["RootPath"] = rest[:end+2]
+		}
+	}
+	root := bag["RootPath"].(string)
+	dir, file := "", rest[len(root):]
+	if sep := strings.LastIndexAny(rest, `/\`); sep >= 0 {
+		dir, file = rest[:sep], rest[sep+1:]
+	}
+	if name, stream, ok := strings.Cut(file, ":"); ok {
+		file = name
+		bag["AlternateDataStreamName"] = stream
+	}
+	bag["Filename"] = file
+	if dot := strings.LastIndexByte(file, '.'); dot > 0 {
+		bag["Extension"] = file[dot+1:]
+	}
+	bag["DirectoryPath"] = dir
+	if i := strings.LastIndexAny(dir, `/\`); i >= 0 && len(dir) > len(root) {
+		bag["DirectoryName"] = dir[i+1:]
+	}
+	return bagValue(bag), nil
+}
+
+func isLetterByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func fnBase64Encode(args []model.Value) (model.Value, error) {
+	s, _ := stringArg(args[0])
+	return stringValue(base64.StdEncoding.EncodeToString([]byte(s))), nil
+}
+
+func fnBase64Decode(args []model.Value) (model.Value, error) {
+	s, _ := stringArg(args[0])
+	out, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		if out, err = base64.RawStdEncoding.DecodeString(s); err != nil {
+			return stringValue(""), nil
+		}
+	}
+	return stringValue(string(out)), nil
+}
@@ -0,0 +1,54 @@
+package exec
+
+import (
+	"testing"
+)
+
+func TestURLFunctions(t *testing.T) {
+	cases := map[string]string{
+		`parse_url("https://u:p@example.com:8443/a/b?k=v&k=w&x=1#frag")`: `{"Fragment":"frag","Host":"example.com","Password":"p","Path":"/a/b","Port":"8443","Query Parameters":{"k":"v","x":"1"},"Scheme":"https","Username":"u"}`,
+		`parse_url("not a url")`:                                               `{}`,
+		`parse_url("https://example.com/x").Host`:                              "example.com",
+		`parse_urlquery("a=1&b=hello%20world")`:                                `{"Query Parameters":{"a":"1","b":"hello world"}}`,
+		`parse_urlquery("https://h/p?q=kql#top")["Query Parameters"].q`:        "kql",
+		`url_decode("a%20b%2Bc+d")`:                                            "a b+c d",
+		`url_decode("%zz")`:                                                    "",
+		`url_encode("a b&c=d/é")`:                                              "a%20b%26c%3Dd%2F%C3%A9",
+		`url_encode("1+1")`:                                                    "1%2B1",
+		`url_decode(url_encode("a b+c"))`:                                      "a b+c",
+		`parse_path("C:\\temp\\logs\\app.log")`:                                `{"AlternateDataStreamName":"","DirectoryName":"logs","DirectoryPath":"C:\\temp\\logs","Extension":"log","Filename":"app.log","RootPath":"C:","Scheme":""}`,
+		`parse_path("/var/log/syslog")`:                                        `{"AlternateDataStreamName":"","DirectoryName":"log","DirectoryPath":"/var/log","Extension":"","Filename":"syslog","RootPath":"","Scheme":""}`,
+		`parse_path("file://share/dir/x.tar.gz:meta").Extension`:               "gz",
+		`parse_path("file://share/dir/x.tar.gz:meta").AlternateDataStreamName`: "meta",
+		`base64_encode_tostring("kusto")`:                                      "a3VzdG8=",
+		`base64_decode_tostring("a3VzdG8=")`:                                   "kusto",
+		`base64_decode_tostring("a3VzdG8")`:                                    "kusto",
+		`base64_decode_tostring("***")`:                                        "",
+		`hash_md5("World")`:                                                    "f5a7924e621e84c9280a9a27e1bcb7f6",
+		`hash_sha256("World")`:                                                 "78ae647dc5544d227130a0682a51e30bc7777fbb6d8a8f17007463a3ecd1d524",
+		`hash("")`:                                                             "-1205034819632174695",
+		`hash("abc")`:                                                          "4952883123889572249",
+		`hash("Nobody inspects the spammish repetition")`:                      "-302119147016844303",
+		`hash("abc", 10)`:                                                      "9",
+	}
+	for expr, want := range cases {
+		rows := runQuery(t, nil, "print x = "+expr)
+		if got := columnStrings(rows, "x"); got != want {
+			t.Fatalf("%s: got %q, want %q", expr, got, want)
+		}
+	}
+}
+
+func TestSummarizeByExpression(t *testing.T) {
+	rows := runQuery(t, numberRows(20), "T | summarize count() by bucket = n % 3 | order by bucket asc")
+	if got := columnStrings(rows, "bucket") + " " + columnStrings(rows, "count"); got != "0,1,2 7,7,6" {
+		t.Fatalf("unexpected groups: %s", got)
+	}
+	rows = runQuery(t, numberRows(20), "T | summarize count() by g, hash(n, 1)")
+	if len(rows) != 7 || rows[0].Schema.Columns[1].Name != "Column2" {
+		t.Fatalf("unexpected groups: %d %v", len(rows), rows[0].Schema.Columns)
+	}
+	if _, err := BuildPipeline(&sliceReader{}, mustParse(t, "T | summarize count() by nope(n)")); err == nil {
+		t.Fatalf("expected unknown function error")
+	}
+}
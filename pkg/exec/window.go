@@ -0,0 +1,349 @@
+package exec
+
+import (
+	"fmt"
+	"io"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+type windowFunc struct {
+	minArgs int
+	maxArgs int
+}
+
+var windowFuncs = map[string]windowFunc{
+	"row_number":         {minArgs: 0, maxArgs: 2},
+	"prev":               {minArgs: 1, maxArgs: 3},
+	"next":               {minArgs: 1, maxArgs: 3},
+	"row_cumsum":         {minArgs: 1, maxArgs: 2},
+	"row_window_session": {minArgs: 3, maxArgs: 4},
+}
+
+// windowFrame sits below an operator whose expressions use window functions.
+// It passes rows through unchanged while keeping the previous rows, the
+// lookahead rows needed by next(), and the running state of the stateful
+// functions, all positioned at the row it last returned.
+type windowFrame struct {
+	in      Operator
+	history []*csvio.Row
+	current *csvio.Row
+	ahead   []*csvio.Row
+	maxPrev int
+	maxNext int
+	done    bool
+	calls   []*windowExpr
+}
+
+func (f *windowFrame) Next() (*csvio.Row, error) {
+	if f.current != nil && f.maxPrev > 0 {
+		f.history = append(f.history, f.current)
+		if len(f.history) > f.maxPrev {
+			f.history = append(f.history[:0], f.history[len(f.history)-f.maxPrev:]...)
+		}
+	}
+	var row *csvio.Row
+	if len(f.ahead) > 0 {
+		row = f.ahead[0]
+		f.ahead = f.ahead[1:]
+	} else {
+		if f.done {
+			return nil, io.EOF
+		}
+		next, err := f.in.Next()
+		if err != nil {
+			return nil, err
+		}
+		row = next
+	}
+	for !f.done && len(f.ahead) < f.maxNext {
+		next, err := f.in.Next()
+		if err == io.EOF {
+			f.done = true
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		f.ahead = append(f.ahead, next)
+	}
+	f.current = row
+	for _, c := range f.calls {
+		if err := c.advance(row); err != nil {
+			return nil, err
+		}
+	}
+	return row, nil
+}
+
+// at returns the row offset rows away from the current one, or nil when it
+// falls outside the input.
+func (f *windowFrame) at(offset int) *csvio.Row {
+	switch {
+	case offset == 0:
+		return f.current
+	case offset < 0:
+		i := len(f.history) + offset
+		if i < 0 {
+			return nil
+		}
+		return f.history[i]
+	default:
+		if offset > len(f.ahead) {
+			return nil
+		}
+		return f.ahead[offset-1]
+	}
+}
+
+// windowExpr is a window function call bound to a frame. prev() and next()
+// are evaluated on demand; the other functions are advanced by the frame on
+// every row so that their state does not depend on short-circuiting.
+type windowExpr struct {
+	call    plan.CallExpr
+	frame   *windowFrame
+	offset  int
+	started bool
+	value   model.Value
+	start   model.Value
+	last    model.Value
+}
+
+func (w *windowExpr) ExprType() string { return "window" }
+
+func (w *windowExpr) eval(row *csvio.Row) (model.Value, error) {
+	if w.call.Name != "prev" && w.call.Name != "next" {
+		return w.value, nil
+	}
+	target := w.frame.at(w.offset)
+	if target == nil {
+		if len(w.call.Args) == 3 {
+			return evalExpr(row, w.call.Args[2])
+		}
+		return dynamicNull, nil
+	}
+	return evalExpr(target, w.call.Args[0])
+}
+
+func (w *windowExpr) advance(row *csvio.Row) error {
+	args := make([]model.Value, len(w.call.Args))
+	for i, a := range w.call.Args {
+		v, err := evalExpr(row, a)
+		if err != nil {
+			return err
+		}
+		args[i] = v
+	}
+	restartAt := 1
+	if w.call.Name == "row_window_session" {
+		restartAt = 3
+	}
+	restart := !w.started
+	if restartAt < len(args) && toBool(args[restartAt]) {
+		restart = true
+	}
+	w.started = true
+
+	switch w.call.Name {
+	case "row_number":
+		if restart {
+			w.value = model.NewInt(1)
+			if len(args) > 0 {
+				w.value = model.NewInt(toInt64(args[0]))
+			}
+			return nil
+		}
+		w.value = model.NewInt(w.value.Int() + 1)
+	case "row_cumsum":
+		term := scalarOf(args[0])
+		if term.IsNull() {
+			term = model.NewInt(0)
+		}
+		if restart {
+			w.value = term
+			return nil
+		}
+		sum, err := arith("+", w.value, term)
+		if err != nil {
+			return fmt.Errorf("row_cumsum: %w", err)
+		}
+		w.value = sum
+	case "row_window_session":
+		v := args[0]
+		if !restart && !v.IsNull() && !w.start.IsNull() {
+			fromFirst, err := arith("-", v, w.start)
+			if err != nil {
+				return fmt.Errorf("row_window_session: %w", err)
+			}
+			between, err := arith("-", v, w.last)
+			if err != nil {
+				return fmt.Errorf("row_window_session: %w", err)
+			}
+			restart = compareValues(fromFirst, args[1]) > 0 || compareValues(between, args[2]) > 0
+		} else {
+			restart = true
+		}
+		if restart {
+			w.start = v
+		}
+		w.last = v
+		w.value = w.start
+	}
+	return nil
+}
+
+// bindWindow wraps in with a window frame when expr uses window functions,
+// returning the expression rewritten to read from that frame. Window
+// functions are only meaningful over ordered input.
+func bindWindow(in Operator, expr plan.Expr, serialized bool) (Operator, plan.Expr, error) {
+	name := windowCall(expr)
+	if name == "" {
+		return in, expr, nil
+	}
+	if !serialized {
+		return nil, nil, fmt.Errorf("%s() requires serialized input (use order by or serialize)", name)
+	}
+	frame := &windowFrame{in: in}
+	bound, err := bindWindowExpr(expr, frame)
+	if err != nil {
+		return nil, nil, err
+	}
+	return frame, bound, nil
+}
+
+func bindWindowExpr(expr plan.Expr, frame *windowFrame) (plan.Expr, error) {
+	bind := func(e plan.Expr) (plan.Expr, error) { return bindWindowExpr(e, frame) }
+	switch e := expr.(type) {
+	case plan.CompareExpr:
+		l, err := bind(e.Left)
+		if err != nil {
+			return nil, err
+		}
+		r, err := bind(e.Right)
+		if err != nil {
+			return nil, err
+		}
+		return plan.CompareExpr{Left: l, Op: e.Op, Right: r}, nil
+	case plan.LogicalExpr:
+		l, err := bind(e.Left)
+		if err != nil {
+			return nil, err
+		}
+		r, err := bind(e.Right)
+		if err != nil {
+			return nil, err
+		}
+		return plan.LogicalExpr{Left: l, Op: e.Op, Right: r}, nil
+	case plan.BinaryExpr:
+		l, err := bind(e.Left)
+		if err != nil {
+			return nil, err
+		}
+		r, err := bind(e.Right)
+		if err != nil {
+			return nil, err
+		}
+		return plan.BinaryExpr{Left: l, Op: e.Op, Right: r}, nil
+	case plan.MemberExpr:
+		t, err := bind(e.Target)
+		if err != nil {
+			return nil, err
+		}
+		return plan.MemberExpr{Target: t, Name: e.Name}, nil
+	case plan.IndexExpr:
+		t, err := bind(e.Target)
+		if err != nil {
+			return nil, err
+		}
+		idx, err := bind(e.Index)
+		if err != nil {
+			return nil, err
+		}
+		return plan.IndexExpr{Target: t, Index: idx}, nil
+	case plan.CallExpr:
+		args := make([]plan.Expr, len(e.Args))
+		for i, a := range e.Args {
+			b, err := bind(a)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = b
+		}
+		fn, ok := windowFuncs[e.Name]
+		if !ok {
+			return plan.CallExpr{Name: e.Name, Args: args}, nil
+		}
+		if len(e.Args) < fn.minArgs || len(e.Args) > fn.maxArgs {
+			return nil, fmt.Errorf("%s: wrong number of arguments (%d)", e.Name, len(e.Args))
+		}
+		w := &windowExpr{call: plan.CallExpr{Name: e.Name, Args: args}, frame: frame}
+		if e.Name == "prev" || e.Name == "next" {
+			if inner := windowCall(e.Args[0]); inner != "" {
+				return nil, fmt.Errorf("%s: cannot nest %s()", e.Name, inner)
+			}
+			offset, err := windowOffset(e)
+			if err != nil {
+				return nil, err
+			}
+			if e.Name == "prev" {
+				w.offset = -offset
+				frame.maxPrev = max(frame.maxPrev, offset)
+			} else {
+				w.offset = offset
+				frame.maxNext = max(frame.maxNext, offset)
+			}
+			return w, nil
+		}
+		frame.calls = append(frame.calls, w)
+		return w, nil
+	default:
+		return expr, nil
+	}
+}
+
+func windowOffset(call plan.CallExpr) (int, error) {
+	if len(call.Args) < 2 {
+		return 1, nil
+	}
+	lit, ok := call.Args[1].(plan.Literal)
+	if !ok || lit.Value.Type != model.TypeInt || lit.Value.Int() < 0 {
+		return 0, fmt.Errorf("%s: offset must be a non-negative integer constant", call.Name)
+	}
+	return int(lit.Value.Int()), nil
+}
+
+// windowCall returns the name of the first window function used in expr, or
+// an empty string.
+func windowCall(expr plan.Expr) string {
+	switch e := expr.(type) {
+	case plan.CompareExpr:
+		return firstNonEmpty(windowCall(e.Left), windowCall(e.Right))
+	case plan.LogicalExpr:
+		return firstNonEmpty(windowCall(e.Left), windowCall(e.Right))
+	case plan.BinaryExpr:
+		return firstNonEmpty(windowCall(e.Left), windowCall(e.Right))
+	case plan.MemberExpr:
+		return windowCall(e.Target)
+	case plan.IndexExpr:
+		return firstNonEmpty(windowCall(e.Target), windowCall(e.Index))
+	case plan.CallExpr:
+		if _, ok := windowFuncs[e.Name]; ok {
+			return e.Name
+		}
+		for _, a := range e.Args {
+			if name := windowCall(a); name != "" {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+func firstNonEmpty(a, b string) string {
+	if a != "" {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,149 @@
+package exec
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/parser"
+	"kqlfile/pkg/plan"
+)
+
+func eventRows(minutes ...int) []*csvio.Row {
+	schema := model.NewSchema([]model.Column{{Name: "ts", Type: model.TypeDateTime}, {Name: "n", Type: model.TypeInt}})
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := make([]*csvio.Row, len(minutes))
+	for i, m := range minutes {
+		rows[i] = &csvio.Row{Schema: schema, Values: []model.Value{
+			model.NewDateTime(base.Add(time.Duration(m) * time.Minute)),
+			model.NewInt(int64(i + 1)),
+		}}
+	}
+	return rows
+}
+
+func mustParse(t *testing.T, query string) []plan.Operator {
+	t.Helper()
+	ops, err := parser.Parse(query)
+	if err != nil {
+		t.Fatalf("parse %s: %v", query, err)
+	}
+	return ops
+}
+
+func runQuery(t *testing.T, rows []*csvio.Row, query string) []*csvio.Row {
+	t.Helper()
+	op, err := BuildPipeline(&sliceReader{rows: rows}, mustParse(t, query))
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+	return drain(t, op)
+}
+
+func columnStrings(rows []*csvio.Row, name string) string {
+	out := make([]string, len(rows))
+	for i, r := range rows {
+		v, _ := r.Get(name)
+		out[i] = v.String()
+	}
+	return strings.Join(out, ",")
+}
+
+func TestWindowFunctions(t *testing.T) {
+	rows := runQuery(t, eventRows(0, 1, 3, 10, 11), "T | serialize rn = row_number(), gap = ts - prev(ts), after = next(n, 2, -1)")
+	if got := columnStrings(rows, "rn"); got != "1,2,3,4,5" {
+		t.Fatalf("row_number: %s", got)
+	}
+	if got := columnStrings(rows, "gap"); got != ",00:01:00,00:02:00,00:07:00,00:01:00" {
+		t.Fatalf("prev: %s", got)
+	}
+	if got := columnStrings(rows, "after"); got != "3,4,5,-1,-1" {
+		t.Fatalf("next: %s", got)
+	}
+
+	rows = runQuery(t, eventRows(0, 1, 3, 10, 11), "T | order by n asc | extend s = row_cumsum(n, n == 3)")
+	if got := columnStrings(rows, "s"); got != "1,3,3,7,12" {
+		t.Fatalf("row_cumsum: %s", got)
+	}
+	rows = runQuery(t, eventRows(0, 1, 3, 10, 11), "T | serialize | extend r = row_number(10, n == 4)")
+	if got := columnStrings(rows, "r"); got != "10,11,12,10,11" {
+		t.Fatalf("row_number restart: %s", got)
+	}
+	rows = runQuery(t, eventRows(0, 1, 3, 10, 11, 13), "T | serialize | extend s = row_window_session(ts, 2m, 5m)")
+	if got := columnStrings(rows, "s"); got != "2024-01-01T00:00:00Z,2024-01-01T00:00:00Z,2024-01-01T00:03:00Z,2024-01-01T00:10:00Z,2024-01-01T00:10:00Z,2024-01-01T00:13:00Z" {
+		t.Fatalf("row_window_session: %s", got)
+	}
+}
+
+func TestWindowStateIgnoresShortCircuit(t *testing.T) {
+	rows := runQuery(t, eventRows(0, 1, 2, 3), "T | serialize | where n % 2 == 0 and row_cumsum(n) > 0 | serialize rn = row_number()")
+	if got := columnStrings(rows, "rn"); got != "1,2" {
+		t.Fatalf("unexpected rows: %s", got)
+	}
+	rows = runQuery(t, eventRows(0, 1, 2, 3), "T | serialize | where n > 1 and row_cumsum(n) >= 6")
+	if got := columnStrings(rows, "n"); got != "3,4" {
+		t.Fatalf("cumsum should include skipped rows: %s", got)
+	}
+}
+
+func TestWindowRequiresSerializedInput(t *testing.T) {
+	cases := map[string]string{
+		"T | extend r = row_number()":                                      "requires serialized input",
+		"T | order by n asc | summarize count() by n | extend p = prev(n)": "requires serialized input",
+		"T | serialize | extend p = prev(n, n)":                            "offset must be",
+		"T | serialize | extend p = prev(prev(n))":                         "cannot nest",
+		"T | serialize | extend p = row_cumsum()":                          "wrong number of arguments",
+		"T | serialize | parse row_number() with x:int":                    "only supported",
+	}
+	for query, want := range cases {
+		ops, err := parser.Parse(query)
+		if err != nil {
+			t.Fatalf("%s: parse: %v", query, err)
+		}
+		if _, err := BuildPipeline(&sliceReader{rows: eventRows(0)}, ops); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%s: expected %q, got %v", query, want, err)
+		}
+	}
+	if _, err := BuildPipeline(&sliceReader{}, []plan.Operator{plan.SerializeOp{}, plan.TakeOp{Count: 1}, plan.ExtendOp{Name: "r", Value: plan.CallExpr{Name: "row_number"}}}); err != nil {
+		t.Fatalf("take should keep serialization: %v", err)
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	i := func(n int64) model.Value { return model.NewInt(n) }
+	f := func(n float64) model.Value { return model.NewFloat(n) }
+	ts := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	dt := model.NewDateTime(ts)
+	cases := []struct {
+		op   string
+		l, r model.Value
+		want string
+	}{
+		{"+", i(2), i(3), "5"},
+		{"/", i(7), i(2), "3"},
+		{"%", i(7), i(2), "1"},
+		{"*", i(2), f(1.5), "3"},
+		{"/", i(1), i(0), ""},
+		{"-", model.NewDateTime(ts.Add(time.Hour)), dt, "01:00:00"},
+		{"+", dt, model.NewTimespan(time.Minute), "2024-01-01T00:01:00Z"},
+		{"-", dt, model.NewTimespan(time.Minute), "2023-12-31T23:59:00Z"},
+		{"*", model.NewTimespan(time.Minute), i(90), "01:30:00"},
+		{"/", model.NewTimespan(time.Hour), model.NewTimespan(time.Minute), "60"},
+		{"+", model.NewDynamic(float64(2)), i(1), "3"},
+		{"+", dynamicNull, i(1), ""},
+	}
+	for _, c := range cases {
+		got, err := arith(c.op, c.l, c.r)
+		if err != nil || got.String() != c.want {
+			t.Fatalf("%v %s %v = %v (%v), want %s", c.l, c.op, c.r, got.String(), err, c.want)
+		}
+	}
+	if _, err := arith("-", model.NewString("a"), i(1)); err == nil {
+		t.Fatalf("expected type error")
+	}
+	if compareValues(model.NewTimespan(time.Hour), model.NewTimespan(time.Minute)) <= 0 {
+		t.Fatalf("expected timespan ordering")
+	}
+}
@@ -21,14 +21,39 @@ type Reader struct {
 	buffer   []map[string]any
 	bufferIx int
 	columns  []string
+	// wanted holds the fields to decode when only some columns are read.
+	wanted  map[string]bool
+	builder *csvio.RowBuilder
+	obj     map[string]any
+	parse   func(j int) (model.Value, error)
+	counted *countingReader
+	stats   csvio.ReadStats
+	// err is the error that ended the last batch.
+	err error
 }
 
-func NewReader(path string, schema *model.Schema) (*Reader, error) {
+// countingReader counts the bytes read from the file.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// NewReader opens the JSON Lines file at path. Without a schema the columns
+// and their types are inferred from the first objects. Options are those of
+// csvio.NewReader.
+func NewReader(path string, schema *model.Schema, opts ...csvio.Option) (*Reader, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
-	sc := bufio.NewScanner(f)
+	counted := &countingReader{r: f}
+	sc := bufio.NewScanner(counted)
 	sc.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
 
 	var sch model.Schema
@@ -40,7 +65,11 @@ func NewReader(path string, schema *model.Schema) (*Reader, error) {
 			columns = append(columns, c.Name)
 		}
 	} else {
+		// Columns keep the order their keys are first seen in; the map
+		// holds the samples, the slice the order.
 		colSamples := map[string][]string{}
+		var colOrder []string
+		dynamicCols := map[string]bool{}
 		for i := 0; i < inferSampleSize && sc.Scan(); i++ {
 			line := strings.TrimSpace(sc.Text())
 			if line == "" {
@@ -52,19 +81,49 @@ func NewReader(path string, schema *model.Schema) (*Reader, error) {
 				return nil, err
 			}
 			buffer = append(buffer, obj)
+			keys, err := objectKeys(line)
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+			for _, k := range keys {
+				if _, ok := colSamples[k]; !ok {
+					colSamples[k] = nil
+					colOrder = append(colOrder, k)
+				}
+			}
 			for k, v := range obj {
-				colSamples[k] = append(colSamples[k], fmt.Sprintf("%v", v))
+				if isNested(v) {
+					dynamicCols[k] = true
+				}
+				colSamples[k] = append(colSamples[k], valueText(v))
 			}
 		}
-		cols := make([]model.Column, 0, len(colSamples))
-		for name, values := range colSamples {
-			cols = append(cols, model.Column{Name: name, Type: model.InferType(values)})
+		cols := make([]model.Column, 0, len(colOrder))
+		for _, name := range colOrder {
+			typ := model.InferType(colSamples[name])
+			if dynamicCols[name] {
+				typ = model.TypeDynamic
+			}
+			cols = append(cols, model.Column{Name: name, Type: typ})
 			columns = append(columns, name)
 		}
 		sch = model.NewSchema(cols)
 	}
 
-	return &Reader{file: f, scanner: sc, schema: sch, buffer: buffer, columns: columns}, nil
+	o := csvio.NewOptions(opts...)
+	r := &Reader{file: f, scanner: sc, buffer: buffer, columns: columns, counted: counted}
+	r.stats = csvio.ReadStats{Path: path, Format: "json"}
+	r.schema, _ = csvio.Select(sch, o.Columns)
+	r.builder = csvio.NewRowBuilder(r.schema, o.Filter)
+	r.parse = func(j int) (model.Value, error) { return r.parseField(r.obj, j) }
+	if len(r.schema.Columns) < len(sch.Columns) {
+		r.wanted = map[string]bool{}
+		for _, c := range r.schema.Columns {
+			r.wanted[c.Name] = true
+		}
+	}
+	return r, nil
 }
 
 func (r *Reader) Schema() model.Schema {
@@ -75,22 +134,81 @@ func (r *Reader) Close() error {
 	return r.file.Close()
 }
 
+// Stats returns what the reader has read so far.
+func (r *Reader) Stats() csvio.ReadStats {
+	s := r.stats
+	s.Bytes = r.counted.n
+	return s
+}
+
+// NextBatch returns the next rows, up to csvio.BatchSize of them. A batch is
+// cut short by an error, which is returned by the following call.
+func (r *Reader) NextBatch() (*csvio.Batch, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	b := csvio.NewBatch(r.schema, csvio.BatchSize)
+	for b.Len < csvio.BatchSize {
+		obj, err := r.object()
+		if err == nil {
+			r.stats.Records++
+			r.obj = obj
+			var kept bool
+			if kept, err = r.builder.Append(b, r.parse); kept {
+				r.stats.Rows++
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				r.stats.ParseErrors++
+			}
+			r.err = err
+			break
+		}
+	}
+	if b.Len == 0 {
+		return nil, r.err
+	}
+	return b, nil
+}
+
 func (r *Reader) Next() (*csvio.Row, error) {
+	for {
+		obj, err := r.object()
+		if err != nil {
+			if err != io.EOF {
+				r.stats.ParseErrors++
+			}
+			return nil, err
+		}
+		r.stats.Records++
+		r.obj = obj
+		row, err := r.builder.Build(r.parse)
+		if err != nil {
+			r.stats.ParseErrors++
+			return nil, err
+		}
+		if row != nil {
+			r.stats.Rows++
+			return row, nil
+		}
+	}
+}
+
+func (r *Reader) object() (map[string]any, error) {
 	if r.bufferIx < len(r.buffer) {
-		obj := r.buffer[r.bufferIx]
 		r.bufferIx++
-		return r.parseObject(obj)
+		return r.buffer[r.bufferIx-1], nil
 	}
 	for r.scanner.Scan() {
 		line := strings.TrimSpace(r.scanner.Text())
 		if line == "" {
 			continue
 		}
-		obj, err := parseJSONLine(line)
-		if err != nil {
-			return nil, err
+		if r.wanted != nil {
+			return parseJSONFields(line, r.wanted)
 		}
-		return r.parseObject(obj)
+		return parseJSONLine(line)
 	}
 	if err := r.scanner.Err(); err != nil {
 		return nil, err
@@ -109,20 +227,83 @@ func parseJSONLine(line string) (map[string]any, error) {
 	return obj, nil
 }
 
-func (r *Reader) parseObject(obj map[string]any) (*csvio.Row, error) {
-	vals := make([]model.Value, len(r.schema.Columns))
-	for i, col := range r.schema.Columns {
-		raw, ok := obj[col.Name]
-		if !ok {
-			vals[i] = model.Value{Type: col.Type, V: ""}
+// objectKeys returns the keys of an object line in the order they appear.
+func objectKeys(line string) ([]string, error) {
+	dec := json.NewDecoder(strings.NewReader(line))
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	var keys []string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil, err
+		}
+		keys = append(keys, tok.(string))
+	}
+	return keys, nil
+}
+
+// parseJSONFields decodes the wanted fields of an object line, leaving the
+// values of the other fields unparsed.
+func parseJSONFields(line string, wanted map[string]bool) (map[string]any, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(line), &raw); err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("expected object")
+	}
+	obj := make(map[string]any, len(wanted))
+	for k, v := range raw {
+		if !wanted[k] {
 			continue
 		}
-		valStr := fmt.Sprintf("%v", raw)
-		v, err := model.ParseValue(col.Type, valStr)
-		if err != nil {
-			return nil, fmt.Errorf("parse field %s: %w", col.Name, err)
+		var val any
+		if err := json.Unmarshal(v, &val); err != nil {
+			return nil, err
+		}
+		obj[k] = val
+	}
+	return obj, nil
+}
+
+// parseField parses the value of schema column j from obj.
+func (r *Reader) parseField(obj map[string]any, j int) (model.Value, error) {
+	col := r.schema.Columns[j]
+	raw, ok := obj[col.Name]
+	if col.Type == model.TypeDynamic {
+		return model.NewDynamic(raw), nil
+	}
+	if !ok {
+		return model.NullOf(col.Type), nil
+	}
+	v, err := model.ParseValue(col.Type, valueText(raw))
+	if err != nil {
+		return model.Value{}, fmt.Errorf("parse field %s: %w", col.Name, err)
+	}
+	return v, nil
+}
+
+func isNested(v any) bool {
+	switch v.(type) {
+	case map[string]any, []any:
+		return true
+	default:
+		return false
+	}
+}
+
+func valueText(v any) string {
+	if isNested(v) {
+		b, err := json.Marshal(v)
+		if err == nil {
+			return string(b)
 		}
-		vals[i] = v
 	}
-	return &csvio.Row{Schema: r.schema, Values: vals}, nil
+	return fmt.Sprintf("%v", v)
 }
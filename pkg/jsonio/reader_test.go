@@ -1,11 +1,13 @@
 package jsonio
 
 import (
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"kqlfile/pkg/csvio"
 	"kqlfile/pkg/model"
 )
 
@@ -29,6 +31,30 @@ func TestJSONReaderInfer(t *testing.T) {
 	}
 }
 
+func TestJSONReaderInferKeepsKeyOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.jsonl")
+	data := "{\"name\":\"alice\",\"age\":30,\"city\":\"seoul\",\"b\":1,\"a\":2}\n" +
+		"{\"zip\":\"04524\",\"name\":\"bob\",\"age\":41}\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	want := "name,age,city,b,a,zip"
+	for i := 0; i < 10; i++ {
+		reader, err := NewReader(path, nil)
+		if err != nil {
+			t.Fatalf("reader: %v", err)
+		}
+		var names []string
+		for _, c := range reader.Schema().Columns {
+			names = append(names, c.Name)
+		}
+		reader.Close()
+		if got := strings.Join(names, ","); got != want {
+			t.Fatalf("columns %s, want %s", got, want)
+		}
+	}
+}
+
 func TestJSONReaderInferBlankLine(t *testing.T) {
 	path := filepath.Join(t.TempDir(), "data.jsonl")
 	data := "\n{\"name\":\"alice\",\"age\":30}\n"
@@ -216,3 +242,132 @@ func TestJSONReaderOpenError(t *testing.T) {
 		t.Fatalf("expected open error")
 	}
 }
+
+func TestJSONReaderDynamicColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.jsonl")
+	data := "{\"id\":1,\"props\":{\"user\":\"alice\"},\"tags\":[\"a\",\"b\"]}\n{\"id\":2,\"props\":{\"user\":\"bob\"}}\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	reader, err := NewReader(path, nil)
+	if err != nil {
+		t.Fatalf("reader: %v", err)
+	}
+	defer reader.Close()
+	sch := reader.Schema()
+	for _, name := range []string{"props", "tags"} {
+		if sch.Columns[sch.Index[name]].Type != model.TypeDynamic {
+			t.Fatalf("expected dynamic %s", name)
+		}
+	}
+	row, err := reader.Next()
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+	tags, _ := row.Get("tags")
+	if tags.String() != `["a","b"]` {
+		t.Fatalf("unexpected tags: %s", tags.String())
+	}
+	row, err = reader.Next()
+	if err != nil {
+		t.Fatalf("next: %v", err)
+	}
+	if tags, _ := row.Get("tags"); !tags.IsNull() {
+		t.Fatalf("expected null tags, got %v", tags)
+	}
+}
+
+func TestJSONReaderWithColumns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.jsonl")
+	data := "{\"name\":\"alice\",\"age\":30,\"tags\":[1]}\n{\"name\":\"bob\",\"age\":\"n/a\",\"tags\":{\"a\":1}}\n{\"name\":\"carol\",\"age\":41}\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	sch := model.NewSchema([]model.Column{
+		{Name: "name", Type: model.TypeString},
+		{Name: "age", Type: model.TypeInt},
+		{Name: "tags", Type: model.TypeDynamic},
+	})
+	reader, err := NewReader(path, &sch, csvio.WithColumns("name"))
+	if err != nil {
+		t.Fatalf("reader: %v", err)
+	}
+	defer reader.Close()
+	if cols := reader.Schema().Columns; len(cols) != 1 || cols[0].Name != "name" {
+		t.Fatalf("expected only name, got %v", cols)
+	}
+	var names []string
+	for {
+		row, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("the malformed age should not be parsed: %v", err)
+		}
+		names = append(names, row.Values[0].String())
+	}
+	if strings.Join(names, ",") != "alice,bob,carol" {
+		t.Fatalf("unexpected names %v", names)
+	}
+}
+
+func TestJSONReaderWithFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.jsonl")
+	data := "{\"name\":\"alice\",\"age\":\"n/a\",\"level\":\"info\"}\n{\"name\":\"bob\",\"age\":41,\"level\":\"error\"}\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	sch := model.NewSchema([]model.Column{
+		{Name: "name", Type: model.TypeString},
+		{Name: "age", Type: model.TypeInt},
+		{Name: "level", Type: model.TypeString},
+	})
+	keep := func(row *csvio.Row) (bool, error) {
+		v, _ := row.Get("level")
+		return v.String() == "error", nil
+	}
+	reader, err := NewReader(path, &sch, csvio.WithFilter(csvio.Filter{Columns: []string{"level"}, Keep: keep}))
+	if err != nil {
+		t.Fatalf("reader: %v", err)
+	}
+	defer reader.Close()
+	row, err := reader.Next()
+	if err != nil {
+		t.Fatalf("the age of the dropped row should not be parsed: %v", err)
+	}
+	if row.Values[0].String() != "bob" || row.Values[1].String() != "41" {
+		t.Fatalf("unexpected row %v", row.Values)
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+	if st := reader.Stats(); st.Records != 2 || st.Rows != 1 || st.Bytes != int64(len(data)) || st.Format != "json" {
+		t.Fatalf("unexpected stats %+v", st)
+	}
+}
+
+func TestJSONReaderNextBatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.jsonl")
+	data := "{\"age\":1}\n\n{\"age\":2,\"x\":true}\n{}\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	sch := model.NewSchema([]model.Column{{Name: "age", Type: model.TypeInt}})
+	reader, err := NewReader(path, &sch)
+	if err != nil {
+		t.Fatalf("reader: %v", err)
+	}
+	defer reader.Close()
+	b, err := reader.NextBatch()
+	if err != nil {
+		t.Fatalf("next batch: %v", err)
+	}
+	// The missing age boxes the column.
+	if b.Rows() != 3 || !b.Columns[0].Boxed() || b.Columns[0].Value(1).Int() != 2 {
+		t.Fatalf("unexpected batch %+v", b.Columns[0])
+	}
+	if _, err := reader.NextBatch(); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+var dateTimeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+// ParseDateTime parses the datetime formats accepted in queries: RFC 3339,
+// with or without a zone, and plain dates.
+func ParseDateTime(s string) (time.Time, bool) {
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// CompareType is the comparison matrix: it reports whether values of types a
+// and b can be compared and the type both are converted to first. Mixed
+// numbers compare as float, though without rounding the int, and strings
+// compared with a datetime or timespan are parsed as one. Dynamic values are
+// only checked once their content is known, so they compare with anything
+// here.
+func CompareType(a, b Type) (Type, bool) {
+	switch {
+	case a == b:
+		return a, true
+	case a == TypeDynamic || a == "":
+		return b, true
+	case b == TypeDynamic || b == "":
+		return a, true
+	case (a == TypeInt || a == TypeFloat) && (b == TypeInt || b == TypeFloat):
+		return TypeFloat, true
+	case a == TypeString && (b == TypeDateTime || b == TypeTimespan):
+		return b, true
+	case b == TypeString && (a == TypeDateTime || a == TypeTimespan):
+		return a, true
+	}
+	return "", false
+}
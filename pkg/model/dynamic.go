@@ -0,0 +1,64 @@
+package model
+
+import (
+	"encoding/json"
+	"math"
+)
+
+func ParseDynamic(raw string) (any, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	var v any
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// FromDynamic converts a decoded JSON value into a typed Value. Scalars take
+// their natural type so they compare like regular columns; objects, arrays and
+// null stay dynamic.
+func FromDynamic(raw any) Value {
+	switch v := raw.(type) {
+	case string:
+		return NewString(v)
+	case bool:
+		return NewBool(v)
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			return NewInt(int64(v))
+		}
+		return NewFloat(v)
+	case int64:
+		return NewInt(v)
+	default:
+		return NewDynamic(raw)
+	}
+}
+
+// ToDynamic converts a typed Value into its JSON representation.
+func ToDynamic(v Value) any {
+	switch v.Type {
+	case TypeInt:
+		return float64(v.Int())
+	case TypeDateTime, TypeTimespan:
+		return v.String()
+	default:
+		return v.Any()
+	}
+}
+
+func dynamicString(raw any) string {
+	switch v := raw.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
@@ -0,0 +1,111 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timespanUnits = map[string]time.Duration{
+	"d":            24 * time.Hour,
+	"day":          24 * time.Hour,
+	"days":         24 * time.Hour,
+	"h":            time.Hour,
+	"hr":           time.Hour,
+	"hrs":          time.Hour,
+	"hour":         time.Hour,
+	"hours":        time.Hour,
+	"m":            time.Minute,
+	"min":          time.Minute,
+	"minute":       time.Minute,
+	"minutes":      time.Minute,
+	"s":            time.Second,
+	"sec":          time.Second,
+	"second":       time.Second,
+	"seconds":      time.Second,
+	"ms":           time.Millisecond,
+	"milli":        time.Millisecond,
+	"millis":       time.Millisecond,
+	"millisecond":  time.Millisecond,
+	"milliseconds": time.Millisecond,
+	"microsecond":  time.Microsecond,
+	"microseconds": time.Microsecond,
+	"tick":         100 * time.Nanosecond,
+	"ticks":        100 * time.Nanosecond,
+}
+
+// ParseTimespan accepts KQL literals such as 7d, 1.5h or 100ms as well as the
+// [d.]hh:mm:ss[.fffffff] form produced by FormatTimespan.
+func ParseTimespan(raw string) (time.Duration, error) {
+	s := strings.TrimSpace(raw)
+	if strings.Contains(s, ":") {
+		return parseClockTimespan(s)
+	}
+	i := 0
+	for i < len(s) && (s[i] == '-' || s[i] == '.' || (s[i] >= '0' && s[i] <= '9')) {
+		i++
+	}
+	unit, ok := timespanUnits[strings.ToLower(s[i:])]
+	if i == 0 || !ok {
+		return 0, fmt.Errorf("invalid timespan: %s", raw)
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timespan: %s", raw)
+	}
+	return time.Duration(n * float64(unit)), nil
+}
+
+func parseClockTimespan(s string) (time.Duration, error) {
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	var days int64
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid timespan: %s", s)
+	}
+	if dot := strings.Index(parts[0], "."); dot != -1 {
+		d, err := strconv.ParseInt(parts[0][:dot], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid timespan: %s", s)
+		}
+		days = d
+		parts[0] = parts[0][dot+1:]
+	}
+	h, err1 := strconv.ParseInt(parts[0], 10, 64)
+	m, err2 := strconv.ParseInt(parts[1], 10, 64)
+	sec, err3 := strconv.ParseFloat(parts[2], 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0, fmt.Errorf("invalid timespan: %s", s)
+	}
+	d := time.Duration(days)*24*time.Hour + time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(sec*float64(time.Second))
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
+
+func FormatTimespan(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	days := d / (24 * time.Hour)
+	d -= days * 24 * time.Hour
+	h := d / time.Hour
+	d -= h * time.Hour
+	m := d / time.Minute
+	d -= m * time.Minute
+	sec := d / time.Second
+	frac := (d - sec*time.Second) / 100
+	out := fmt.Sprintf("%s%02d:%02d:%02d", sign, h, m, sec)
+	if days > 0 {
+		out = fmt.Sprintf("%s%d.%02d:%02d:%02d", sign, days, h, m, sec)
+	}
+	if frac > 0 {
+		out += fmt.Sprintf(".%07d", frac)
+	}
+	return out
+}
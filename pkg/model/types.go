@@ -2,9 +2,11 @@ package model
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
+	"unsafe"
 )
 
 type Type string
@@ -15,8 +17,31 @@ const (
 	TypeFloat    Type = "float"
 	TypeBool     Type = "bool"
 	TypeDateTime Type = "datetime"
+	TypeDynamic  Type = "dynamic"
+	TypeTimespan Type = "timespan"
 )
 
+func ParseType(name string) (Type, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "string":
+		return TypeString, true
+	case "int", "long":
+		return TypeInt, true
+	case "float", "real", "double", "decimal":
+		return TypeFloat, true
+	case "bool", "boolean":
+		return TypeBool, true
+	case "datetime", "date":
+		return TypeDateTime, true
+	case "dynamic":
+		return TypeDynamic, true
+	case "timespan", "time":
+		return TypeTimespan, true
+	default:
+		return "", false
+	}
+}
+
 type Column struct {
 	Name string
 	Type Type
@@ -35,31 +60,180 @@ func NewSchema(cols []Column) Schema {
 	return Schema{Columns: cols, Index: idx}
 }
 
+// Value is a typed value with an unboxed payload. Type is still the name of
+// the type, as before, so values are no smaller; what they avoid is boxing
+// the payload in an interface. Ints, floats, bools and timespans are kept in
+// num; strings as their length in num and their bytes in ptr; datetimes as
+// nanoseconds since the epoch in num and their location in ptr. Dynamic
+// values, and values of other types, keep their content behind ptr. Values
+// are built with the constructors below and read with the accessor of their
+// type; since == and reflect.DeepEqual compare ptr by address, compare
+// payloads rather than values.
 type Value struct {
 	Type Type
-	V    any
+	num  uint64
+	ptr  unsafe.Pointer
+}
+
+// farTime in num marks a datetime kept whole behind ptr.
+const farTime uint64 = 1 << 63
+
+func NewString(s string) Value {
+	return Value{Type: TypeString, num: uint64(len(s)), ptr: unsafe.Pointer(unsafe.StringData(s))}
+}
+
+func NewInt(i int64) Value {
+	return Value{Type: TypeInt, num: uint64(i)}
+}
+
+func NewFloat(f float64) Value {
+	return Value{Type: TypeFloat, num: math.Float64bits(f)}
+}
+
+func NewBool(b bool) Value {
+	v := Value{Type: TypeBool}
+	if b {
+		v.num = 1
+	}
+	return v
+}
+
+// NewDateTime stores t as nanoseconds since the epoch and its location.
+// Instants out of the range of int64 nanoseconds, before 1678 or after 2262,
+// are kept whole.
+func NewDateTime(t time.Time) Value {
+	ns := t.UnixNano()
+	if uint64(ns) == farTime || !time.Unix(0, ns).Equal(t) {
+		return Value{Type: TypeDateTime, num: farTime, ptr: unsafe.Pointer(&t)}
+	}
+	return Value{Type: TypeDateTime, num: uint64(ns), ptr: unsafe.Pointer(t.Location())}
+}
+
+func NewTimespan(d time.Duration) Value {
+	return Value{Type: TypeTimespan, num: uint64(d)}
+}
+
+// NewDynamic returns a dynamic value holding x, a decoded JSON value; a nil
+// x is null.
+func NewDynamic(x any) Value {
+	if x == nil {
+		return Value{Type: TypeDynamic}
+	}
+	return Value{Type: TypeDynamic, ptr: unsafe.Pointer(&x)}
+}
+
+// Null is the value of missing data.
+var Null = NewDynamic(nil)
+
+// NullOf returns the value of missing data in a column of type t: the empty
+// string for strings and null otherwise.
+func NullOf(t Type) Value {
+	if t == TypeString {
+		return NewString("")
+	}
+	return Null
+}
+
+// IsNull reports whether v is null.
+func (v Value) IsNull() bool {
+	return v.Type == TypeDynamic && v.ptr == nil
+}
+
+// Int returns the payload of an int.
+func (v Value) Int() int64 {
+	return int64(v.num)
+}
+
+// Float returns the payload of a float.
+func (v Value) Float() float64 {
+	return math.Float64frombits(v.num)
+}
+
+// Bool returns the payload of a bool.
+func (v Value) Bool() bool {
+	return v.num != 0
+}
+
+// DateTime returns the payload of a datetime.
+func (v Value) DateTime() time.Time {
+	switch {
+	case v.ptr == nil:
+		return time.Time{}
+	case v.num == farTime:
+		return *(*time.Time)(v.ptr)
+	}
+	return time.Unix(0, int64(v.num)).In((*time.Location)(v.ptr))
+}
+
+// Timespan returns the payload of a timespan.
+func (v Value) Timespan() time.Duration {
+	return time.Duration(v.num)
+}
+
+// Dynamic returns the content of a dynamic value, nil for null, or of a
+// value of another type. It is nil for the other built-in types.
+func (v Value) Dynamic() any {
+	switch v.Type {
+	case TypeString, TypeInt, TypeFloat, TypeBool, TypeDateTime, TypeTimespan:
+		return nil
+	}
+	if v.ptr == nil {
+		return nil
+	}
+	return *(*any)(v.ptr)
+}
+
+// Any returns the payload of v boxed as the Go type of its type: string,
+// int64, float64, bool, time.Time or time.Duration, or the content of
+// dynamic values.
+func (v Value) Any() any {
+	switch v.Type {
+	case TypeString:
+		return v.String()
+	case TypeInt:
+		return v.Int()
+	case TypeFloat:
+		return v.Float()
+	case TypeBool:
+		return v.Bool()
+	case TypeDateTime:
+		return v.DateTime()
+	case TypeTimespan:
+		return v.Timespan()
+	default:
+		return v.Dynamic()
+	}
 }
 
 func (v Value) String() string {
 	switch v.Type {
 	case TypeString:
-		return v.V.(string)
+		return unsafe.String((*byte)(v.ptr), int(v.num))
 	case TypeInt:
-		return strconv.FormatInt(v.V.(int64), 10)
+		return strconv.FormatInt(v.Int(), 10)
 	case TypeFloat:
-		return strconv.FormatFloat(v.V.(float64), 'f', -1, 64)
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64)
 	case TypeBool:
-		if v.V.(bool) {
+		if v.Bool() {
 			return "true"
 		}
 		return "false"
 	case TypeDateTime:
-		return v.V.(time.Time).Format(time.RFC3339)
+		return v.DateTime().Format(time.RFC3339)
+	case TypeDynamic:
+		return dynamicString(v.Dynamic())
+	case TypeTimespan:
+		return FormatTimespan(v.Timespan())
 	default:
-		return fmt.Sprintf("%v", v.V)
+		return fmt.Sprintf("%v", v.Dynamic())
 	}
 }
 
+// GoString renders v with its type and payload, for %#v.
+func (v Value) GoString() string {
+	return fmt.Sprintf("model.Value{Type: %q, Payload: %#v}", v.Type, v.Any())
+}
+
 func ParseValue(t Type, raw string) (Value, error) {
 	s := strings.TrimSpace(raw)
 	switch t {
@@ -68,27 +242,39 @@ func ParseValue(t Type, raw string) (Value, error) {
 		if err != nil {
 			return Value{}, err
 		}
-		return Value{Type: TypeInt, V: v}, nil
+		return NewInt(v), nil
 	case TypeFloat:
 		v, err := strconv.ParseFloat(s, 64)
 		if err != nil {
 			return Value{}, err
 		}
-		return Value{Type: TypeFloat, V: v}, nil
+		return NewFloat(v), nil
 	case TypeBool:
 		v, err := strconv.ParseBool(s)
 		if err != nil {
 			return Value{}, err
 		}
-		return Value{Type: TypeBool, V: v}, nil
+		return NewBool(v), nil
 	case TypeDateTime:
 		v, err := time.Parse(time.RFC3339, s)
 		if err != nil {
 			return Value{}, err
 		}
-		return Value{Type: TypeDateTime, V: v}, nil
+		return NewDateTime(v), nil
+	case TypeDynamic:
+		v, err := ParseDynamic(s)
+		if err != nil {
+			return Value{}, err
+		}
+		return NewDynamic(v), nil
+	case TypeTimespan:
+		v, err := ParseTimespan(s)
+		if err != nil {
+			return Value{}, err
+		}
+		return NewTimespan(v), nil
 	default:
-		return Value{Type: TypeString, V: s}, nil
+		return NewString(s), nil
 	}
 }
 
@@ -1,25 +1,27 @@
 package model
 
 import (
+	"math"
 	"testing"
 	"time"
+	"unsafe"
 )
 
 func TestParseValue(t *testing.T) {
-	if v, err := ParseValue(TypeInt, "10"); err != nil || v.V.(int64) != 10 {
+	if v, err := ParseValue(TypeInt, "10"); err != nil || v.Int() != 10 {
 		t.Fatalf("int parse failed: %v", err)
 	}
-	if v, err := ParseValue(TypeFloat, "1.5"); err != nil || v.V.(float64) != 1.5 {
+	if v, err := ParseValue(TypeFloat, "1.5"); err != nil || v.Float() != 1.5 {
 		t.Fatalf("float parse failed: %v", err)
 	}
-	if v, err := ParseValue(TypeBool, "true"); err != nil || v.V.(bool) != true {
+	if v, err := ParseValue(TypeBool, "true"); err != nil || !v.Bool() {
 		t.Fatalf("bool parse failed: %v", err)
 	}
 	tm := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
-	if v, err := ParseValue(TypeDateTime, tm.Format(time.RFC3339)); err != nil || v.V.(time.Time) != tm {
+	if v, err := ParseValue(TypeDateTime, tm.Format(time.RFC3339)); err != nil || v.DateTime() != tm {
 		t.Fatalf("time parse failed: %v", err)
 	}
-	if v, err := ParseValue(TypeString, " x "); err != nil || v.V.(string) != "x" {
+	if v, err := ParseValue(TypeString, " x "); err != nil || v.String() != "x" {
 		t.Fatalf("string parse failed: %v", err)
 	}
 }
@@ -64,33 +66,187 @@ func TestInferType(t *testing.T) {
 }
 
 func TestValueString(t *testing.T) {
-	if (Value{Type: TypeString, V: "x"}).String() != "x" {
+	if NewString("x").String() != "x" {
 		t.Fatalf("string failed")
 	}
-	if (Value{Type: TypeInt, V: int64(2)}).String() != "2" {
+	if NewInt(2).String() != "2" {
 		t.Fatalf("int failed")
 	}
-	if (Value{Type: TypeFloat, V: float64(1.5)}).String() == "" {
+	if NewFloat(1.5).String() == "" {
 		t.Fatalf("float failed")
 	}
-	if (Value{Type: TypeBool, V: true}).String() != "true" {
+	if NewBool(true).String() != "true" {
 		t.Fatalf("bool failed")
 	}
-	if (Value{Type: TypeBool, V: false}).String() != "false" {
+	if NewBool(false).String() != "false" {
 		t.Fatalf("bool false failed")
 	}
 	tm := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
-	if (Value{Type: TypeDateTime, V: tm}).String() == "" {
+	if NewDateTime(tm).String() == "" {
 		t.Fatalf("time failed")
 	}
-	if (Value{Type: Type("other"), V: 1}).String() == "" {
+	other := NewDynamic(1)
+	other.Type = Type("other")
+	if other.String() == "" {
 		t.Fatalf("default failed")
 	}
 }
 
+func TestValuePayloads(t *testing.T) {
+	if unsafe.Sizeof(Value{}) != 32 {
+		t.Fatalf("value takes %d bytes", unsafe.Sizeof(Value{}))
+	}
+	if v := NewInt(-7); v.Int() != -7 || v.Any() != int64(-7) {
+		t.Fatalf("int payload: %#v", v)
+	}
+	if v := NewFloat(-0.25); v.Float() != -0.25 || v.Any() != -0.25 {
+		t.Fatalf("float payload: %#v", v)
+	}
+	if v := NewTimespan(-time.Minute); v.Timespan() != -time.Minute {
+		t.Fatalf("timespan payload: %#v", v)
+	}
+	if v := NewString("abc"[1:]); v.String() != "bc" || v.Any() != "bc" || v.Dynamic() != nil {
+		t.Fatalf("string payload: %#v", v)
+	}
+	zone := time.FixedZone("", 9*3600)
+	for _, tm := range []time.Time{
+		time.Date(2024, 1, 2, 3, 4, 5, 6, zone),
+		time.Date(1000, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(3000, 1, 1, 0, 0, 0, 0, zone),
+		time.Unix(0, math.MinInt64),
+	} {
+		got := NewDateTime(tm).DateTime()
+		if !got.Equal(tm) || got.Location() != tm.Location() {
+			t.Fatalf("datetime %v came back as %v", tm, got)
+		}
+	}
+	if (Value{Type: TypeDateTime}).DateTime() != (time.Time{}) {
+		t.Fatalf("zero datetime")
+	}
+}
+
+func TestNull(t *testing.T) {
+	if !Null.IsNull() || Null.Dynamic() != nil || NewDynamic(nil) != Null {
+		t.Fatalf("null: %#v", Null)
+	}
+	if NewDynamic("").IsNull() || NewString("").IsNull() || NewInt(0).IsNull() {
+		t.Fatalf("empty values are not null")
+	}
+	if v := NullOf(TypeString); v.Type != TypeString || v.String() != "" {
+		t.Fatalf("string null: %#v", v)
+	}
+	if v := NullOf(TypeInt); !v.IsNull() {
+		t.Fatalf("int null: %#v", v)
+	}
+}
+
 func TestNewSchema(t *testing.T) {
 	sch := NewSchema([]Column{{Name: "a", Type: TypeString}})
 	if sch.Index["a"] != 0 {
 		t.Fatalf("schema index failed")
 	}
 }
+
+func TestDynamicValue(t *testing.T) {
+	v, err := ParseValue(TypeDynamic, `{"a":{"b":[1,2]}}`)
+	if err != nil {
+		t.Fatalf("dynamic parse: %v", err)
+	}
+	if v.String() != `{"a":{"b":[1,2]}}` {
+		t.Fatalf("unexpected dynamic string: %s", v.String())
+	}
+	if _, err := ParseValue(TypeDynamic, "{bad"); err == nil {
+		t.Fatalf("expected dynamic error")
+	}
+	if (Value{Type: TypeDynamic}).String() != "" {
+		t.Fatalf("expected empty null string")
+	}
+	if FromDynamic(float64(3)).Type != TypeInt {
+		t.Fatalf("expected int from whole number")
+	}
+	if FromDynamic(1.5).Type != TypeFloat {
+		t.Fatalf("expected float")
+	}
+	if FromDynamic("x").Type != TypeString || FromDynamic(true).Type != TypeBool {
+		t.Fatalf("expected scalar types")
+	}
+	if FromDynamic([]any{1.0}).Type != TypeDynamic {
+		t.Fatalf("expected dynamic array")
+	}
+	if ToDynamic(NewInt(2)) != float64(2) {
+		t.Fatalf("expected json number")
+	}
+}
+
+func TestParseType(t *testing.T) {
+	cases := map[string]Type{"long": TypeInt, "real": TypeFloat, "string": TypeString, "boolean": TypeBool, "datetime": TypeDateTime, "dynamic": TypeDynamic}
+	for name, want := range cases {
+		if got, ok := ParseType(name); !ok || got != want {
+			t.Fatalf("ParseType(%s) = %s", name, got)
+		}
+	}
+	if _, ok := ParseType("blob"); ok {
+		t.Fatalf("expected unknown type")
+	}
+}
+
+func TestTimespan(t *testing.T) {
+	cases := map[string]time.Duration{
+		"7d":         7 * 24 * time.Hour,
+		"1.5h":       90 * time.Minute,
+		"100ms":      100 * time.Millisecond,
+		"-30s":       -30 * time.Second,
+		"02:30:00":   150 * time.Minute,
+		"1.00:00:01": 24*time.Hour + time.Second,
+		"00:00:00.5": 500 * time.Millisecond,
+		"10tick":     1000 * time.Nanosecond,
+		"3minutes":   3 * time.Minute,
+	}
+	for raw, want := range cases {
+		v, err := ParseValue(TypeTimespan, raw)
+		if err != nil || v.Timespan() != want {
+			t.Fatalf("ParseTimespan(%q) = %v, %v", raw, v, err)
+		}
+	}
+	if _, err := ParseTimespan("soon"); err == nil {
+		t.Fatalf("expected timespan error")
+	}
+	if got := FormatTimespan(26*time.Hour + 1500*time.Millisecond); got != "1.02:00:01.5000000" {
+		t.Fatalf("unexpected format: %s", got)
+	}
+	if got := FormatTimespan(-time.Minute); got != "-00:01:00" {
+		t.Fatalf("unexpected negative format: %s", got)
+	}
+	if typ, ok := ParseType("timespan"); !ok || typ != TypeTimespan {
+		t.Fatalf("expected timespan type")
+	}
+}
+
+func TestCompareType(t *testing.T) {
+	cases := []struct {
+		a, b Type
+		want Type
+		ok   bool
+	}{
+		{TypeInt, TypeInt, TypeInt, true},
+		{TypeInt, TypeFloat, TypeFloat, true},
+		{TypeDateTime, TypeString, TypeDateTime, true},
+		{TypeString, TypeTimespan, TypeTimespan, true},
+		{TypeDynamic, TypeInt, TypeInt, true},
+		{TypeString, TypeInt, "", false},
+		{TypeFloat, TypeString, "", false},
+		{TypeBool, TypeInt, "", false},
+		{TypeDateTime, TypeInt, "", false},
+	}
+	for _, c := range cases {
+		if got, ok := CompareType(c.a, c.b); got != c.want || ok != c.ok {
+			t.Fatalf("CompareType(%s, %s) = %s, %v", c.a, c.b, got, ok)
+		}
+	}
+	if _, ok := ParseDateTime("2024-01-02 03:04:05"); !ok {
+		t.Fatalf("expected datetime")
+	}
+	if _, ok := ParseDateTime("abc"); ok {
+		t.Fatalf("expected invalid datetime")
+	}
+}
@@ -0,0 +1,171 @@
+package optimizer
+
+import (
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+// refs adds the columns read by e to out. A member chain such as a.b.c adds
+// both its flattened name and its root, since either may be the column.
+// It reports false when e reads columns it does not name, as a search term
+// without a column does.
+func refs(e plan.Expr, out map[string]bool) bool {
+	switch e := e.(type) {
+	case nil, plan.Literal:
+		return true
+	case plan.ColumnRef:
+		out[e.Name] = true
+		return true
+	case plan.CompareExpr:
+		return refs(e.Left, out) && refs(e.Right, out)
+	case plan.LogicalExpr:
+		return refs(e.Left, out) && refs(e.Right, out)
+	case plan.BinaryExpr:
+		return refs(e.Left, out) && refs(e.Right, out)
+	case plan.MemberExpr:
+		if name, ok := dottedName(e); ok {
+			out[name] = true
+		}
+		return refs(e.Target, out)
+	case plan.IndexExpr:
+		return refs(e.Target, out) && refs(e.Index, out)
+	case plan.CallExpr:
+		for _, a := range e.Args {
+			if !refs(a, out) {
+				return false
+			}
+		}
+		return true
+	case plan.SearchTerm:
+		if e.Column == "" {
+			return false
+		}
+		out[e.Column] = true
+		return true
+	default:
+		return false
+	}
+}
+
+// exprRefs returns the columns read by e, or nil when they are not known.
+func exprRefs(e plan.Expr) map[string]bool {
+	out := map[string]bool{}
+	if !refs(e, out) {
+		return nil
+	}
+	return out
+}
+
+// conjuncts splits e into the terms of its top-level and chain.
+func conjuncts(e plan.Expr) []plan.Expr {
+	if l, ok := e.(plan.LogicalExpr); ok && l.Op == "and" {
+		return append(conjuncts(l.Left), conjuncts(l.Right)...)
+	}
+	return []plan.Expr{e}
+}
+
+// and joins terms into a left-deep and chain.
+func and(terms []plan.Expr) plan.Expr {
+	e := terms[0]
+	for _, t := range terms[1:] {
+		e = plan.LogicalExpr{Left: e, Op: "and", Right: t}
+	}
+	return e
+}
+
+// rename rewrites the column references of e through names, matching member
+// chains by their flattened name.
+func rename(e plan.Expr, names map[string]string) plan.Expr {
+	switch x := e.(type) {
+	case plan.ColumnRef:
+		if n, ok := names[x.Name]; ok {
+			return plan.ColumnRef{Name: n}
+		}
+	case plan.MemberExpr:
+		if name, ok := dottedName(x); ok {
+			if n, ok := names[name]; ok {
+				return plan.ColumnRef{Name: n}
+			}
+		}
+		return plan.MemberExpr{Target: rename(x.Target, names), Name: x.Name}
+	case plan.CompareExpr:
+		return plan.CompareExpr{Left: rename(x.Left, names), Op: x.Op, Right: rename(x.Right, names)}
+	case plan.LogicalExpr:
+		return plan.LogicalExpr{Left: rename(x.Left, names), Op: x.Op, Right: rename(x.Right, names)}
+	case plan.BinaryExpr:
+		return plan.BinaryExpr{Left: rename(x.Left, names), Op: x.Op, Right: rename(x.Right, names)}
+	case plan.IndexExpr:
+		return plan.IndexExpr{Target: rename(x.Target, names), Index: rename(x.Index, names)}
+	case plan.CallExpr:
+		args := make([]plan.Expr, len(x.Args))
+		for i, a := range x.Args {
+			args[i] = rename(a, names)
+		}
+		return plan.CallExpr{Name: x.Name, Args: args}
+	case plan.SearchTerm:
+		if n, ok := names[x.Column]; ok {
+			x.Column = n
+			return x
+		}
+	}
+	return e
+}
+
+func dottedName(e plan.Expr) (string, bool) {
+	switch e := e.(type) {
+	case plan.ColumnRef:
+		return e.Name, true
+	case plan.MemberExpr:
+		prefix, ok := dottedName(e.Target)
+		if !ok {
+			return "", false
+		}
+		return prefix + "." + e.Name, true
+	default:
+		return "", false
+	}
+}
+
+// boolLiteral reports the value of e when it is a bool literal.
+func boolLiteral(e plan.Expr) (value, ok bool) {
+	lit, isLit := e.(plan.Literal)
+	if !isLit || lit.Value.Type != model.TypeBool {
+		return false, false
+	}
+	return lit.Value.Bool(), true
+}
+
+// readsOnly reports whether every column read by e is in cols. Member chains
+// match by their flattened name, or else by their root column.
+func readsOnly(e plan.Expr, cols map[string]bool) bool {
+	switch e := e.(type) {
+	case nil, plan.Literal:
+		return true
+	case plan.ColumnRef:
+		return cols[e.Name]
+	case plan.CompareExpr:
+		return readsOnly(e.Left, cols) && readsOnly(e.Right, cols)
+	case plan.LogicalExpr:
+		return readsOnly(e.Left, cols) && readsOnly(e.Right, cols)
+	case plan.BinaryExpr:
+		return readsOnly(e.Left, cols) && readsOnly(e.Right, cols)
+	case plan.MemberExpr:
+		if name, ok := dottedName(e); ok && cols[name] {
+			return true
+		}
+		return readsOnly(e.Target, cols)
+	case plan.IndexExpr:
+		return readsOnly(e.Target, cols) && readsOnly(e.Index, cols)
+	case plan.CallExpr:
+		for _, a := range e.Args {
+			if !readsOnly(a, cols) {
+				return false
+			}
+		}
+		return true
+	case plan.SearchTerm:
+		return e.Column != "" && cols[e.Column]
+	default:
+		return false
+	}
+}
@@ -0,0 +1,100 @@
+// Package optimizer rewrites a parsed query into an equivalent plan that
+// does less work. Each rewrite is a named Rule that can be run on its own in
+// tests or switched off while debugging a query.
+package optimizer
+
+import (
+	"kqlfile/pkg/binder"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+// Rule is one plan rewrite. Apply must return a plan producing the same rows
+// as ops; it may return ops itself when nothing applies.
+type Rule struct {
+	Name  string
+	Apply func(ops []plan.Operator, env *Env) []plan.Operator
+}
+
+// Rules are run in order by Optimize. Later rules see the output of earlier
+// ones: filters are pushed and merged before projections are pruned.
+var Rules = []Rule{
+	{Name: "fold-constants", Apply: FoldConstants},
+	{Name: "push-predicates", Apply: PushPredicates},
+	{Name: "merge-where", Apply: MergeWhere},
+	{Name: "top-n", Apply: TopN},
+	{Name: "prune-projections", Apply: PruneProjections},
+	{Name: "remove-redundant-project", Apply: RemoveRedundantProject},
+}
+
+// Options configure Optimize.
+type Options struct {
+	// Input is the schema of the query input, or nil when it is unknown.
+	// Rules that need column names skip the operators they cannot resolve.
+	Input *model.Schema
+	// Tables resolves the schema of join inputs, as for the binder.
+	Tables binder.Tables
+	// Disabled names the rules to skip; "all" skips every rule.
+	Disabled map[string]bool
+}
+
+// Env gives rules access to the schemas flowing through a plan.
+type Env struct {
+	Input  *model.Schema
+	Tables binder.Tables
+}
+
+// Schemas returns the output schema of each operator of ops, nil where it is
+// unknown.
+func (e *Env) Schemas(ops []plan.Operator) []*model.Schema {
+	out, err := binder.Bind("", e.Input, ops, e.Tables)
+	if err != nil {
+		return make([]*model.Schema, len(ops))
+	}
+	return out
+}
+
+// SchemaBefore returns the input schema of ops[i], or nil when it is unknown.
+func (e *Env) SchemaBefore(ops []plan.Operator, i int) *model.Schema {
+	if i == 0 {
+		return e.Input
+	}
+	return e.Schemas(ops[:i])[i-1]
+}
+
+// Optimize applies the enabled rules to ops and to the branches of a
+// trailing fork.
+func Optimize(ops []plan.Operator, opts Options) []plan.Operator {
+	if opts.Disabled["all"] {
+		return ops
+	}
+	env := &Env{Input: opts.Input, Tables: opts.Tables}
+	for _, r := range Rules {
+		if !opts.Disabled[r.Name] {
+			ops = r.Apply(ops, env)
+		}
+	}
+	for i, op := range ops {
+		fork, ok := op.(plan.ForkOp)
+		if !ok {
+			continue
+		}
+		branchOpts := opts
+		branchOpts.Input = env.SchemaBefore(ops, i)
+		branches := make([]plan.ForkBranch, len(fork.Branches))
+		for j, b := range fork.Branches {
+			branches[j] = plan.ForkBranch{Name: b.Name, Ops: Optimize(b.Ops, branchOpts)}
+		}
+		ops = replace(ops, i, plan.ForkOp{Branches: branches})
+	}
+	return ops
+}
+
+// replace returns a copy of ops with ops[i] replaced by repl, which may be
+// empty to remove it.
+func replace(ops []plan.Operator, i int, repl ...plan.Operator) []plan.Operator {
+	out := make([]plan.Operator, 0, len(ops)+len(repl)-1)
+	out = append(out, ops[:i]...)
+	out = append(out, repl...)
+	return append(out, ops[i+1:]...)
+}
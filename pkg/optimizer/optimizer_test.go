@@ -0,0 +1,286 @@
+package optimizer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/exec"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/parser"
+	"kqlfile/pkg/plan"
+)
+
+var people = model.NewSchema([]model.Column{
+	{Name: "name", Type: model.TypeString},
+	{Name: "age", Type: model.TypeInt},
+	{Name: "city", Type: model.TypeString},
+})
+
+var cities = model.NewSchema([]model.Column{
+	{Name: "city", Type: model.TypeString},
+	{Name: "country", Type: model.TypeString},
+	{Name: "pop", Type: model.TypeInt},
+})
+
+func testEnv() *Env {
+	return &Env{Input: &people, Tables: func(name string) (model.Schema, bool) {
+		return cities, name == "cities.csv"
+	}}
+}
+
+func mustParse(t *testing.T, query string) []plan.Operator {
+	t.Helper()
+	ops, err := parser.Parse(query)
+	if err != nil {
+		t.Fatalf("parse %s: %v", query, err)
+	}
+	return ops
+}
+
+// samePlan reports whether two plans are equal. Values keep their payload
+// behind a pointer, so plans are compared by their Go syntax rather than with
+// reflect.DeepEqual.
+func samePlan(a, b any) bool {
+	return fmt.Sprintf("%#v", a) == fmt.Sprintf("%#v", b)
+}
+
+// checkRule applies rule to query and compares the result with the plan of
+// want.
+func checkRule(t *testing.T, rule func([]plan.Operator, *Env) []plan.Operator, query, want string) {
+	t.Helper()
+	got := rule(mustParse(t, query), testEnv())
+	if expected := mustParse(t, want); !samePlan(got, expected) {
+		t.Fatalf("%s:\n got %#v\nwant %#v", query, got, expected)
+	}
+}
+
+func TestFoldConstants(t *testing.T) {
+	cases := []struct{ query, want string }{
+		{"T | where age > 10 * 3", "T | where age > 30"},
+		{"T | extend s = url_encode('a b')", "T | extend s = 'a%20b'"},
+		{"T | where 1 < 2 and age > 1", "T | where age > 1"},
+		{"T | where 1 > 2 or age > 1", "T | where age > 1"},
+		{"T | where 1 < 2 | take 1", "T | take 1"},
+		{"T | where 1 > 2 and age > 1", "T | take 0"},
+		{"T | where ts > ago(1h)", "T | where ts > ago(1h)"},
+		{"T | extend n = row_number()", "T | extend n = row_number()"},
+	}
+	for _, c := range cases {
+		checkRule(t, FoldConstants, c.query, c.want)
+	}
+}
+
+func TestPushPredicates(t *testing.T) {
+	cases := []struct{ query, want string }{
+		{"T | extend x = age * 2 | where age > 3", "T | where age > 3 | extend x = age * 2"},
+		{"T | extend x = age * 2 | where x > 3", "T | extend x = age * 2 | where x > 3"},
+		{"T | extend p = prev(age) | where age > 3", "T | extend p = prev(age) | where age > 3"},
+		{"T | order by age | project name, age | where age > 3", "T | where age > 3 | order by age | project name, age"},
+		{"T | take 5 | where age > 3", "T | take 5 | where age > 3"},
+		{"T | extend x = age | where prev(age) > 3", "T | extend x = age | where prev(age) > 3"},
+	}
+	for _, c := range cases {
+		checkRule(t, PushPredicates, c.query, c.want)
+	}
+}
+
+func TestPushPredicatesIntoJoin(t *testing.T) {
+	got := PushPredicates(mustParse(t, "T | join kind=inner (cities.csv) on city == city | where age > 3 and pop > 1000 and right.city != name"), testEnv())
+	want := mustParse(t, "T | where age > 3 | join kind=inner (cities.csv) on city == city | where right.city != name")
+	join := want[1].(plan.JoinOp)
+	join.RightOps = mustParse(t, "T | where pop > 1000")
+	want[1] = join
+	if !samePlan(got, want) {
+		t.Fatalf("got %#v\nwant %#v", got, want)
+	}
+
+	// A right column clashing with a left one is referenced as right.<name>.
+	got = PushPredicates(mustParse(t, "T | join kind=inner (cities.csv) on city == city | where right.city == 'Oslo'"), testEnv())
+	if join := got[0].(plan.JoinOp); len(got) != 1 || !samePlan(join.RightOps, mustParse(t, "T | where city == 'Oslo'")) {
+		t.Fatalf("expected the predicate inside the join, got %#v", got)
+	}
+
+	// Unknown right tables leave the predicate where it is.
+	query := "T | join kind=inner (other.csv) on city == city | where age > 3"
+	if got := PushPredicates(mustParse(t, query), testEnv()); !samePlan(got, mustParse(t, query)) {
+		t.Fatalf("expected no change, got %#v", got)
+	}
+}
+
+func TestMergeWhere(t *testing.T) {
+	checkRule(t, MergeWhere, "T | where age > 3 | where city == 'Oslo' | take 1 | where age < 9", "T | where age > 3 and city == 'Oslo' | take 1 | where age < 9")
+	checkRule(t, MergeWhere, "T | where age > 3 | where prev(age) > 3", "T | where age > 3 | where prev(age) > 3")
+}
+
+func TestTopN(t *testing.T) {
+	checkRule(t, TopN, "T | order by age desc | take 3 | take 1", "T | top 3 by age desc | take 1")
+	checkRule(t, TopN, "T | order by age asc | where age > 1 | take 3", "T | order by age asc | where age > 1 | take 3")
+}
+
+func TestPruneProjections(t *testing.T) {
+	checkRule(t, PruneProjections, "T | extend x = age * 2 | extend y = 1 | project name, x", "T | extend x = age * 2 | project name, x")
+	checkRule(t, PruneProjections, "T | extend x = age * 2 | summarize count() by city", "T | summarize count() by city")
+	checkRule(t, PruneProjections, "T | extend x = age * 2", "T | extend x = age * 2")
+	checkRule(t, PruneProjections, "T | extend x = 1 | getschema | project ColumnName", "T | extend x = 1 | getschema | project ColumnName")
+
+	got := PruneProjections(mustParse(t, "T | join kind=inner (cities.csv) on city == city | summarize sum(pop) by name"), testEnv())
+	join := got[0].(plan.JoinOp)
+	if want := []plan.Operator{plan.ProjectOp{Columns: []string{"city", "pop"}}}; !samePlan(join.RightOps, want) {
+		t.Fatalf("expected the right side pruned to city, pop, got %#v", join.RightOps)
+	}
+}
+
+func TestRequiredColumns(t *testing.T) {
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"T | where age > 3 | project name", []string{"age", "name"}},
+		{"T | extend x = age * 2 | summarize count() by x", []string{"age"}},
+		{"T | summarize count()", []string{}},
+		{"T | join kind=inner (cities.csv) on city == city | project name, country", []string{"city", "country", "name"}},
+		{"T | where age > 3", nil},
+		{"T | getschema", nil},
+		{"T | search \"oslo\" | project name", nil},
+	}
+	for _, c := range cases {
+		if got := RequiredColumns(mustParse(t, c.query)); !reflect.DeepEqual(got, c.want) {
+			t.Fatalf("%s: got %v, want %v", c.query, got, c.want)
+		}
+	}
+}
+
+func TestSplitScanFilter(t *testing.T) {
+	f, rest := SplitScanFilter(mustParse(t, "T | where age > 3 and props.kind == 'a' | project name"))
+	if f == nil || !reflect.DeepEqual(f.Columns, []string{"age", "props", "props.kind"}) {
+		t.Fatalf("unexpected filter %#v", f)
+	}
+	if !samePlan(rest, mustParse(t, "T | project name")) {
+		t.Fatalf("unexpected remaining plan %#v", rest)
+	}
+	for _, q := range []string{"T | where prev(age) > 3", "T | project name | where age > 3", "T | search \"x\""} {
+		if f, rest := SplitScanFilter(mustParse(t, q)); f != nil || len(rest) != len(mustParse(t, q)) {
+			t.Fatalf("%s: expected no scan filter, got %#v", q, f)
+		}
+	}
+}
+
+func TestRemoveRedundantProject(t *testing.T) {
+	checkRule(t, RemoveRedundantProject, "T | project name, age | project age", "T | project age")
+	checkRule(t, RemoveRedundantProject, "T | project name, age, city | take 1", "T | take 1")
+	checkRule(t, RemoveRedundantProject, "T | project city, age, name", "T | project city, age, name")
+}
+
+func TestOptimizeDisabledRules(t *testing.T) {
+	query := "T | order by age | take 3 | where 1 < 2"
+	opts := Options{Input: &people}
+	if got := Optimize(mustParse(t, query), opts); !samePlan(got, mustParse(t, "T | top 3 by age asc")) {
+		t.Fatalf("unexpected plan %#v", got)
+	}
+	opts.Disabled = map[string]bool{"top-n": true}
+	if got := Optimize(mustParse(t, query), opts); !samePlan(got, mustParse(t, "T | order by age asc | take 3")) {
+		t.Fatalf("unexpected plan with top-n disabled %#v", got)
+	}
+	opts.Disabled = map[string]bool{"all": true}
+	if got := Optimize(mustParse(t, query), opts); !samePlan(got, mustParse(t, query)) {
+		t.Fatalf("unexpected plan with all rules disabled %#v", got)
+	}
+}
+
+func TestOptimizeForkBranches(t *testing.T) {
+	got := Optimize(mustParse(t, "T | fork (order by age | take 1) (where 2 > 1 | project name)"), Options{Input: &people})
+	want := mustParse(t, "T | fork (top 1 by age asc) (project name)")
+	if !samePlan(got, want) {
+		t.Fatalf("got %#v\nwant %#v", got, want)
+	}
+}
+
+// TestOptimizedResultsMatch runs queries with and without the optimizer and
+// expects the same rows.
+func TestOptimizedResultsMatch(t *testing.T) {
+	dir := t.TempDir()
+	input := writeFile(t, dir, "people.csv", "name,age,city\nann,31,Oslo\nbob,25,Rome\ncid,47,Oslo\ndee,19,Lima\neve,52,Rome\n")
+	right := writeFile(t, dir, "cities.csv", "city,country,pop\nOslo,NO,700\nRome,IT,2800\nLima,PE,9700\n")
+	tables := func(name string) (model.Schema, bool) {
+		r, err := csvio.NewReader(name, nil)
+		if err != nil {
+			return model.Schema{}, false
+		}
+		defer r.Close()
+		return r.Schema(), true
+	}
+	queries := []string{
+		"T | extend decade = age / 10 | where age > 20 and city != 'Lima' | project name, decade",
+		"T | join kind=inner (" + right + ") on city == city | where pop > 1000 and age > 20 | project name, country",
+		"T | join kind=inner (" + right + ") on city == city | where right.city == 'Oslo' | summarize sum(pop) by name",
+		"T | where 1 == 1 | order by age desc | take 2 | project name",
+		"T | project name, age | project age | where age > 30 | extend x = 1",
+	}
+	for _, q := range queries {
+		ops := mustParse(t, q)
+		plain := rows(t, input, ops)
+		optimized := rows(t, input, Optimize(ops, Options{Input: schemaOf(t, input), Tables: tables}))
+		if plain != optimized {
+			t.Fatalf("%s: optimizer changed the result\n got %s\nwant %s", q, optimized, plain)
+		}
+	}
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func schemaOf(t *testing.T, path string) *model.Schema {
+	t.Helper()
+	r, err := csvio.NewReader(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	s := r.Schema()
+	return &s
+}
+
+// rows runs ops over the CSV file at path and returns the sorted rows.
+func rows(t *testing.T, path string, ops []plan.Operator) string {
+	t.Helper()
+	r, err := csvio.NewReader(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	op, err := exec.BuildPipeline(r, ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for {
+		row, err := op.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		parts := make([]string, len(row.Values))
+		for i, c := range row.Schema.Columns {
+			parts[i] = c.Name + "=" + row.Values[i].String()
+		}
+		lines = append(lines, strings.Join(parts, ","))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,420 @@
+package optimizer
+
+import (
+	"sort"
+
+	"kqlfile/pkg/exec"
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+// FoldConstants evaluates the parts of expressions that read no column once,
+// at plan time. A where whose predicate folds to true is dropped and one
+// that folds to false becomes take 0.
+func FoldConstants(ops []plan.Operator, _ *Env) []plan.Operator {
+	out := make([]plan.Operator, 0, len(ops))
+	for _, op := range ops {
+		switch o := op.(type) {
+		case plan.WhereOp:
+			pred := fold(o.Predicate)
+			if v, ok := boolLiteral(pred); ok {
+				if !v {
+					out = append(out, plan.TakeOp{Count: 0})
+				}
+				continue
+			}
+			op = plan.WhereOp{Predicate: pred}
+		case plan.ExtendOp:
+			op = plan.ExtendOp{Name: o.Name, Value: fold(o.Value)}
+		case plan.SummarizeOp:
+			if o.Keys != nil {
+				keys := make([]plan.Expr, len(o.Keys))
+				for i, k := range o.Keys {
+					keys[i] = fold(k)
+				}
+				o.Keys = keys
+				op = o
+			}
+		}
+		out = append(out, op)
+	}
+	return out
+}
+
+// fold returns e with its constant subexpressions replaced by their values.
+// Expressions that fail to evaluate are left for the executor to report.
+func fold(e plan.Expr) plan.Expr {
+	if _, ok := e.(plan.Literal); ok {
+		return e
+	}
+	if exec.IsConstant(e) {
+		if v, err := exec.EvalConstant(e); err == nil {
+			return plan.Literal{Value: v}
+		}
+		return e
+	}
+	switch x := e.(type) {
+	case plan.CompareExpr:
+		return plan.CompareExpr{Left: fold(x.Left), Op: x.Op, Right: fold(x.Right)}
+	case plan.LogicalExpr:
+		l, r := fold(x.Left), fold(x.Right)
+		for _, side := range [][2]plan.Expr{{l, r}, {r, l}} {
+			v, ok := boolLiteral(side[0])
+			switch {
+			case !ok:
+			case x.Op == "and" && v, x.Op == "or" && !v:
+				return side[1]
+			default:
+				return side[0]
+			}
+		}
+		return plan.LogicalExpr{Left: l, Op: x.Op, Right: r}
+	case plan.BinaryExpr:
+		return plan.BinaryExpr{Left: fold(x.Left), Op: x.Op, Right: fold(x.Right)}
+	case plan.CallExpr:
+		args := make([]plan.Expr, len(x.Args))
+		for i, a := range x.Args {
+			args[i] = fold(a)
+		}
+		return plan.CallExpr{Name: x.Name, Args: args}
+	case plan.IndexExpr:
+		return plan.IndexExpr{Target: fold(x.Target), Index: fold(x.Index)}
+	}
+	return e
+}
+
+// PushPredicates moves each where as early as it can go: below the extends
+// that do not compute a column it reads, below project and order by, and
+// into the side of an inner join that owns its columns. Predicates calling
+// window functions stay put, as do predicates below extends that call them,
+// since filtering earlier would change the rows those functions see.
+func PushPredicates(ops []plan.Operator, env *Env) []plan.Operator {
+	out := append([]plan.Operator(nil), ops...)
+	for moved := true; moved; {
+		moved = false
+		for i := 1; i < len(out); i++ {
+			w, ok := out[i].(plan.WhereOp)
+			if !ok || exec.HasWindowFunction(w.Predicate) {
+				continue
+			}
+			switch prev := out[i-1].(type) {
+			case plan.ProjectOp, plan.OrderByOp:
+				out[i-1], out[i] = w, prev
+				moved = true
+			case plan.ExtendOp:
+				cols := exprRefs(w.Predicate)
+				if cols != nil && !cols[prev.Name] && !exec.HasWindowFunction(prev.Value) {
+					out[i-1], out[i] = w, prev
+					moved = true
+				}
+			case plan.JoinOp:
+				var pushed bool
+				if out, pushed = pushIntoJoin(out, i, env); pushed {
+					moved = true
+				}
+			}
+		}
+	}
+	return out
+}
+
+// pushIntoJoin splits the where at ops[i] over the join before it: terms
+// reading only left columns move before the join, terms reading only right
+// columns move into its RightOps, and the rest stay after it.
+func pushIntoJoin(ops []plan.Operator, i int, env *Env) ([]plan.Operator, bool) {
+	j := ops[i-1].(plan.JoinOp)
+	left := env.SchemaBefore(ops, i-1)
+	if left == nil || j.Kind != "inner" {
+		return ops, false
+	}
+	right := joinRight(j, env)
+	if right == nil {
+		return ops, false
+	}
+	leftCols := map[string]bool{}
+	for _, c := range left.Columns {
+		leftCols[c.Name] = true
+	}
+	// rightNames maps the join output names of the right columns to their
+	// names in the right input.
+	rightNames := map[string]string{}
+	rightCols := map[string]bool{}
+	for _, c := range right.Columns {
+		name := c.Name
+		if leftCols[name] {
+			name = "right." + name
+		}
+		rightNames[name] = c.Name
+		rightCols[name] = true
+	}
+	var before, into, after []plan.Expr
+	for _, t := range conjuncts(ops[i].(plan.WhereOp).Predicate) {
+		switch {
+		case readsOnly(t, leftCols):
+			before = append(before, t)
+		case readsOnly(t, rightCols):
+			into = append(into, rename(t, rightNames))
+		default:
+			after = append(after, t)
+		}
+	}
+	if before == nil && into == nil {
+		return ops, false
+	}
+	if into != nil {
+		j.RightOps = append(append([]plan.Operator(nil), j.RightOps...), plan.WhereOp{Predicate: and(into)})
+	}
+	var repl []plan.Operator
+	if before != nil {
+		repl = append(repl, plan.WhereOp{Predicate: and(before)})
+	}
+	repl = append(repl, j)
+	if after != nil {
+		repl = append(repl, plan.WhereOp{Predicate: and(after)})
+	}
+	out := append([]plan.Operator(nil), ops[:i-1]...)
+	out = append(out, repl...)
+	return append(out, ops[i+1:]...), true
+}
+
+// joinRight returns the schema of the right side of j after its RightOps,
+// or nil when it is unknown.
+func joinRight(j plan.JoinOp, env *Env) *model.Schema {
+	if env.Tables == nil {
+		return nil
+	}
+	right, ok := env.Tables(j.Right)
+	if !ok {
+		return nil
+	}
+	if len(j.RightOps) == 0 {
+		return &right
+	}
+	schemas := (&Env{Input: &right, Tables: env.Tables}).Schemas(j.RightOps)
+	return schemas[len(schemas)-1]
+}
+
+// MergeWhere combines consecutive where operators into one and chain.
+func MergeWhere(ops []plan.Operator, _ *Env) []plan.Operator {
+	out := make([]plan.Operator, 0, len(ops))
+	for _, op := range ops {
+		w, ok := op.(plan.WhereOp)
+		if ok && len(out) > 0 && !exec.HasWindowFunction(w.Predicate) {
+			if prev, ok := out[len(out)-1].(plan.WhereOp); ok && !exec.HasWindowFunction(prev.Predicate) {
+				out[len(out)-1] = plan.WhereOp{Predicate: plan.LogicalExpr{Left: prev.Predicate, Op: "and", Right: w.Predicate}}
+				continue
+			}
+		}
+		out = append(out, op)
+	}
+	return out
+}
+
+// TopN replaces order by followed by take with top, which keeps only the
+// best rows instead of sorting the whole input.
+func TopN(ops []plan.Operator, _ *Env) []plan.Operator {
+	out := make([]plan.Operator, 0, len(ops))
+	for i := 0; i < len(ops); i++ {
+		if ord, ok := ops[i].(plan.OrderByOp); ok && i+1 < len(ops) {
+			if take, ok := ops[i+1].(plan.TakeOp); ok {
+				out = append(out, plan.TopOp{Count: take.Count, Column: ord.Column, Desc: ord.Desc})
+				i++
+				continue
+			}
+		}
+		out = append(out, ops[i])
+	}
+	return out
+}
+
+// PruneProjections drops the columns no later operator reads: extends of
+// unused columns are removed, and the right side of a join is projected to
+// the columns the rest of the query needs.
+func PruneProjections(ops []plan.Operator, env *Env) []plan.Operator {
+	out := append([]plan.Operator(nil), ops...)
+	// need holds the columns read after the current operator; nil means
+	// every column, as when the operator's output is the query result.
+	var need map[string]bool
+	for i := len(out) - 1; i >= 0; i-- {
+		switch o := out[i].(type) {
+		case plan.ExtendOp:
+			if need != nil && !need[o.Name] && !exec.HasWindowFunction(o.Value) {
+				out = replace(out, i)
+				continue
+			}
+		case plan.JoinOp:
+			if need != nil {
+				out[i] = pruneJoin(o, need, env.SchemaBefore(out, i), env)
+			}
+		}
+		need = needBefore(out[i], need)
+	}
+	return out
+}
+
+// RequiredColumns returns the input columns ops read, in no particular
+// order, or nil when they need every column.
+func RequiredColumns(ops []plan.Operator) []string {
+	var need map[string]bool
+	for i := len(ops) - 1; i >= 0; i-- {
+		need = needBefore(ops[i], need)
+	}
+	if need == nil {
+		return nil
+	}
+	cols := make([]string, 0, len(need))
+	for name := range need {
+		cols = append(cols, name)
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+// needBefore returns the columns op reads from its input when the columns
+// in need are read after it. A nil set stands for every column.
+func needBefore(op plan.Operator, need map[string]bool) map[string]bool {
+	switch o := op.(type) {
+	case plan.ProjectOp:
+		return setOf(o.Columns...)
+	case plan.ExtendOp:
+		if need != nil {
+			delete(need, o.Name)
+		}
+		return addRefs(need, o.Value)
+	case plan.WhereOp:
+		return addRefs(need, o.Predicate)
+	case plan.OrderByOp:
+		return addColumns(need, o.Column)
+	case plan.TopOp:
+		return addColumns(need, o.Column)
+	case plan.TakeOp, plan.SampleOp, plan.AsOp:
+		return need
+	case plan.SampleDistinctOp:
+		return setOf(o.Column)
+	case plan.SummarizeOp:
+		need = map[string]bool{}
+		if o.Keys == nil {
+			need = addColumns(need, o.ByColumns...)
+		}
+		for _, k := range o.Keys {
+			need = addRefs(need, k)
+		}
+		for _, a := range o.Aggregates {
+			for _, arg := range a.Args {
+				need = addRefs(need, arg)
+			}
+		}
+		return need
+	case plan.MakeSeriesOp:
+		need = setOf(append([]string{o.On}, o.ByColumns...)...)
+		for _, a := range o.Aggregates {
+			for _, arg := range a.Args {
+				need = addRefs(need, arg)
+			}
+		}
+		return need
+	case plan.JoinOp:
+		return addColumns(need, o.LeftKey)
+	case plan.ParseOp:
+		return addRefs(need, o.Source)
+	case plan.MvExpandOp:
+		for _, c := range o.Columns {
+			need = addRefs(need, c.Value)
+		}
+		return need
+	default:
+		return nil
+	}
+}
+
+// pruneJoin projects the right side of j to its key and the columns in need,
+// which are named as in the join output.
+func pruneJoin(j plan.JoinOp, need map[string]bool, left *model.Schema, env *Env) plan.JoinOp {
+	right := joinRight(j, env)
+	if left == nil || right == nil {
+		return j
+	}
+	var keep []string
+	for _, c := range right.Columns {
+		name := c.Name
+		if _, clash := left.Index[name]; clash {
+			name = "right." + name
+		}
+		if c.Name == j.RightKey || need[name] {
+			keep = append(keep, c.Name)
+		}
+	}
+	if len(keep) == len(right.Columns) {
+		return j
+	}
+	rightOps := append([]plan.Operator(nil), j.RightOps...)
+	if n := len(rightOps); n > 0 {
+		if _, ok := rightOps[n-1].(plan.ProjectOp); ok {
+			rightOps = rightOps[:n-1]
+		}
+	}
+	j.RightOps = append(rightOps, plan.ProjectOp{Columns: keep})
+	return j
+}
+
+func setOf(names ...string) map[string]bool {
+	return addColumns(map[string]bool{}, names...)
+}
+
+// addColumns adds names to need; a nil need already holds every column.
+func addColumns(need map[string]bool, names ...string) map[string]bool {
+	if need == nil {
+		return nil
+	}
+	for _, n := range names {
+		need[n] = true
+	}
+	return need
+}
+
+// addRefs adds the columns read by e to need, which becomes nil when they
+// are not known.
+func addRefs(need map[string]bool, e plan.Expr) map[string]bool {
+	if need == nil || !refs(e, need) {
+		return nil
+	}
+	return need
+}
+
+// RemoveRedundantProject drops a project directly followed by another one,
+// which must select a subset of its columns, and a project that keeps every
+// input column in order.
+func RemoveRedundantProject(ops []plan.Operator, env *Env) []plan.Operator {
+	out := append([]plan.Operator(nil), ops...)
+	for i := 0; i < len(out); {
+		p, ok := out[i].(plan.ProjectOp)
+		if !ok {
+			i++
+			continue
+		}
+		if i+1 < len(out) {
+			if _, next := out[i+1].(plan.ProjectOp); next {
+				out = replace(out, i)
+				continue
+			}
+		}
+		if s := env.SchemaBefore(out, i); s != nil && sameColumns(s, p.Columns) {
+			out = replace(out, i)
+			continue
+		}
+		i++
+	}
+	return out
+}
+
+func sameColumns(s *model.Schema, names []string) bool {
+	if len(s.Columns) != len(names) {
+		return false
+	}
+	for i, c := range s.Columns {
+		if c.Name != names[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+package optimizer
+
+import (
+	"sort"
+
+	"kqlfile/pkg/exec"
+	"kqlfile/pkg/plan"
+)
+
+// ScanFilter is a where that the input readers evaluate on each record,
+// parsing only Columns, before building its row.
+type ScanFilter struct {
+	Predicate plan.Expr
+	Columns   []string
+}
+
+// SplitScanFilter removes the where at the head of ops when readers can
+// evaluate it: it must name every column it reads and call no window
+// function. It returns nil and ops unchanged otherwise.
+func SplitScanFilter(ops []plan.Operator) (*ScanFilter, []plan.Operator) {
+	if len(ops) == 0 {
+		return nil, ops
+	}
+	w, ok := ops[0].(plan.WhereOp)
+	if !ok || exec.HasWindowFunction(w.Predicate) {
+		return nil, ops
+	}
+	cols := exprRefs(w.Predicate)
+	if cols == nil {
+		return nil, ops
+	}
+	f := &ScanFilter{Predicate: w.Predicate, Columns: make([]string, 0, len(cols))}
+	for name := range cols {
+		f.Columns = append(f.Columns, name)
+	}
+	sort.Strings(f.Columns)
+	return f, ops[1:]
+}
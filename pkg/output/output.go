@@ -9,6 +9,7 @@ import (
 	"strings"
 
 	"kqlfile/pkg/csvio"
+	"kqlfile/pkg/model"
 )
 
 type Format string
@@ -65,7 +66,11 @@ func writeJSON(w io.Writer, rows <-chan *csvio.Row) error {
 	for row := range rows {
 		obj := make(map[string]any, len(row.Schema.Columns))
 		for i, c := range row.Schema.Columns {
-			obj[c.Name] = row.Values[i].V
+			if row.Values[i].Type == model.TypeTimespan {
+				obj[c.Name] = row.Values[i].String()
+				continue
+			}
+			obj[c.Name] = row.Values[i].Any()
 		}
 		if err := enc.Encode(obj); err != nil {
 			return err
@@ -148,7 +148,7 @@ func TestWriteCSVRecordError(t *testing.T) {
 	rows := make(chan *csvio.Row, 1)
 	large := strings.Repeat("x", 5000)
 	schema := model.NewSchema([]model.Column{{Name: "col", Type: model.TypeString}})
-	rows <- &csvio.Row{Schema: schema, Values: []model.Value{{Type: model.TypeString, V: large}}}
+	rows <- &csvio.Row{Schema: schema, Values: []model.Value{model.NewString(large)}}
 	close(rows)
 
 	if err := WriteTo(errWriter{}, FormatCSV, rows); err == nil {
@@ -160,7 +160,7 @@ func TestWriteCSVHeaderError(t *testing.T) {
 	rows := make(chan *csvio.Row, 1)
 	large := strings.Repeat("h", 5000)
 	schema := model.NewSchema([]model.Column{{Name: large, Type: model.TypeString}})
-	rows <- &csvio.Row{Schema: schema, Values: []model.Value{{Type: model.TypeString, V: "x"}}}
+	rows <- &csvio.Row{Schema: schema, Values: []model.Value{model.NewString("x")}}
 	close(rows)
 
 	if err := WriteTo(errWriter{}, FormatCSV, rows); err == nil {
@@ -195,8 +195,89 @@ func sampleRow() *csvio.Row {
 	return &csvio.Row{
 		Schema: schema,
 		Values: []model.Value{
-			{Type: model.TypeString, V: "alice"},
-			{Type: model.TypeInt, V: int64(30)},
+			model.NewString("alice"),
+			model.NewInt(30),
 		},
 	}
 }
+
+func TestWriteJSONDynamic(t *testing.T) {
+	props, err := model.ParseDynamic(`{"user":{"name":"alice"},"tags":["a"]}`)
+	if err != nil {
+		t.Fatalf("parse dynamic: %v", err)
+	}
+	schema := model.NewSchema([]model.Column{{Name: "props", Type: model.TypeDynamic}})
+	rows := make(chan *csvio.Row, 1)
+	rows <- &csvio.Row{Schema: schema, Values: []model.Value{model.NewDynamic(props)}}
+	close(rows)
+
+	var buf bytes.Buffer
+	if err := WriteTo(&buf, FormatJSON, rows); err != nil {
+		t.Fatalf("write json: %v", err)
+	}
+	if strings.TrimSpace(buf.String()) != `{"props":{"tags":["a"],"user":{"name":"alice"}}}` {
+		t.Fatalf("unexpected json: %s", buf.String())
+	}
+}
+
+func resultSet(name string, n int) ResultSet {
+	rows := make(chan *csvio.Row, n)
+	for i := 0; i < n; i++ {
+		rows <- sampleRow()
+	}
+	close(rows)
+	return ResultSet{Name: name, Rows: rows}
+}
+
+func TestWriteResults(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteResults(&buf, FormatCSV, []ResultSet{resultSet("only", 1)}); err != nil {
+		t.Fatalf("write results: %v", err)
+	}
+	if buf.String() != "name,age\nalice,30\n" {
+		t.Fatalf("single result should not be framed: %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteResults(&buf, FormatCSV, []ResultSet{resultSet("a", 1), resultSet("b", 2)}); err != nil {
+		t.Fatalf("write results: %v", err)
+	}
+	want := "# a\nname,age\nalice,30\n\n# b\nname,age\nalice,30\nalice,30\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected framing: %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteResults(&buf, FormatJSON, []ResultSet{resultSet("a", 1), resultSet("b", 0)}); err != nil {
+		t.Fatalf("write results: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || lines[0] != `{"$result":"a"}` || lines[2] != `{"$result":"b"}` {
+		t.Fatalf("unexpected json framing: %q", buf.String())
+	}
+}
+
+func TestWriteResultsToDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteResultsToDir(dir, FormatJSON, []ResultSet{resultSet("a", 1), resultSet("b", 2)}); err != nil {
+		t.Fatalf("write results: %v", err)
+	}
+	data, err := os.ReadFile(dir + "/b.json")
+	if err != nil {
+		t.Fatalf("read result: %v", err)
+	}
+	if strings.Count(string(data), "\n") != 2 {
+		t.Fatalf("unexpected file: %q", data)
+	}
+	if err := WriteResultsToDir(dir, FormatCSV, []ResultSet{resultSet("a", 1), resultSet("a", 1)}); err == nil {
+		t.Fatalf("expected duplicate name error")
+	}
+	if err := WriteResultsToDir(dir, FormatCSV, []ResultSet{resultSet("", 1), resultSet("", 2)}); err != nil {
+		t.Fatalf("write unnamed results: %v", err)
+	}
+	for _, name := range []string{"result_1.csv", "result_2.csv"} {
+		if _, err := os.Stat(dir + "/" + name); err != nil {
+			t.Fatalf("expected %s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"kqlfile/pkg/csvio"
+)
+
+// ResultSet is one named stream of rows. Queries ending in fork produce
+// several of them.
+type ResultSet struct {
+	Name string
+	Rows <-chan *csvio.Row
+}
+
+// WriteResults writes every result set to w. A single set is written exactly
+// like WriteTo. Several sets are framed: csv and table sections start with a
+// "# name" line and are separated by a blank line, and json sections start
+// with a {"$result": name} line. The first set is streamed while the others
+// are buffered, since fork branches are fed from the same input and must be
+// drained together: every set after the first is held in memory in full
+// until the first one ends. WriteResultsToDir streams every set.
+func WriteResults(w io.Writer, format Format, sets []ResultSet) error {
+	if len(sets) == 1 {
+		return WriteTo(w, format, sets[0].Rows)
+	}
+	bufs := make([]bytes.Buffer, len(sets))
+	errs := make([]error, len(sets))
+	var wg sync.WaitGroup
+	for i := 1; i < len(sets); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = WriteTo(&bufs[i], format, sets[i].Rows)
+		}(i)
+	}
+	if err := writeHeader(w, format, sets[0].Name, true); err != nil {
+		drain(sets[0].Rows)
+		wg.Wait()
+		return err
+	}
+	errs[0] = WriteTo(w, format, sets[0].Rows)
+	drain(sets[0].Rows)
+	wg.Wait()
+	if errs[0] != nil {
+		return errs[0]
+	}
+	for i := 1; i < len(sets); i++ {
+		if errs[i] != nil {
+			return errs[i]
+		}
+		if err := writeHeader(w, format, sets[i].Name, false); err != nil {
+			return err
+		}
+		if _, err := bufs[i].WriteTo(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeHeader(w io.Writer, format Format, name string, first bool) error {
+	if format == FormatJSON {
+		marker, err := json.Marshal(map[string]string{"$result": name})
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", marker)
+		return err
+	}
+	if !first {
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "# %s\n", name)
+	return err
+}
+
+// WriteResultsToDir writes each result set to its own file in dir, named
+// after the set with an extension matching the format. An unnamed set, the
+// result of a query without fork or as, is named result, or result_N after
+// its position when there are several sets.
+func WriteResultsToDir(dir string, format Format, sets []ResultSet) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	ext := ".csv"
+	switch format {
+	case FormatJSON:
+		ext = ".json"
+	case FormatTable:
+		ext = ".txt"
+	}
+	names := make([]string, len(sets))
+	seen := make(map[string]bool, len(sets))
+	for i, s := range sets {
+		names[i] = s.Name
+		switch {
+		case s.Name != "":
+		case len(sets) == 1:
+			names[i] = "result"
+		default:
+			names[i] = fmt.Sprintf("result_%d", i+1)
+		}
+		if seen[names[i]] {
+			drainAll(sets)
+			return fmt.Errorf("duplicate result name: %s", names[i])
+		}
+		seen[names[i]] = true
+	}
+	errs := make([]error, len(sets))
+	var wg sync.WaitGroup
+	for i, s := range sets {
+		wg.Add(1)
+		go func(i int, s ResultSet) {
+			defer wg.Done()
+			defer drain(s.Rows)
+			f, err := os.Create(filepath.Join(dir, names[i]+ext))
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			err = WriteTo(f, format, s.Rows)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			errs[i] = err
+		}(i, s)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// drainAll drains every set concurrently, as fork branches must be.
+func drainAll(sets []ResultSet) {
+	var wg sync.WaitGroup
+	for _, s := range sets {
+		wg.Add(1)
+		go func(rows <-chan *csvio.Row) {
+			defer wg.Done()
+			drain(rows)
+		}(s.Rows)
+	}
+	wg.Wait()
+}
+
+// drain discards the remaining rows so that the producer is not blocked.
+func drain(rows <-chan *csvio.Row) {
+	for range rows {
+	}
+}
@@ -0,0 +1,152 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+var (
+	expandLimitRe  = regexp.MustCompile(`(?i)\s+limit\s+(\d+)\s*$`)
+	expandTypeofRe = regexp.MustCompile(`(?i)\s+to\s+typeof\s*\(\s*(\w+)\s*\)\s*$`)
+	itemIndexRe    = regexp.MustCompile(`(?i)^with_itemindex\s*=\s*(\w+)\s*`)
+)
+
+func parseMvExpand(seg string) (plan.Operator, error) {
+	body := strings.TrimSpace(seg[len("mv-expand"):])
+	cols, index, limit, err := parseExpandBody(body)
+	if err != nil {
+		return nil, fmt.Errorf("mv-expand: %w", err)
+	}
+	return plan.MvExpandOp{Columns: cols, IndexName: index, Limit: limit}, nil
+}
+
+func parseMvApply(seg string) (plan.Operator, error) {
+	body := strings.TrimSpace(seg[len("mv-apply"):])
+	onIdx := findKeyword(body, "on")
+	if onIdx == -1 {
+		return nil, fmt.Errorf("mv-apply requires on (subquery)")
+	}
+	sub := strings.TrimSpace(body[onIdx+len("on"):])
+	if !strings.HasPrefix(sub, "(") || !strings.HasSuffix(sub, ")") {
+		return nil, fmt.Errorf("mv-apply subquery must be in parentheses")
+	}
+	cols, index, limit, err := parseExpandBody(strings.TrimSpace(body[:onIdx]))
+	if err != nil {
+		return nil, fmt.Errorf("mv-apply: %w", err)
+	}
+	subOps, err := Parse(sub[1 : len(sub)-1])
+	if err != nil {
+		return nil, fmt.Errorf("mv-apply subquery: %w", err)
+	}
+	return plan.MvApplyOp{Columns: cols, IndexName: index, Limit: limit, Subquery: subOps}, nil
+}
+
+func parseExpandBody(body string) ([]plan.ExpandColumn, string, int, error) {
+	index := ""
+	if m := itemIndexRe.FindStringSubmatch(body); m != nil {
+		index = m[1]
+		body = strings.TrimSpace(body[len(m[0]):])
+	}
+	limit := 0
+	if m := expandLimitRe.FindStringSubmatchIndex(body); m != nil {
+		n, err := strconv.Atoi(body[m[2]:m[3]])
+		if err != nil {
+			return nil, "", 0, fmt.Errorf("invalid limit: %w", err)
+		}
+		limit = n
+		body = body[:m[0]]
+	}
+	var cols []plan.ExpandColumn
+	for _, item := range splitTopLevel(body, ',') {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		col, err := parseExpandColumn(item)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		cols = append(cols, col)
+	}
+	if len(cols) == 0 {
+		return nil, "", 0, fmt.Errorf("requires a column to expand")
+	}
+	return cols, index, limit, nil
+}
+
+func parseExpandColumn(item string) (plan.ExpandColumn, error) {
+	var col plan.ExpandColumn
+	if m := expandTypeofRe.FindStringSubmatchIndex(item); m != nil {
+		t, ok := model.ParseType(item[m[2]:m[3]])
+		if !ok {
+			return col, fmt.Errorf("unknown type %s", item[m[2]:m[3]])
+		}
+		col.ToType = t
+		item = item[:m[0]]
+	}
+	if name, rest, ok := splitAssignment(item); ok {
+		col.Name = name
+		item = rest
+	}
+	expr, err := parseExpression(item)
+	if err != nil {
+		return col, err
+	}
+	col.Value = expr
+	if col.Name == "" {
+		ref, ok := expr.(plan.ColumnRef)
+		if !ok {
+			return col, fmt.Errorf("expression %s requires a name", item)
+		}
+		col.Name = ref.Name
+	}
+	return col, nil
+}
+
+// splitAssignment splits "name = expr" while leaving comparisons such as
+// "a == b" untouched.
+func splitAssignment(s string) (string, string, bool) {
+	toks, err := tokenize(s)
+	if err != nil || len(toks) < 3 {
+		return "", "", false
+	}
+	if toks[0].kind != tokIdent || toks[1].kind != tokSymbol || toks[1].text != "=" {
+		return "", "", false
+	}
+	return toks[0].text, strings.TrimSpace(s[toks[1].pos+1:]), true
+}
+
+// findKeyword returns the offset of a standalone keyword outside quotes and
+// parentheses, or -1.
+func findKeyword(s, kw string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			if end, err := scanString(s, i); err == nil {
+				i = end - 1
+			}
+			continue
+		case '(', '[', '{':
+			depth++
+			continue
+		case ')', ']', '}':
+			depth--
+			continue
+		}
+		if depth != 0 || !strings.EqualFold(s[i:min(i+len(kw), len(s))], kw) {
+			continue
+		}
+		before := i == 0 || !isIdentPart(rune(s[i-1]))
+		after := i+len(kw) == len(s) || !isIdentPart(rune(s[i+len(kw)]))
+		if before && after {
+			return i
+		}
+	}
+	return -1
+}
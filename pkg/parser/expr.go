@@ -0,0 +1,411 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokSymbol
+	tokDynamic
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+var symbols = []string{"==", "!=", "=~", "!~", ">=", "<=", "=", ">", "<", "(", ")", "[", "]", ",", ".", "+", "-", "*", "/", "%", ":"}
+
+func tokenize(src string) ([]token, error) {
+	toks := make([]token, 0, 8)
+	i := 0
+	for i < len(src) {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			end, err := scanString(src, i)
+			if err != nil {
+				return nil, err
+			}
+			toks = append(toks, token{kind: tokString, text: src[i:end], pos: i})
+			i = end
+		case c == '@' && i+1 < len(src) && (src[i+1] == '"' || src[i+1] == '\''):
+			end := strings.IndexByte(src[i+2:], src[i+1])
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			end += i + 3
+			toks = append(toks, token{kind: tokString, text: src[i:end], pos: i})
+			i = end
+		case isDigit(c):
+			start := i
+			for i < len(src) && (isIdentPart(rune(src[i])) || src[i] == '.') {
+				i++
+			}
+			toks = append(toks, token{kind: tokNumber, text: src[start:i], pos: start})
+		case isIdentStart(c) || (c == '!' && i+1 < len(src) && unicode.IsLetter(rune(src[i+1]))):
+			start := i
+			i++
+			for i < len(src) && isIdentPart(rune(src[i])) {
+				i++
+			}
+			open := i
+			for open < len(src) && (src[open] == ' ' || src[open] == '\t') {
+				open++
+			}
+			if strings.EqualFold(src[start:i], "dynamic") && open < len(src) && src[open] == '(' {
+				end := closingParen(src[open:])
+				if end == -1 {
+					return nil, fmt.Errorf("unterminated dynamic literal at position %d", start)
+				}
+				toks = append(toks, token{kind: tokDynamic, text: src[open+1 : open+end], pos: start})
+				i = open + end + 1
+				continue
+			}
+			toks = append(toks, token{kind: tokIdent, text: src[start:i], pos: start})
+		default:
+			matched := ""
+			for _, s := range symbols {
+				if strings.HasPrefix(src[i:], s) {
+					matched = s
+					break
+				}
+			}
+			if matched == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+			toks = append(toks, token{kind: tokSymbol, text: matched, pos: i})
+			i += len(matched)
+		}
+	}
+	toks = append(toks, token{kind: tokEOF, pos: len(src)})
+	return toks, nil
+}
+
+func scanString(src string, start int) (int, error) {
+	quote := src[start]
+	for i := start + 1; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case quote:
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated string at position %d", start)
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c rune) bool {
+	return c == '_' || c == '$' || unicode.IsLetter(c)
+}
+
+func isIdentPart(c rune) bool {
+	return isIdentStart(c) || isDigit(c)
+}
+
+type exprParser struct {
+	toks   []token
+	pos    int
+	search bool
+}
+
+var stringOps = map[string]bool{
+	"has": true, "!has": true, "has_cs": true, "!has_cs": true,
+	"contains": true, "!contains": true, "contains_cs": true, "!contains_cs": true,
+	"startswith": true, "!startswith": true, "endswith": true, "!endswith": true,
+}
+
+func isCompareOp(tok token) bool {
+	switch tok.kind {
+	case tokSymbol:
+		switch tok.text {
+		case "==", "=", "!=", ">", ">=", "<", "<=", "=~", "!~":
+			return true
+		}
+	case tokIdent:
+		return stringOps[strings.ToLower(tok.text)]
+	}
+	return false
+}
+
+func parseExpression(body string) (plan.Expr, error) {
+	toks, err := tokenize(body)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{toks: toks}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	}
+	return expr, nil
+}
+
+func (p *exprParser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *exprParser) next() token {
+	tok := p.toks[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *exprParser) isSymbol(s string) bool {
+	tok := p.peek()
+	return tok.kind == tokSymbol && tok.text == s
+}
+
+func (p *exprParser) isKeyword(kw string) bool {
+	tok := p.peek()
+	return tok.kind == tokIdent && strings.EqualFold(tok.text, kw)
+}
+
+func (p *exprParser) expect(s string) error {
+	if !p.isSymbol(s) {
+		tok := p.peek()
+		if tok.kind == tokEOF {
+			return fmt.Errorf("expected %q at end of expression", s)
+		}
+		return fmt.Errorf("expected %q at position %d, got %q", s, tok.pos, tok.text)
+	}
+	p.next()
+	return nil
+}
+
+func (p *exprParser) parseOr() (plan.Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = plan.LogicalExpr{Left: left, Op: "or", Right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (plan.Expr, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword("and") {
+		p.next()
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = plan.LogicalExpr{Left: left, Op: "and", Right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseComparison() (plan.Expr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.peek()
+	if p.search && p.isSymbol(":") {
+		return p.parseSearchColumn(left)
+	}
+	if !isCompareOp(tok) {
+		return left, nil
+	}
+	p.next()
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	return plan.CompareExpr{Left: left, Op: strings.ToLower(tok.text), Right: right}, nil
+}
+
+func (p *exprParser) parseAdditive() (plan.Expr, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for p.isSymbol("+") || p.isSymbol("-") {
+		op := p.next().text
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = plan.BinaryExpr{Left: left, Op: op, Right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseMultiplicative() (plan.Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isSymbol("*") || p.isSymbol("/") || p.isSymbol("%") {
+		op := p.next().text
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = plan.BinaryExpr{Left: left, Op: op, Right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (plan.Expr, error) {
+	if p.isSymbol("-") {
+		p.next()
+		if tok := p.peek(); tok.kind == tokNumber {
+			p.next()
+			return parseLiteralOrColumn("-" + tok.text)
+		}
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		zero := plan.Literal{Value: model.NewInt(0)}
+		return plan.BinaryExpr{Left: zero, Op: "-", Right: operand}, nil
+	}
+	return p.parsePostfix()
+}
+
+func (p *exprParser) parsePostfix() (plan.Expr, error) {
+	expr, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch {
+		case p.isSymbol("."):
+			p.next()
+			tok := p.next()
+			if tok.kind != tokIdent {
+				return nil, fmt.Errorf("expected property name at position %d", tok.pos)
+			}
+			expr = plan.MemberExpr{Target: expr, Name: tok.text}
+		case p.isSymbol("["):
+			p.next()
+			idx, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			expr = plan.IndexExpr{Target: expr, Index: idx}
+		default:
+			return expr, nil
+		}
+	}
+}
+
+func (p *exprParser) parsePrimary() (plan.Expr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+	case tokString:
+		return parseLiteralOrColumn(tok.text)
+	case tokNumber:
+		expr, err := parseLiteralOrColumn(tok.text)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := expr.(plan.Literal); !ok {
+			return nil, fmt.Errorf("invalid number %q at position %d", tok.text, tok.pos)
+		}
+		return expr, nil
+	case tokDynamic:
+		v, err := model.ParseDynamic(tok.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dynamic literal at position %d: %w", tok.pos, err)
+		}
+		return plan.Literal{Value: model.NewDynamic(v)}, nil
+	case tokIdent:
+		if strings.EqualFold(tok.text, "typeof") && p.isSymbol("(") {
+			return p.parseTypeof()
+		}
+		if p.isSymbol("(") {
+			return p.parseCall(tok.text)
+		}
+		return parseLiteralOrColumn(tok.text)
+	}
+	if tok.text == "(" {
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+	return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+}
+
+func (p *exprParser) parseCall(name string) (plan.Expr, error) {
+	p.next()
+	args := make([]plan.Expr, 0, 2)
+	if p.isSymbol(")") {
+		p.next()
+		return plan.CallExpr{Name: name, Args: args}, nil
+	}
+	for {
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.isSymbol(",") {
+			p.next()
+			continue
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return plan.CallExpr{Name: name, Args: args}, nil
+	}
+}
+
+// parseTypeof reads a typeof(T) type literal, represented as a string literal
+// holding the canonical type name.
+func (p *exprParser) parseTypeof() (plan.Expr, error) {
+	p.next()
+	tok := p.next()
+	t, ok := model.ParseType(tok.text)
+	if tok.kind != tokIdent || !ok {
+		return nil, fmt.Errorf("invalid type %q at position %d", tok.text, tok.pos)
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return plan.Literal{Value: model.NewString(string(t))}, nil
+}
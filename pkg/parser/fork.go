@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"kqlfile/pkg/plan"
+)
+
+var branchNameRe = regexp.MustCompile(`^(\w+)\s*=\s*`)
+
+func parseTop(seg string) (plan.Operator, error) {
+	parts := strings.Fields(seg)
+	if len(parts) < 4 || !strings.EqualFold(parts[2], "by") || len(parts) > 5 {
+		return nil, fmt.Errorf("top requires N by column [asc|desc]")
+	}
+	n, err := strconv.Atoi(parts[1])
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid top count: %s", parts[1])
+	}
+	desc := true
+	if len(parts) == 5 {
+		switch strings.ToLower(parts[4]) {
+		case "desc":
+		case "asc":
+			desc = false
+		default:
+			return nil, fmt.Errorf("top direction must be asc or desc")
+		}
+	}
+	return plan.TopOp{Count: n, Column: parts[3], Desc: desc}, nil
+}
+
+func parseAs(seg string) (plan.Operator, error) {
+	parts := strings.Fields(seg)
+	if len(parts) != 2 || !isIdentifier(parts[1]) {
+		return nil, fmt.Errorf("as requires a name")
+	}
+	return plan.AsOp{Name: parts[1]}, nil
+}
+
+// parseFork parses one or more "[name=](subquery)" branches.
+func parseFork(seg string) (plan.Operator, error) {
+	body := strings.TrimSpace(seg[len("fork"):])
+	var op plan.ForkOp
+	for body != "" {
+		var branch plan.ForkBranch
+		if m := branchNameRe.FindStringSubmatch(body); m != nil {
+			branch.Name = m[1]
+			body = body[len(m[0]):]
+		}
+		if !strings.HasPrefix(body, "(") {
+			return nil, fmt.Errorf("fork branches must be in parentheses")
+		}
+		end := closingParen(body)
+		if end == -1 {
+			return nil, fmt.Errorf("fork: unbalanced parentheses")
+		}
+		ops, err := Parse(body[1:end])
+		if err != nil {
+			return nil, fmt.Errorf("fork branch %d: %w", len(op.Branches)+1, err)
+		}
+		branch.Ops = ops
+		op.Branches = append(op.Branches, branch)
+		body = strings.TrimSpace(body[end+1:])
+	}
+	if len(op.Branches) == 0 {
+		return nil, fmt.Errorf("fork requires at least one branch")
+	}
+	seen := make(map[string]bool, len(op.Branches))
+	for i, b := range op.Branches {
+		name := b.ResultName(i)
+		if seen[name] {
+			return nil, fmt.Errorf("fork: duplicate result name %s", name)
+		}
+		seen[name] = true
+	}
+	return op, nil
+}
+
+// closingParen returns the index of the parenthesis closing s[0], skipping
+// quoted strings, or -1.
+func closingParen(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			end, err := scanString(s, i)
+			if err != nil {
+				return -1
+			}
+			i = end - 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		if !isIdentPart(r) || i == 0 && isDigit(r) {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+// leadingWord returns the operator keyword at the start of a segment, which
+// for generators such as datatable(...) is directly followed by a bracket.
+func leadingWord(field string) string {
+	end := strings.IndexAny(field, "([")
+	if end == -1 {
+		return field
+	}
+	return field[:end]
+}
+
+func parseRange(seg string) (plan.Operator, error) {
+	body := strings.TrimSpace(seg[len("range"):])
+	var op plan.RangeOp
+	clauses := map[string]*plan.Expr{"step": &op.Step, "to": &op.To, "from": &op.From}
+	for _, kw := range []string{"step", "to", "from"} {
+		i := findKeyword(body, kw)
+		if i == -1 {
+			return nil, fmt.Errorf("range requires name from <start> to <stop> step <step>")
+		}
+		expr, err := parseExpression(strings.TrimSpace(body[i+len(kw):]))
+		if err != nil {
+			return nil, fmt.Errorf("range %s: %w", kw, err)
+		}
+		*clauses[kw] = expr
+		body = body[:i]
+	}
+	op.Column = strings.TrimSpace(body)
+	if op.Column == "" || strings.ContainsAny(op.Column, " \t(") {
+		return nil, fmt.Errorf("range: invalid column name %q", op.Column)
+	}
+	return op, nil
+}
+
+func parseDatatable(seg string) (plan.Operator, error) {
+	body := strings.TrimSpace(seg[len("datatable"):])
+	open := strings.Index(body, "[")
+	if !strings.HasPrefix(body, "(") || open == -1 || !strings.HasSuffix(body, "]") {
+		return nil, fmt.Errorf("datatable requires (name:type, ...)[values]")
+	}
+	schema := strings.TrimSpace(body[:open])
+	if !strings.HasSuffix(schema, ")") {
+		return nil, fmt.Errorf("datatable requires (name:type, ...)[values]")
+	}
+	var op plan.DatatableOp
+	for _, item := range splitTopLevel(schema[1:len(schema)-1], ',') {
+		name, typ, ok := strings.Cut(strings.TrimSpace(item), ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("datatable column must be name:type, got %q", strings.TrimSpace(item))
+		}
+		t, ok := model.ParseType(strings.TrimSpace(typ))
+		if !ok {
+			return nil, fmt.Errorf("datatable: unknown type %q", strings.TrimSpace(typ))
+		}
+		op.Columns = append(op.Columns, model.Column{Name: name, Type: t})
+	}
+	if len(op.Columns) == 0 {
+		return nil, fmt.Errorf("datatable requires columns")
+	}
+	values := strings.TrimSpace(body[open+1 : len(body)-1])
+	if values != "" {
+		for _, item := range splitTopLevel(values, ',') {
+			expr, err := parseExpression(strings.TrimSpace(item))
+			if err != nil {
+				return nil, fmt.Errorf("datatable: %w", err)
+			}
+			op.Values = append(op.Values, expr)
+		}
+	}
+	if len(op.Values)%len(op.Columns) != 0 {
+		return nil, fmt.Errorf("datatable: %d values do not fill %d columns", len(op.Values), len(op.Columns))
+	}
+	return op, nil
+}
+
+func parsePrint(seg string) (plan.Operator, error) {
+	body := strings.TrimSpace(seg[len("print"):])
+	if body == "" {
+		return nil, fmt.Errorf("print requires an expression")
+	}
+	var op plan.PrintOp
+	for i, item := range splitTopLevel(body, ',') {
+		item = strings.TrimSpace(item)
+		name, value, ok := splitAssignment(item)
+		if !ok {
+			name, value = fmt.Sprintf("print_%d", i), item
+		}
+		expr, err := parseExpression(value)
+		if err != nil {
+			return nil, fmt.Errorf("print: %w", err)
+		}
+		op.Columns = append(op.Columns, plan.ExtendOp{Name: name, Value: expr})
+	}
+	return op, nil
+}
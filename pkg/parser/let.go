@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"kqlfile/pkg/plan"
+)
+
+var letRe = regexp.MustCompile(`(?is)^let\s+(\w+)\s*=\s*(.*)$`)
+
+// ParseLets splits the let statements off the front of a query. It returns
+// the parsed bindings and the remaining query text.
+func ParseLets(query string) ([]plan.LetStmt, string, error) {
+	stmts := splitTopLevel(query, ';')
+	var lets []plan.LetStmt
+	defined := map[string]bool{}
+	for i, raw := range stmts {
+		stmt := strings.TrimSpace(raw)
+		if stmt == "" {
+			continue
+		}
+		m := letRe.FindStringSubmatch(stmt)
+		if m == nil {
+			if rest := strings.Join(stmts[i+1:], ";"); strings.TrimSpace(rest) != "" {
+				return nil, "", fmt.Errorf("only let statements may precede the query")
+			}
+			return lets, stmt, nil
+		}
+		let, err := parseLet(m[1], m[2])
+		if err != nil {
+			return nil, "", err
+		}
+		for _, ref := range tableRefs(let) {
+			if ref == let.Name || !defined[ref] && isLater(ref, stmts[i+1:]) {
+				return nil, "", fmt.Errorf("let %s: %s is not defined yet", let.Name, ref)
+			}
+		}
+		defined[let.Name] = true
+		lets = append(lets, let)
+	}
+	return nil, "", fmt.Errorf("query must follow the let statements")
+}
+
+func parseLet(name, body string) (plan.LetStmt, error) {
+	let := plan.LetStmt{Name: name}
+	body = strings.TrimSpace(body)
+	if inner, ok := strings.CutPrefix(body, "materialize"); ok && strings.HasPrefix(strings.TrimSpace(inner), "(") {
+		inner = strings.TrimSpace(inner)
+		end := closingParen(inner)
+		if end == -1 || strings.TrimSpace(inner[end+1:]) != "" {
+			return let, fmt.Errorf("let %s: materialize requires one parenthesized subquery", name)
+		}
+		body = strings.TrimSpace(inner[1:end])
+		let.Materialize = true
+	}
+	if isIdentifier(body) && !IsOperator(body) {
+		let.Source = body
+		return let, nil
+	}
+	ops, err := Parse(body)
+	if err != nil {
+		return let, fmt.Errorf("let %s: %w", name, err)
+	}
+	if !isGenerator(ops[0]) {
+		first := strings.Fields(splitTopLevel(body, '|')[0])
+		if len(first) != 1 || IsOperator(leadingWord(first[0])) {
+			return let, fmt.Errorf("let %s: subquery must start with a table name", name)
+		}
+		let.Source = first[0]
+	}
+	let.Ops = ops
+	return let, nil
+}
+
+// tableRefs lists the tables a binding reads: its source and the right side
+// of its joins.
+func tableRefs(let plan.LetStmt) []string {
+	var refs []string
+	if let.Source != "" {
+		refs = append(refs, let.Source)
+	}
+	var walk func(ops []plan.Operator)
+	walk = func(ops []plan.Operator) {
+		for _, op := range ops {
+			switch o := op.(type) {
+			case plan.JoinOp:
+				refs = append(refs, o.Right)
+			case plan.MvApplyOp:
+				walk(o.Subquery)
+			case plan.ForkOp:
+				for _, b := range o.Branches {
+					walk(b.Ops)
+				}
+			}
+		}
+	}
+	walk(let.Ops)
+	return refs
+}
+
+// isLater reports whether name is bound by one of the following statements.
+func isLater(name string, stmts []string) bool {
+	for _, s := range stmts {
+		if m := letRe.FindStringSubmatch(strings.TrimSpace(s)); m != nil && m[1] == name {
+			return true
+		}
+	}
+	return false
+}
@@ -10,7 +10,7 @@ import (
 )
 
 func Parse(query string) ([]plan.Operator, error) {
-	parts := strings.Split(query, "|")
+	parts := splitTopLevel(query, '|')
 	ops := make([]plan.Operator, 0, len(parts))
 	for i, p := range parts {
 		seg := strings.TrimSpace(p)
@@ -19,7 +19,7 @@ func Parse(query string) ([]plan.Operator, error) {
 		}
 		if i == 0 {
 			fields := strings.Fields(seg)
-			if len(fields) == 1 && !isOperator(fields[0]) {
+			if len(fields) == 1 && !IsOperator(leadingWord(fields[0])) {
 				continue
 			}
 		}
@@ -27,17 +27,54 @@ func Parse(query string) ([]plan.Operator, error) {
 		if err != nil {
 			return nil, err
 		}
+		if isGenerator(op) && i > 0 {
+			return nil, fmt.Errorf("%s must be the first operator", op.Type())
+		}
 		ops = append(ops, op)
 	}
 	if len(ops) == 0 {
 		return nil, fmt.Errorf("empty query")
 	}
+	for _, op := range ops[:len(ops)-1] {
+		if _, ok := op.(plan.ForkOp); ok {
+			return nil, fmt.Errorf("fork must be the last operator")
+		}
+	}
 	return ops, nil
 }
 
-func isOperator(tok string) bool {
+// OperatorOffsets returns the byte offset in query of each operator that
+// Parse returns, in the same order.
+func OperatorOffsets(query string) []int {
+	var offsets []int
+	start := 0
+	for i, p := range splitTopLevel(query, '|') {
+		seg := strings.TrimSpace(p)
+		off := start + strings.Index(p, seg)
+		start += len(p) + 1
+		if seg == "" {
+			continue
+		}
+		if fields := strings.Fields(seg); i == 0 && len(fields) == 1 && !IsOperator(leadingWord(fields[0])) {
+			continue
+		}
+		offsets = append(offsets, off)
+	}
+	return offsets
+}
+
+func IsOperator(tok string) bool {
 	switch strings.ToLower(tok) {
-	case "where", "project", "extend", "summarize", "take", "order", "join":
+	case "where", "project", "extend", "summarize", "take", "order", "join", "mv-expand", "mv-apply", "parse", "search", "serialize", "make-series", "range", "datatable", "print", "getschema", "evaluate", "sample", "sample-distinct", "top", "as", "fork":
+		return true
+	default:
+		return false
+	}
+}
+
+func isGenerator(op plan.Operator) bool {
+	switch op.(type) {
+	case plan.RangeOp, plan.DatatableOp, plan.PrintOp:
 		return true
 	default:
 		return false
@@ -49,7 +86,7 @@ func parseOperator(seg string) (plan.Operator, error) {
 	if len(fields) == 0 {
 		return nil, fmt.Errorf("empty segment")
 	}
-	switch strings.ToLower(fields[0]) {
+	switch strings.ToLower(leadingWord(fields[0])) {
 	case "where":
 		return parseWhere(seg)
 	case "project":
@@ -64,6 +101,41 @@ func parseOperator(seg string) (plan.Operator, error) {
 		return parseOrderBy(seg)
 	case "join":
 		return parseJoin(seg)
+	case "mv-expand":
+		return parseMvExpand(seg)
+	case "mv-apply":
+		return parseMvApply(seg)
+	case "parse":
+		return parseParse(seg)
+	case "search":
+		return parseSearch(seg)
+	case "serialize":
+		return parseSerialize(seg)
+	case "make-series":
+		return parseMakeSeries(seg)
+	case "range":
+		return parseRange(seg)
+	case "datatable":
+		return parseDatatable(seg)
+	case "print":
+		return parsePrint(seg)
+	case "getschema":
+		if len(fields) != 1 {
+			return nil, fmt.Errorf("getschema takes no arguments")
+		}
+		return plan.GetSchemaOp{}, nil
+	case "evaluate":
+		return parseEvaluate(seg)
+	case "sample":
+		return parseSample(seg)
+	case "sample-distinct":
+		return parseSampleDistinct(seg)
+	case "top":
+		return parseTop(seg)
+	case "as":
+		return parseAs(seg)
+	case "fork":
+		return parseFork(seg)
 	default:
 		return nil, fmt.Errorf("unknown operator: %s", fields[0])
 	}
@@ -97,24 +169,183 @@ func parseExtend(seg string) (plan.Operator, error) {
 		return nil, fmt.Errorf("extend requires name = value")
 	}
 	name := strings.TrimSpace(parts[0])
-	valueExpr, err := parseLiteralOrColumn(strings.TrimSpace(parts[1]))
+	valueExpr, err := parseExpression(strings.TrimSpace(parts[1]))
 	if err != nil {
 		return nil, err
 	}
 	return plan.ExtendOp{Name: name, Value: valueExpr}, nil
 }
 
+func parseSerialize(seg string) (plan.Operator, error) {
+	body := strings.TrimSpace(seg[len("serialize"):])
+	if body == "" {
+		return plan.SerializeOp{}, nil
+	}
+	var cols []plan.ExtendOp
+	for _, part := range splitTopLevel(body, ',') {
+		name, value, ok := splitAssignment(strings.TrimSpace(part))
+		if !ok {
+			return nil, fmt.Errorf("serialize requires name = value, got %q", strings.TrimSpace(part))
+		}
+		expr, err := parseExpression(value)
+		if err != nil {
+			return nil, err
+		}
+		cols = append(cols, plan.ExtendOp{Name: name, Value: expr})
+	}
+	return plan.SerializeOp{Columns: cols}, nil
+}
+
+func parseEvaluate(seg string) (plan.Operator, error) {
+	body := strings.TrimSpace(seg[len("evaluate"):])
+	if body == "" {
+		return nil, fmt.Errorf("evaluate requires a plugin call")
+	}
+	expr, err := parseExpression(body)
+	if err != nil {
+		return nil, fmt.Errorf("evaluate: %w", err)
+	}
+	call, ok := expr.(plan.CallExpr)
+	if !ok {
+		return nil, fmt.Errorf("evaluate requires a plugin call, got %q", body)
+	}
+	return plan.EvaluateOp{Plugin: call.Name, Args: call.Args}, nil
+}
+
 func parseSummarize(seg string) (plan.Operator, error) {
 	body := strings.TrimSpace(strings.TrimPrefix(seg, "summarize"))
-	if !strings.HasPrefix(strings.ToLower(body), "count()") {
-		return nil, fmt.Errorf("only count() supported in summarize")
+	list, by := body, ""
+	if i := findKeyword(body, "by"); i != -1 {
+		list, by = strings.TrimSpace(body[:i]), strings.TrimSpace(body[i+len("by"):])
+	}
+	var aggs []plan.Aggregate
+	for _, item := range splitTopLevel(list, ',') {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		expanded, err := parseSummarizeAggregate(item)
+		if err != nil {
+			return nil, err
+		}
+		aggs = append(aggs, expanded...)
 	}
-	body = strings.TrimSpace(body[len("count()"):])
-	if strings.HasPrefix(strings.ToLower(body), "by") {
-		body = strings.TrimSpace(body[2:])
+	if len(aggs) == 0 {
+		return nil, fmt.Errorf("summarize requires at least one aggregation")
 	}
-	cols := splitCSVList(body)
-	return plan.SummarizeOp{ByColumns: cols}, nil
+	op, err := parseGroupBy(by)
+	if err != nil {
+		return nil, err
+	}
+	op.Aggregates = aggs
+	return op, nil
+}
+
+// parseSummarizeAggregate parses one summarize aggregation. percentile names
+// carry the percentage, and percentiles(x, p1, p2, ...) expands into one
+// percentile column per percentage.
+func parseSummarizeAggregate(item string) ([]plan.Aggregate, error) {
+	agg, named, err := parseAggregate(item)
+	if err != nil {
+		return nil, err
+	}
+	if agg.Func != "percentile" && agg.Func != "percentiles" {
+		return []plan.Aggregate{agg}, nil
+	}
+	if len(agg.Args) < 2 {
+		return nil, fmt.Errorf("%s expects a column and at least one percentage", agg.Func)
+	}
+	if agg.Func == "percentile" && len(agg.Args) != 2 {
+		return nil, fmt.Errorf("percentile expects 2 arguments, use percentiles for several")
+	}
+	base := agg.Name
+	if !named {
+		base = strings.Replace(agg.Name, "percentiles", "percentile", 1)
+	}
+	var out []plan.Aggregate
+	for _, p := range agg.Args[1:] {
+		suffix := "p"
+		if lit, ok := p.(plan.Literal); ok {
+			suffix = strings.ReplaceAll(lit.Value.String(), ".", "_")
+		}
+		name := base
+		if !named || agg.Func == "percentiles" {
+			name += "_" + suffix
+		}
+		out = append(out, plan.Aggregate{Name: name, Func: "percentile", Args: []plan.Expr{agg.Args[0], p}})
+	}
+	return out, nil
+}
+
+// parseAggregate parses [name =] func(args). Unnamed aggregations are called
+// func_col after their first column argument, or just func; named reports
+// whether the name was given.
+func parseAggregate(item string) (agg plan.Aggregate, named bool, err error) {
+	if name, value, ok := splitAssignment(item); ok {
+		agg.Name, named = name, true
+		item = value
+	}
+	expr, err := parseExpression(item)
+	if err != nil {
+		return agg, named, err
+	}
+	call, ok := expr.(plan.CallExpr)
+	if !ok {
+		return agg, named, fmt.Errorf("expected an aggregation, got %q", item)
+	}
+	agg.Func = call.Name
+	agg.Args = call.Args
+	if agg.Name == "" {
+		agg.Name = call.Name
+		if len(call.Args) > 0 {
+			if col, ok := call.Args[0].(plan.ColumnRef); ok {
+				agg.Name = call.Name + "_" + col.Name
+			}
+		}
+	}
+	return agg, named, nil
+}
+
+// parseGroupBy parses summarize's by clause. Columns may be computed with
+// name = expr; unnamed expressions are named after the column of bin(col, ...)
+// or Column1, Column2, ...
+func parseGroupBy(body string) (plan.SummarizeOp, error) {
+	var op plan.SummarizeOp
+	var keys []plan.Expr
+	computed := false
+	for _, part := range splitTopLevel(body, ',') {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := splitAssignment(part)
+		if !ok {
+			name, value = "", part
+		}
+		expr, err := parseExpression(value)
+		if err != nil {
+			return op, err
+		}
+		if ref, isCol := expr.(plan.ColumnRef); isCol && name == "" {
+			name = ref.Name
+		} else {
+			computed = true
+		}
+		if call, isCall := expr.(plan.CallExpr); name == "" && isCall && call.Name == "bin" && len(call.Args) > 0 {
+			if ref, isCol := call.Args[0].(plan.ColumnRef); isCol {
+				name = ref.Name
+			}
+		}
+		if name == "" {
+			name = fmt.Sprintf("Column%d", len(op.ByColumns)+1)
+		}
+		op.ByColumns = append(op.ByColumns, name)
+		keys = append(keys, expr)
+	}
+	if computed {
+		op.Keys = keys
+	}
+	return op, nil
 }
 
 func parseTake(seg string) (plan.Operator, error) {
@@ -129,6 +360,30 @@ func parseTake(seg string) (plan.Operator, error) {
 	return plan.TakeOp{Count: n}, nil
 }
 
+func parseSample(seg string) (plan.Operator, error) {
+	parts := strings.Fields(seg)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("sample requires a count")
+	}
+	n, err := strconv.Atoi(parts[1])
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid sample count: %s", parts[1])
+	}
+	return plan.SampleOp{Count: n}, nil
+}
+
+func parseSampleDistinct(seg string) (plan.Operator, error) {
+	parts := strings.Fields(seg)
+	if len(parts) != 4 || !strings.EqualFold(parts[2], "of") {
+		return nil, fmt.Errorf("sample-distinct requires N of column")
+	}
+	n, err := strconv.Atoi(parts[1])
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid sample-distinct count: %s", parts[1])
+	}
+	return plan.SampleDistinctOp{Count: n, Column: parts[3]}, nil
+}
+
 func parseOrderBy(seg string) (plan.Operator, error) {
 	lower := strings.ToLower(seg)
 	if !strings.HasPrefix(lower, "order by") {
@@ -187,12 +442,21 @@ func parseJoin(seg string) (plan.Operator, error) {
 	if len(tokens) < 3 {
 		return nil, fmt.Errorf("join on requires left op right")
 	}
-	leftKey := tokens[0]
-	op := tokens[1]
-	rightKey := tokens[2]
-	if op != "==" && op != "=" {
+	expr, err := parseCompare(tokens)
+	if err != nil {
+		return nil, err
+	}
+	cmp := expr.(plan.CompareExpr)
+	left, lok := cmp.Left.(plan.ColumnRef)
+	right, rok := cmp.Right.(plan.ColumnRef)
+	if !lok || !rok {
+		return nil, fmt.Errorf("join on requires column names")
+	}
+	if cmp.Op != "==" && cmp.Op != "=" {
 		return nil, fmt.Errorf("join on only supports = or ==")
 	}
+	leftKey := left.Name
+	rightKey := right.Name
 	if kind != "inner" {
 		return nil, fmt.Errorf("only inner join supported")
 	}
@@ -204,20 +468,36 @@ func parseLiteralOrColumn(raw string) (plan.Expr, error) {
 	if raw == "" {
 		return nil, fmt.Errorf("empty literal or column")
 	}
-	if strings.HasPrefix(raw, "\"") && strings.HasSuffix(raw, "\"") {
-		return plan.Literal{Value: model.Value{Type: model.TypeString, V: strings.Trim(raw, "\"")}}, nil
+	if strings.HasPrefix(raw, "@") && len(raw) >= 3 && (raw[1] == '"' || raw[1] == '\'') && raw[len(raw)-1] == raw[1] {
+		return plan.Literal{Value: model.NewString(raw[2 : len(raw)-1])}, nil
+	}
+	if strings.HasPrefix(raw, "\"") && strings.HasSuffix(raw, "\"") && len(raw) >= 2 {
+		s, err := strconv.Unquote(raw)
+		if err != nil {
+			s = strings.Trim(raw, "\"")
+		}
+		return plan.Literal{Value: model.NewString(s)}, nil
 	}
-	if strings.HasPrefix(raw, "'") && strings.HasSuffix(raw, "'") {
-		return plan.Literal{Value: model.Value{Type: model.TypeString, V: strings.Trim(raw, "'")}}, nil
+	if strings.HasPrefix(raw, "'") && strings.HasSuffix(raw, "'") && len(raw) >= 2 {
+		s := strings.ReplaceAll(raw[1:len(raw)-1], "\\'", "'")
+		return plan.Literal{Value: model.NewString(s)}, nil
 	}
 	if v, err := strconv.ParseInt(raw, 10, 64); err == nil {
-		return plan.Literal{Value: model.Value{Type: model.TypeInt, V: v}}, nil
+		return plan.Literal{Value: model.NewInt(v)}, nil
 	}
 	if v, err := strconv.ParseFloat(raw, 64); err == nil {
-		return plan.Literal{Value: model.Value{Type: model.TypeFloat, V: v}}, nil
+		return plan.Literal{Value: model.NewFloat(v)}, nil
+	}
+	if raw[0] == '-' || isDigit(rune(raw[0])) {
+		if d, err := model.ParseTimespan(raw); err == nil {
+			return plan.Literal{Value: model.NewTimespan(d)}, nil
+		}
 	}
-	if v, err := strconv.ParseBool(raw); err == nil {
-		return plan.Literal{Value: model.Value{Type: model.TypeBool, V: v}}, nil
+	switch strings.ToLower(raw) {
+	case "true":
+		return plan.Literal{Value: model.NewBool(true)}, nil
+	case "false":
+		return plan.Literal{Value: model.NewBool(false)}, nil
 	}
 	return plan.ColumnRef{Name: raw}, nil
 }
@@ -239,30 +519,13 @@ func splitCSVList(body string) []string {
 }
 
 func parseLogicalExpr(body string) (plan.Expr, error) {
-	tokens := strings.Fields(body)
-	if len(tokens) < 3 {
-		return nil, fmt.Errorf("invalid expression: %s", body)
-	}
-	left, err := parseCompare(tokens[:3])
+	expr, err := parseExpression(body)
 	if err != nil {
 		return nil, err
 	}
-	expr := left
-	rest := tokens[3:]
-	for len(rest) > 0 {
-		if len(rest) < 4 {
-			return nil, fmt.Errorf("invalid logical expression: %s", body)
-		}
-		op := strings.ToLower(rest[0])
-		if op != "and" && op != "or" {
-			return nil, fmt.Errorf("expected logical operator, got %s", rest[0])
-		}
-		right, err := parseCompare(rest[1:4])
-		if err != nil {
-			return nil, err
-		}
-		expr = plan.LogicalExpr{Left: expr, Op: op, Right: right}
-		rest = rest[4:]
+	switch expr.(type) {
+	case plan.ColumnRef, plan.Literal:
+		return nil, fmt.Errorf("invalid expression: %s", body)
 	}
 	return expr, nil
 }
@@ -271,16 +534,36 @@ func parseCompare(tokens []string) (plan.Expr, error) {
 	if len(tokens) < 3 {
 		return nil, fmt.Errorf("invalid comparison")
 	}
-	left := plan.ColumnRef{Name: tokens[0]}
-	op := tokens[1]
-	switch op {
-	case "==", "=", "!=", ">", ">=", "<", "<=":
-	default:
-		return nil, fmt.Errorf("invalid comparison operator")
-	}
-	right, err := parseLiteralOrColumn(strings.Join(tokens[2:], " "))
+	expr, err := parseExpression(strings.Join(tokens, " "))
 	if err != nil {
 		return nil, err
 	}
-	return plan.CompareExpr{Left: left, Op: op, Right: right}, nil
+	if _, ok := expr.(plan.CompareExpr); !ok {
+		return nil, fmt.Errorf("invalid comparison operator")
+	}
+	return expr, nil
+}
+
+func splitTopLevel(s string, sep byte) []string {
+	var parts []string
+	depth := 0
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\'':
+			if end, err := scanString(s, i); err == nil {
+				i = end - 1
+			}
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
 }
@@ -1,6 +1,12 @@
 package parser
 
-import "testing"
+import (
+	"strings"
+	"testing"
+
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
 
 func TestParseErrors(t *testing.T) {
 	if _, err := Parse(""); err == nil {
@@ -18,7 +24,7 @@ func TestParseErrors(t *testing.T) {
 	if _, err := Parse("T | extend x = "); err == nil {
 		t.Fatalf("expected extend value error")
 	}
-	if _, err := Parse("T | summarize sum(x)"); err == nil {
+	if _, err := Parse("T | summarize x"); err == nil {
 		t.Fatalf("expected summarize error")
 	}
 	if _, err := Parse("T | take x"); err == nil {
@@ -196,3 +202,529 @@ func TestParseJoinSuccessCases(t *testing.T) {
 		t.Fatalf("join kind parse: %v", err)
 	}
 }
+
+func TestParseDynamicAccess(t *testing.T) {
+	expr, err := parseExpression("props.user.name == \"alice\"")
+	if err != nil {
+		t.Fatalf("member parse: %v", err)
+	}
+	cmp := expr.(plan.CompareExpr)
+	member, ok := cmp.Left.(plan.MemberExpr)
+	if !ok || member.Name != "name" {
+		t.Fatalf("expected member expr, got %#v", cmp.Left)
+	}
+	expr, err = parseExpression("tags[0]")
+	if err != nil {
+		t.Fatalf("index parse: %v", err)
+	}
+	if _, ok := expr.(plan.IndexExpr); !ok {
+		t.Fatalf("expected index expr")
+	}
+	expr, err = parseExpression("bag_has_key(parse_json(raw), \"k\")")
+	if err != nil {
+		t.Fatalf("call parse: %v", err)
+	}
+	call := expr.(plan.CallExpr)
+	if call.Name != "bag_has_key" || len(call.Args) != 2 {
+		t.Fatalf("unexpected call: %#v", call)
+	}
+	if _, err := Parse("T | where array_length(tags) > 1 | extend first = tags[-1]"); err != nil {
+		t.Fatalf("pipeline parse: %v", err)
+	}
+}
+
+func TestParseDynamicAccessErrors(t *testing.T) {
+	cases := []string{"props.", "tags[0", "f(a,", "props.[1]", "\"open", "1e"}
+	for _, c := range cases {
+		if _, err := parseExpression(c); err == nil {
+			t.Fatalf("expected error for %s", c)
+		}
+	}
+}
+
+func TestParseMvExpand(t *testing.T) {
+	ops, err := Parse("T | mv-expand with_itemindex=i tag = tags to typeof(string), spans limit 10")
+	if err != nil {
+		t.Fatalf("mv-expand parse: %v", err)
+	}
+	op := ops[0].(plan.MvExpandOp)
+	if op.IndexName != "i" || op.Limit != 10 || len(op.Columns) != 2 {
+		t.Fatalf("unexpected mv-expand: %#v", op)
+	}
+	if op.Columns[0].Name != "tag" || op.Columns[0].ToType != model.TypeString {
+		t.Fatalf("unexpected first column: %#v", op.Columns[0])
+	}
+	if op.Columns[1].Name != "spans" || op.Columns[1].ToType != "" {
+		t.Fatalf("unexpected second column: %#v", op.Columns[1])
+	}
+	for _, bad := range []string{"T | mv-expand", "T | mv-expand tags to typeof(blob)", "T | mv-expand props.tags", "T | mv-expand tags[0"} {
+		if _, err := Parse(bad); err == nil {
+			t.Fatalf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestParseMvApply(t *testing.T) {
+	ops, err := Parse("T | mv-apply t = tags to typeof(string) on (where t == \"a|b\" | summarize count()) | take 1")
+	if err != nil {
+		t.Fatalf("mv-apply parse: %v", err)
+	}
+	if len(ops) != 2 {
+		t.Fatalf("expected 2 ops, got %d", len(ops))
+	}
+	op := ops[0].(plan.MvApplyOp)
+	if len(op.Subquery) != 2 || op.Columns[0].Name != "t" {
+		t.Fatalf("unexpected mv-apply: %#v", op)
+	}
+	for _, bad := range []string{"T | mv-apply tags", "T | mv-apply tags on where x", "T | mv-apply tags on (badop)", "T | mv-apply on (take 1)"} {
+		if _, err := Parse(bad); err == nil {
+			t.Fatalf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestSplitTopLevel(t *testing.T) {
+	parts := splitTopLevel(`T | where a == "x|y" | mv-apply t on (take 1 | take 2)`, '|')
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 parts, got %q", parts)
+	}
+}
+
+func TestParseParseOperator(t *testing.T) {
+	ops, err := Parse(`T | parse message with "user=" user:string " took " ms:long "ms" *`)
+	if err != nil {
+		t.Fatalf("parse operator: %v", err)
+	}
+	op := ops[0].(plan.ParseOp)
+	if op.Kind != "simple" || len(op.Pattern) != 6 {
+		t.Fatalf("unexpected parse op: %#v", op)
+	}
+	if op.Pattern[3].Column != "ms" || op.Pattern[3].ColType != model.TypeInt || !op.Pattern[5].Wildcard {
+		t.Fatalf("unexpected pattern: %#v", op.Pattern)
+	}
+	ops, err = Parse(`T | parse kind=regex message with @"user=" user @"\s+took"`)
+	if err != nil {
+		t.Fatalf("parse regex: %v", err)
+	}
+	op = ops[0].(plan.ParseOp)
+	if op.Kind != "regex" || op.Pattern[2].Literal != `\s+took` {
+		t.Fatalf("unexpected regex pattern: %#v", op.Pattern)
+	}
+	bad := []string{
+		`T | parse kind=fuzzy m with "a" x`,
+		`T | parse m "a" x`,
+		`T | parse m with "a"`,
+		`T | parse m with x:blob`,
+		`T | parse m with x, y`,
+		`T | parse with x`,
+	}
+	for _, b := range bad {
+		if _, err := Parse(b); err == nil {
+			t.Fatalf("expected error for %s", b)
+		}
+	}
+}
+
+func TestParseTypeofAndVerbatim(t *testing.T) {
+	expr, err := parseExpression(`extract(@"(\d+)", 1, msg, typeof(long))`)
+	if err != nil {
+		t.Fatalf("extract parse: %v", err)
+	}
+	call := expr.(plan.CallExpr)
+	if call.Args[0].(plan.Literal).Value.String() != `(\d+)` {
+		t.Fatalf("unexpected verbatim string: %#v", call.Args[0])
+	}
+	if call.Args[3].(plan.Literal).Value.String() != string(model.TypeInt) {
+		t.Fatalf("unexpected typeof literal: %#v", call.Args[3])
+	}
+	for _, bad := range []string{"typeof(blob)", "typeof(long", `@"open`} {
+		if _, err := parseExpression(bad); err == nil {
+			t.Fatalf("expected error for %s", bad)
+		}
+	}
+}
+
+func TestParseSearch(t *testing.T) {
+	ops, err := Parse(`search in (A, B) "timeout" and host:"web*"`)
+	if err != nil {
+		t.Fatalf("search parse: %v", err)
+	}
+	op := ops[0].(plan.SearchOp)
+	if len(op.Tables) != 2 || op.Tables[1] != "B" {
+		t.Fatalf("unexpected tables: %v", op.Tables)
+	}
+	logical := op.Predicate.(plan.LogicalExpr)
+	if term := logical.Left.(plan.SearchTerm); term.Term != "timeout" || term.CaseSensitive {
+		t.Fatalf("unexpected term: %#v", term)
+	}
+	if term := logical.Right.(plan.SearchTerm); term.Column != "host" || term.Term != "web*" {
+		t.Fatalf("unexpected column term: %#v", term)
+	}
+	ops, err = Parse(`T | search kind=case_sensitive "Error" or level == "warn"`)
+	if err != nil {
+		t.Fatalf("search parse: %v", err)
+	}
+	logical = ops[0].(plan.SearchOp).Predicate.(plan.LogicalExpr)
+	if !logical.Left.(plan.SearchTerm).CaseSensitive {
+		t.Fatalf("expected case-sensitive term")
+	}
+	if _, ok := logical.Right.(plan.CompareExpr); !ok {
+		t.Fatalf("expected comparison to be kept")
+	}
+	bad := []string{
+		"search",
+		"search kind=fuzzy \"x\"",
+		"search in (A \"x\"",
+		"search in () \"x\"",
+		"search 42",
+		"search col",
+		"search col:42",
+		"search f(x):\"y\"",
+		"search \"x\" )",
+		"search \"x\" ~",
+	}
+	for _, b := range bad {
+		if _, err := Parse(b); err == nil {
+			t.Fatalf("expected error for %s", b)
+		}
+	}
+}
+
+func TestParseStringOperators(t *testing.T) {
+	for _, op := range []string{"has", "!has", "contains", "!contains_cs", "startswith", "endswith", "=~", "!~", "has_cs"} {
+		expr, err := parseExpression("msg " + op + " \"x\"")
+		if err != nil {
+			t.Fatalf("%s: %v", op, err)
+		}
+		if expr.(plan.CompareExpr).Op != op {
+			t.Fatalf("unexpected op for %s", op)
+		}
+	}
+	expr, err := parseExpression("msg HAS \"x\"")
+	if err != nil || expr.(plan.CompareExpr).Op != "has" {
+		t.Fatalf("expected lower-cased operator: %v", err)
+	}
+}
+
+func TestParseArithmeticAndTimespan(t *testing.T) {
+	expr, err := parseExpression("a + b * 2 - -c")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	sub, ok := expr.(plan.BinaryExpr)
+	if !ok || sub.Op != "-" {
+		t.Fatalf("expected subtraction at the root, got %#v", expr)
+	}
+	add := sub.Left.(plan.BinaryExpr)
+	if add.Op != "+" || add.Right.(plan.BinaryExpr).Op != "*" {
+		t.Fatalf("unexpected precedence: %#v", add)
+	}
+	if neg := sub.Right.(plan.BinaryExpr); neg.Op != "-" || neg.Right.(plan.ColumnRef).Name != "c" {
+		t.Fatalf("expected negated column: %#v", sub.Right)
+	}
+	expr, err = parseExpression("ts - prev(ts) > 1h")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	lit := expr.(plan.CompareExpr).Right.(plan.Literal)
+	if lit.Value.Type != model.TypeTimespan || lit.Value.String() != "01:00:00" {
+		t.Fatalf("expected timespan literal, got %#v", lit)
+	}
+	if _, err := parseExpression("1x"); err == nil {
+		t.Fatalf("expected error for invalid number")
+	}
+}
+
+func TestParseSerialize(t *testing.T) {
+	ops, err := Parse("T | serialize")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if s := ops[0].(plan.SerializeOp); len(s.Columns) != 0 {
+		t.Fatalf("expected no columns")
+	}
+	ops, err = Parse("T | serialize rn = row_number(), d = ts - prev(ts, 2)")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	s := ops[0].(plan.SerializeOp)
+	if len(s.Columns) != 2 || s.Columns[0].Name != "rn" || s.Columns[1].Name != "d" {
+		t.Fatalf("unexpected serialize columns: %#v", s.Columns)
+	}
+	if _, err := Parse("T | serialize row_number()"); err == nil {
+		t.Fatalf("expected error for missing name")
+	}
+}
+
+func TestParseMakeSeries(t *testing.T) {
+	ops, err := Parse("T | make-series count() default=0, avg_lat = avg(latency) default = -1 on ts from ago(7d) to now() step 1h by host, region")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	ms := ops[0].(plan.MakeSeriesOp)
+	if ms.On != "ts" || ms.From == nil || ms.To == nil || ms.Step == nil {
+		t.Fatalf("unexpected axis: %#v", ms)
+	}
+	if len(ms.ByColumns) != 2 || ms.ByColumns[1] != "region" {
+		t.Fatalf("unexpected by columns: %v", ms.ByColumns)
+	}
+	if len(ms.Aggregates) != 2 || ms.Aggregates[0].Name != "count" || ms.Aggregates[1].Name != "avg_lat" {
+		t.Fatalf("unexpected aggregates: %#v", ms.Aggregates)
+	}
+	if def := ms.Aggregates[1].Default.(plan.Literal); def.Value.Int() != -1 {
+		t.Fatalf("unexpected default: %#v", def)
+	}
+	ops, err = Parse("T | make-series sum(bytes) on ts step 5m")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if ms := ops[0].(plan.MakeSeriesOp); ms.Aggregates[0].Name != "sum_bytes" || ms.From != nil || ms.To != nil {
+		t.Fatalf("unexpected defaults: %#v", ms)
+	}
+	bad := []string{
+		"T | make-series count() step 1h",
+		"T | make-series on ts step 1h",
+		"T | make-series count() on ts",
+		"T | make-series count() on ts step",
+		"T | make-series x on ts step 1h",
+		"T | make-series count() on ts step 1h by",
+		"T | make-series count() on bin(ts, 1h) step 1h",
+	}
+	for _, b := range bad {
+		if _, err := Parse(b); err == nil {
+			t.Fatalf("expected error for %s", b)
+		}
+	}
+}
+
+func TestParseGenerators(t *testing.T) {
+	ops, err := Parse("range x from 1 to 100 step 2 | where x > 10")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	r := ops[0].(plan.RangeOp)
+	if r.Column != "x" || r.Step.(plan.Literal).Value.Int() != 2 || len(ops) != 2 {
+		t.Fatalf("unexpected range: %#v", r)
+	}
+	ops, err = Parse(`datatable(a:int, b:string)[1, "x, y", 2, "z|w"] | project b`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	dt := ops[0].(plan.DatatableOp)
+	if len(dt.Columns) != 2 || dt.Columns[0].Type != model.TypeInt || len(dt.Values) != 4 {
+		t.Fatalf("unexpected datatable: %#v", dt)
+	}
+	if dt.Values[3].(plan.Literal).Value.String() != "z|w" {
+		t.Fatalf("unexpected value: %#v", dt.Values[3])
+	}
+	ops, err = Parse("print v = now(), 1 + 1")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	p := ops[0].(plan.PrintOp)
+	if len(p.Columns) != 2 || p.Columns[0].Name != "v" || p.Columns[1].Name != "print_1" {
+		t.Fatalf("unexpected print: %#v", p)
+	}
+	bad := []string{
+		"range x from 1 to 10",
+		"range from 1 to 10 step 1",
+		"datatable(a:int)",
+		"datatable(a:blob)[1]",
+		"datatable(a)[1]",
+		"datatable(a:int, b:int)[1, 2, 3]",
+		"print",
+		"print x = ",
+	}
+	for _, b := range bad {
+		if _, err := Parse(b); err == nil {
+			t.Fatalf("expected error for %s", b)
+		}
+	}
+}
+
+func TestParseGeneratorPosition(t *testing.T) {
+	for _, q := range []string{"T | range x from 1 to 2 step 1", "print x = 1 | print y = 2"} {
+		if _, err := Parse(q); err == nil || !strings.Contains(err.Error(), "must be the first operator") {
+			t.Fatalf("%s: expected position error, got %v", q, err)
+		}
+	}
+}
+
+func TestParseGetSchemaAndEvaluate(t *testing.T) {
+	ops, err := Parse("T | getschema")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if _, ok := ops[0].(plan.GetSchemaOp); !ok {
+		t.Fatalf("expected getschema, got %#v", ops[0])
+	}
+	ops, err = Parse("T | evaluate profile(3)")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if e := ops[0].(plan.EvaluateOp); e.Plugin != "profile" || len(e.Args) != 1 {
+		t.Fatalf("unexpected evaluate: %#v", e)
+	}
+	for _, b := range []string{"T | getschema x", "T | evaluate", "T | evaluate profile", "T | evaluate 1 + 1"} {
+		if _, err := Parse(b); err == nil {
+			t.Fatalf("expected error for %s", b)
+		}
+	}
+}
+
+func TestParseSample(t *testing.T) {
+	ops, err := Parse("T | sample 10 | sample-distinct 5 of city")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if s := ops[0].(plan.SampleOp); s.Count != 10 {
+		t.Fatalf("unexpected sample: %#v", s)
+	}
+	if s := ops[1].(plan.SampleDistinctOp); s.Count != 5 || s.Column != "city" {
+		t.Fatalf("unexpected sample-distinct: %#v", s)
+	}
+	for _, b := range []string{"T | sample", "T | sample x", "T | sample -1", "T | sample-distinct 5 city", "T | sample-distinct x of city"} {
+		if _, err := Parse(b); err == nil {
+			t.Fatalf("expected error for %s", b)
+		}
+	}
+}
+
+func TestParseForkTopAs(t *testing.T) {
+	ops, err := Parse(`T | where x > 1 | fork (summarize count() by host) slow=(top 10 by latency | project host) (where msg == "a|b)" | as errs)`)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	f, ok := ops[1].(plan.ForkOp)
+	if !ok || len(f.Branches) != 3 {
+		t.Fatalf("unexpected fork: %#v", ops[1])
+	}
+	if f.Branches[0].Name != "" || f.Branches[1].Name != "slow" || len(f.Branches[1].Ops) != 2 {
+		t.Fatalf("unexpected branches: %#v", f.Branches)
+	}
+	if top := f.Branches[1].Ops[0].(plan.TopOp); top.Count != 10 || top.Column != "latency" || !top.Desc {
+		t.Fatalf("unexpected top: %#v", top)
+	}
+	if as := f.Branches[2].Ops[1].(plan.AsOp); as.Name != "errs" {
+		t.Fatalf("unexpected as: %#v", as)
+	}
+	if top := mustParseOp(t, "T | top 3 by n asc").(plan.TopOp); top.Desc {
+		t.Fatalf("expected ascending top")
+	}
+	for _, b := range []string{"T | fork", "T | fork take 1", "T | fork (take 1", "T | fork (take 1) | take 1", "T | fork (bogus)",
+		"T | top 3 n", "T | top x by n", "T | top 3 by n up", "T | as", "T | as 1x",
+		"T | fork x=(take 1) x=(take 2)", "T | fork (take 1 | as x) x=(take 2)", "T | fork fork2=(take 1) (take 2)"} {
+		if _, err := Parse(b); err == nil {
+			t.Fatalf("expected error for %s", b)
+		}
+	}
+}
+
+func mustParseOp(t *testing.T, q string) plan.Operator {
+	t.Helper()
+	ops, err := Parse(q)
+	if err != nil {
+		t.Fatalf("parse %s: %v", q, err)
+	}
+	return ops[len(ops)-1]
+}
+
+func TestParseLets(t *testing.T) {
+	lets, rest, err := ParseLets(`let A = materialize(T | where x > 1); let B = A | join kind=inner (A) on id == id; let C = datatable(a:int)[1]; B | take 1`)
+	if err != nil {
+		t.Fatalf("parse lets: %v", err)
+	}
+	if rest != "B | take 1" || len(lets) != 3 {
+		t.Fatalf("unexpected lets: %#v, rest %q", lets, rest)
+	}
+	if !lets[0].Materialize || lets[0].Source != "T" || len(lets[0].Ops) != 1 {
+		t.Fatalf("unexpected materialized let: %#v", lets[0])
+	}
+	if lets[1].Materialize || lets[1].Source != "A" {
+		t.Fatalf("unexpected let: %#v", lets[1])
+	}
+	if lets[2].Source != "" {
+		t.Fatalf("generator let should have no source: %#v", lets[2])
+	}
+	if lets, rest, err := ParseLets("let A = materialize(T); A"); err != nil || lets[0].Source != "T" || lets[0].Ops != nil || rest != "A" {
+		t.Fatalf("unexpected bare let: %#v %q %v", lets, rest, err)
+	}
+	if _, rest, err := ParseLets("T | where s == \"a;b\""); err != nil || rest != "T | where s == \"a;b\"" {
+		t.Fatalf("unexpected query without lets: %q %v", rest, err)
+	}
+	for _, b := range []string{"let A = T", "let A = T; B; C", "let A = A | take 1; A", "let A = B | take 1; let B = T; A",
+		"let A = T | join kind=inner (A) on x == x; A", "let A = materialize(T | take 1) | take 1; A", "let A = take 1; A", "let A = T | bogus; A"} {
+		if _, _, err := ParseLets(b); err == nil {
+			t.Fatalf("expected error for %s", b)
+		}
+	}
+}
+
+func TestParseSummarizeByExpressions(t *testing.T) {
+	s := mustParseOp(t, "T | summarize count() by host, bin(ts, 1h), h = hash(ip, 4), parse_url(u).Host").(plan.SummarizeOp)
+	if strings.Join(s.ByColumns, ",") != "host,ts,h,Column4" || len(s.Keys) != 4 {
+		t.Fatalf("unexpected summarize: %#v", s)
+	}
+	if _, ok := s.Keys[0].(plan.ColumnRef); !ok {
+		t.Fatalf("expected column key, got %#v", s.Keys[0])
+	}
+	if s := mustParseOp(t, "T | summarize count() by a, b").(plan.SummarizeOp); s.Keys != nil {
+		t.Fatalf("plain columns should not need keys: %#v", s)
+	}
+}
+
+func TestParseDynamicLiteral(t *testing.T) {
+	w := mustParseOp(t, `T | where geo_point_in_polygon(lon, lat, dynamic ({"type":"Polygon","coordinates":[[[0,0],[1,0],[0,1],[0,0]]]}))`).(plan.WhereOp)
+	call := w.Predicate.(plan.CallExpr)
+	lit, ok := call.Args[2].(plan.Literal)
+	if !ok || lit.Value.Type != model.TypeDynamic {
+		t.Fatalf("expected dynamic literal, got %#v", call.Args[2])
+	}
+	if bag := lit.Value.Dynamic().(map[string]any); bag["type"] != "Polygon" {
+		t.Fatalf("unexpected literal: %#v", bag)
+	}
+	if e := mustParseOp(t, "T | extend x = dynamic([1, 2])[0]").(plan.ExtendOp); e.Value.ExprType() != "index" {
+		t.Fatalf("unexpected extend: %#v", e)
+	}
+	for _, b := range []string{"T | extend x = dynamic({", "T | extend x = dynamic({bad})"} {
+		if _, err := Parse(b); err == nil {
+			t.Fatalf("expected error for %s", b)
+		}
+	}
+}
+
+func TestParseSummarizeAggregates(t *testing.T) {
+	s := mustParseOp(t, "T | summarize count(), users = dcount(user, 2), percentile(ms, 95), percentiles(ms, 50, 99.9), p = percentiles(ms, 5) by host").(plan.SummarizeOp)
+	var names []string
+	for _, a := range s.Aggregates {
+		names = append(names, a.Name)
+	}
+	if got := strings.Join(names, ","); got != "count,users,percentile_ms_95,percentile_ms_50,percentile_ms_99_9,p_5" {
+		t.Fatalf("unexpected aggregates: %s", got)
+	}
+	if s.Aggregates[3].Func != "percentile" || len(s.Aggregates[3].Args) != 2 || strings.Join(s.ByColumns, ",") != "host" {
+		t.Fatalf("unexpected summarize: %#v", s)
+	}
+	for _, b := range []string{"T | summarize by host", "T | summarize percentiles(ms)", "T | summarize percentile(ms, 1, 2)"} {
+		if _, err := Parse(b); err == nil {
+			t.Fatalf("expected error for %s", b)
+		}
+	}
+}
+
+func TestOperatorOffsets(t *testing.T) {
+	q := "T | where a > 1 |  project a, b | fork (take 1) (where x == \"|\")"
+	offsets := OperatorOffsets(q)
+	ops, err := Parse(q)
+	if err != nil || len(offsets) != len(ops) {
+		t.Fatalf("expected an offset per operator: %v %v", offsets, err)
+	}
+	for i, want := range []string{"where", "project", "fork"} {
+		if !strings.HasPrefix(q[offsets[i]:], want) {
+			t.Fatalf("offset %d points at %q", i, q[offsets[i]:])
+		}
+	}
+	if got := OperatorOffsets("range x from 1 to 3 step 1 | take 1"); len(got) != 2 || got[0] != 0 {
+		t.Fatalf("unexpected generator offsets: %v", got)
+	}
+}
@@ -0,0 +1,104 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+func parseSearch(seg string) (plan.Operator, error) {
+	body := strings.TrimSpace(seg[len("search"):])
+	caseSensitive := false
+	if strings.HasPrefix(strings.ToLower(body), "kind=") {
+		fields := strings.Fields(body)
+		switch strings.ToLower(fields[0]) {
+		case "kind=case_sensitive":
+			caseSensitive = true
+		case "kind=case_insensitive":
+		default:
+			return nil, fmt.Errorf("search kind must be case_sensitive or case_insensitive")
+		}
+		body = strings.TrimSpace(body[len(fields[0]):])
+	}
+	var tables []string
+	if strings.HasPrefix(strings.ToLower(body), "in") && strings.HasPrefix(strings.TrimSpace(body[2:]), "(") {
+		rest := strings.TrimSpace(body[2:])
+		closeIdx := strings.Index(rest, ")")
+		if closeIdx == -1 {
+			return nil, fmt.Errorf("search in requires closing parenthesis")
+		}
+		tables = splitCSVList(rest[1:closeIdx])
+		if len(tables) == 0 {
+			return nil, fmt.Errorf("search in requires table names")
+		}
+		body = strings.TrimSpace(rest[closeIdx+1:])
+	}
+	if body == "" {
+		return nil, fmt.Errorf("search requires a predicate")
+	}
+	toks, err := tokenize(body)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{toks: toks, search: true}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	}
+	pred, err := searchTerms(expr, caseSensitive)
+	if err != nil {
+		return nil, err
+	}
+	return plan.SearchOp{Tables: tables, Predicate: pred}, nil
+}
+
+// parseSearchColumn handles the column:"term" form, which restricts a term to
+// a single column.
+func (p *exprParser) parseSearchColumn(left plan.Expr) (plan.Expr, error) {
+	ref, ok := left.(plan.ColumnRef)
+	if !ok {
+		return nil, fmt.Errorf("search: expected column name before ':'")
+	}
+	p.next()
+	tok := p.next()
+	if tok.kind != tokString {
+		return nil, fmt.Errorf("search: expected string term at position %d", tok.pos)
+	}
+	lit, err := parseLiteralOrColumn(tok.text)
+	if err != nil {
+		return nil, err
+	}
+	return plan.SearchTerm{Column: ref.Name, Term: lit.(plan.Literal).Value.String()}, nil
+}
+
+func searchTerms(expr plan.Expr, caseSensitive bool) (plan.Expr, error) {
+	switch e := expr.(type) {
+	case plan.Literal:
+		if e.Value.Type != model.TypeString {
+			return nil, fmt.Errorf("search: terms must be strings")
+		}
+		return plan.SearchTerm{Term: e.Value.String(), CaseSensitive: caseSensitive}, nil
+	case plan.SearchTerm:
+		e.CaseSensitive = caseSensitive
+		return e, nil
+	case plan.LogicalExpr:
+		left, err := searchTerms(e.Left, caseSensitive)
+		if err != nil {
+			return nil, err
+		}
+		right, err := searchTerms(e.Right, caseSensitive)
+		if err != nil {
+			return nil, err
+		}
+		return plan.LogicalExpr{Left: left, Op: e.Op, Right: right}, nil
+	case plan.ColumnRef:
+		return nil, fmt.Errorf("search: unexpected column %s", e.Name)
+	default:
+		return expr, nil
+	}
+}
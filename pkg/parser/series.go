@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+func parseMakeSeries(seg string) (plan.Operator, error) {
+	body := strings.TrimSpace(seg[len("make-series"):])
+	onIdx := findKeyword(body, "on")
+	if onIdx == -1 {
+		return nil, fmt.Errorf("make-series requires on <column>")
+	}
+	var op plan.MakeSeriesOp
+	for _, item := range splitTopLevel(body[:onIdx], ',') {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		agg, err := parseSeriesAggregate(item)
+		if err != nil {
+			return nil, fmt.Errorf("make-series: %w", err)
+		}
+		op.Aggregates = append(op.Aggregates, agg)
+	}
+	if len(op.Aggregates) == 0 {
+		return nil, fmt.Errorf("make-series requires an aggregation")
+	}
+
+	axis := body[onIdx+len("on"):]
+	if i := findKeyword(axis, "by"); i != -1 {
+		op.ByColumns = splitCSVList(axis[i+len("by"):])
+		if len(op.ByColumns) == 0 {
+			return nil, fmt.Errorf("make-series: by requires columns")
+		}
+		axis = axis[:i]
+	}
+	clauses := map[string]*plan.Expr{"step": &op.Step, "to": &op.To, "from": &op.From}
+	for _, kw := range []string{"step", "to", "from"} {
+		i := findKeyword(axis, kw)
+		if i == -1 {
+			continue
+		}
+		raw := strings.TrimSpace(axis[i+len(kw):])
+		if raw == "" {
+			return nil, fmt.Errorf("make-series: %s requires a value", kw)
+		}
+		expr, err := parseExpression(raw)
+		if err != nil {
+			return nil, fmt.Errorf("make-series %s: %w", kw, err)
+		}
+		*clauses[kw] = expr
+		axis = axis[:i]
+	}
+	if op.Step == nil {
+		return nil, fmt.Errorf("make-series requires step")
+	}
+	op.On = strings.TrimSpace(axis)
+	if op.On == "" || strings.ContainsAny(op.On, " \t(") {
+		return nil, fmt.Errorf("make-series: invalid axis column %q", op.On)
+	}
+	return op, nil
+}
+
+// parseSeriesAggregate parses "[name =] func(args) [default = value]".
+func parseSeriesAggregate(item string) (plan.SeriesAggregate, error) {
+	var agg plan.SeriesAggregate
+	if i := findKeyword(item, "default"); i != -1 {
+		raw := strings.TrimSpace(item[i+len("default"):])
+		raw = strings.TrimSpace(strings.TrimPrefix(raw, "="))
+		def, err := parseExpression(raw)
+		if err != nil {
+			return agg, fmt.Errorf("default: %w", err)
+		}
+		agg.Default = def
+		item = strings.TrimSpace(item[:i])
+	}
+	a, _, err := parseAggregate(item)
+	if err != nil {
+		return agg, err
+	}
+	agg.Name, agg.Func, agg.Args = a.Name, a.Func, a.Args
+	if agg.Default == nil {
+		agg.Default = plan.Literal{Value: model.NewInt(0)}
+	}
+	return agg, nil
+}
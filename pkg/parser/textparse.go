@@ -0,0 +1,78 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+
+	"kqlfile/pkg/model"
+	"kqlfile/pkg/plan"
+)
+
+func parseParse(seg string) (plan.Operator, error) {
+	body := strings.TrimSpace(seg[len("parse"):])
+	kind := "simple"
+	if strings.HasPrefix(strings.ToLower(body), "kind=") {
+		fields := strings.Fields(body)
+		kind = strings.ToLower(strings.TrimPrefix(strings.ToLower(fields[0]), "kind="))
+		body = strings.TrimSpace(body[len(fields[0]):])
+	}
+	switch kind {
+	case "simple", "regex", "relaxed":
+	default:
+		return nil, fmt.Errorf("parse kind must be simple, regex or relaxed")
+	}
+	withIdx := findKeyword(body, "with")
+	if withIdx == -1 {
+		return nil, fmt.Errorf("parse requires with pattern")
+	}
+	src, err := parseExpression(strings.TrimSpace(body[:withIdx]))
+	if err != nil {
+		return nil, fmt.Errorf("parse source: %w", err)
+	}
+	pattern, err := parsePattern(body[withIdx+len("with"):])
+	if err != nil {
+		return nil, err
+	}
+	return plan.ParseOp{Source: src, Kind: kind, Pattern: pattern}, nil
+}
+
+func parsePattern(raw string) ([]plan.ParsePart, error) {
+	toks, err := tokenize(raw)
+	if err != nil {
+		return nil, err
+	}
+	var parts []plan.ParsePart
+	hasColumn := false
+	for i := 0; toks[i].kind != tokEOF; i++ {
+		tok := toks[i]
+		switch {
+		case tok.kind == tokString:
+			lit, err := parseLiteralOrColumn(tok.text)
+			if err != nil {
+				return nil, err
+			}
+			parts = append(parts, plan.ParsePart{Literal: lit.(plan.Literal).Value.String()})
+		case tok.kind == tokSymbol && tok.text == "*":
+			parts = append(parts, plan.ParsePart{Wildcard: true})
+		case tok.kind == tokIdent:
+			part := plan.ParsePart{Column: tok.text, ColType: model.TypeString}
+			if toks[i+1].kind == tokSymbol && toks[i+1].text == ":" {
+				typeTok := toks[i+2]
+				t, ok := model.ParseType(typeTok.text)
+				if typeTok.kind != tokIdent || !ok {
+					return nil, fmt.Errorf("parse: invalid type %q at position %d", typeTok.text, typeTok.pos)
+				}
+				part.ColType = t
+				i += 2
+			}
+			parts = append(parts, part)
+			hasColumn = true
+		default:
+			return nil, fmt.Errorf("parse: unexpected %q in pattern", tok.text)
+		}
+	}
+	if !hasColumn {
+		return nil, fmt.Errorf("parse pattern requires at least one column")
+	}
+	return parts, nil
+}
@@ -0,0 +1,273 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"kqlfile/pkg/model"
+)
+
+// Format renders op in query syntax, for explain output. The text reads
+// like the query but is not guaranteed to parse back.
+func Format(op Operator) string {
+	switch o := op.(type) {
+	case WhereOp:
+		return "where " + FormatExpr(o.Predicate)
+	case ProjectOp:
+		return "project " + strings.Join(o.Columns, ", ")
+	case ExtendOp:
+		return "extend " + assignment(o)
+	case TakeOp:
+		return fmt.Sprintf("take %d", o.Count)
+	case OrderByOp:
+		return "order by " + o.Column + direction(o.Desc)
+	case SummarizeOp:
+		aggs := make([]string, len(o.Aggregates))
+		for i, a := range o.Aggregates {
+			aggs[i] = a.Name + " = " + call(a.Func, a.Args)
+		}
+		s := "summarize " + strings.Join(aggs, ", ")
+		if len(o.ByColumns) > 0 {
+			keys := make([]string, len(o.ByColumns))
+			for i, name := range o.ByColumns {
+				keys[i] = name
+				if o.Keys != nil {
+					keys[i] = assignment(ExtendOp{Name: name, Value: o.Keys[i]})
+				}
+			}
+			s += " by " + strings.Join(keys, ", ")
+		}
+		return s
+	case JoinOp:
+		right := o.Right
+		if len(o.RightOps) > 0 {
+			right += " | " + FormatOps(o.RightOps)
+		}
+		return fmt.Sprintf("join kind=%s (%s) on %s == %s", o.Kind, right, o.LeftKey, o.RightKey)
+	case MvExpandOp:
+		return "mv-expand " + expandColumns(o.Columns, o.IndexName, o.Limit)
+	case MvApplyOp:
+		return fmt.Sprintf("mv-apply %s on (%s)", expandColumns(o.Columns, o.IndexName, o.Limit), FormatOps(o.Subquery))
+	case ParseOp:
+		var pattern strings.Builder
+		for _, p := range o.Pattern {
+			switch {
+			case p.Literal != "":
+				pattern.WriteString(strconv.Quote(p.Literal))
+			case p.Wildcard:
+				pattern.WriteString("*")
+			default:
+				pattern.WriteString(p.Column)
+				if p.ColType != "" {
+					pattern.WriteString(":" + string(p.ColType))
+				}
+			}
+			pattern.WriteString(" ")
+		}
+		kind := ""
+		if o.Kind != "" {
+			kind = " kind=" + o.Kind
+		}
+		return fmt.Sprintf("parse%s %s with %s", kind, FormatExpr(o.Source), strings.TrimSpace(pattern.String()))
+	case SearchOp:
+		in := ""
+		if len(o.Tables) > 0 {
+			in = " in (" + strings.Join(o.Tables, ", ") + ")"
+		}
+		return "search" + in + " " + FormatExpr(o.Predicate)
+	case SerializeOp:
+		cols := make([]string, len(o.Columns))
+		for i, c := range o.Columns {
+			cols[i] = assignment(c)
+		}
+		return strings.TrimSpace("serialize " + strings.Join(cols, ", "))
+	case MakeSeriesOp:
+		aggs := make([]string, len(o.Aggregates))
+		for i, a := range o.Aggregates {
+			aggs[i] = a.Name + " = " + call(a.Func, a.Args)
+			if a.Default != nil {
+				aggs[i] += " default=" + FormatExpr(a.Default)
+			}
+		}
+		s := "make-series " + strings.Join(aggs, ", ") + " on " + o.On
+		for _, b := range []struct {
+			kw string
+			e  Expr
+		}{{"from", o.From}, {"to", o.To}, {"step", o.Step}} {
+			if b.e != nil {
+				s += " " + b.kw + " " + FormatExpr(b.e)
+			}
+		}
+		if len(o.ByColumns) > 0 {
+			s += " by " + strings.Join(o.ByColumns, ", ")
+		}
+		return s
+	case RangeOp:
+		return fmt.Sprintf("range %s from %s to %s step %s", o.Column, FormatExpr(o.From), FormatExpr(o.To), FormatExpr(o.Step))
+	case DatatableOp:
+		cols := make([]string, len(o.Columns))
+		for i, c := range o.Columns {
+			cols[i] = c.Name + ":" + string(c.Type)
+		}
+		vals := make([]string, len(o.Values))
+		for i, v := range o.Values {
+			vals[i] = FormatExpr(v)
+		}
+		return fmt.Sprintf("datatable(%s) [%s]", strings.Join(cols, ", "), strings.Join(vals, ", "))
+	case PrintOp:
+		cols := make([]string, len(o.Columns))
+		for i, c := range o.Columns {
+			cols[i] = assignment(c)
+		}
+		return "print " + strings.Join(cols, ", ")
+	case GetSchemaOp:
+		return "getschema"
+	case EvaluateOp:
+		return "evaluate " + call(o.Plugin, o.Args)
+	case SampleOp:
+		return fmt.Sprintf("sample %d", o.Count)
+	case SampleDistinctOp:
+		return fmt.Sprintf("sample-distinct %d of %s", o.Count, o.Column)
+	case TopOp:
+		return fmt.Sprintf("top %d by %s%s", o.Count, o.Column, direction(o.Desc))
+	case AsOp:
+		return "as " + o.Name
+	case ForkOp:
+		branches := make([]string, len(o.Branches))
+		for i, b := range o.Branches {
+			branches[i] = "(" + FormatOps(b.Ops) + ")"
+		}
+		return "fork " + strings.Join(branches, " ")
+	default:
+		return op.Type()
+	}
+}
+
+// FormatOps renders a pipeline of operators joined by pipes.
+func FormatOps(ops []Operator) string {
+	parts := make([]string, len(ops))
+	for i, op := range ops {
+		parts[i] = Format(op)
+	}
+	return strings.Join(parts, " | ")
+}
+
+// FormatExpr renders e in query syntax.
+func FormatExpr(e Expr) string {
+	switch x := e.(type) {
+	case nil:
+		return ""
+	case ColumnRef:
+		return x.Name
+	case Literal:
+		return formatLiteral(x.Value)
+	case CompareExpr:
+		return operand(x.Left) + " " + x.Op + " " + operand(x.Right)
+	case LogicalExpr:
+		return logicalOperand(x.Left, x.Op) + " " + x.Op + " " + logicalOperand(x.Right, x.Op)
+	case BinaryExpr:
+		return operand(x.Left) + " " + x.Op + " " + operand(x.Right)
+	case CallExpr:
+		return call(x.Name, x.Args)
+	case MemberExpr:
+		return operand(x.Target) + "." + x.Name
+	case IndexExpr:
+		return operand(x.Target) + "[" + FormatExpr(x.Index) + "]"
+	case SearchTerm:
+		op := "has"
+		if x.CaseSensitive {
+			op = "has_cs"
+		}
+		if x.Column == "" {
+			return strconv.Quote(x.Term)
+		}
+		return x.Column + " " + op + " " + strconv.Quote(x.Term)
+	default:
+		return e.ExprType()
+	}
+}
+
+// operand renders e parenthesized when it is itself a binary expression.
+func operand(e Expr) string {
+	switch e.(type) {
+	case CompareExpr, LogicalExpr, BinaryExpr:
+		return "(" + FormatExpr(e) + ")"
+	}
+	return FormatExpr(e)
+}
+
+// logicalOperand leaves comparisons and chains of the same logical
+// operator unparenthesized.
+func logicalOperand(e Expr, op string) string {
+	switch x := e.(type) {
+	case CompareExpr:
+		return FormatExpr(e)
+	case LogicalExpr:
+		if x.Op == op {
+			return FormatExpr(e)
+		}
+	}
+	return operand(e)
+}
+
+func formatLiteral(v model.Value) string {
+	switch v.Type {
+	case "":
+		return "null"
+	case model.TypeString:
+		return strconv.Quote(v.String())
+	case model.TypeDateTime:
+		return "datetime(" + v.DateTime().Format(time.RFC3339Nano) + ")"
+	case model.TypeTimespan:
+		return "timespan(" + v.String() + ")"
+	case model.TypeDynamic:
+		b, err := json.Marshal(v.Dynamic())
+		if err != nil {
+			return "dynamic(" + v.String() + ")"
+		}
+		return "dynamic(" + string(b) + ")"
+	default:
+		return v.String()
+	}
+}
+
+func call(name string, args []Expr) string {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		parts[i] = FormatExpr(a)
+	}
+	return name + "(" + strings.Join(parts, ", ") + ")"
+}
+
+func assignment(o ExtendOp) string {
+	return o.Name + " = " + FormatExpr(o.Value)
+}
+
+func direction(desc bool) string {
+	if desc {
+		return " desc"
+	}
+	return " asc"
+}
+
+func expandColumns(cols []ExpandColumn, indexName string, limit int) string {
+	s := ""
+	if indexName != "" {
+		s = "with_itemindex=" + indexName + " "
+	}
+	parts := make([]string, len(cols))
+	for i, c := range cols {
+		parts[i] = c.Name + " = " + FormatExpr(c.Value)
+		if c.ToType != "" {
+			parts[i] += " to typeof(" + string(c.ToType) + ")"
+		}
+	}
+	s += strings.Join(parts, ", ")
+	if limit > 0 {
+		s += fmt.Sprintf(" limit %d", limit)
+	}
+	return s
+}
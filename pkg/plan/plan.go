@@ -1,6 +1,10 @@
 package plan
 
-import "kqlfile/pkg/model"
+import (
+	"fmt"
+
+	"kqlfile/pkg/model"
+)
 
 type Operator interface {
 	Type() string
@@ -38,6 +42,43 @@ type LogicalExpr struct {
 
 func (l LogicalExpr) ExprType() string { return "logical" }
 
+type BinaryExpr struct {
+	Left  Expr
+	Op    string
+	Right Expr
+}
+
+func (b BinaryExpr) ExprType() string { return "binary" }
+
+type CallExpr struct {
+	Name string
+	Args []Expr
+}
+
+func (c CallExpr) ExprType() string { return "call" }
+
+type MemberExpr struct {
+	Target Expr
+	Name   string
+}
+
+func (m MemberExpr) ExprType() string { return "member" }
+
+type IndexExpr struct {
+	Target Expr
+	Index  Expr
+}
+
+func (i IndexExpr) ExprType() string { return "index" }
+
+type SearchTerm struct {
+	Column        string
+	Term          string
+	CaseSensitive bool
+}
+
+func (s SearchTerm) ExprType() string { return "search" }
+
 type WhereOp struct {
 	Predicate Expr
 }
@@ -70,17 +111,199 @@ type OrderByOp struct {
 
 func (o OrderByOp) Type() string { return "orderby" }
 
+// Aggregate is one aggregation of summarize, producing the column Name.
+type Aggregate struct {
+	Name string
+	Func string
+	Args []Expr
+}
+
+// SummarizeOp groups by ByColumns. When Keys is set, Keys[i] computes the
+// value of ByColumns[i] instead of reading a column of that name. Without
+// Aggregates it counts the rows of each group into a count column.
 type SummarizeOp struct {
-	ByColumns []string
+	Aggregates []Aggregate
+	ByColumns  []string
+	Keys       []Expr
 }
 
 func (o SummarizeOp) Type() string { return "summarize" }
 
+// JoinOp joins with the table or file Right. RightOps, when set, run over
+// the right input before it is hashed; the optimizer moves filters and
+// projections there.
 type JoinOp struct {
 	Kind     string
 	Right    string
 	LeftKey  string
 	RightKey string
+	RightOps []Operator
 }
 
 func (o JoinOp) Type() string { return "join" }
+
+type ExpandColumn struct {
+	Name   string
+	Value  Expr
+	ToType model.Type
+}
+
+type MvExpandOp struct {
+	Columns   []ExpandColumn
+	IndexName string
+	Limit     int
+}
+
+func (o MvExpandOp) Type() string { return "mv-expand" }
+
+type MvApplyOp struct {
+	Columns   []ExpandColumn
+	IndexName string
+	Limit     int
+	Subquery  []Operator
+}
+
+func (o MvApplyOp) Type() string { return "mv-apply" }
+
+type ParsePart struct {
+	Literal  string
+	Column   string
+	ColType  model.Type
+	Wildcard bool
+}
+
+type ParseOp struct {
+	Source  Expr
+	Kind    string
+	Pattern []ParsePart
+}
+
+func (o ParseOp) Type() string { return "parse" }
+
+type SearchOp struct {
+	Tables    []string
+	Predicate Expr
+}
+
+func (o SearchOp) Type() string { return "search" }
+
+type SerializeOp struct {
+	Columns []ExtendOp
+}
+
+func (o SerializeOp) Type() string { return "serialize" }
+
+type SeriesAggregate struct {
+	Name    string
+	Func    string
+	Args    []Expr
+	Default Expr
+}
+
+type MakeSeriesOp struct {
+	Aggregates []SeriesAggregate
+	On         string
+	From       Expr
+	To         Expr
+	Step       Expr
+	ByColumns  []string
+}
+
+func (o MakeSeriesOp) Type() string { return "make-series" }
+
+type RangeOp struct {
+	Column string
+	From   Expr
+	To     Expr
+	Step   Expr
+}
+
+func (o RangeOp) Type() string { return "range" }
+
+type DatatableOp struct {
+	Columns []model.Column
+	Values  []Expr
+}
+
+func (o DatatableOp) Type() string { return "datatable" }
+
+type PrintOp struct {
+	Columns []ExtendOp
+}
+
+func (o PrintOp) Type() string { return "print" }
+
+type GetSchemaOp struct{}
+
+func (o GetSchemaOp) Type() string { return "getschema" }
+
+type EvaluateOp struct {
+	Plugin string
+	Args   []Expr
+}
+
+func (o EvaluateOp) Type() string { return "evaluate" }
+
+type SampleOp struct {
+	Count int
+	Seed  int64
+}
+
+func (o SampleOp) Type() string { return "sample" }
+
+type SampleDistinctOp struct {
+	Count  int
+	Column string
+	Seed   int64
+}
+
+func (o SampleDistinctOp) Type() string { return "sample-distinct" }
+
+type TopOp struct {
+	Count  int
+	Column string
+	Desc   bool
+}
+
+func (o TopOp) Type() string { return "top" }
+
+type AsOp struct {
+	Name string
+}
+
+func (o AsOp) Type() string { return "as" }
+
+type ForkBranch struct {
+	Name string
+	Ops  []Operator
+}
+
+// ResultName names the result set of the i-th branch, counting from 0: the
+// branch name, else the name of a trailing as, else fork<i+1>.
+func (b ForkBranch) ResultName(i int) string {
+	if b.Name != "" {
+		return b.Name
+	}
+	if len(b.Ops) > 0 {
+		if as, ok := b.Ops[len(b.Ops)-1].(AsOp); ok {
+			return as.Name
+		}
+	}
+	return fmt.Sprintf("fork%d", i+1)
+}
+
+type ForkOp struct {
+	Branches []ForkBranch
+}
+
+func (o ForkOp) Type() string { return "fork" }
+
+// LetStmt binds Name to a subquery over the table Source, or over nothing
+// when the subquery starts with a generator. Materialized bindings are
+// evaluated once and shared by every reference.
+type LetStmt struct {
+	Name        string
+	Source      string
+	Ops         []Operator
+	Materialize bool
+}
@@ -1,6 +1,10 @@
 package plan
 
-import "testing"
+import (
+	"testing"
+
+	"kqlfile/pkg/model"
+)
 
 func TestPlanTypes(t *testing.T) {
 	if (ColumnRef{Name: "x"}).ExprType() != "column" {
@@ -38,3 +42,62 @@ func TestPlanTypes(t *testing.T) {
 		t.Fatalf("join type")
 	}
 }
+
+func TestDynamicExprTypes(t *testing.T) {
+	if (CallExpr{}).ExprType() != "call" {
+		t.Fatalf("call expr")
+	}
+	if (MemberExpr{}).ExprType() != "member" {
+		t.Fatalf("member expr")
+	}
+	if (IndexExpr{}).ExprType() != "index" {
+		t.Fatalf("index expr")
+	}
+}
+
+func TestExpandOpTypes(t *testing.T) {
+	if (MvExpandOp{}).Type() != "mv-expand" {
+		t.Fatalf("mv-expand type")
+	}
+	if (MvApplyOp{}).Type() != "mv-apply" {
+		t.Fatalf("mv-apply type")
+	}
+}
+
+func TestParseOpType(t *testing.T) {
+	if (ParseOp{}).Type() != "parse" {
+		t.Fatalf("parse type")
+	}
+}
+
+func TestSearchTypes(t *testing.T) {
+	if (SearchTerm{}).ExprType() != "search" {
+		t.Fatalf("search term expr")
+	}
+	if (SearchOp{}).Type() != "search" {
+		t.Fatalf("search type")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	age := ColumnRef{Name: "age"}
+	cases := []struct {
+		op   Operator
+		want string
+	}{
+		{WhereOp{Predicate: LogicalExpr{
+			Left:  LogicalExpr{Left: CompareExpr{Left: age, Op: ">", Right: Literal{Value: model.NewInt(3)}}, Op: "and", Right: CompareExpr{Left: ColumnRef{Name: "city"}, Op: "==", Right: Literal{Value: model.NewString("Oslo")}}},
+			Op:    "or",
+			Right: CompareExpr{Left: BinaryExpr{Left: age, Op: "*", Right: Literal{Value: model.NewInt(2)}}, Op: "<", Right: Literal{}},
+		}}, `where (age > 3 and city == "Oslo") or (age * 2) < null`},
+		{SummarizeOp{Aggregates: []Aggregate{{Name: "n", Func: "count"}}, ByColumns: []string{"city"}}, "summarize n = count() by city"},
+		{JoinOp{Kind: "inner", Right: "cities.csv", LeftKey: "city", RightKey: "city", RightOps: []Operator{ProjectOp{Columns: []string{"city"}}}}, "join kind=inner (cities.csv | project city) on city == city"},
+		{TopOp{Count: 3, Column: "age", Desc: true}, "top 3 by age desc"},
+		{ForkOp{Branches: []ForkBranch{{Ops: []Operator{TakeOp{Count: 1}}}, {Ops: []Operator{ProjectOp{Columns: []string{"a", "b"}}}}}}, "fork (take 1) (project a, b)"},
+	}
+	for _, c := range cases {
+		if got := Format(c.op); got != c.want {
+			t.Fatalf("got %q, want %q", got, c.want)
+		}
+	}
+}